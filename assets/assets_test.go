@@ -0,0 +1,123 @@
+package assets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A stand-in for a font service: a stylesheet referring to font files by absolute and
+// relative URLs.
+func fontServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/css2", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.UserAgent(), "Chrome") {
+			t.Errorf("unexpected User-Agent %q", r.UserAgent())
+		}
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Write([]byte(`@font-face { src: url(` + srv.URL + `/s/roboto/v1/regular.woff2) format('woff2'); }
+@font-face { src: url("fonts/bold.woff2") format('woff2'); }
+.icon { background: url(data:image/png;base64,AAAA); }`))
+	})
+	mux.HandleFunc("/s/roboto/v1/regular.woff2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("regular"))
+	})
+	mux.HandleFunc("/fonts/bold.woff2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("bold"))
+	})
+	mux.HandleFunc("/lib.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("console.log('lib')"))
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVendor(t *testing.T) {
+	srv := fontServer(t)
+	root := t.TempDir()
+	views := filepath.Join(root, "views")
+	os.MkdirAll(views, 0o755)
+
+	layout := `<head>
+    <link rel="preconnect" href="` + srv.URL + `">
+    <link href="` + srv.URL + `/css2?family=Roboto&amp;display=swap" rel="stylesheet">
+    <script src="` + srv.URL + `/lib.js" integrity="sha384-abc" crossorigin="anonymous"></script>
+    <a href="https://example.com/">a link, not an asset</a>
+</head>`
+	if err := os.WriteFile(filepath.Join(views, "base.jet"), []byte(layout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{Assets: []Asset{{URL: srv.URL + "/lib.js", Path: "lib/lib.js"}}}
+	added, changed, err := Rewrite(views, config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || len(added) != 1 || !strings.HasSuffix(added[0].Path, ".css") {
+		t.Fatalf("Rewrite() added %v, changed %v", added, changed)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(views, "base.jet"))
+	got := string(data)
+	for _, want := range []string{
+		`<link href="{{ asset("vendor/` + added[0].Path + `") }}" rel="stylesheet">`,
+		`<script src="{{ asset("vendor/lib/lib.js") }}"></script>`,
+		`<a href="https://example.com/">`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rewritten view lacks %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "preconnect") {
+		t.Errorf("rewritten view still preconnects:\n%s", got)
+	}
+
+	// the view was rewritten, so a second run finds nothing
+	if added, changed, _ := Rewrite(views, config, true); len(added)+len(changed) != 0 {
+		t.Errorf("second Rewrite() added %v, changed %v", added, changed)
+	}
+
+	public := filepath.Join(root, "public")
+	v := &Vendor{PublicDir: public}
+	result, err := v.Fetch(context.Background(), Asset{URL: srv.URL + "/css2?family=Roboto&display=swap", Path: "fonts/roboto.css"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 3 {
+		t.Errorf("Fetch() vendored %d files, want 3", result.Files)
+	}
+
+	css, _ := os.ReadFile(filepath.Join(public, "vendor", "fonts", "roboto.css"))
+	if strings.Contains(string(css), srv.URL) || !strings.Contains(string(css), `url("roboto/`) || !strings.Contains(string(css), "url(data:") {
+		t.Errorf("stylesheet references were not rewritten:\n%s", css)
+	}
+	fonts, _ := filepath.Glob(filepath.Join(public, "vendor", "fonts", "roboto", "*.woff2"))
+	if len(fonts) != 2 {
+		t.Errorf("vendored fonts = %v", fonts)
+	}
+
+	srv.Close()
+	if result, err := v.Fetch(context.Background(), Asset{URL: srv.URL + "/css2", Path: "fonts/roboto.css"}); err != nil || !result.Kept {
+		t.Errorf("Fetch() of a vendored asset = %+v, %v, want it kept without a request", result, err)
+	}
+}
+
+func TestPathFor(t *testing.T) {
+	tests := []struct{ url, tag, rel, want string }{
+		{"https://cdn.example.com/lib/1.0/lib.min.js", "script", "", "cdn.example.com/lib/1.0/lib.min.js"},
+		{"https://cdn.example.com/lib", "script", "", "cdn.example.com/lib.js"},
+		{"https://fonts.googleapis.com/css2?family=Roboto", "link", "stylesheet", "fonts.googleapis.com/css2-"},
+	}
+	for _, tt := range tests {
+		got, err := PathFor(tt.url, tt.tag, tt.rel)
+		if err != nil || !strings.HasPrefix(got, tt.want) {
+			t.Errorf("PathFor(%q) = %q, %v, want %q", tt.url, got, err, tt.want)
+		}
+	}
+}
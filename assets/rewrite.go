@@ -0,0 +1,162 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// The tags that load assets.
+	assetTag = regexp.MustCompile(`(?is)<(link|script|img)\b[^>]*>`)
+	// The attribute of a tag pointing to another host.
+	externalAttr = regexp.MustCompile(`(?i)\b(href|src)\s*=\s*"((?:https?:)?//[^"]+)"`)
+	relAttr      = regexp.MustCompile(`(?i)\brel\s*=\s*"([^"]*)"`)
+	// Attributes that no longer apply to a vendored copy.
+	staleAttr = regexp.MustCompile(`(?i)\s+(integrity\s*=\s*"[^"]*"|crossorigin(\s*=\s*"[^"]*")?)`)
+)
+
+// A marker left where a tag is removed, so the line can be removed when nothing else is on it.
+const removed = "\x00removed\x00"
+
+// Rewrite changes the views (*.jet) loading assets from other hosts to load the vendored
+// copies through the asset helper, e.g.,
+//
+//	<link href="https://cdn.example.com/lib.css" rel="stylesheet">
+//
+// becomes
+//
+//	<link href="{{ asset("vendor/cdn.example.com/lib.css") }}" rel="stylesheet">
+//
+// Links that only open connections to other hosts (preconnect, dns-prefetch) are removed,
+// and integrity and crossorigin attributes are dropped from rewritten tags. Assets already
+// listed in the config keep their path. With dryRun set, no view is written. It returns the
+// assets the views loaded that the config does not list, and the views changed.
+func Rewrite(viewsDir string, config *Config, dryRun bool) ([]Asset, []string, error) {
+	known := make(map[string]Asset)
+	for _, a := range config.Assets {
+		known[a.URL] = a
+	}
+
+	var added []Asset
+	var changed []string
+
+	err := filepath.WalkDir(viewsDir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(file, ".jet") {
+			return nil
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		content := assetTag.ReplaceAllStringFunc(string(data), func(tag string) string {
+			m := externalAttr.FindStringSubmatchIndex(tag)
+			if m == nil {
+				return tag
+			}
+			name := strings.ToLower(assetTag.FindStringSubmatch(tag)[1])
+
+			rel := ""
+			if r := relAttr.FindStringSubmatch(tag); r != nil {
+				rel = strings.ToLower(r[1])
+			}
+			if name == "link" && (strings.Contains(rel, "preconnect") || strings.Contains(rel, "dns-prefetch")) {
+				return removed
+			}
+
+			rawURL := html.UnescapeString(tag[m[4]:m[5]])
+			if strings.HasPrefix(rawURL, "//") {
+				rawURL = "https:" + rawURL
+			}
+
+			a, ok := known[rawURL]
+			if !ok {
+				p, err := PathFor(rawURL, name, rel)
+				if err != nil {
+					return tag
+				}
+				a = Asset{URL: rawURL, Path: p}
+				known[rawURL] = a
+				added = append(added, a)
+			}
+
+			tag = tag[:m[4]] + fmt.Sprintf(`{{ asset(%q) }}`, Dir+"/"+a.Path) + tag[m[5]:]
+			return staleAttr.ReplaceAllString(tag, "")
+		})
+
+		if content == string(data) {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(viewsDir, file)
+		changed = append(changed, filepath.ToSlash(rel))
+		if dryRun {
+			return nil
+		}
+		return os.WriteFile(file, []byte(removeMarkers(content)), 0o644)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("assets: %w", err)
+	}
+
+	return added, changed, nil
+}
+
+// Remove the lines left holding nothing but removed tags, then the markers.
+func removeMarkers(content string) string {
+	lines := strings.SplitAfter(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.Contains(line, removed) && strings.TrimSpace(strings.ReplaceAll(line, removed, "")) == "" {
+			continue
+		}
+		kept = append(kept, strings.ReplaceAll(line, removed, ""))
+	}
+	return strings.Join(kept, "")
+}
+
+// PathFor returns where the copy of an asset is kept: its host and path, with a hash of its
+// query when it has one (fonts.googleapis.com/css2-1a2b3c4d.css), and an extension guessed
+// from the tag loading it when the path has none.
+func PathFor(rawURL, tag, rel string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("assets: invalid URL %q", rawURL)
+	}
+
+	p := strings.TrimSuffix(path.Clean("/"+u.Path), "/")
+	if p == "" {
+		p = "/index"
+	}
+
+	ext := path.Ext(p)
+	p = strings.TrimSuffix(p, ext)
+	if ext == "" {
+		switch {
+		case tag == "script":
+			ext = ".js"
+		case tag == "link" && strings.Contains(rel, "stylesheet"):
+			ext = ".css"
+		}
+	}
+
+	if u.RawQuery != "" {
+		sum := sha256.Sum256([]byte(u.RawQuery))
+		p += "-" + hex.EncodeToString(sum[:])[:8]
+	}
+
+	return u.Host + p + ext, nil
+}
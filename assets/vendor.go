@@ -0,0 +1,255 @@
+// Package assets vendors third-party web assets (fonts, stylesheets, scripts, ...) into the
+// public directory, so pages are served without requests to other hosts: visitors' IPs are
+// not leaked to CDNs, and the application works offline and in air-gapped deployments.
+//
+// The assets to vendor are listed in config/assets.yml. The assets vendor command downloads
+// them into public/vendor, along with the files a stylesheet refers to (e.g., the font files
+// of a web font), and rewrites any view still loading an asset from another host to load
+// the vendored copy through the asset helper instead. See Vendor and Rewrite.
+package assets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dir is the directory of the vendored assets, relative to the public directory.
+const Dir = "vendor"
+
+// UserAgent is sent when downloading. Font services pick the font format by the user agent,
+// and serve WOFF2 to current browsers.
+const UserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+
+// Asset is a third-party asset and where its copy is kept, relative to public/vendor.
+type Asset struct {
+	URL  string `yaml:"URL"`
+	Path string `yaml:"Path"`
+}
+
+// Config is config/assets.yml.
+type Config struct {
+	Assets []Asset `yaml:"Assets"`
+}
+
+// LoadConfig reads the assets to vendor. A file that does not exist lists none.
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("assets: %s: %w", file, err)
+	}
+	for _, a := range c.Assets {
+		if _, err := localPath(a.Path); err != nil || a.URL == "" {
+			return nil, fmt.Errorf("assets: %s: invalid asset %q -> %q", file, a.URL, a.Path)
+		}
+	}
+	return &c, nil
+}
+
+// AppendConfig adds assets to the end of the config file, keeping its comments.
+func AppendConfig(file string, assets []Asset) error {
+	if len(assets) == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.Write(data)
+	if len(data) == 0 {
+		b.WriteString("Assets:\n")
+	} else if !bytes.HasSuffix(data, []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	for _, a := range assets {
+		fmt.Fprintf(&b, "  - URL: %q\n    Path: %q\n", a.URL, a.Path)
+	}
+
+	return os.WriteFile(file, b.Bytes(), 0o644)
+}
+
+// Vendor downloads assets into the vendor directory of a public directory. Assets already
+// downloaded are kept unless Force is set, so building again needs no network.
+type Vendor struct {
+	PublicDir string
+	Client    *http.Client
+	Force     bool
+}
+
+// Result reports what vendoring an asset did.
+type Result struct {
+	Asset Asset
+	Files int
+	Kept  bool
+}
+
+// Fetch downloads an asset. A stylesheet has the files it refers to with url() downloaded
+// next to it (in a directory named after it) and its references rewritten to the copies.
+func (v *Vendor) Fetch(ctx context.Context, a Asset) (Result, error) {
+	dest, err := localPath(a.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	dest = filepath.Join(v.PublicDir, Dir, dest)
+
+	if !v.Force {
+		if _, err := os.Stat(dest); err == nil {
+			return Result{Asset: a, Kept: true}, nil
+		}
+	}
+
+	body, contentType, err := v.get(ctx, a.URL)
+	if err != nil {
+		return Result{}, err
+	}
+
+	files := 1
+	if strings.HasSuffix(a.Path, ".css") || strings.HasPrefix(contentType, "text/css") {
+		var n int
+		body, n, err = v.fetchReferences(ctx, a, body)
+		if err != nil {
+			return Result{}, err
+		}
+		files += n
+	}
+
+	if err := writeFile(dest, body); err != nil {
+		return Result{}, err
+	}
+	return Result{Asset: a, Files: files}, nil
+}
+
+// The url() references of a stylesheet, quoted or not.
+var cssURL = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+// Download the files a stylesheet refers to and point its references at the copies.
+func (v *Vendor) fetchReferences(ctx context.Context, a Asset, css []byte) ([]byte, int, error) {
+	base, err := url.Parse(a.URL)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the files of fonts/roboto.css go in fonts/roboto/
+	dir := strings.TrimSuffix(path.Base(a.Path), path.Ext(a.Path))
+	fetched := map[string]string{}
+
+	var fetchErr error
+	out := cssURL.ReplaceAllFunc(css, func(match []byte) []byte {
+		m := cssURL.FindSubmatch(match)
+		ref := strings.TrimSpace(string(m[2]))
+		if fetchErr != nil || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return match
+		}
+
+		u, err := base.Parse(ref)
+		if err != nil {
+			fetchErr = err
+			return match
+		}
+
+		local, ok := fetched[u.String()]
+		if !ok {
+			body, _, err := v.get(ctx, u.String())
+			if err != nil {
+				fetchErr = err
+				return match
+			}
+			local = dir + "/" + fileName(u)
+			file := filepath.Join(v.PublicDir, Dir, filepath.FromSlash(path.Dir(a.Path)), filepath.FromSlash(local))
+			if err := writeFile(file, body); err != nil {
+				fetchErr = err
+				return match
+			}
+			fetched[u.String()] = local
+		}
+
+		return []byte("url(" + string(m[1]) + local + string(m[3]) + ")")
+	})
+
+	return out, len(fetched), fetchErr
+}
+
+func (v *Vendor) get(ctx context.Context, rawURL string) ([]byte, string, error) {
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("assets: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("assets: GET %s: %s", rawURL, res.Status)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("assets: GET %s: %w", rawURL, err)
+	}
+	return body, res.Header.Get("Content-Type"), nil
+}
+
+// The name of the copy of a file: the name in its URL, prefixed with a hash of the URL so
+// files of the same name from different places do not collide.
+func fileName(u *url.URL) string {
+	sum := sha256.Sum256([]byte(u.String()))
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = "index"
+	}
+	return hex.EncodeToString(sum[:])[:8] + "-" + name
+}
+
+// Check that a vendored path stays in the vendor directory.
+func localPath(p string) (string, error) {
+	clean := path.Clean("/" + p)
+	if p == "" || clean == "/" || strings.Contains(p, "..") {
+		return "", fmt.Errorf("assets: invalid path %q", p)
+	}
+	return filepath.FromSlash(strings.TrimPrefix(clean, "/")), nil
+}
+
+// Write a file through a temporary file, so a failed download leaves no partial copy.
+func writeFile(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
@@ -0,0 +1,300 @@
+// Package boot loads the service providers of the framework in dependency order, in place
+// of provider.Provider.LoadProviders, which orders them by priority alone and reports
+// nothing but the first error.
+//
+// A provider declares the providers it needs by implementing DependentProvider, and the
+// configuration it takes by implementing SchemaProvider; providers of other packages are
+// declared by a Spec instead. Before any provider is registered the loader checks that
+// every dependency is registered and enabled, that the dependencies have no cycle, and
+// that every configuration has the types its provider expects, and reports all that is
+// wrong at once. Providers are then registered and booted in dependency order, lower
+// priorities first among providers that do not depend on each other, and the Report
+// tells how each fared and how long it took.
+package boot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"myapp/services"
+
+	"github.com/cidekar/adele-framework/provider"
+)
+
+// DefaultPriority is the priority of a provider that sets none, as in the framework.
+const DefaultPriority = 100
+
+// Loader loads service providers.
+type Loader struct {
+	// Provider holds which providers are enabled and their configuration.
+	Provider *provider.Provider
+	// Specs declare providers by name. A spec's dependencies add to those the provider
+	// declares, and its schema is used when the provider declares none.
+	Specs map[string]Spec
+	// Services, when set, receives the services of each provider once it boots; see
+	// services.Container.PublishFrom.
+	Services *services.Container
+}
+
+// A provider being loaded.
+type entry struct {
+	provider.ServiceProvider
+	index  int
+	config map[string]interface{}
+	schema Schema
+	status *Status
+}
+
+// Load loads the enabled providers into app: configure, register, then boot them. The
+// report is returned even when loading fails, to tell why.
+func (l *Loader) Load(app interface{}, providers []provider.ServiceProvider) (*Report, error) {
+	start := time.Now()
+	report := &Report{}
+
+	entries, byName := l.entries(providers)
+	enabled := make([]*entry, 0, len(entries))
+	for _, e := range entries {
+		if e.status.State != Disabled {
+			enabled = append(enabled, e)
+		}
+	}
+
+	// everything that is wrong is found before anything is registered
+	var errs []error
+	fail := func(e *entry, err error) {
+		e.status.Err = errors.Join(e.status.Err, err)
+		errs = append(errs, fmt.Errorf("provider %q: %w", e.Name(), err))
+	}
+	for _, e := range enabled {
+		for _, dep := range e.status.DependsOn {
+			switch d, ok := byName[dep]; {
+			case !ok:
+				fail(e, fmt.Errorf("depends on %q, which is not registered", dep))
+			case d.status.State == Disabled:
+				fail(e, fmt.Errorf("depends on %q, which is disabled", dep))
+			}
+		}
+		if e.schema != nil {
+			for _, err := range e.schema.Validate(e.config) {
+				fail(e, fmt.Errorf("config: %w", err))
+			}
+		}
+	}
+
+	order, cycle := sortByDependencies(enabled, byName)
+	if cycle != nil {
+		names := make([]string, len(cycle))
+		for i, e := range cycle {
+			names[i] = e.Name()
+		}
+		fail(cycle[0], fmt.Errorf("dependency cycle %s", strings.Join(names, " -> ")))
+	}
+
+	for _, e := range order {
+		report.Providers = append(report.Providers, e.status)
+	}
+	for _, e := range entries {
+		if e.status.State == Disabled || (cycle != nil && !contains(order, e)) {
+			report.Providers = append(report.Providers, e.status)
+		}
+	}
+
+	if len(errs) > 0 {
+		report.Duration = time.Since(start)
+		return report, errors.Join(errs...)
+	}
+
+	for _, e := range order {
+		if err := l.register(app, e); err != nil {
+			e.status.State, e.status.Err = Failed, err
+			report.Duration = time.Since(start)
+			return report, fmt.Errorf("provider %q: %w", e.Name(), err)
+		}
+		e.status.State = Registered
+	}
+
+	for _, e := range order {
+		if err := l.boot(app, e, byName); err != nil {
+			report.Duration = time.Since(start)
+			return report, fmt.Errorf("provider %q: %w", e.Name(), err)
+		}
+	}
+
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+func (l *Loader) entries(providers []provider.ServiceProvider) ([]*entry, map[string]*entry) {
+	entries := make([]*entry, 0, len(providers))
+	byName := make(map[string]*entry, len(providers))
+	for i, p := range providers {
+		spec := l.Specs[p.Name()]
+		e := &entry{ServiceProvider: p, index: i, config: l.Provider.GetProviderConfig(p.Name()), schema: l.schema(p, p.Name())}
+
+		status := &Status{Name: p.Name(), State: Pending, Priority: DefaultPriority}
+		if !l.Provider.IsProviderEnabled(p.Name()) {
+			status.State = Disabled
+		}
+		if o, ok := p.(provider.OptionalProvider); ok {
+			status.Optional = o.IsOptional()
+		}
+		if priority, ok := e.config["priority"].(int); ok {
+			status.Priority = priority
+		} else if pp, ok := p.(provider.PriorityProvider); ok {
+			status.Priority = pp.Priority()
+		}
+		if d, ok := p.(DependentProvider); ok {
+			status.DependsOn = append(status.DependsOn, d.DependsOn()...)
+		}
+		for _, dep := range spec.DependsOn {
+			if !containsName(status.DependsOn, dep) {
+				status.DependsOn = append(status.DependsOn, dep)
+			}
+		}
+
+		e.status = status
+		entries = append(entries, e)
+		byName[p.Name()] = e
+	}
+	return entries, byName
+}
+
+func (l *Loader) register(app interface{}, e *entry) error {
+	start := time.Now()
+	defer func() { e.status.Register = time.Since(start) }()
+
+	if c, ok := e.ServiceProvider.(provider.ConfigurableProvider); ok && e.config != nil {
+		if err := c.Configure(e.config); err != nil {
+			return fmt.Errorf("configure: %w", err)
+		}
+	}
+	if err := e.Register(app); err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+	return nil
+}
+
+// Boot a registered provider. An optional provider that fails to boot, or whose
+// dependencies did not boot, is skipped rather than failing the load.
+func (l *Loader) boot(app interface{}, e *entry, byName map[string]*entry) error {
+	for _, dep := range e.status.DependsOn {
+		if d := byName[dep]; d.status.State != Booted {
+			err := fmt.Errorf("depends on %q, which did not boot", dep)
+			if !e.status.Optional {
+				e.status.State, e.status.Err = Failed, err
+				return err
+			}
+			e.status.State, e.status.Err = Skipped, err
+			return nil
+		}
+	}
+
+	start := time.Now()
+	err := e.Boot(app)
+	e.status.Boot = time.Since(start)
+	if err == nil && l.Services != nil {
+		err = l.Services.PublishFrom(e.Name(), e.ServiceProvider)
+	}
+	if err == nil {
+		e.status.State = Booted
+		return nil
+	}
+	e.status.State, e.status.Err = Failed, err
+	if e.status.Optional {
+		return nil
+	}
+	return fmt.Errorf("boot: %w", err)
+}
+
+// Sort providers so each comes after its dependencies, by priority and then registration
+// order among those that are ready together. A cycle is returned when there is one;
+// dependencies that are missing are left out, as they were reported already.
+func sortByDependencies(entries []*entry, byName map[string]*entry) (order, cycle []*entry) {
+	waiting := make(map[*entry]int, len(entries))
+	dependents := make(map[*entry][]*entry)
+	for _, e := range entries {
+		for _, dep := range e.status.DependsOn {
+			if d, ok := byName[dep]; ok && d.status.State != Disabled {
+				waiting[e]++
+				dependents[d] = append(dependents[d], e)
+			}
+		}
+	}
+
+	var ready []*entry
+	for _, e := range entries {
+		if waiting[e] == 0 {
+			ready = append(ready, e)
+		}
+	}
+	for len(ready) > 0 {
+		sort.SliceStable(ready, func(i, j int) bool {
+			if ready[i].status.Priority != ready[j].status.Priority {
+				return ready[i].status.Priority < ready[j].status.Priority
+			}
+			return ready[i].index < ready[j].index
+		})
+		e := ready[0]
+		ready = ready[1:]
+		order = append(order, e)
+		for _, d := range dependents[e] {
+			if waiting[d]--; waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(order) < len(entries) {
+		cycle = findCycle(entries, order, byName)
+	}
+	return order, cycle
+}
+
+// Find a cycle among the providers left unsorted, following dependencies until one
+// repeats. Each of them waits on another of them, so the walk must come back.
+func findCycle(entries, sorted []*entry, byName map[string]*entry) []*entry {
+	var e *entry
+	for _, candidate := range entries {
+		if !contains(sorted, candidate) {
+			e = candidate
+			break
+		}
+	}
+
+	seen := make(map[*entry]int)
+	var path []*entry
+	for {
+		if at, ok := seen[e]; ok {
+			return append(path[at:], e)
+		}
+		seen[e] = len(path)
+		path = append(path, e)
+		for _, dep := range e.status.DependsOn {
+			if d, ok := byName[dep]; ok && d.status.State != Disabled && !contains(sorted, d) {
+				e = d
+				break
+			}
+		}
+	}
+}
+
+func contains(entries []*entry, e *entry) bool {
+	for _, other := range entries {
+		if other == e {
+			return true
+		}
+	}
+	return false
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
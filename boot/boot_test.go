@@ -0,0 +1,198 @@
+package boot
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"myapp/services"
+
+	"github.com/cidekar/adele-framework/provider"
+)
+
+// A provider recording the order it is registered and booted in.
+type fakeProvider struct {
+	name      string
+	priority  int
+	deps      []string
+	optional  bool
+	bootErr   error
+	schema    Schema
+	configure func(map[string]interface{}) error
+	log       *[]string
+}
+
+func (p *fakeProvider) Name() string         { return p.name }
+func (p *fakeProvider) Priority() int        { return p.priority }
+func (p *fakeProvider) DependsOn() []string  { return p.deps }
+func (p *fakeProvider) IsOptional() bool     { return p.optional }
+func (p *fakeProvider) ConfigSchema() Schema { return p.schema }
+
+func (p *fakeProvider) Configure(config map[string]interface{}) error {
+	if p.configure != nil {
+		return p.configure(config)
+	}
+	return nil
+}
+
+func (p *fakeProvider) Register(app interface{}) error {
+	*p.log = append(*p.log, "register "+p.name)
+	return nil
+}
+
+func (p *fakeProvider) Boot(app interface{}) error {
+	*p.log = append(*p.log, "boot "+p.name)
+	return p.bootErr
+}
+
+func newLoader() *Loader {
+	return &Loader{Provider: &provider.Provider{
+		EnabledProviders: make(map[string]bool),
+		ProviderConfigs:  make(map[string]map[string]interface{}),
+	}}
+}
+
+func TestLoad_DependencyOrder(t *testing.T) {
+	var log []string
+	providers := []provider.ServiceProvider{
+		&fakeProvider{name: "oauth", priority: 51, deps: []string{"sessions"}, log: &log},
+		&fakeProvider{name: "sessions", priority: 90, log: &log},
+		&fakeProvider{name: "queue", priority: 30, log: &log},
+	}
+	l := newLoader()
+	l.Specs = map[string]Spec{"queue": {DependsOn: []string{"sessions"}}}
+
+	report, err := l.Load(nil, providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "register sessions,register queue,register oauth,boot sessions,boot queue,boot oauth"
+	if got := strings.Join(log, ","); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if report.Count(Booted) != 3 {
+		t.Errorf("Expected 3 booted providers, got %+v", report.Providers)
+	}
+}
+
+func TestLoad_Diagnostics(t *testing.T) {
+	var log []string
+	providers := []provider.ServiceProvider{
+		&fakeProvider{name: "a", deps: []string{"b"}, log: &log},
+		&fakeProvider{name: "b", deps: []string{"c"}, log: &log},
+		&fakeProvider{name: "c", deps: []string{"a"}, log: &log},
+		&fakeProvider{name: "d", deps: []string{"missing", "off"}, log: &log},
+		&fakeProvider{name: "off", log: &log},
+		&fakeProvider{name: "queue", log: &log, schema: Schema{
+			"worker_count": {Type: Int, Required: true},
+			"backend":      {Type: String},
+		}},
+	}
+	l := newLoader()
+	l.Provider.SetProviderEnabled("off", false)
+	l.Provider.ProviderConfigs["queue"] = map[string]interface{}{"backend": "redis", "worker_count": float64(4)}
+
+	report, err := l.Load(nil, providers)
+	if err == nil {
+		t.Fatal("Expected loading to fail")
+	}
+	if len(log) > 0 {
+		t.Errorf("Expected nothing to be registered, got %v", log)
+	}
+
+	for _, want := range []string{
+		`provider "a": dependency cycle a -> b -> c -> a`,
+		`provider "d": depends on "missing", which is not registered`,
+		`provider "d": depends on "off", which is disabled`,
+		`provider "queue": config: worker_count must be int, not float64`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to report %q, got:\n%s", want, err)
+		}
+	}
+
+	if s, _ := report.Status("off"); s.State != Disabled {
+		t.Errorf("Expected off to be disabled, got %s", s.State)
+	}
+	if s, _ := report.Status("queue"); s.State != Pending || s.Err == nil {
+		t.Errorf("Expected queue to be pending with an error, got %+v", s)
+	}
+	var buf bytes.Buffer
+	report.WriteTo(&buf)
+	if !strings.Contains(buf.String(), "worker_count must be int") {
+		t.Errorf("Expected the report to show the config error, got:\n%s", buf.String())
+	}
+}
+
+func TestLoad_OptionalFailures(t *testing.T) {
+	var log []string
+	boom := errors.New("boom")
+	providers := []provider.ServiceProvider{
+		&fakeProvider{name: "metrics", priority: 10, optional: true, bootErr: boom, log: &log},
+		&fakeProvider{name: "dashboard", priority: 20, optional: true, deps: []string{"metrics"}, log: &log},
+		&fakeProvider{name: "app", priority: 30, log: &log},
+	}
+
+	report, err := newLoader().Load(nil, providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := report.Status("metrics"); s.State != Failed || !errors.Is(s.Err, boom) {
+		t.Errorf("Expected metrics to fail, got %+v", s)
+	}
+	if s, _ := report.Status("dashboard"); s.State != Skipped {
+		t.Errorf("Expected dashboard to be skipped, got %+v", s)
+	}
+	if s, _ := report.Status("app"); s.State != Booted {
+		t.Errorf("Expected app to boot, got %+v", s)
+	}
+
+	// a provider that is not optional cannot go without its dependencies
+	providers[1].(*fakeProvider).optional = false
+	log = nil
+	if _, err := newLoader().Load(nil, providers); err == nil || !strings.Contains(err.Error(), `depends on "metrics", which did not boot`) {
+		t.Errorf("Expected dashboard to fail without metrics, got %v", err)
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema := Schema{
+		"scopes":   {Type: StringMap},
+		"channels": {Type: Strings, Required: true},
+	}
+	errs := schema.Validate(map[string]interface{}{
+		"scopes":   map[string]interface{}{"user-read": "Read users"},
+		"priority": "10",
+	})
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %v", errs)
+	}
+	if errs := schema.Validate(map[string]interface{}{"channels": []string{"default"}, "priority": 5}); len(errs) != 0 {
+		t.Errorf("Expected a valid config, got %v", errs)
+	}
+}
+
+// A provider exposing its service as the first-party providers do.
+type serviceProvider struct {
+	fakeProvider
+	service *bytes.Buffer
+}
+
+func (p *serviceProvider) Service() *bytes.Buffer { return p.service }
+
+func TestLoad_PublishesServices(t *testing.T) {
+	var log []string
+	l := newLoader()
+	l.Services = services.New()
+	buf := &bytes.Buffer{}
+	providers := []provider.ServiceProvider{&serviceProvider{fakeProvider: fakeProvider{name: "buffer", log: &log}, service: buf}}
+
+	if _, err := l.Load(nil, providers); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := services.Resolve[*bytes.Buffer](l.Services); err != nil || got != buf {
+		t.Errorf("Expected the provider's service to be published, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,232 @@
+package boot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/cidekar/adele-framework/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// A provider's entry in the config file.
+type configEntry struct {
+	Enabled  *bool                  `yaml:"Enabled"`
+	Priority *int                   `yaml:"Priority"`
+	Config   map[string]interface{} `yaml:"Config"`
+}
+
+// LoadConfig reads the providers' configuration from a file, e.g., config/providers.yml,
+// into the loader's Provider, for Load to use:
+//
+//	Providers:
+//	  queue:
+//	    Enabled: true
+//	    Priority: 30
+//	    Config:
+//	      backend: ${QUEUE_BACKEND:-memory}
+//	      worker_count: ${QUEUE_WORKERS:-4}
+//
+// Enabled turns a provider on or off; providers the file does not mention stay enabled.
+// Priority sets the framework's "priority" key. In the strings of Config, ${NAME} is
+// replaced by the environment variable NAME, which must be set, and ${NAME:-default} by
+// the variable or, when it is unset or empty, the default. Values are then converted to
+// the types of the provider's schema where nothing is lost, e.g., the float64 4 of a YAML
+// decoder or the string "4" of a variable to the int 4; values that cannot be are left
+// for Load to refuse.
+//
+// A file that does not exist configures nothing. The keys the file sets that no provider
+// takes are returned, e.g., "queue.worker_cnt" or "oauht", so they can be reported; they
+// are set all the same.
+func (l *Loader) LoadConfig(file string, providers []provider.ServiceProvider) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		Providers map[string]configEntry `yaml:"Providers"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("boot: %s: %w", file, err)
+	}
+
+	registered := make(map[string]provider.ServiceProvider, len(providers))
+	for _, p := range providers {
+		registered[p.Name()] = p
+	}
+
+	var unknown []string
+	for _, name := range sortedKeys(config.Providers) {
+		entry := config.Providers[name]
+		p, ok := registered[name]
+		if !ok {
+			unknown = append(unknown, name)
+		}
+
+		if entry.Enabled != nil {
+			l.Provider.EnabledProviders[name] = *entry.Enabled
+		}
+		if entry.Config == nil && entry.Priority == nil {
+			continue
+		}
+
+		values := make(map[string]interface{}, len(entry.Config)+1)
+		for key, value := range entry.Config {
+			value, err := interpolate(value)
+			if err != nil {
+				return nil, fmt.Errorf("boot: %s: %s.%s: %w", file, name, key, err)
+			}
+			values[key] = value
+		}
+		if entry.Priority != nil {
+			values["priority"] = *entry.Priority
+		}
+
+		if schema := l.schema(p, name); schema != nil {
+			for _, key := range sortedKeys(values) {
+				k, ok := schema[key]
+				switch {
+				case ok && k.Type != nil:
+					values[key] = coerce(values[key], k.Type)
+				case !ok && key != "priority":
+					unknown = append(unknown, name+"."+key)
+				}
+			}
+		}
+		l.Provider.ProviderConfigs[name] = values
+	}
+	return unknown, nil
+}
+
+// The schema of a provider: its own, or that of its spec. p is nil for a provider that
+// is not registered.
+func (l *Loader) schema(p provider.ServiceProvider, name string) Schema {
+	if s, ok := p.(SchemaProvider); ok {
+		if schema := s.ConfigSchema(); schema != nil {
+			return schema
+		}
+	}
+	return l.Specs[name].Config
+}
+
+var variable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Replace the variables in the strings of a value.
+func interpolate(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var err error
+		s := variable.ReplaceAllStringFunc(v, func(match string) string {
+			m := variable.FindStringSubmatch(match)
+			if value := os.Getenv(m[1]); value != "" {
+				return value
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+			if _, ok := os.LookupEnv(m[1]); !ok && err == nil {
+				err = fmt.Errorf("%s is not set", m[1])
+			}
+			return ""
+		})
+		return s, err
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			item, err := interpolate(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			item, err := interpolate(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = item
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// Convert a decoded value to a type where nothing is lost, or return it as it is.
+func coerce(value interface{}, t reflect.Type) interface{} {
+	if value == nil || reflect.TypeOf(value) == t {
+		return value
+	}
+
+	switch t {
+	case Int:
+		switch v := value.(type) {
+		case int64:
+			if v >= math.MinInt && v <= math.MaxInt {
+				return int(v)
+			}
+		case uint64:
+			if v <= math.MaxInt {
+				return int(v)
+			}
+		case float64:
+			if v == math.Trunc(v) && v >= math.MinInt && v <= math.MaxInt {
+				return int(v)
+			}
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				return n
+			}
+		}
+	case Bool:
+		if v, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	case String:
+		switch value.(type) {
+		case int, int64, uint64, float64, bool:
+			return fmt.Sprint(value)
+		}
+	case Strings:
+		if v, ok := value.([]interface{}); ok {
+			out := make([]string, len(v))
+			for i, item := range v {
+				s, ok := coerce(item, String).(string)
+				if !ok {
+					return value
+				}
+				out[i] = s
+			}
+			return out
+		}
+	case StringMap:
+		if v, ok := value.(map[string]interface{}); ok {
+			out := make(map[string]string, len(v))
+			for key, item := range v {
+				s, ok := coerce(item, String).(string)
+				if !ok {
+					return value
+				}
+				out[key] = s
+			}
+			return out
+		}
+	}
+	return value
+}
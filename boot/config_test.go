@@ -0,0 +1,113 @@
+package boot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cidekar/adele-framework/provider"
+)
+
+const testConfig = `
+Providers:
+  queue:
+    Priority: 30
+    Config:
+      backend: ${TEST_QUEUE_BACKEND:-memory}
+      worker_count: ${TEST_QUEUE_WORKERS}
+      max_attempts: 5.0
+      queue_channels: [default, mail]
+      worker_cnt: 2
+  oauth:
+    Enabled: false
+    Config:
+      scopes:
+        user-read: Read users
+  oauht:
+    Enabled: true
+`
+
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "providers.yml")
+	if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TEST_QUEUE_WORKERS", "4")
+	var log []string
+	providers := []provider.ServiceProvider{
+		&fakeProvider{name: "queue", log: &log},
+		&fakeProvider{name: "oauth", log: &log, schema: Schema{"scopes": {Type: StringMap}}},
+	}
+	l := newLoader()
+	l.Specs = map[string]Spec{"queue": {Config: Schema{
+		"backend":        {Type: String},
+		"worker_count":   {Type: Int, Required: true},
+		"max_attempts":   {Type: Int},
+		"queue_channels": {Type: Strings},
+	}}}
+
+	unknown, err := l.LoadConfig(writeConfig(t, testConfig), providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"oauht", "queue.worker_cnt"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("Expected unknown keys %v, got %v", want, unknown)
+	}
+
+	want := map[string]interface{}{
+		"backend":        "memory",
+		"worker_count":   4,
+		"max_attempts":   5,
+		"queue_channels": []string{"default", "mail"},
+		"worker_cnt":     2,
+		"priority":       30,
+	}
+	if got := l.Provider.GetProviderConfig("queue"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the queue config %#v, got %#v", want, got)
+	}
+	if got := l.Provider.GetProviderConfig("oauth")["scopes"]; !reflect.DeepEqual(got, map[string]string{"user-read": "Read users"}) {
+		t.Errorf("Expected the scopes as a map[string]string, got %#v", got)
+	}
+	if l.Provider.IsProviderEnabled("oauth") {
+		t.Error("Expected oauth to be disabled")
+	}
+
+	report, err := l.Load(nil, providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := report.Status("queue"); s.State != Booted || s.Priority != 30 {
+		t.Errorf("Expected queue to boot with priority 30, got %+v", s)
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	l := newLoader()
+	if _, err := l.LoadConfig(writeConfig(t, "Providers:\n  queue:\n    Config:\n      backend: ${TEST_UNSET_VARIABLE}\n"), nil); err == nil || !strings.Contains(err.Error(), "TEST_UNSET_VARIABLE is not set") {
+		t.Errorf("Expected an unset variable to be refused, got %v", err)
+	}
+	if _, err := l.LoadConfig(writeConfig(t, "Providers:\n  queue:\n    Enable: true\n"), nil); err == nil {
+		t.Error("Expected a misspelled field to be refused")
+	}
+
+	// a value that cannot be converted is left for Load to refuse
+	var log []string
+	providers := []provider.ServiceProvider{&fakeProvider{name: "queue", log: &log, schema: Schema{"worker_count": {Type: Int}}}}
+	if _, err := l.LoadConfig(writeConfig(t, "Providers:\n  queue:\n    Config:\n      worker_count: 2.5\n"), providers); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Load(nil, providers); err == nil || !strings.Contains(err.Error(), "worker_count must be int, not float64") {
+		t.Errorf("Expected 2.5 to be refused as worker_count, got %v", err)
+	}
+
+	if unknown, err := l.LoadConfig(filepath.Join(t.TempDir(), "missing.yml"), nil); err != nil || unknown != nil {
+		t.Errorf("Expected a missing file to configure nothing, got %v, %v", unknown, err)
+	}
+}
@@ -0,0 +1,107 @@
+package boot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// State is how far a provider got.
+type State string
+
+const (
+	// Pending providers were not loaded, as loading failed before their turn.
+	Pending State = "pending"
+	// Disabled providers are turned off in the configuration.
+	Disabled State = "disabled"
+	// Registered providers did not boot yet.
+	Registered State = "registered"
+	// Booted providers are loaded.
+	Booted State = "booted"
+	// Failed providers failed to configure, register or boot.
+	Failed State = "failed"
+	// Skipped providers are optional and were not booted, as a dependency did not boot.
+	Skipped State = "skipped"
+)
+
+// Status is how a provider fared.
+type Status struct {
+	Name      string
+	State     State
+	Optional  bool
+	Priority  int
+	DependsOn []string
+	// Register is how long it took to configure and register, Boot how long to boot.
+	Register time.Duration
+	Boot     time.Duration
+	// Err is why the provider failed, was skipped or kept the others from loading.
+	Err error
+}
+
+// Report is how loading the providers went: each provider in the order loaded, followed by
+// those that were not.
+type Report struct {
+	Providers []*Status
+	Duration  time.Duration
+}
+
+// Status returns the status of a provider by name.
+func (r *Report) Status(name string) (*Status, bool) {
+	for _, s := range r.Providers {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Count returns how many providers are in a state.
+func (r *Report) Count(state State) int {
+	n := 0
+	for _, s := range r.Providers {
+		if s.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteTo writes the report as a table, for the boot log.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Providers: %d booted, %d disabled, %d failed, %d skipped in %s\n",
+		r.Count(Booted), r.Count(Disabled), r.Count(Failed), r.Count(Skipped), r.Duration.Round(time.Microsecond))
+
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, s := range r.Providers {
+		fmt.Fprintf(tw, "  %s\t%s\tpriority %d", s.Name, s.State, s.Priority)
+		if s.State != Disabled && s.State != Pending {
+			fmt.Fprintf(tw, "\tregister %s\tboot %s", s.Register.Round(time.Microsecond), s.Boot.Round(time.Microsecond))
+		} else {
+			fmt.Fprint(tw, "\t\t")
+		}
+		if len(s.DependsOn) > 0 {
+			fmt.Fprintf(tw, "\tdepends on %s", strings.Join(s.DependsOn, ", "))
+		} else {
+			fmt.Fprint(tw, "\t")
+		}
+		if s.Optional {
+			fmt.Fprint(tw, "\toptional")
+		} else {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprintln(tw)
+		if s.Err != nil {
+			for _, line := range strings.Split(s.Err.Error(), "\n") {
+				fmt.Fprintf(tw, "    %s\n", line)
+			}
+		}
+	}
+	tw.Flush()
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
@@ -0,0 +1,81 @@
+package boot
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// The types of configuration values, as the Configure methods of providers assert them.
+var (
+	Int       = reflect.TypeOf(0)
+	Bool      = reflect.TypeOf(false)
+	String    = reflect.TypeOf("")
+	Strings   = reflect.TypeOf([]string(nil))
+	StringMap = reflect.TypeOf(map[string]string(nil))
+)
+
+// Key is a configuration key of a provider.
+type Key struct {
+	// Type is the type the provider expects the value to have. It is matched exactly: a
+	// provider asserting an int does not take an int64 or a float64, it zero-defaults them.
+	Type     reflect.Type
+	Required bool
+}
+
+// Schema is the configuration a provider takes, by key.
+type Schema map[string]Key
+
+// Spec declares what a provider needs, for providers that do not declare it themselves;
+// see Loader.Specs.
+type Spec struct {
+	// DependsOn are the names of the providers to load first.
+	DependsOn []string
+	// Config is the configuration the provider takes; nil takes anything.
+	Config Schema
+}
+
+// DependentProvider is a provider that needs other providers loaded first.
+type DependentProvider interface {
+	DependsOn() []string
+}
+
+// SchemaProvider is a provider that declares the configuration it takes.
+type SchemaProvider interface {
+	ConfigSchema() Schema
+}
+
+// Validate checks a provider's configuration against its schema: required keys are set
+// and values have the type the provider expects. The "priority" key of the framework is
+// always taken as an int. Keys the schema does not name are left alone.
+func (s Schema) Validate(config map[string]interface{}) []error {
+	var errs []error
+	for _, name := range sortedKeys(s) {
+		key := s[name]
+		value, ok := config[name]
+		if !ok || value == nil {
+			if key.Required {
+				errs = append(errs, fmt.Errorf("%s is required", name))
+			}
+			continue
+		}
+		if key.Type != nil && reflect.TypeOf(value) != key.Type {
+			errs = append(errs, fmt.Errorf("%s must be %s, not %T", name, key.Type, value))
+		}
+	}
+	if value, ok := config["priority"]; ok {
+		if _, isInt := value.(int); !isInt {
+			errs = append(errs, fmt.Errorf("priority must be int, not %T", value))
+		}
+	}
+	return errs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"myapp/assets"
+	"myapp/i18n"
+	"myapp/maintenance"
+	"myapp/rpcapi"
+	"os"
+	"path/filepath"
+
+	"github.com/cidekar/adele-framework"
+)
+
+// Here is where the commands of the application binary are handled. A command runs in
+// place of the server and exits, for example:
+//
+//	./adeleApp down --secret s3cr3t --retry 60
+//	./adeleApp up
+//	./adeleApp lang missing
+//	./adeleApp assets vendor [--force] [--check]
+//	./adeleApp rpc list
+//	./adeleApp rpc client [--check]
+//	./adeleApp ctl status
+//	./adeleApp ctl run mail.send
+//	./adeleApp ctl log-level debug
+//
+// The ctl commands drive the running application over RPC rather than the files it
+// reads: status, jobs, run <job>, down, up, log-level <level> and shutdown (see ctl).
+//
+// The reported bool is false when the arguments name no command and the server should
+// start.
+func runCommand(args []string, out io.Writer) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	path, err := os.Getwd()
+	if err != nil {
+		return true, err
+	}
+
+	switch args[0] {
+	case "down":
+		flags := flag.NewFlagSet("down", flag.ContinueOnError)
+		secret := flags.String("secret", "", "path that sets a cookie to bypass maintenance mode")
+		retry := flags.Int("retry", 0, "seconds clients are told to wait in Retry-After")
+		if err := flags.Parse(args[1:]); err != nil {
+			return true, err
+		}
+
+		mode := maintenance.New(path)
+		if err := mode.Down(maintenance.State{Secret: *secret, Retry: *retry}); err != nil {
+			return true, err
+		}
+		fmt.Fprintln(out, "Application is now in maintenance mode.")
+		if *secret != "" {
+			fmt.Fprintf(out, "Bypass it by visiting /%s\n", *secret)
+		}
+		return true, nil
+
+	case "up":
+		if err := maintenance.New(path).Up(); err != nil {
+			return true, err
+		}
+		fmt.Fprintln(out, "Application is now live.")
+		return true, nil
+
+	case "lang":
+		if len(args) < 2 || args[1] != "missing" {
+			return true, fmt.Errorf("usage: lang missing")
+		}
+
+		bundle, err := i18n.Load(filepath.Join(path, "resources", "lang"), fallbackLocale())
+		if err != nil {
+			return true, err
+		}
+		missing, err := i18n.Report(bundle, filepath.Join(path, "resources", "views"))
+		if err != nil {
+			return true, err
+		}
+		for _, m := range missing {
+			fmt.Fprintln(out, m)
+		}
+		if len(missing) > 0 {
+			return true, fmt.Errorf("%d missing translations", len(missing))
+		}
+		fmt.Fprintln(out, "No missing translations.")
+		return true, nil
+
+	case "assets":
+		if len(args) < 2 || args[1] != "vendor" {
+			return true, fmt.Errorf("usage: assets vendor [--force] [--check]")
+		}
+		flags := flag.NewFlagSet("assets vendor", flag.ContinueOnError)
+		force := flags.Bool("force", false, "download assets already vendored again")
+		check := flags.Bool("check", false, "only report views loading assets from other hosts")
+		if err := flags.Parse(args[2:]); err != nil {
+			return true, err
+		}
+		return true, vendorAssets(path, out, *force, *check)
+
+	case "rpc":
+		if len(args) < 2 || (args[1] != "list" && args[1] != "client") {
+			return true, fmt.Errorf("usage: rpc list | rpc client [--check]")
+		}
+		flags := flag.NewFlagSet("rpc "+args[1], flag.ContinueOnError)
+		check := flags.Bool("check", false, "only report whether the client is up to date")
+		if err := flags.Parse(args[2:]); err != nil {
+			return true, err
+		}
+
+		// the methods are registered without booting the application; only their types
+		// are needed
+		registry := rpcapi.NewRegistry(nil)
+		(&application{App: &adele.Adele{RootPath: path}}).rpcServices(registry)
+
+		if args[1] == "list" {
+			for _, m := range registry.Methods() {
+				fmt.Fprintf(out, "%s(%s) %s\n", m.Name, m.Request, m.Response)
+				if m.Description != "" {
+					fmt.Fprintf(out, "    %s\n", m.Description)
+				}
+			}
+			return true, nil
+		}
+		return true, generateRPCClient(path, out, registry, *check)
+
+	case "ctl":
+		return true, ctl(path, args[1:], out)
+	}
+
+	return false, nil
+}
+
+// Write the rpcclient package, the typed client of the RPC methods of the application (see
+// rpcapi.Generate).
+func generateRPCClient(path string, out io.Writer, registry *rpcapi.Registry, check bool) error {
+	src, err := rpcapi.Generate(registry, "rpcclient")
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(path, "rpcclient", "client.go")
+	current, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(current, src) {
+		fmt.Fprintln(out, "The RPC client is up to date.")
+		return nil
+	}
+	if check {
+		return fmt.Errorf("%s is out of date; run rpc client", file)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, src, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %s (%d methods)\n", file, len(registry.Methods()))
+	return nil
+}
+
+// Vendor the third-party assets of config/assets.yml into public/vendor, after rewriting the
+// views that load assets from other hosts to load vendored copies (see the assets package).
+func vendorAssets(path string, out io.Writer, force, check bool) error {
+	configFile := filepath.Join(path, "config", "assets.yml")
+	config, err := assets.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	added, changed, err := assets.Rewrite(filepath.Join(path, "resources", "views"), config, check)
+	if err != nil {
+		return err
+	}
+	for _, view := range changed {
+		fmt.Fprintf(out, "%s loads assets from other hosts\n", view)
+	}
+	if check {
+		if len(changed) > 0 {
+			return fmt.Errorf("%d views load assets from other hosts; run assets vendor", len(changed))
+		}
+		return nil
+	}
+
+	if err := assets.AppendConfig(configFile, added); err != nil {
+		return err
+	}
+	config.Assets = append(config.Assets, added...)
+
+	vendor := &assets.Vendor{PublicDir: filepath.Join(path, "public"), Force: force}
+	for _, a := range config.Assets {
+		result, err := vendor.Fetch(context.Background(), a)
+		if err != nil {
+			return err
+		}
+		if result.Kept {
+			fmt.Fprintf(out, "Kept %s\n", a.Path)
+			continue
+		}
+		fmt.Fprintf(out, "Vendored %s (%d files) from %s\n", a.Path, result.Files, a.URL)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"myapp/rpcapi/admin"
+	"myapp/rpcclient"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const ctlUsage = "usage: ctl [--addr host:port] status | jobs | run <job> | down [--secret s] [--retry n] | up | log-level <level> | shutdown"
+
+// Drive the running application over RPC with the Admin methods. The server is the one
+// of this application, as RPC_SERVER_ADDR and RPC_SERVER_PORT configure it, unless --addr
+// names another; the credentials are those of rpcapi.CredentialsFromEnv. Both are read
+// from the .env file under path too, for variables the environment does not set.
+func ctl(path string, args []string, out io.Writer) error {
+	if err := godotenv.Load(filepath.Join(path, ".env")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	flags := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	addr := flags.String("addr", "", "address of the RPC server")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return fmt.Errorf(ctlUsage)
+	}
+
+	client, err := rpcclient.Dial(*addr)
+	if err != nil {
+		return fmt.Errorf("cannot connect to the application: %w", err)
+	}
+	defer client.Close()
+
+	// a job runs as long as it takes; anything else answers at once
+	ctx := context.Background()
+	if args[0] != "run" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+	}
+
+	switch args[0] {
+	case "status":
+		status, err := client.Admin.Status(ctx, &admin.StatusRequest{})
+		if err != nil {
+			return err
+		}
+		printStatus(out, status)
+
+	case "jobs":
+		jobs, err := client.Admin.Jobs(ctx, &admin.JobsRequest{})
+		if err != nil {
+			return err
+		}
+		for _, name := range jobs.Names {
+			fmt.Fprintln(out, name)
+		}
+
+	case "run":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: ctl run <job>")
+		}
+		resp, err := client.Admin.RunJob(ctx, &admin.RunJobRequest{Name: args[1]})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Ran %s in %s.\n", resp.Name, resp.Duration.Round(time.Millisecond))
+
+	case "down":
+		down := flag.NewFlagSet("ctl down", flag.ContinueOnError)
+		secret := down.String("secret", "", "path that sets a cookie to bypass maintenance mode")
+		retry := down.Int("retry", 0, "seconds clients are told to wait in Retry-After")
+		if err := down.Parse(args[1:]); err != nil {
+			return err
+		}
+		if _, err := client.Admin.Maintenance(ctx, &admin.MaintenanceRequest{Down: true, Secret: *secret, Retry: *retry}); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Application is now in maintenance mode.")
+		if *secret != "" {
+			fmt.Fprintf(out, "Bypass it by visiting /%s\n", *secret)
+		}
+
+	case "up":
+		if _, err := client.Admin.Maintenance(ctx, &admin.MaintenanceRequest{}); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Application is now live.")
+
+	case "log-level":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: ctl log-level <level>")
+		}
+		resp, err := client.Admin.SetLogLevel(ctx, &admin.SetLogLevelRequest{Level: args[1]})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Log level changed from %s to %s.\n", resp.Previous, resp.Level)
+
+	case "shutdown":
+		if _, err := client.Admin.Shutdown(ctx, &admin.ShutdownRequest{}); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Application is shutting down.")
+
+	default:
+		return fmt.Errorf(ctlUsage)
+	}
+	return nil
+}
+
+func printStatus(out io.Writer, status *admin.StatusResponse) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Started:\t%s (up %s)\n", status.StartedAt.Format(time.RFC3339), status.Uptime.Round(time.Second))
+	fmt.Fprintf(w, "Goroutines:\t%d\n", status.Goroutines)
+	fmt.Fprintf(w, "Maintenance:\t%s\n", status.Maintenance)
+	fmt.Fprintf(w, "Log level:\t%s\n", status.LogLevel)
+	w.Flush()
+
+	fmt.Fprintf(out, "\nProviders (%d):\n", len(status.Providers))
+	for _, p := range status.Providers {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", p.Name, p.State, p.Boot.Round(time.Microsecond), p.Error)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nRoutes (%d):\n", len(status.Routes))
+	for _, r := range status.Routes {
+		fmt.Fprintf(w, "  %s\t%s\n", r.Method, r.Pattern)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nScheduled jobs (%d):\n", len(status.Scheduled))
+	for _, j := range status.Scheduled {
+		name, schedule := j.Name, j.Schedule
+		if name == "" {
+			name, schedule = "(framework)", "-"
+		}
+		prev := "never"
+		if !j.Prev.IsZero() {
+			prev = j.Prev.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "  %s\t%s\tnext %s\tlast %s\n", name, schedule, j.Next.Format(time.RFC3339), prev)
+	}
+	w.Flush()
+}
@@ -0,0 +1,174 @@
+package flash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// Level is the kind of a flash message.
+type Level string
+
+// The levels of a flash message, used by the flash partial as the class of the message.
+const (
+	Success Level = "success"
+	Info    Level = "info"
+	Warning Level = "warning"
+	Error   Level = "error"
+)
+
+// The session keys the flash data is stored under. The values are JSON encoded so the
+// session store needs no types registered. The framework's own "flash" and "error" strings
+// are read as well, so messages set with Session.Put(ctx, "flash", ...) are not lost.
+const (
+	messagesKey = "_flash"
+	errorsKey   = "_flash_errors"
+	inputKey    = "_flash_input"
+
+	legacyFlashKey = "flash"
+	legacyErrorKey = "error"
+)
+
+// Message is a flash message.
+type Message struct {
+	Level Level  `json:"level"`
+	Text  string `json:"text"`
+}
+
+// Store keeps flash data in the session. Flash messages survive exactly one redirect: a
+// handler adds a message before redirecting and the next page rendered shows it, which is
+// what the post-redirect-get pattern needs. The same goes for the validation errors and
+// submitted values of a form, so the page a failed form redirects back to can show the
+// errors next to the inputs and fill them in.
+//
+// Example:
+//
+//	func (h *Handlers) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+//		var input ProfileInput
+//		if err := h.Bind(r, &input); err != nil {
+//			h.Flash.Input(r.Context(), r.Form, err)
+//			http.Redirect(w, r, "/profile", http.StatusSeeOther)
+//			return
+//		}
+//		...
+//		h.Flash.Success(r.Context(), "Your profile was saved.")
+//		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+//	}
+//
+// Views rendered with Handlers.Render, and the pages middleware renders like the maintenance
+// page, receive the messages as flashes, and the errors and submitted values as errors and
+// old.
+type Store struct {
+	session *scs.SessionManager
+}
+
+// New returns a store backed by the session manager of the application.
+func New(session *scs.SessionManager) *Store {
+	return &Store{session: session}
+}
+
+// Add adds a message to show on the next page rendered.
+func (s *Store) Add(ctx context.Context, level Level, text string) {
+	messages := s.decodeMessages(ctx)
+	messages = append(messages, Message{Level: level, Text: text})
+	s.put(ctx, messagesKey, messages)
+}
+
+// Success adds a success message.
+func (s *Store) Success(ctx context.Context, text string) {
+	s.Add(ctx, Success, text)
+}
+
+// Info adds an informational message.
+func (s *Store) Info(ctx context.Context, text string) {
+	s.Add(ctx, Info, text)
+}
+
+// Warning adds a warning.
+func (s *Store) Warning(ctx context.Context, text string) {
+	s.Add(ctx, Warning, text)
+}
+
+// Error adds an error message.
+func (s *Store) Error(ctx context.Context, text string) {
+	s.Add(ctx, Error, text)
+}
+
+// Input keeps the submitted values of a form and the error it failed with for the next page
+// rendered. Passwords are never kept. Errors with a Map method (handlers.ValidationErrors)
+// are kept by input name; any other error is kept under "form".
+func (s *Store) Input(ctx context.Context, values url.Values, err error) {
+	old := url.Values{}
+	for name, v := range values {
+		if !isPassword(name) {
+			old[name] = v
+		}
+	}
+	s.put(ctx, inputKey, old)
+
+	var fields interface{ Map() map[string][]string }
+	switch {
+	case err == nil:
+	case errors.As(err, &fields):
+		s.put(ctx, errorsKey, fields.Map())
+	default:
+		s.put(ctx, errorsKey, map[string][]string{"form": {err.Error()}})
+	}
+}
+
+// Pop returns the messages and removes them from the session.
+func (s *Store) Pop(ctx context.Context) []Message {
+	messages := s.decodeMessages(ctx)
+	if len(messages) > 0 {
+		s.session.Remove(ctx, messagesKey)
+	}
+
+	if text := s.session.PopString(ctx, legacyFlashKey); text != "" {
+		messages = append(messages, Message{Level: Success, Text: text})
+	}
+	if text := s.session.PopString(ctx, legacyErrorKey); text != "" {
+		messages = append(messages, Message{Level: Error, Text: text})
+	}
+	return messages
+}
+
+// PopInput returns the kept form values and errors and removes them from the session.
+func (s *Store) PopInput(ctx context.Context) (url.Values, map[string][]string) {
+	var (
+		old    url.Values
+		fields map[string][]string
+	)
+	s.pop(ctx, inputKey, &old)
+	s.pop(ctx, errorsKey, &fields)
+	return old, fields
+}
+
+func (s *Store) decodeMessages(ctx context.Context) []Message {
+	var messages []Message
+	if data := s.session.GetString(ctx, messagesKey); data != "" {
+		json.Unmarshal([]byte(data), &messages)
+	}
+	return messages
+}
+
+func (s *Store) put(ctx context.Context, key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.session.Put(ctx, key, string(data))
+}
+
+func (s *Store) pop(ctx context.Context, key string, v interface{}) {
+	if data := s.session.PopString(ctx, key); data != "" {
+		json.Unmarshal([]byte(data), v)
+	}
+}
+
+func isPassword(name string) bool {
+	return strings.Contains(strings.ToLower(name), "password")
+}
@@ -0,0 +1,139 @@
+package flash
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/alexedwards/scs/v2"
+)
+
+type fieldErrors map[string][]string
+
+func (e fieldErrors) Error() string            { return "invalid" }
+func (e fieldErrors) Map() map[string][]string { return e }
+
+// Serve a handler with the session loaded, carrying the session cookie from one request
+// to the next like a browser following a redirect.
+func newClient(session *scs.SessionManager) func(h http.HandlerFunc) {
+	var cookies []*http.Cookie
+	return func(h http.HandlerFunc) {
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		session.LoadAndSave(h).ServeHTTP(w, req)
+		if c := w.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+	}
+}
+
+func TestStore_SurvivesOneRedirect(t *testing.T) {
+	session := scs.New()
+	store := New(session)
+	serve := newClient(session)
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		store.Success(r.Context(), "Saved.")
+		store.Warning(r.Context(), "Check your email.")
+		session.Put(r.Context(), "flash", "Set by the framework.")
+	})
+
+	var messages []Message
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		messages = store.Pop(r.Context())
+	})
+	want := []Message{{Success, "Saved."}, {Warning, "Check your email."}, {Success, "Set by the framework."}}
+	if len(messages) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, messages)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], messages[i])
+		}
+	}
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		messages = store.Pop(r.Context())
+	})
+	if len(messages) != 0 {
+		t.Errorf("Expected the messages to be shown once, got %v", messages)
+	}
+}
+
+func TestStore_Input(t *testing.T) {
+	session := scs.New()
+	store := New(session)
+	serve := newClient(session)
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		values := url.Values{"email": {"ada@example"}, "password": {"secret"}}
+		store.Input(r.Context(), values, fieldErrors{"email": {"must be a valid email address"}})
+	})
+
+	var (
+		old    url.Values
+		fields map[string][]string
+	)
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		old, fields = store.PopInput(r.Context())
+	})
+	if old.Get("email") != "ada@example" || old.Has("password") {
+		t.Errorf("Unexpected old input %v", old)
+	}
+	if len(fields["email"]) != 1 {
+		t.Errorf("Unexpected errors %v", fields)
+	}
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		store.Input(r.Context(), nil, errors.New("the form expired"))
+	})
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		_, fields = store.PopInput(r.Context())
+	})
+	if fields["form"][0] != "the form expired" {
+		t.Errorf("Expected the error under form, got %v", fields)
+	}
+}
+
+type formErrors map[string][]string
+
+func (e formErrors) Has(field string) bool     { return len(e[field]) > 0 }
+func (e formErrors) First(field string) string { return e[field][0] }
+
+func TestFlashPartial(t *testing.T) {
+	views := jet.NewSet(jet.NewOSFileSystemLoader("../resources/views"))
+
+	tmpl, err := views.Parse("/flash_test.jet", `{{ import "./partials/flash.jet" }}{{ yield flash() }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("flashes", []Message{{Success, "Saved."}})
+	vars.Set("errors", formErrors{"form": {"The form expired."}})
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	html := out.String()
+	for _, expected := range []string{`class="flash flash-success" role="status">Saved.`, `class="flash flash-error" role="alert">The form expired.`} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected the partial to contain %s, got %s", expected, html)
+		}
+	}
+
+	out.Reset()
+	if err := tmpl.Execute(&out, nil, nil); err != nil {
+		t.Errorf("Expected the partial to render without flash data, got %v", err)
+	}
+}
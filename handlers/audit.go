@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"myapp/models"
+	"myapp/pagination"
+	"net/http"
+	"time"
+)
+
+// AuditIndex lists audit log entries filtered by entity. Pages are numbered newest first;
+// cursor pagination walks the entries in the order they were recorded.
+//
+// Example:
+//
+//	GET /api/audit?table=users&record_id=42
+//	GET /api/audit?actor=7&action=delete&since=2026-01-01T00:00:00Z&cursor=
+func (h *Handlers) AuditIndex(w http.ResponseWriter, r *http.Request) {
+	params, err := pagination.Parse(r, pagination.DefaultOptions)
+	if err != nil {
+		h.Error(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Table:    query.Get("table"),
+		RecordID: query.Get("record_id"),
+		Actor:    query.Get("actor"),
+		Action:   query.Get("action"),
+	}
+
+	for param, value := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if s := query.Get(param); s != "" {
+			if *value, err = time.Parse(time.RFC3339, s); err != nil {
+				h.Error(w, r, BadRequest("invalid "+param+" parameter: must be an RFC 3339 time"))
+				return
+			}
+		}
+	}
+
+	res, err := h.Models.Audit.Find(r.Context(), filter)
+	if err != nil {
+		h.Error(w, r, err)
+		return
+	}
+
+	page, err := pagination.Paginate[models.AuditEntry](res, params)
+	if err != nil {
+		h.Error(w, r, err)
+		return
+	}
+
+	if err := pagination.WriteJSON(w, r, page); err != nil {
+		h.App.Log.Error("error writing response:", err)
+	}
+}
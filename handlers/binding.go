@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"myapp/views"
+
+	"github.com/CloudyKit/jet/v6"
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	// maxJSONBody caps the size of a JSON request body read by Bind.
+	maxJSONBody = 1 << 20
+
+	// maxMultipartMemory is the part of a multipart body kept in memory; the rest of the
+	// files are written to temporary files.
+	maxMultipartMemory = 32 << 20
+)
+
+// BindError reports a request body that could not be decoded at all, such as malformed
+// JSON or an unsupported content type. It is answered with 400 rather than field errors.
+type BindError struct {
+	Status  int
+	Message string
+}
+
+func (e *BindError) Error() string {
+	return e.Message
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	fileHeaderType = reflect.TypeOf(&multipart.FileHeader{})
+)
+
+// Bind decodes the input of a request into the struct pointed to by dst and validates it.
+// The body is decoded according to its content type: JSON, URL-encoded forms and multipart
+// forms (including files). Requests without a body (GET, HEAD, DELETE) bind the query
+// string. Form and query values are matched by the form tag of a field, then its json tag,
+// then its name; uploaded files bind to *multipart.FileHeader or []*multipart.FileHeader
+// fields.
+//
+// The error is a *BindError when the body can not be decoded, or ValidationErrors when
+// values are missing, of the wrong type or break a validate rule. Either can be handed to
+// BindFailed to answer the request.
+//
+// Example:
+//
+//	type SignupInput struct {
+//		Email    string `form:"email" json:"email" validate:"required,email"`
+//		Password string `form:"password" json:"password" validate:"required,min=12"`
+//		Confirm  string `form:"confirm" json:"confirm" validate:"eqfield=Password"`
+//	}
+//
+//	var input SignupInput
+//	if err := h.Bind(r, &input); err != nil {
+//		h.BindFailed(w, r, "signup", err, nil)
+//		return
+//	}
+func (h *Handlers) Bind(r *http.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("handlers: Bind needs a pointer to a struct, got %T", dst)
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		err = bindValues(v.Elem(), r.URL.Query(), nil)
+	default:
+		err = bindBody(r, v.Elem(), dst)
+	}
+
+	// values of the wrong type are reported along with the rules broken by the other fields
+	var errs ValidationErrors
+	if err != nil && !errors.As(err, &errs) {
+		return err
+	}
+
+	var invalid ValidationErrors
+	if err := Validate(dst); errors.As(err, &invalid) {
+		for _, fe := range invalid {
+			if !errs.has(fe.Field) {
+				errs = append(errs, fe)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Decode the body of a request according to its content type.
+func bindBody(r *http.Request, v reflect.Value, dst interface{}) error {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch {
+	case contentType == "application/json" || strings.HasSuffix(contentType, "+json"):
+		return bindJSON(r, dst)
+
+	case contentType == "multipart/form-data":
+		if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+			return &BindError{Status: http.StatusBadRequest, Message: "malformed multipart body"}
+		}
+		return bindValues(v, r.Form, r.MultipartForm.File)
+
+	case contentType == "application/x-www-form-urlencoded" || contentType == "":
+		if err := r.ParseForm(); err != nil {
+			return &BindError{Status: http.StatusBadRequest, Message: "malformed form body"}
+		}
+		return bindValues(v, r.Form, nil)
+
+	default:
+		return &BindError{Status: http.StatusUnsupportedMediaType, Message: fmt.Sprintf("unsupported content type %q", contentType)}
+	}
+}
+
+// Decode a JSON body. A value of the wrong type is reported as an error of its field.
+func bindJSON(r *http.Request, dst interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxJSONBody+1))
+
+	err := decoder.Decode(dst)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return &BindError{Status: http.StatusBadRequest, Message: "request body must be a JSON object"}
+		}
+		return ValidationErrors{{Field: field, Rule: "type", Message: fmt.Sprintf("must be %s", describeType(typeErr.Type))}}
+	case errors.Is(err, io.EOF):
+		return &BindError{Status: http.StatusBadRequest, Message: "request body must not be empty"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &BindError{Status: http.StatusBadRequest, Message: "request body is too large or truncated"}
+	default:
+		return &BindError{Status: http.StatusBadRequest, Message: "request body is not valid JSON"}
+	}
+}
+
+// Set the fields of a struct from form or query values and uploaded files. A value that
+// can not be converted to the type of its field is reported as an error of the field.
+func bindValues(v reflect.Value, values url.Values, files map[string][]*multipart.FileHeader) error {
+	var errs ValidationErrors
+
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := inputName(sf)
+		if name == "-" {
+			continue
+		}
+
+		field := v.Field(i)
+
+		switch {
+		case sf.Type == fileHeaderType:
+			if fh := files[name]; len(fh) > 0 {
+				field.Set(reflect.ValueOf(fh[0]))
+			}
+			continue
+		case sf.Type == reflect.SliceOf(fileHeaderType):
+			if fh := files[name]; len(fh) > 0 {
+				field.Set(reflect.ValueOf(fh))
+			}
+			continue
+		case sf.Anonymous && sf.Type.Kind() == reflect.Struct:
+			if err := bindValues(field, values, files); err != nil {
+				var nested ValidationErrors
+				if !errors.As(err, &nested) {
+					return err
+				}
+				errs = append(errs, nested...)
+			}
+			continue
+		}
+
+		raw, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		if err := setValue(field, raw); err != nil {
+			errs = append(errs, FieldError{Field: name, Rule: "type", Message: err.Error()})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Convert the raw values of an input to the type of the field.
+func setValue(field reflect.Value, raw []string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(raw), len(raw))
+		for i, s := range raw {
+			if err := setScalar(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	if len(raw) == 0 {
+		return nil
+	}
+
+	if field.Kind() == reflect.Pointer {
+		if raw[0] == "" {
+			return nil
+		}
+		ptr := reflect.New(field.Type().Elem())
+		if err := setScalar(ptr.Elem(), raw[0]); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	return setScalar(field, raw[0])
+}
+
+// Convert a single value. Empty strings leave numbers, booleans and times at their zero
+// value so optional inputs can be left blank.
+func setScalar(field reflect.Value, s string) error {
+	if field.Type() == timeType {
+		if s == "" {
+			return nil
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				field.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return errors.New("must be a valid date")
+	}
+
+	if field.Kind() != reflect.String && strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "on", "yes":
+			s = "true"
+		case "off", "no":
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive whole number")
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("can not be bound to %s", field.Type())
+	}
+
+	return nil
+}
+
+// The name of the input a field is bound from: its form tag, then its json tag, then its
+// name.
+func inputName(sf reflect.StructField) string {
+	for _, tag := range []string{"form", "json"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(tag), ","); name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// Describe a Go type in the words used by error messages.
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
+
+// BindFailed answers a request whose input failed to bind. API requests get an RFC 7807
+// problem: 422 listing the field errors, or the status of a *BindError. Web requests get
+// the view rendered again with the errors (views.FormErrors) and the submitted values (old)
+// so the form can be corrected; password inputs are never sent back. Any other error is a
+// 500 and is logged with the request ID. The variables are those the view normally renders
+// with and may be nil.
+func (h *Handlers) BindFailed(w http.ResponseWriter, r *http.Request, view string, err error, variables jet.VarMap) {
+	if isAPIRequest(r) {
+		h.Error(w, r, err)
+		return
+	}
+
+	status := http.StatusUnprocessableEntity
+	formErrors := views.FormErrors{}
+
+	var fieldErrs ValidationErrors
+	var bindErr *BindError
+	switch {
+	case errors.As(err, &fieldErrs):
+		formErrors = views.FormErrors(fieldErrs.Map())
+	case errors.As(err, &bindErr):
+		status = bindErr.Status
+		formErrors["form"] = []string{bindErr.Message}
+	default:
+		status = http.StatusInternalServerError
+		formErrors["form"] = []string{http.StatusText(status)}
+		h.App.Log.WithField("req_id", chimw.GetReqID(r.Context())).WithError(err).Error("request failed")
+	}
+
+	if variables == nil {
+		variables = make(jet.VarMap)
+	}
+	variables.Set("errors", formErrors)
+	variables.Set("old", oldInput(r))
+
+	w.WriteHeader(status)
+	if err := h.Render(w, r, view, variables, nil); err != nil {
+		h.App.Log.Error("error rendering:", err)
+	}
+}
+
+// The submitted form values to fill a form in again, leaving out passwords.
+func oldInput(r *http.Request) url.Values {
+	old := url.Values{}
+	for name, values := range r.Form {
+		if !strings.Contains(strings.ToLower(name), "password") {
+			old[name] = values
+		}
+	}
+	return old
+}
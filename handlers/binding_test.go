@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type signupInput struct {
+	Email    string `form:"email" json:"email" validate:"required,email"`
+	Name     string `form:"name" json:"name" validate:"required,min=2,max=20"`
+	Age      int    `form:"age" json:"age" validate:"min=18"`
+	Password string `form:"password" json:"password" validate:"required,min=8"`
+	Confirm  string `form:"confirm" json:"confirm" validate:"eqfield=Password"`
+	Code     string `form:"code" json:"code" validate:"regex=^[A-Z]{2,3}$"`
+	Plan     string `form:"plan" json:"plan" validate:"oneof=free pro"`
+}
+
+func fieldNames(err error) []string {
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	var names []string
+	for _, fe := range errs {
+		names = append(names, fe.Field)
+	}
+	return names
+}
+
+func TestBind_JSON(t *testing.T) {
+	h := &Handlers{}
+	body := `{"email":"ada@example.com","name":"Ada","age":36,"password":"correct horse","confirm":"correct horse","code":"UK","plan":"pro"}`
+	req := httptest.NewRequest("POST", "/api/signup", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	var input signupInput
+	if err := h.Bind(req, &input); err != nil {
+		t.Fatalf("Expected valid input, got %v", err)
+	}
+	if input.Email != "ada@example.com" || input.Age != 36 {
+		t.Errorf("Unexpected input %+v", input)
+	}
+}
+
+func TestBind_FormErrors(t *testing.T) {
+	h := &Handlers{}
+	form := url.Values{
+		"email":    {"not-an-email"},
+		"name":     {"A"},
+		"age":      {"twelve"},
+		"password": {"short"},
+		"confirm":  {"other"},
+		"code":     {"u,k"},
+		"plan":     {"gold"},
+	}
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var input signupInput
+	err := h.Bind(req, &input)
+
+	want := []string{"age", "email", "name", "password", "confirm", "code", "plan"}
+	if got := fieldNames(err); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected errors for %v, got %v (%v)", want, got, err)
+	}
+}
+
+func TestBind_QueryAndOptionalFields(t *testing.T) {
+	type search struct {
+		Query string   `form:"q" validate:"required"`
+		Tags  []string `form:"tag" validate:"max=2"`
+		Page  *int     `form:"page" validate:"min=1"`
+	}
+
+	h := &Handlers{}
+	var input search
+	if err := h.Bind(httptest.NewRequest("GET", "/search?q=go&tag=a&tag=b", nil), &input); err != nil {
+		t.Fatal(err)
+	}
+	if input.Query != "go" || len(input.Tags) != 2 || input.Page != nil {
+		t.Errorf("Unexpected input %+v", input)
+	}
+
+	input = search{}
+	err := h.Bind(httptest.NewRequest("GET", "/search?tag=a&tag=b&tag=c&page=0", nil), &input)
+	if got := fieldNames(err); !reflect.DeepEqual(got, []string{"q", "tag", "page"}) {
+		t.Errorf("Unexpected errors %v", err)
+	}
+}
+
+func TestBind_Multipart(t *testing.T) {
+	type upload struct {
+		Title  string                `form:"title" validate:"required"`
+		Avatar *multipart.FileHeader `form:"avatar" validate:"required"`
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "Me")
+	fw, _ := mw.CreateFormFile("avatar", "me.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var input upload
+	if err := (&Handlers{}).Bind(req, &input); err != nil {
+		t.Fatal(err)
+	}
+	if input.Title != "Me" || input.Avatar == nil || input.Avatar.Filename != "me.png" {
+		t.Errorf("Unexpected input %+v", input)
+	}
+}
+
+func TestValidate_CustomRule(t *testing.T) {
+	RegisterRule("even", "must be even", func(value reflect.Value, param string) bool {
+		return value.Int()%2 == 0
+	})
+
+	type input struct {
+		N int `json:"n" validate:"even"`
+	}
+
+	if err := Validate(&input{N: 4}); err != nil {
+		t.Errorf("Expected 4 to pass, got %v", err)
+	}
+	if err := Validate(&input{N: 3}); err == nil || !strings.Contains(err.Error(), "must be even") {
+		t.Errorf("Expected 3 to fail, got %v", err)
+	}
+}
+
+func TestBindFailed_Problem(t *testing.T) {
+	h := &Handlers{}
+
+	req := httptest.NewRequest("POST", "/api/signup", strings.NewReader(`{"email":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var input signupInput
+	err := h.Bind(req, &input)
+
+	w := httptest.NewRecorder()
+	h.BindFailed(w, req, "signup", err, nil)
+
+	if w.Code != http.StatusUnprocessableEntity || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("Expected a 422 problem, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var problem Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != 422 || problem.Instance != "/api/signup" || len(problem.Errors) == 0 || problem.Errors[0].Field != "email" {
+		t.Errorf("Unexpected problem %+v", problem)
+	}
+
+	req = httptest.NewRequest("POST", "/api/signup", strings.NewReader(`{"email":`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w = httptest.NewRecorder()
+	h.BindFailed(w, req, "signup", h.Bind(req, &input), nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for malformed JSON, got %d", w.Code)
+	}
+}
+
+func TestValidate_BlankConfirmation(t *testing.T) {
+	type input struct {
+		Password string  `form:"password" validate:"required,min=12"`
+		Confirm  string  `form:"confirm" validate:"eqfield=Password"`
+		Previous *string `form:"previous" validate:"nefield=Password"`
+		Nickname string  `form:"nickname" validate:"min=3"`
+	}
+
+	err := Validate(&input{Password: "correct horse battery"})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "confirm" || errs[0].Rule != "eqfield" {
+		t.Fatalf("Expected a blank confirmation to fail eqfield only, got %v", err)
+	}
+
+	if err := Validate(&input{Password: "correct horse battery", Confirm: "correct horse battery"}); err != nil {
+		t.Errorf("Expected a matching confirmation to pass, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"myapp/models"
+	"myapp/pagination"
+	"myapp/views"
+	"net/http"
+
+	"github.com/CloudyKit/jet/v6"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	upper "github.com/upper/db/v4"
+)
+
+// Error is an application error that knows the HTTP response it maps to. Handlers return
+// one of the constructors below (or wrap one) and hand the error to h.Error, which
+// translates it into an RFC 7807 problem.
+//
+// The errors form a hierarchy by status: errors.Is(err, handlers.ErrNotFound) holds for any
+// *Error with status 404, whatever its detail.
+//
+// Example:
+//
+//	user, err := users.Get(r.Context(), id)
+//	if err != nil {
+//		h.Error(w, r, err) // upper.ErrNoMoreRows becomes a 404 problem
+//		return
+//	}
+//	if user.Locked {
+//		h.Error(w, r, handlers.Forbidden("the account is locked"))
+//		return
+//	}
+type Error struct {
+	// Status is the HTTP status code of the response.
+	Status int
+
+	// Type is a URI reference identifying the problem type (default: about:blank).
+	Type string
+
+	// Title is a short summary of the problem type (default: the status text).
+	Title string
+
+	// Detail explains this occurrence of the problem and is shown to the client.
+	Detail string
+
+	// Fields are the field errors of a request that failed validation.
+	Fields []FieldError
+
+	// Err is the underlying cause; it is logged and only shown to the client in debug mode.
+	Err error
+}
+
+// The status classes of the hierarchy, for use with errors.Is.
+var (
+	ErrBadRequest         = &Error{Status: http.StatusBadRequest}
+	ErrUnauthorized       = &Error{Status: http.StatusUnauthorized}
+	ErrForbidden          = &Error{Status: http.StatusForbidden}
+	ErrNotFound           = &Error{Status: http.StatusNotFound}
+	ErrMethodNotAllowed   = &Error{Status: http.StatusMethodNotAllowed}
+	ErrNotAcceptable      = &Error{Status: http.StatusNotAcceptable}
+	ErrConflict           = &Error{Status: http.StatusConflict}
+	ErrGone               = &Error{Status: http.StatusGone}
+	ErrUnprocessable      = &Error{Status: http.StatusUnprocessableEntity}
+	ErrTooManyRequests    = &Error{Status: http.StatusTooManyRequests}
+	ErrInternal           = &Error{Status: http.StatusInternalServerError}
+	ErrServiceUnavailable = &Error{Status: http.StatusServiceUnavailable}
+)
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, e.title())
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same status, so the status classes above can be used as
+// targets of errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Status == e.Status
+}
+
+func (e *Error) title() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	return http.StatusText(e.Status)
+}
+
+// BadRequest is a request the client got wrong.
+func BadRequest(detail string) *Error {
+	return &Error{Status: http.StatusBadRequest, Detail: detail}
+}
+
+// Unauthorized is a request that needs authentication.
+func Unauthorized(detail string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Detail: detail}
+}
+
+// Forbidden is a request the principal may not make.
+func Forbidden(detail string) *Error {
+	return &Error{Status: http.StatusForbidden, Detail: detail}
+}
+
+// NotFound is a request for something that does not exist.
+func NotFound(detail string) *Error {
+	return &Error{Status: http.StatusNotFound, Detail: detail}
+}
+
+// Conflict is a request that conflicts with the current state, e.g., a duplicate.
+func Conflict(detail string) *Error {
+	return &Error{Status: http.StatusConflict, Detail: detail}
+}
+
+// Unprocessable is a well-formed request with invalid input.
+func Unprocessable(detail string, fields ...FieldError) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Detail: detail, Fields: fields}
+}
+
+// TooManyRequests is a request over a rate limit.
+func TooManyRequests(detail string) *Error {
+	return &Error{Status: http.StatusTooManyRequests, Detail: detail}
+}
+
+// ServiceUnavailable is a request that can not be served right now.
+func ServiceUnavailable(detail string) *Error {
+	return &Error{Status: http.StatusServiceUnavailable, Detail: detail}
+}
+
+// Internal wraps an unexpected error. The cause is logged and only shown to the client in
+// debug mode.
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Err: err}
+}
+
+// Error answers the request with the problem the error translates to. This is the single
+// place errors become responses, so every handler answers failures the same way. API
+// clients get an RFC 7807 problem document; browsers get the view for the status from
+// resources/views/errors (e.g., errors/404.jet), or errors/500.jet for a server error
+// without a view of its own.
+//
+// Errors are translated as follows:
+//
+//	*Error                  its status, title and detail
+//	ValidationErrors        422 listing the field errors
+//	*BindError              its status and message
+//	*pagination.ParamError  400
+//	upper.ErrNoMoreRows     404
+//	models.ErrTenantRequired, models.ErrTenantMismatch  404 (the row is not the tenant's)
+//	models.ErrNoDatabase    503
+//	anything else           500, with the cause logged and only shown in debug mode
+//
+// Server errors (5xx) are logged with the request ID that is also sent to the client.
+func (h *Handlers) Error(w http.ResponseWriter, r *http.Request, err error) {
+	problem := h.problemFor(err)
+
+	if problem.Status >= http.StatusInternalServerError && h.App != nil {
+		h.App.Log.WithField("req_id", chimw.GetReqID(r.Context())).WithError(err).Error("request failed")
+	}
+
+	if isAPIRequest(r) || h.App == nil {
+		h.WriteProblem(w, r, problem)
+		return
+	}
+
+	h.errorPage(w, r, problem)
+}
+
+// Render the error view for the status of a problem. The view receives the problem as
+// status, title, detail and requestID, and no flash messages (see views.IsPartial).
+func (h *Handlers) errorPage(w http.ResponseWriter, r *http.Request, problem *Problem) {
+	view := fmt.Sprintf("errors/%d", problem.Status)
+	if !h.hasView(view) {
+		view = "errors/500"
+		if problem.Status < http.StatusInternalServerError || !h.hasView(view) {
+			http.Error(w, http.StatusText(problem.Status), problem.Status)
+			return
+		}
+	}
+
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("status", problem.Status)
+	vars.Set("title", problem.Title)
+	vars.Set("detail", problem.Detail)
+	vars.Set("requestID", chimw.GetReqID(r.Context()))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(problem.Status)
+	// not a full page, so the flash messages stay for the next one
+	if err := h.Render(w, views.AsPartial(r), view, vars, nil); err != nil {
+		h.App.Log.Error("error rendering:", err)
+	}
+}
+
+// Report whether the view exists.
+func (h *Handlers) hasView(view string) bool {
+	if h.App.JetViews == nil {
+		return false
+	}
+	_, err := h.App.JetViews.GetTemplate(view + ".jet")
+	return err == nil
+}
+
+// Translate an error into a problem.
+func (h *Handlers) problemFor(err error) *Problem {
+	var (
+		appErr   *Error
+		fieldErr ValidationErrors
+		bindErr  *BindError
+		paramErr *pagination.ParamError
+	)
+
+	switch {
+	case errors.As(err, &appErr):
+		problem := &Problem{Status: appErr.Status, Type: appErr.Type, Title: appErr.Title, Detail: appErr.Detail, Errors: appErr.Fields}
+		if appErr.Status >= http.StatusInternalServerError && problem.Detail == "" && h.App != nil && h.App.Debug && appErr.Err != nil {
+			problem.Detail = appErr.Err.Error()
+		}
+		if len(problem.Errors) > 0 && problem.Type == "" {
+			problem.Type = validationProblemType
+			problem.Title = validationProblemTitle
+		}
+		return problem
+
+	case errors.As(err, &fieldErr):
+		return &Problem{Status: http.StatusUnprocessableEntity, Type: validationProblemType, Title: validationProblemTitle, Errors: fieldErr}
+
+	case errors.As(err, &bindErr):
+		return &Problem{Status: bindErr.Status, Detail: bindErr.Message}
+
+	case errors.As(err, &paramErr):
+		return &Problem{Status: http.StatusBadRequest, Detail: paramErr.Error()}
+
+	case errors.Is(err, upper.ErrNoMoreRows), errors.Is(err, models.ErrTenantRequired), errors.Is(err, models.ErrTenantMismatch):
+		return &Problem{Status: http.StatusNotFound}
+
+	case errors.Is(err, models.ErrNoDatabase):
+		return &Problem{Status: http.StatusServiceUnavailable}
+
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Problem{Status: http.StatusGatewayTimeout}
+	}
+
+	problem := &Problem{Status: http.StatusInternalServerError}
+	if h.App != nil && h.App.Debug {
+		problem.Detail = err.Error()
+	}
+	return problem
+}
@@ -2,28 +2,50 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
+	"myapp/flash"
+	"myapp/i18n"
+	"myapp/mail"
+	"myapp/mail/webhook"
 	"myapp/models"
+	"myapp/services"
+	"myapp/views"
 
 	"github.com/cidekar/adele-framework"
 )
 
 type Handlers struct {
-	App    *adele.Adele
-	Models *models.Models
+	App   *adele.Adele
+	Flash *flash.Store
+	Lang  *i18n.Bundle
+	Mail  *mail.Worker
+	// MailWebhooks are the mail services whose delivery events are received, by name.
+	MailWebhooks map[string]webhook.Provider
+	Models       *models.Models
+	// Services are those the providers publish; see the services package.
+	Services *services.Container
+	Views    *views.Registry
 }
 
 func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
-	err := h.App.Helpers.Render(w, r, "home", nil, nil)
+	err := h.Render(w, r, "home", nil, nil)
 	if err != nil {
 		h.App.Log.Error("error rendering:", err)
 	}
 }
 
+// NotFound answers requests that match no route: a problem document for API clients and the
+// errors/404 view for browsers.
 func (h *Handlers) NotFound(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
-	err := h.App.Helpers.Render(w, r, "404", nil, nil)
-	if err != nil {
-		h.App.Log.Error("error rendering:", err)
+	h.Error(w, r, NotFound("no resource matches "+r.URL.Path))
+}
+
+// MethodNotAllowed answers requests that match a route but none of its methods. The Allow
+// header lists the methods the route does support.
+func (h *Handlers) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if allowed := allowedMethods(r); len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
 	}
+	h.Error(w, r, &Error{Status: http.StatusMethodNotAllowed, Detail: r.Method + " is not supported by " + r.URL.Path})
 }
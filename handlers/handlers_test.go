@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMethodNotAllowed_Allow(t *testing.T) {
+	h := &Handlers{}
+
+	api := chi.NewRouter()
+	api.NotFound(h.NotFound)
+	api.MethodNotAllowed(h.MethodNotAllowed)
+	api.Get("/users", func(w http.ResponseWriter, r *http.Request) {})
+	api.Post("/users", func(w http.ResponseWriter, r *http.Request) {})
+
+	r := chi.NewRouter()
+	r.Mount("/api", api)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/users", nil))
+
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("Expected a 405 problem, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, POST" {
+		t.Errorf("Expected Allow: GET, POST, got %q", allow)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/nope", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("Expected a 404 problem, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestIsAPIRequest(t *testing.T) {
+	tests := []struct {
+		path   string
+		accept string
+		api    bool
+	}{
+		{"/api/users", "text/html", true},
+		{"/api", "", true},
+		{"/apiary", "", false},
+		{"/users", "", false},
+		{"/users", "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"/users", "application/json", true},
+		{"/users", "application/problem+json", true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Accept", tt.accept)
+		if got := isAPIRequest(req); got != tt.api {
+			t.Errorf("%s (Accept %q): expected %v, got %v", tt.path, tt.accept, tt.api, got)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// streamFlushEvery is the number of array elements StreamJSON writes between flushes.
+const streamFlushEvery = 100
+
+// WriteJSON writes v as the JSON body of a response with the status. The body is indented
+// in debug mode or when the request asks for it with ?pretty=true. A request whose Accept
+// header rules out JSON is answered with 406 instead.
+//
+// Example:
+//
+//	h.WriteJSON(w, r, http.StatusCreated, user)
+func (h *Handlers) WriteJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	if !acceptsJSON(r) {
+		h.Error(w, r, &Error{Status: http.StatusNotAcceptable, Detail: "this resource is only available as application/json"})
+		return nil
+	}
+
+	var (
+		body []byte
+		err  error
+	)
+	if h.prettyJSON(r) {
+		body, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		body, err = json.Marshal(v)
+	}
+	if err != nil {
+		h.Error(w, r, Internal(err))
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)+1))
+	w.WriteHeader(status)
+
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// StreamJSON writes a JSON array element by element, so a large result is sent as it is
+// read instead of being held in memory. The function passed in calls send for each element;
+// the response is flushed every few elements. Once the first byte is written the status can
+// no longer change, so when fn returns an error the array is left unterminated and the
+// error is logged; clients see an invalid body rather than a silently truncated list.
+//
+// Example:
+//
+//	h.StreamJSON(w, r, http.StatusOK, func(send func(v interface{}) error) error {
+//		var user User
+//		for iter.Next(&user) {
+//			if err := send(user); err != nil {
+//				return err
+//			}
+//		}
+//		return iter.Err()
+//	})
+func (h *Handlers) StreamJSON(w http.ResponseWriter, r *http.Request, status int, fn func(send func(v interface{}) error) error) error {
+	if !acceptsJSON(r) {
+		h.Error(w, r, &Error{Status: http.StatusNotAcceptable, Detail: "this resource is only available as application/json"})
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	rc := http.NewResponseController(w)
+	pretty := h.prettyJSON(r)
+	count := 0
+
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+
+	err := fn(func(v interface{}) error {
+		var (
+			b   []byte
+			err error
+		)
+		if pretty {
+			b, err = json.MarshalIndent(v, "  ", "  ")
+		} else {
+			b, err = json.Marshal(v)
+		}
+		if err != nil {
+			return err
+		}
+
+		separator := ","
+		if count == 0 {
+			separator = ""
+		}
+		if pretty {
+			separator += "\n  "
+		}
+
+		if _, err := w.Write(append([]byte(separator), b...)); err != nil {
+			return err
+		}
+
+		if count++; count%streamFlushEvery == 0 {
+			rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if h.App != nil {
+			h.App.Log.WithError(err).Error("error streaming response")
+		}
+		return err
+	}
+
+	end := "]\n"
+	if pretty && count > 0 {
+		end = "\n]\n"
+	}
+	_, err = w.Write([]byte(end))
+	return err
+}
+
+// Report whether the response should be indented.
+func (h *Handlers) prettyJSON(r *http.Request) bool {
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		return pretty
+	}
+	return h.App != nil && h.App.Debug
+}
+
+// Report whether the Accept header of the request allows a JSON response. A request without
+// an Accept header accepts anything.
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch {
+		case mediaType == "*/*", mediaType == "application/*", mediaType == "application/json",
+			strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"):
+			return true
+		}
+	}
+
+	return false
+}
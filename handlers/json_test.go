@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"myapp/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	upper "github.com/upper/db/v4"
+)
+
+func TestWriteJSON_Negotiation(t *testing.T) {
+	h := &Handlers{}
+
+	tests := []struct {
+		accept string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"application/json", http.StatusOK},
+		{"text/html, */*;q=0.8", http.StatusOK},
+		{"application/vnd.api+json", http.StatusOK},
+		{"text/html", http.StatusNotAcceptable},
+		{"application/json;q=0", http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/users", nil)
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+
+		h.WriteJSON(w, req, http.StatusOK, map[string]int{"id": 1})
+		if w.Code != tt.status {
+			t.Errorf("Accept %q: expected %d, got %d", tt.accept, tt.status, w.Code)
+		}
+	}
+}
+
+func TestWriteJSON_Pretty(t *testing.T) {
+	h := &Handlers{}
+	w := httptest.NewRecorder()
+
+	h.WriteJSON(w, httptest.NewRequest("GET", "/api/users?pretty=true", nil), http.StatusOK, map[string]int{"id": 1})
+	if w.Body.String() != "{\n  \"id\": 1\n}\n" {
+		t.Errorf("Expected an indented body, got %q", w.Body.String())
+	}
+}
+
+func TestStreamJSON(t *testing.T) {
+	h := &Handlers{}
+
+	for _, pretty := range []string{"false", "true"} {
+		w := httptest.NewRecorder()
+		err := h.StreamJSON(w, httptest.NewRequest("GET", "/api/items?pretty="+pretty, nil), http.StatusOK, func(send func(v interface{}) error) error {
+			for i := 0; i < 250; i++ {
+				if err := send(map[string]int{"n": i}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var items []map[string]int
+		if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+			t.Fatalf("Expected a valid JSON array (pretty=%s), got %v", pretty, err)
+		}
+		if len(items) != 250 || items[249]["n"] != 249 {
+			t.Errorf("Unexpected items (pretty=%s): %d", pretty, len(items))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.StreamJSON(w, httptest.NewRequest("GET", "/api/items", nil), http.StatusOK, func(send func(v interface{}) error) error {
+		return nil
+	})
+	if w.Body.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %q", w.Body.String())
+	}
+}
+
+func TestError_Translation(t *testing.T) {
+	h := &Handlers{}
+
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{NotFound("no such user"), http.StatusNotFound},
+		{fmt.Errorf("loading: %w", Conflict("duplicate email")), http.StatusConflict},
+		{ValidationErrors{{Field: "email", Rule: "required", Message: "is required"}}, http.StatusUnprocessableEntity},
+		{&BindError{Status: http.StatusUnsupportedMediaType, Message: "unsupported"}, http.StatusUnsupportedMediaType},
+		{fmt.Errorf("get: %w", upper.ErrNoMoreRows), http.StatusNotFound},
+		{models.ErrNoDatabase, http.StatusServiceUnavailable},
+		{errors.New("disk on fire"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/users/1", nil)
+		w := httptest.NewRecorder()
+		h.Error(w, req, tt.err)
+
+		var problem Problem
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tt.status || problem.Status != tt.status {
+			t.Errorf("%v: expected %d, got %d", tt.err, tt.status, w.Code)
+		}
+		if strings.Contains(problem.Detail, "disk on fire") {
+			t.Errorf("Expected the cause of a server error to be hidden, got %q", problem.Detail)
+		}
+	}
+}
+
+func TestError_Hierarchy(t *testing.T) {
+	err := fmt.Errorf("handler: %w", NotFound("no such user"))
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("Expected a not found error to match ErrNotFound")
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Error("Expected a not found error not to match ErrConflict")
+	}
+
+	cause := errors.New("timeout")
+	if !errors.Is(Internal(cause), cause) {
+		t.Error("Expected an internal error to unwrap to its cause")
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"myapp/mail/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// The largest webhook request read; providers batch events well below it.
+const maxWebhookBody = 5 << 20
+
+// MailWebhook receives the delivery events a mail service posts: the request is verified
+// with the provider's signature, its events are recorded, and hard bounces, complaints and
+// unsubscribes put their recipients on the suppression list. A provider that is not
+// configured is not found. Events already recorded are skipped, so a provider retrying a
+// request is answered with success.
+//
+// Example:
+//
+//	POST /api/webhooks/mail/mailgun
+func (h *Handlers) MailWebhook(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := h.MailWebhooks[name]
+	if !ok {
+		h.Error(w, r, NotFound("no mail webhook for "+name))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.Error(w, r, &Error{Status: http.StatusRequestEntityTooLarge, Detail: "the request body is too large"})
+			return
+		}
+		h.Error(w, r, BadRequest("the request body could not be read"))
+		return
+	}
+
+	if err := provider.Verify(r.Header, body); err != nil {
+		h.Error(w, r, Unauthorized(err.Error()))
+		return
+	}
+
+	events, err := provider.Parse(body)
+	if err != nil {
+		h.Error(w, r, BadRequest("the events could not be read: "+err.Error()))
+		return
+	}
+
+	recorded, err := h.Models.MailEvents.Record(r.Context(), events)
+	if err != nil {
+		h.Error(w, r, err)
+		return
+	}
+
+	// only now, so a request retried after a failure is not refused as a replay
+	if c, ok := provider.(webhook.Committer); ok {
+		c.Commit(r.Header, body)
+	}
+
+	h.WriteJSON(w, r, http.StatusOK, map[string]int{"received": len(events), "recorded": recorded})
+}
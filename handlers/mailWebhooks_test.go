@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/mail/webhook"
+	"myapp/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/upper/db/v4/adapter/sqlite"
+)
+
+func TestMailWebhook_Refuses(t *testing.T) {
+	h := &Handlers{MailWebhooks: map[string]webhook.Provider{
+		"sparkpost": &webhook.SparkPost{Username: "sparkpost", Password: "s3cret"},
+	}}
+
+	api := chi.NewRouter()
+	api.Post("/webhooks/mail/{provider}", h.MailWebhook)
+	r := chi.NewRouter()
+	r.Mount("/api", api)
+
+	tests := []struct {
+		name     string
+		provider string
+		password string
+		status   int
+	}{
+		{"an unconfigured provider", "mailgun", "s3cret", http.StatusNotFound},
+		{"a wrong password", "sparkpost", "guess", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/webhooks/mail/"+tt.provider, strings.NewReader(`[{"msys": {}}]`))
+		req.SetBasicAuth("sparkpost", tt.password)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.status || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: expected a %d problem, got %d %s", tt.name, tt.status, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestMailWebhook_RetryAfterFailure(t *testing.T) {
+	sess, err := sqlite.Open(sqlite.ConnectionURL{Database: filepath.Join(t.TempDir(), "mail_events.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	sessions := models.Sessions
+	models.Sessions = models.NewSessionRouter(sess, "sqlite")
+	defer func() { models.Sessions = sessions }()
+
+	body, err := os.ReadFile(filepath.Join("..", "mail", "webhook", "testdata", "mailgun_bounce.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handlers{
+		Models: &models.Models{MailEvents: models.NewMailEvents()},
+		MailWebhooks: map[string]webhook.Provider{"mailgun": &webhook.Mailgun{
+			SigningKey: "key-3ax6xnjp29jd6fds4gc373sgvjxteol0",
+			Now:        func() time.Time { return time.Unix(1760886060, 0) },
+		}},
+	}
+	r := chi.NewRouter()
+	r.Post("/api/webhooks/mail/{provider}", h.MailWebhook)
+
+	post := func() int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/api/webhooks/mail/mailgun", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	// the tables are missing, so recording the events fails
+	if code := post(); code != http.StatusInternalServerError {
+		t.Fatalf("Expected the first delivery to fail, got %d", code)
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE mail_events (id INTEGER PRIMARY KEY AUTOINCREMENT, provider TEXT NOT NULL,
+			event_id TEXT NOT NULL DEFAULT '', type TEXT NOT NULL, permanent BOOLEAN NOT NULL DEFAULT FALSE,
+			recipient TEXT NOT NULL, reason TEXT NOT NULL DEFAULT '', message_id TEXT NOT NULL DEFAULT '',
+			payload TEXT NOT NULL DEFAULT '{}', occurred_at DATETIME NOT NULL, created_at DATETIME NOT NULL)`,
+		`CREATE TABLE mail_suppressions (email TEXT PRIMARY KEY, reason TEXT NOT NULL, detail TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL)`,
+	} {
+		if _, err := sess.SQL().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("Expected Mailgun retrying the request to be served, got %d", code)
+	}
+	if s, err := models.NewMailSuppressions().Get(context.Background(), "alice@example.com"); err != nil || s == nil {
+		t.Errorf("Expected the bounce of the retried request to be recorded, got %v, %v", s, err)
+	}
+
+	if code := post(); code != http.StatusUnauthorized {
+		t.Errorf("Expected the request sent again once recorded to be refused, got %d", code)
+	}
+}
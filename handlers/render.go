@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+
+	"myapp/i18n"
+	"myapp/views"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/cidekar/adele-framework/render"
+	"github.com/justinas/nosurf"
+)
+
+// Render renders a view like Helpers.Render, with the data of the template data providers
+// registered in h.Views merged into the variables (see views.Registry and the providers
+// registered in views.go). Variables already set by the caller win, so BindFailed can render
+// a form with the errors of the current request.
+//
+// Requests for a fragment (?fragment=<block>, or an HTMX request; see views.Fragment) get
+// just that block of the view, rendered with the same variables, so one handler serves both
+// the page and the pieces of it HTMX swaps in.
+func (h *Handlers) Render(w http.ResponseWriter, r *http.Request, view string, variables jet.VarMap, data interface{}) error {
+	if variables == nil {
+		variables = make(jet.VarMap)
+	}
+
+	if h.Views != nil {
+		h.Views.Apply(r, variables)
+	}
+
+	w.Header().Add("Vary", "HX-Request")
+
+	if block, targeted := views.Fragment(r); block != "" {
+		return h.renderFragment(w, r, view, block, targeted, variables, data)
+	}
+
+	return h.App.Helpers.Render(w, r, view, variables, data)
+}
+
+// Render a single block of a view. A block named by HX-Target that the view does not have
+// falls back to the page content; a block named by the fragment parameter is a 404.
+func (h *Handlers) renderFragment(w http.ResponseWriter, r *http.Request, view, block string, targeted bool, variables jet.VarMap, data interface{}) error {
+	variables.Set("view", view)
+	variables.Set("path", r.URL.Path)
+
+	td := &render.TemplateData{}
+	if data != nil {
+		td = data.(*render.TemplateData)
+	}
+	td.CSRFToken = nosurf.Token(r)
+	if h.App.Session != nil {
+		td.IsAuthenticated = h.App.Session.Exists(r.Context(), "userID")
+	}
+
+	var buf bytes.Buffer
+	err := h.executeFragment(&buf, view, block, variables, td)
+	if views.IsUnknownBlock(err) && targeted {
+		buf.Reset()
+		err = h.executeFragment(&buf, view, views.DefaultFragment, variables, td)
+	}
+	if views.IsUnknownBlock(err) {
+		// answered plainly: an error view rendered for the same request would ask for the
+		// same fragment
+		http.Error(w, "the view has no fragment "+block, http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (h *Handlers) executeFragment(buf *bytes.Buffer, view, block string, variables jet.VarMap, td *render.TemplateData) error {
+	t, err := views.FragmentTemplate(h.App.JetViews, view, block)
+	if err != nil {
+		return err
+	}
+	return t.Execute(buf, variables, td)
+}
+
+// Redirect redirects the client to a URL. HTMX requests are told to load the URL with
+// HX-Redirect instead, since HTMX would otherwise follow the redirect and swap the whole
+// page into the target element.
+func (h *Handlers) Redirect(w http.ResponseWriter, r *http.Request, url string, status int) {
+	if views.IsHTMX(r) {
+		views.HXRedirect(w, url)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, url, status)
+}
+
+// T translates a message into the locale of the request (see middleware.Locale), e.g., for a
+// flash message:
+//
+//	h.Flash.Success(r.Context(), h.T(r, "profile.saved"))
+func (h *Handlers) T(r *http.Request, key string, args ...interface{}) string {
+	if h.Lang == nil {
+		return key
+	}
+	locale := i18n.LocaleFromContext(r.Context())
+	if locale == "" {
+		locale = h.Lang.Fallback()
+	}
+	return h.Lang.T(locale, key, args...)
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError is a single input that failed to bind or validate. Field is the name of the
+// input (its form or json name) so errors can be shown next to the input they belong to.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors are the field errors of a request, in the order of the struct fields.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	var parts []string
+	for _, fe := range e {
+		parts = append(parts, fe.Field+" "+fe.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Map groups the messages by input name.
+func (e ValidationErrors) Map() map[string][]string {
+	m := make(map[string][]string, len(e))
+	for _, fe := range e {
+		m[fe.Field] = append(m[fe.Field], fe.Message)
+	}
+	return m
+}
+
+// Report whether the input has an error.
+func (e ValidationErrors) has(field string) bool {
+	for _, fe := range e {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleFunc reports whether a value satisfies a custom rule. The param is the text after
+// the = of the rule in the validate tag and is empty when there is none.
+type RuleFunc func(value reflect.Value, param string) bool
+
+type customRule struct {
+	fn      RuleFunc
+	message string
+}
+
+var (
+	customRules sync.Map
+	patterns    sync.Map
+)
+
+// RegisterRule adds a custom rule usable by name in validate tags. The message is shown when
+// the rule fails; %s is replaced with the param of the rule.
+//
+// Example:
+//
+//	handlers.RegisterRule("slug", "may only contain lowercase letters, digits and dashes",
+//		func(value reflect.Value, param string) bool {
+//			return regexp.MustCompile(`^[a-z0-9-]+$`).MatchString(value.String())
+//		})
+//
+//	Slug string `form:"slug" validate:"required,slug"`
+func RegisterRule(name, message string, fn RuleFunc) {
+	customRules.Store(name, customRule{fn: fn, message: message})
+}
+
+// Validate checks the fields of the struct pointed to by v against their validate tags and
+// returns ValidationErrors listing every failure, or nil. Rules are separated by commas:
+//
+//	required          the value must not be empty (zero, blank string, empty list, nil)
+//	min=N, max=N      the length of a string or list, or the value of a number
+//	email             a valid email address
+//	regex=PATTERN     the string must match the pattern; the rule takes the rest of the tag,
+//	                  so it must be the last rule
+//	eqfield=Field     the value must equal the value of another field (e.g., confirmation)
+//	nefield=Field     the value must differ from the value of another field
+//	gtfield=Field     the value must be greater than the value of another field
+//	ltfield=Field     the value must be less than the value of another field
+//	oneof=a b c       the value must be one of the space-separated options
+//	<name>            a custom rule added with RegisterRule
+//
+// A field that is empty and not required is not checked against its other rules, except
+// those comparing it with another field: a blank confirmation does not match a password.
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("handlers: Validate needs a struct, got %T", v)
+	}
+
+	if errs := validateStruct(rv); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value) ValidationErrors {
+	var errs ValidationErrors
+
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			errs = append(errs, validateStruct(v.Field(i))...)
+			continue
+		}
+
+		tag := sf.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := inputName(sf)
+		field := v.Field(i)
+		empty := isEmpty(field)
+
+		for _, rule := range splitRules(tag) {
+			rule, param, _ := strings.Cut(rule, "=")
+
+			if rule == "required" {
+				if empty {
+					errs = append(errs, FieldError{Field: name, Rule: rule, Message: "is required"})
+					break
+				}
+				continue
+			}
+
+			if empty && !crossFieldRules[rule] {
+				continue
+			}
+
+			if message, ok := checkRule(v, field, rule, param); !ok {
+				errs = append(errs, FieldError{Field: name, Rule: rule, Param: param, Message: message})
+				break
+			}
+		}
+	}
+
+	return errs
+}
+
+// The rules comparing a value with another field, checked even when the value is empty.
+var crossFieldRules = map[string]bool{"eqfield": true, "nefield": true, "gtfield": true, "ltfield": true}
+
+// Split a validate tag into rules. A regex rule takes the rest of the tag since the
+// pattern may contain commas.
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		rule, rest, _ := strings.Cut(tag, ",")
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+		tag = rest
+	}
+	return rules
+}
+
+// Check a value against a rule and return the message to show when it fails.
+func checkRule(parent, field reflect.Value, rule, param string) (string, bool) {
+	value := reflect.Indirect(field)
+
+	switch rule {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("handlers: invalid %s rule %q", rule, param))
+		}
+		size, unit := measure(value)
+		bound := "at least"
+		if rule == "max" {
+			bound = "at most"
+		}
+		if (rule == "min" && size < limit) || (rule == "max" && size > limit) {
+			switch unit {
+			case "characters":
+				return fmt.Sprintf("must be %s %s characters", bound, param), false
+			case "items":
+				return fmt.Sprintf("must have %s %s items", bound, param), false
+			default:
+				return fmt.Sprintf("must be %s %s", bound, param), false
+			}
+		}
+		return "", true
+
+	case "email":
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Address != value.String() {
+			return "must be a valid email address", false
+		}
+		return "", true
+
+	case "regex":
+		if !compilePattern(param).MatchString(value.String()) {
+			return "has an invalid format", false
+		}
+		return "", true
+
+	case "oneof":
+		s := fmt.Sprint(value.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return "", true
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(param), ", "), false
+
+	case "eqfield", "nefield", "gtfield", "ltfield":
+		other := parent.FieldByName(param)
+		if !other.IsValid() {
+			panic(fmt.Sprintf("handlers: %s rule names unknown field %q", rule, param))
+		}
+		otherName := inputName(mustField(parent.Type(), param))
+		if !value.IsValid() {
+			// a nil pointer: compared as the zero value
+			value = reflect.Zero(field.Type().Elem())
+		}
+		if other = reflect.Indirect(other); !other.IsValid() {
+			// the other field is a nil pointer: nothing can equal it
+			if rule == "eqfield" {
+				return "must match " + otherName, false
+			}
+			return "", true
+		}
+		return compareFields(rule, value, other, otherName)
+	}
+
+	custom, ok := customRules.Load(rule)
+	if !ok {
+		panic(fmt.Sprintf("handlers: unknown validation rule %q", rule))
+	}
+	if !custom.(customRule).fn(value, param) {
+		return strings.ReplaceAll(custom.(customRule).message, "%s", param), false
+	}
+	return "", true
+}
+
+// Compare a value with the value of another field.
+func compareFields(rule string, value, other reflect.Value, otherName string) (string, bool) {
+	switch rule {
+	case "eqfield":
+		if !reflect.DeepEqual(value.Interface(), other.Interface()) {
+			return "must match " + otherName, false
+		}
+	case "nefield":
+		if reflect.DeepEqual(value.Interface(), other.Interface()) {
+			return "must differ from " + otherName, false
+		}
+	default:
+		a, _ := measure(value)
+		b, _ := measure(other)
+		if rule == "gtfield" && !(a > b) {
+			return "must be greater than " + otherName, false
+		}
+		if rule == "ltfield" && !(a < b) {
+			return "must be less than " + otherName, false
+		}
+	}
+	return "", true
+}
+
+// Measure a value for min, max and the field comparisons: the length of strings and lists,
+// the value of numbers and times. The second result names the unit of a length.
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return float64(t.UnixNano()), ""
+	}
+
+	return 0, ""
+}
+
+// Report whether a value is empty for the required rule.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+// Compile a pattern of a regex rule once.
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patterns.Store(pattern, re)
+	return re
+}
+
+func mustField(t reflect.Type, name string) reflect.StructField {
+	sf, _ := t.FieldByName(name)
+	return sf
+}
@@ -0,0 +1,185 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats are how a locale writes dates and numbers, set by the _formats key of its catalog.
+// Layouts are Go time layouts. Months and Days, when given, replace the English names
+// written by the January, Jan, Monday and Mon elements of a layout; the short names are the
+// first three letters of the long ones unless ShortMonths and ShortDays are given.
+//
+// Example:
+//
+//	_formats:
+//	  date: 02/01/2006
+//	  time: "15:04"
+//	  datetime: 02/01/2006 15:04
+//	  long: 2 January 2006
+//	  decimal: ","
+//	  thousands: " "
+//	  months: [janvier, février, mars, avril, mai, juin, juillet, août, septembre, octobre, novembre, décembre]
+//	  days: [dimanche, lundi, mardi, mercredi, jeudi, vendredi, samedi]
+type Formats struct {
+	Date        string
+	Time        string
+	DateTime    string
+	Long        string
+	Decimal     string
+	Thousands   string
+	Months      []string
+	ShortMonths []string
+	Days        []string
+	ShortDays   []string
+}
+
+var defaultFormats = Formats{
+	Date:      "01/02/2006",
+	Time:      "3:04 PM",
+	DateTime:  "01/02/2006 3:04 PM",
+	Long:      "January 2, 2006",
+	Decimal:   ".",
+	Thousands: ",",
+}
+
+// Decode the _formats of a catalog over the current formats.
+func (f *Formats) decode(value interface{}) error {
+	values, ok := value.(map[string]interface{})
+	if m, isAny := value.(map[interface{}]interface{}); isAny {
+		values, ok = stringKeys(m), true
+	}
+	if !ok {
+		return fmt.Errorf("%s must be a map", FormatsKey)
+	}
+
+	layouts := map[string]*string{
+		"date": &f.Date, "time": &f.Time, "datetime": &f.DateTime, "long": &f.Long,
+		"decimal": &f.Decimal, "thousands": &f.Thousands,
+	}
+	names := map[string]*[]string{
+		"months": &f.Months, "short_months": &f.ShortMonths, "days": &f.Days, "short_days": &f.ShortDays,
+	}
+
+	for key, v := range values {
+		if p, ok := layouts[key]; ok {
+			*p = fmt.Sprint(v)
+			continue
+		}
+		p, ok := names[key]
+		if !ok {
+			return fmt.Errorf("%s has no format %q", FormatsKey, key)
+		}
+		list, _ := v.([]interface{})
+		want := 12
+		if strings.HasSuffix(key, "days") {
+			want = 7
+		}
+		if len(list) != want {
+			return fmt.Errorf("%s.%s must list %d names", FormatsKey, key, want)
+		}
+		*p = make([]string, len(list))
+		for i, name := range list {
+			(*p)[i] = fmt.Sprint(name)
+		}
+	}
+	return nil
+}
+
+// FormatNumber formats a number with the separators of the locale. A negative number of decimals
+// writes as many as the number needs.
+func (f Formats) FormatNumber(n float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(n), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if n < 0 {
+		b.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.Thousands)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(f.Decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// FormatTime formats a time with one of the layouts of the locale: "date", "time", "datetime" or
+// "long". Any other style is used as the layout itself.
+func (f Formats) FormatTime(t time.Time, style string) string {
+	layout := style
+	switch style {
+	case "", "date":
+		layout = f.Date
+	case "time":
+		layout = f.Time
+	case "datetime":
+		layout = f.DateTime
+	case "long":
+		layout = f.Long
+	}
+
+	if f.Months == nil && f.Days == nil {
+		return t.Format(layout)
+	}
+
+	// Format the layout in parts, writing the names of the locale for the name elements.
+	var b strings.Builder
+	for layout != "" {
+		i, element := nameElement(layout)
+		if i < 0 {
+			b.WriteString(t.Format(layout))
+			break
+		}
+		b.WriteString(t.Format(layout[:i]))
+		b.WriteString(f.name(t, element))
+		layout = layout[i+len(element):]
+	}
+	return b.String()
+}
+
+// Find the first month or day name element of a layout.
+func nameElement(layout string) (int, string) {
+	for i := 0; i < len(layout); i++ {
+		for _, element := range []string{"January", "Jan", "Monday", "Mon"} {
+			if strings.HasPrefix(layout[i:], element) {
+				return i, element
+			}
+		}
+	}
+	return -1, ""
+}
+
+func (f Formats) name(t time.Time, element string) string {
+	short := func(names []string, i int) string {
+		if r := []rune(names[i]); len(r) > 3 {
+			return string(r[:3])
+		}
+		return names[i]
+	}
+
+	month, day := int(t.Month())-1, int(t.Weekday())
+	switch {
+	case element == "January" && f.Months != nil:
+		return f.Months[month]
+	case element == "Jan" && f.ShortMonths != nil:
+		return f.ShortMonths[month]
+	case element == "Jan" && f.Months != nil:
+		return short(f.Months, month)
+	case element == "Monday" && f.Days != nil:
+		return f.Days[day]
+	case element == "Mon" && f.ShortDays != nil:
+		return f.ShortDays[day]
+	case element == "Mon" && f.Days != nil:
+		return short(f.Days, day)
+	}
+	return t.Format(element)
+}
@@ -0,0 +1,366 @@
+// Package i18n translates the messages of the application. Messages are kept in catalogs,
+// one file per locale in resources/lang named after the locale (en.yml, fr.json, pt-BR.yaml,
+// ...), written in YAML or JSON. Nested keys are joined with dots, so
+//
+//	errors:
+//	  404: The page you are looking for does not exist.
+//	inbox:
+//	  count:
+//	    zero: Your inbox is empty.
+//	    one: You have {count} message.
+//	    other: You have {count} messages.
+//
+// has the keys errors.404 and inbox.count. A message whose value has an other key (and only
+// plural categories as keys) has plural forms, picked by the count argument with the plural
+// rules of the locale (see PluralCategory). Arguments replace {name} placeholders.
+//
+// The _formats key of a catalog holds how the locale formats dates and numbers (see Formats)
+// and is not a message.
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FormatsKey is the key of a catalog holding the formats of its locale.
+const FormatsKey = "_formats"
+
+// Message is a translated message, with plural forms by category when it has any.
+type Message struct {
+	Text   string
+	Plural map[string]string
+}
+
+// Catalog holds the messages of a locale by key.
+type Catalog struct {
+	Locale   string
+	Messages map[string]Message
+	Formats  Formats
+}
+
+// Bundle holds the catalogs of the application. Messages missing from the catalog of a
+// locale are looked up in the catalog of the base language (fr for fr-CA), then in the
+// catalog of the fallback locale.
+type Bundle struct {
+	fallback string
+	catalogs map[string]*Catalog
+
+	// OnMissing is called when a message is missing from every catalog it was looked up in.
+	OnMissing func(locale, key string)
+
+	missing sync.Map
+}
+
+// NewBundle returns a bundle without catalogs. The fallback locale is used for requests in
+// a locale without a catalog, and for messages missing from a catalog.
+func NewBundle(fallback string) *Bundle {
+	return &Bundle{fallback: fallback, catalogs: make(map[string]*Catalog)}
+}
+
+// Load reads every catalog (*.yml, *.yaml and *.json) in a directory into a bundle. A
+// directory that does not exist gives a bundle without catalogs, in which every message is
+// its key.
+func Load(dir, fallback string) (*Bundle, error) {
+	b := NewBundle(fallback)
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("i18n: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml" && ext != ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("i18n: %w", err)
+		}
+
+		var values map[string]interface{}
+		if ext == ".json" {
+			err = json.Unmarshal(data, &values)
+		} else {
+			err = yaml.Unmarshal(data, &values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", entry.Name(), err)
+		}
+
+		locale := strings.TrimSuffix(entry.Name(), ext)
+		if err := b.Add(locale, values); err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", entry.Name(), err)
+		}
+	}
+
+	return b, nil
+}
+
+// Add merges nested messages into the catalog of a locale.
+func (b *Bundle) Add(locale string, values map[string]interface{}) error {
+	c, ok := b.catalogs[locale]
+	if !ok {
+		c = &Catalog{Locale: locale, Messages: make(map[string]Message), Formats: defaultFormats}
+		b.catalogs[locale] = c
+	}
+
+	if formats, ok := values[FormatsKey]; ok {
+		if err := c.Formats.decode(formats); err != nil {
+			return err
+		}
+	}
+
+	return flatten(c.Messages, "", values)
+}
+
+// Flatten nested values into messages keyed by their dotted path.
+func flatten(messages map[string]Message, prefix string, values map[string]interface{}) error {
+	for key, value := range values {
+		if prefix == "" && key == FormatsKey {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if m, ok := value.(map[interface{}]interface{}); ok {
+			value = stringKeys(m)
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if plural, ok := pluralForms(v); ok {
+				messages[key] = Message{Text: plural["other"], Plural: plural}
+				continue
+			}
+			if err := flatten(messages, key, v); err != nil {
+				return err
+			}
+		case nil:
+			continue
+		case []interface{}:
+			return fmt.Errorf("message %s is a list", key)
+		default:
+			messages[key] = Message{Text: fmt.Sprint(v)}
+		}
+	}
+	return nil
+}
+
+// YAML keys that are not strings (e.g., 404) decode into maps with keys of any type.
+func stringKeys(m map[interface{}]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		values[fmt.Sprint(k)] = v
+	}
+	return values
+}
+
+// The plural forms of a message, when the map holds them.
+func pluralForms(values map[string]interface{}) (map[string]string, bool) {
+	if _, ok := values["other"]; !ok {
+		return nil, false
+	}
+	forms := make(map[string]string, len(values))
+	for category, value := range values {
+		text, ok := value.(string)
+		if !ok || !isCategory(category) {
+			return nil, false
+		}
+		forms[category] = text
+	}
+	return forms, true
+}
+
+// Locales returns the locales that have a catalog, the fallback locale first.
+func (b *Bundle) Locales() []string {
+	locales := []string{b.fallback}
+	for locale := range b.catalogs {
+		if locale != b.fallback {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+// Fallback returns the fallback locale.
+func (b *Bundle) Fallback() string {
+	return b.fallback
+}
+
+// Catalog returns the catalog of a locale, or nil when it has none.
+func (b *Bundle) Catalog(locale string) *Catalog {
+	return b.catalogs[locale]
+}
+
+// Has reports whether a message is in the catalog of a locale, its base language or the
+// fallback locale.
+func (b *Bundle) Has(locale, key string) bool {
+	_, ok := b.lookup(locale, key)
+	return ok
+}
+
+// The catalogs a message is looked up in, in order.
+func (b *Bundle) chain(locale string) []*Catalog {
+	var chain []*Catalog
+	base, _, _ := strings.Cut(locale, "-")
+	for _, l := range []string{locale, base, b.fallback} {
+		if c, ok := b.catalogs[l]; ok && (len(chain) == 0 || chain[len(chain)-1] != c) {
+			chain = append(chain, c)
+		}
+	}
+	return chain
+}
+
+func (b *Bundle) lookup(locale, key string) (Message, bool) {
+	for _, c := range b.chain(locale) {
+		if m, ok := c.Messages[key]; ok {
+			return m, true
+		}
+	}
+	return Message{}, false
+}
+
+// Formats returns the formats of a locale: those of its catalog, of its base language or of
+// the fallback locale, in that order.
+func (b *Bundle) Formats(locale string) Formats {
+	if chain := b.chain(locale); len(chain) > 0 {
+		return chain[0].Formats
+	}
+	return defaultFormats
+}
+
+// T translates a message into a locale. The arguments are name and value pairs that replace
+// the {name} placeholders of the message, or a single map of them; a count argument picks
+// the plural form and is formatted as a number of the locale. A missing message is its key.
+//
+// Example:
+//
+//	bundle.T("fr", "inbox.count", "count", 3) // Vous avez 3 messages.
+func (b *Bundle) T(locale, key string, args ...interface{}) string {
+	m, ok := b.lookup(locale, key)
+	if !ok {
+		if _, seen := b.missing.LoadOrStore(locale+"\x00"+key, true); !seen && b.OnMissing != nil {
+			b.OnMissing(locale, key)
+		}
+		return key
+	}
+
+	params := Params(args...)
+	text := m.Text
+	if count, ok := params["count"]; ok && m.Plural != nil {
+		if n, ok := toFloat(count); ok {
+			text = m.form(PluralCategory(locale, n), n)
+			params["count"] = b.Formats(locale).FormatNumber(n, -1)
+		}
+	}
+
+	return interpolate(text, params)
+}
+
+// The plural form of a message for a category. A zero form, when given, is used for a count
+// of zero in every language.
+func (m Message) form(category string, n float64) string {
+	if text, ok := m.Plural["zero"]; ok && n == 0 {
+		return text
+	}
+	if text, ok := m.Plural[category]; ok {
+		return text
+	}
+	return m.Plural["other"]
+}
+
+// Params turns the arguments of T into placeholder values: name and value pairs, or a single
+// map. A trailing name without a value is ignored.
+func Params(args ...interface{}) map[string]interface{} {
+	params := make(map[string]interface{})
+	if len(args) == 1 {
+		switch m := args[0].(type) {
+		case map[string]interface{}:
+			for k, v := range m {
+				params[k] = v
+			}
+			return params
+		case map[string]string:
+			for k, v := range m {
+				params[k] = v
+			}
+			return params
+		}
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		params[fmt.Sprint(args[i])] = args[i+1]
+	}
+	return params
+}
+
+// Replace the {name} placeholders of a message. Unknown placeholders are left as they are.
+func interpolate(text string, params map[string]interface{}) string {
+	if len(params) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+type localeKey struct{}
+
+// WithLocale returns a copy of the context carrying the locale of a request.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext returns the locale of a request (see middleware.Locale), or "" when it
+// was not set.
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
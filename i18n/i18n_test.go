@@ -0,0 +1,150 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func loadTestBundle(t *testing.T) (*Bundle, string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"lang/en.yml": `
+greeting: Hello, {name}!
+errors:
+  404: Not found
+inbox:
+  count:
+    zero: Your inbox is empty.
+    one: You have {count} message.
+    other: You have {count} messages.
+`,
+		"lang/fr.json": `{
+	"_formats": {"decimal": ",", "thousands": " ", "long": "2 January 2006", "months": ["janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"]},
+	"greeting": "Bonjour, {name} !",
+	"inbox": {"count": {"one": "Vous avez {count} message.", "other": "Vous avez {count} messages."}}
+}`,
+		"lang/ru.yml": `
+inbox:
+  count:
+    one: "{count} сообщение"
+    few: "{count} сообщения"
+    many: "{count} сообщений"
+    other: "{count} сообщения"
+`,
+		"views/home.jet": `<h1>{{ t("greeting", "name", user) }}</h1><p>{{ t("home.intro") }}</p>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := Load(filepath.Join(dir, "lang"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, filepath.Join(dir, "views")
+}
+
+func TestT(t *testing.T) {
+	b, _ := loadTestBundle(t)
+
+	tests := []struct {
+		locale, key string
+		args        []interface{}
+		want        string
+	}{
+		{"en", "greeting", []interface{}{"name", "Ada"}, "Hello, Ada!"},
+		{"fr", "greeting", []interface{}{map[string]interface{}{"name": "Ada"}}, "Bonjour, Ada !"},
+		{"fr-CA", "greeting", []interface{}{"name", "Ada"}, "Bonjour, Ada !"},
+		{"fr", "errors.404", nil, "Not found"},
+		{"de", "errors.404", nil, "Not found"},
+		{"en", "nope", nil, "nope"},
+		{"en", "inbox.count", []interface{}{"count", 0}, "Your inbox is empty."},
+		{"en", "inbox.count", []interface{}{"count", 1}, "You have 1 message."},
+		{"en", "inbox.count", []interface{}{"count", 1200}, "You have 1,200 messages."},
+		{"fr", "inbox.count", []interface{}{"count", 0}, "Vous avez 0 message."},
+		{"fr", "inbox.count", []interface{}{"count", 1500}, "Vous avez 1 500 messages."},
+		{"ru", "inbox.count", []interface{}{"count", 21}, "21 сообщение"},
+		{"ru", "inbox.count", []interface{}{"count", 3}, "3 сообщения"},
+		{"ru", "inbox.count", []interface{}{"count", 11}, "11 сообщений"},
+	}
+	for _, tt := range tests {
+		if got := b.T(tt.locale, tt.key, tt.args...); got != tt.want {
+			t.Errorf("T(%q, %q) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	b, _ := loadTestBundle(t)
+	date := time.Date(2026, time.March, 7, 14, 5, 0, 0, time.UTC)
+
+	if got := b.Formats("en").FormatTime(date, "long"); got != "March 7, 2026" {
+		t.Errorf("en long date = %q", got)
+	}
+	if got := b.Formats("fr").FormatTime(date, "long"); got != "7 mars 2026" {
+		t.Errorf("fr long date = %q", got)
+	}
+	if got := b.Formats("fr").FormatTime(date, "Jan 2006"); got != "mar 2026" {
+		t.Errorf("fr short month = %q", got)
+	}
+	if got := b.Formats("en").FormatNumber(-1234567.891, 2); got != "-1,234,567.89" {
+		t.Errorf("en number = %q", got)
+	}
+	if got := b.Formats("fr").FormatNumber(1234.5, -1); got != "1 234,5" {
+		t.Errorf("fr number = %q", got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	b, views := loadTestBundle(t)
+
+	missing, err := Report(b, views)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, m := range missing {
+		got[m.String()] = true
+	}
+	for _, want := range []string{
+		"en: home.intro (home.jet:1)",
+		"fr: errors.404",
+		"fr: home.intro (home.jet:1)",
+		"ru: greeting (home.jet:1)",
+	} {
+		if !got[want] {
+			t.Errorf("report is missing %q, got %v", want, missing)
+		}
+	}
+	if got["fr: greeting"] || got["fr: inbox.count"] {
+		t.Errorf("report lists translated messages: %v", missing)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	supported := []string{"en", "fr", "pt-BR"}
+	tests := map[string]string{
+		"":                        "en",
+		"fr-CA,fr;q=0.9":          "fr",
+		"pt-br":                   "pt-BR",
+		"fr;q=0, de, en-GB;q=0.5": "en",
+		"fr;q=0.1, en;q=0.9":      "en",
+		"de, fr;q=0.5, en;q=0.8":  "en",
+		"de, *;q=0.5, en;q=0.1":   "fr",
+		"en;q=0, *":               "fr",
+		"en-GB, en;q=0, fr;q=0.2": "fr",
+	}
+	for header, want := range tests {
+		if got := Match(header, supported); got != want {
+			t.Errorf("Match(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
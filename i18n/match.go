@@ -0,0 +1,83 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Match picks the language of an Accept-Language header with the highest weight (q) that is
+// supported, matching the base language when the region is not (en-GB matches en). Languages
+// of equal weight keep the order of the header. The wildcard * matches any supported locale
+// the header does not name. Languages with q=0 are refused. With no match it returns the
+// first supported locale.
+func Match(header string, supported []string) string {
+	type language struct {
+		tag    string
+		weight float64
+	}
+
+	var languages []language
+	refused := map[string]bool{}
+	named := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		weight := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			w, err := strconv.ParseFloat(q, 64)
+			if err != nil || w < 0 || w > 1 {
+				continue
+			}
+			weight = w
+		}
+
+		if weight == 0 {
+			refused[strings.ToLower(tag)] = true
+			continue
+		}
+		named[strings.ToLower(tag)] = true
+		languages = append(languages, language{tag, weight})
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].weight > languages[j].weight
+	})
+
+	for _, l := range languages {
+		if l.tag == "*" {
+			for _, s := range supported {
+				if !named[strings.ToLower(s)] && !refused[strings.ToLower(s)] {
+					return s
+				}
+			}
+			continue
+		}
+
+		if s, ok := Supported(l.tag, supported); ok && !refused[strings.ToLower(s)] {
+			return s
+		}
+	}
+	return supported[0]
+}
+
+// Supported returns the supported locale of a language tag, matching the base language when
+// the region is not supported. Tags are compared without regard to case.
+func Supported(tag string, supported []string) (string, bool) {
+	if tag == "" {
+		return "", false
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	for _, candidate := range []string{tag, base} {
+		for _, s := range supported {
+			if strings.EqualFold(s, candidate) {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,160 @@
+package i18n
+
+import (
+	"math"
+	"strings"
+	"sync"
+)
+
+// The plural categories of CLDR.
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// PluralRule returns the plural category of a count.
+type PluralRule func(n float64) string
+
+var (
+	pluralMu    sync.RWMutex
+	pluralRules = map[string]PluralRule{}
+)
+
+func init() {
+	for _, lang := range []string{"ja", "ko", "zh", "th", "vi", "id", "ms"} {
+		pluralRules[lang] = pluralNone
+	}
+	for _, lang := range []string{"fr", "pt"} {
+		pluralRules[lang] = pluralZeroOne
+	}
+	for _, lang := range []string{"ru", "uk", "be", "sr", "hr", "bs"} {
+		pluralRules[lang] = pluralEastSlavic
+	}
+	pluralRules["pl"] = pluralPolish
+	pluralRules["cs"] = pluralCzech
+	pluralRules["sk"] = pluralCzech
+	pluralRules["ar"] = pluralArabic
+}
+
+// RegisterPluralRule sets the plural rule of a language (e.g., "ga"), or of a locale when it
+// differs from its language (e.g., "pt-PT").
+func RegisterPluralRule(locale string, rule PluralRule) {
+	pluralMu.Lock()
+	defer pluralMu.Unlock()
+	pluralRules[locale] = rule
+}
+
+// PluralCategory returns the plural category of a count in a locale. Languages without a
+// rule follow English: one for 1, other for everything else.
+func PluralCategory(locale string, n float64) string {
+	pluralMu.RLock()
+	rule, ok := pluralRules[locale]
+	if !ok {
+		base, _, _ := strings.Cut(locale, "-")
+		rule, ok = pluralRules[base]
+	}
+	pluralMu.RUnlock()
+
+	if !ok {
+		rule = pluralOne
+	}
+	return rule(math.Abs(n))
+}
+
+func isCategory(s string) bool {
+	switch s {
+	case Zero, One, Two, Few, Many, Other:
+		return true
+	}
+	return false
+}
+
+func isInteger(n float64) bool {
+	return n == math.Trunc(n)
+}
+
+func pluralNone(n float64) string {
+	return Other
+}
+
+// English, German, Spanish, Italian, Dutch, the Scandinavian languages, ...
+func pluralOne(n float64) string {
+	if n == 1 {
+		return One
+	}
+	return Other
+}
+
+// French and Portuguese: 0 and 1 are singular.
+func pluralZeroOne(n float64) string {
+	if n < 2 {
+		return One
+	}
+	return Other
+}
+
+// Russian, Ukrainian, Belarusian and the Serbo-Croatian languages.
+func pluralEastSlavic(n float64) string {
+	if !isInteger(n) {
+		return Other
+	}
+	i := int64(n)
+	switch {
+	case i%10 == 1 && i%100 != 11:
+		return One
+	case i%10 >= 2 && i%10 <= 4 && (i%100 < 12 || i%100 > 14):
+		return Few
+	}
+	return Many
+}
+
+func pluralPolish(n float64) string {
+	if !isInteger(n) {
+		return Other
+	}
+	i := int64(n)
+	switch {
+	case i == 1:
+		return One
+	case i%10 >= 2 && i%10 <= 4 && (i%100 < 12 || i%100 > 14):
+		return Few
+	}
+	return Many
+}
+
+// Czech and Slovak.
+func pluralCzech(n float64) string {
+	switch {
+	case !isInteger(n):
+		return Many
+	case n == 1:
+		return One
+	case n >= 2 && n <= 4:
+		return Few
+	}
+	return Other
+}
+
+func pluralArabic(n float64) string {
+	if !isInteger(n) {
+		return Other
+	}
+	i := int64(n)
+	switch {
+	case i == 0:
+		return Zero
+	case i == 1:
+		return One
+	case i == 2:
+		return Two
+	case i%100 >= 3 && i%100 <= 10:
+		return Few
+	case i%100 >= 11:
+		return Many
+	}
+	return Other
+}
@@ -0,0 +1,119 @@
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Missing is a message missing from the catalog of a locale. Source is where a view uses it,
+// when the message was found in a view rather than in the catalog of the fallback locale.
+type Missing struct {
+	Locale string
+	Key    string
+	Source string
+}
+
+func (m Missing) String() string {
+	if m.Source != "" {
+		return fmt.Sprintf("%s: %s (%s)", m.Locale, m.Key, m.Source)
+	}
+	return fmt.Sprintf("%s: %s", m.Locale, m.Key)
+}
+
+// The t function of the views called with a literal key: t("home.title", ...).
+var translateCall = regexp.MustCompile(`\bt\(\s*"([^"]+)"`)
+
+// Report lists the messages missing from the catalogs: the messages of the fallback catalog
+// that the catalog of another locale lacks (the visitor reads them in the fallback language),
+// and the messages views translate (calls of t with a literal key, in the views directory)
+// that a catalog lacks. A locale with a region is not missing what its base language has.
+func Report(b *Bundle, viewsDir string) ([]Missing, error) {
+	sources, err := viewKeys(viewsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	if c := b.catalogs[b.fallback]; c != nil {
+		for key := range c.Messages {
+			keys[key] = true
+		}
+	}
+	for key := range sources {
+		keys[key] = true
+	}
+
+	var missing []Missing
+	for _, locale := range b.Locales() {
+		own := []*Catalog{b.catalogs[locale]}
+		if base, _, _ := strings.Cut(locale, "-"); base != locale && base != b.fallback {
+			own = append(own, b.catalogs[base])
+		}
+
+		for key := range keys {
+			found := false
+			for _, c := range own {
+				if c != nil {
+					if _, ok := c.Messages[key]; ok {
+						found = true
+					}
+				}
+			}
+			if !found {
+				missing = append(missing, Missing{Locale: locale, Key: key, Source: sources[key]})
+			}
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].Locale != missing[j].Locale {
+			return missing[i].Locale < missing[j].Locale
+		}
+		return missing[i].Key < missing[j].Key
+	})
+	return missing, nil
+}
+
+// The keys translated by the views, with where each is first used.
+func viewKeys(dir string) (map[string]string, error) {
+	sources := make(map[string]string)
+	if dir == "" {
+		return sources, nil
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".jet") {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		rel, _ := filepath.Rel(dir, path)
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			for _, match := range translateCall.FindAllStringSubmatch(scanner.Text(), -1) {
+				if _, ok := sources[match[1]]; !ok {
+					sources[match[1]] = fmt.Sprintf("%s:%d", filepath.ToSlash(rel), line)
+				}
+			}
+		}
+		return scanner.Err()
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("i18n: %w", err)
+	}
+	return sources, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"myapp/i18n"
+	"myapp/views"
+	"os"
+	"path/filepath"
+
+	"github.com/cidekar/adele-framework"
+)
+
+// Here is where the translations of the application are loaded: a catalog per locale in
+// resources/lang (see the i18n package). Requests get the locale picked by the Locale
+// middleware, views translate with t(), and handlers with Handlers.T. Messages missing from
+// a catalog are logged in debug mode; ./adeleApp lang missing lists them all.
+//
+// Configuration via environment variables:
+//
+//	APP_LOCALE: The fallback locale, used when a request asks for no supported locale and
+//	            for messages missing from a catalog (default: "en")
+func loadLang(a *adele.Adele) (*i18n.Bundle, error) {
+	bundle, err := i18n.Load(filepath.Join(a.RootPath, "resources", "lang"), fallbackLocale())
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Debug {
+		bundle.OnMissing = func(locale, key string) {
+			a.Log.Warn("missing translation: ", locale, ": ", key)
+		}
+	}
+
+	views.Translate(a.JetViews, bundle)
+
+	return bundle, nil
+}
+
+func fallbackLocale() string {
+	if locale := os.Getenv("APP_LOCALE"); locale != "" {
+		return locale
+	}
+	return "en"
+}
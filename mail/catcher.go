@@ -0,0 +1,167 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Caught is a message a Catcher kept.
+type Caught struct {
+	ID      string    `json:"id"`
+	At      time.Time `json:"at"`
+	Message Message   `json:"message"`
+	// Raw is the message as it would have been sent over SMTP.
+	Raw string `json:"raw"`
+}
+
+// Catcher is a transport that keeps messages instead of sending them, in memory or as JSON
+// files in a directory (so they survive restarts and can be read by other processes). In
+// development its ServeHTTP shows them in the browser; tests read them through the mailtest
+// package.
+type Catcher struct {
+	dir string
+	// Limit is the number of messages kept in memory; the oldest are dropped first.
+	Limit int
+
+	mu     sync.Mutex
+	caught []Caught
+	last   int64
+}
+
+// NewCatcher returns a catcher keeping messages in a directory, or in memory when dir is
+// empty.
+func NewCatcher(dir string) *Catcher {
+	return &Catcher{dir: dir, Limit: 500}
+}
+
+// Send keeps a message.
+func (c *Catcher) Send(ctx context.Context, msg *Message) error {
+	email := buildEmail(msg)
+	if email.Error != nil {
+		return email.Error
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// IDs sort in the order messages were caught
+	id := time.Now().UnixNano()
+	if id <= c.last {
+		id = c.last + 1
+	}
+	c.last = id
+
+	caught := Caught{ID: fmt.Sprintf("%019d", id), At: time.Now(), Message: *msg, Raw: email.GetMessage()}
+
+	if c.dir != "" {
+		data, err := json.Marshal(caught)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(c.dir, 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(c.dir, caught.ID+".json"), data, 0o644)
+	}
+
+	c.caught = append(c.caught, caught)
+	if c.Limit > 0 && len(c.caught) > c.Limit {
+		c.caught = c.caught[len(c.caught)-c.Limit:]
+	}
+	return nil
+}
+
+// Messages returns the messages caught, oldest first.
+func (c *Catcher) Messages() ([]Caught, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dir == "" {
+		return append([]Caught(nil), c.caught...), nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	caught := make([]Caught, 0, len(files))
+	for _, file := range files {
+		m, err := readCaught(file)
+		if err != nil {
+			return nil, err
+		}
+		caught = append(caught, m)
+	}
+	return caught, nil
+}
+
+// ErrNotCaught is returned for a message the catcher does not have.
+var ErrNotCaught = errors.New("mail: no such message")
+
+// Get returns a message caught.
+func (c *Catcher) Get(id string) (Caught, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dir == "" {
+		for _, m := range c.caught {
+			if m.ID == id {
+				return m, nil
+			}
+		}
+		return Caught{}, ErrNotCaught
+	}
+
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return Caught{}, ErrNotCaught
+	}
+	m, err := readCaught(filepath.Join(c.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return Caught{}, ErrNotCaught
+	}
+	return m, err
+}
+
+// Clear drops the messages caught.
+func (c *Catcher) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.caught = nil
+	if c.dir == "" {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readCaught(file string) (Caught, error) {
+	var m Caught
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("mail: %s: %w", file, err)
+	}
+	return m, nil
+}
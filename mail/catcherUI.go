@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// CatcherPath is the path of the mail a Catcher caught, in development.
+const CatcherPath = "/_dev/mail"
+
+var catcherIndex = template.Must(template.New("index").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>Mail</title></head>
+<body style="font-family: sans-serif">
+<h1>Mail</h1>
+<form method="post" action="{{ .Path }}/clear"><button>Clear</button> <a href="{{ .PreviewPath }}">Previews</a></form>
+<table cellpadding="6">
+<tr><th align="left">Caught</th><th align="left">To</th><th align="left">Subject</th><th align="left">Attachments</th><th></th></tr>
+{{ range .Caught }}<tr>
+<td>{{ .At.Format "2006-01-02 15:04:05" }}</td>
+<td>{{ range $i, $to := .Message.To }}{{ if $i }}, {{ end }}{{ $to }}{{ end }}</td>
+<td>{{ .Message.Subject }}</td>
+<td>{{ range $i, $a := .Message.Attachments }}{{ if $i }}, {{ end }}{{ $a.Name }}{{ end }}</td>
+<td><a href="{{ $.Path }}/{{ .ID }}/html">HTML</a> <a href="{{ $.Path }}/{{ .ID }}/text">text</a> <a href="{{ $.Path }}/{{ .ID }}/raw">raw</a></td>
+</tr>{{ else }}<tr><td colspan="5">No mail was caught.</td></tr>{{ end }}
+</table>
+</body></html>`))
+
+// ServeHTTP shows the mail caught: CatcherPath lists it, newest first, and
+// CatcherPath/<id>/html, /text and /raw show a message. A POST to CatcherPath/clear drops
+// the mail caught.
+func (c *Catcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, CatcherPath), "/"), "/")
+
+	// the mail is a document of its own, with inline styles and data: images
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data: https:; style-src 'unsafe-inline'")
+
+	switch {
+	case parts[0] == "" && r.Method == http.MethodGet:
+		caught, err := c.Messages()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i, j := 0, len(caught)-1; i < j; i, j = i+1, j-1 {
+			caught[i], caught[j] = caught[j], caught[i]
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		catcherIndex.Execute(w, struct {
+			Path        string
+			PreviewPath string
+			Caught      []Caught
+		}{CatcherPath, PreviewPath, caught})
+
+	case len(parts) == 1 && parts[0] == "clear" && r.Method == http.MethodPost:
+		if err := c.Clear(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, CatcherPath, http.StatusSeeOther)
+
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		m, err := c.Get(parts[0])
+		if err == ErrNotCaught {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		switch parts[1] {
+		case "html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(DataURIs(&m.Message)))
+		case "text":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(m.Message.Text))
+		case "raw":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(m.Raw))
+		default:
+			http.NotFound(w, r)
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCatcher(t *testing.T) {
+	for name, dir := range map[string]string{"memory": "", "disk": t.TempDir()} {
+		t.Run(name, func(t *testing.T) {
+			c := NewCatcher(dir)
+			for _, subject := range []string{"First", "Second"} {
+				msg := &Message{From: "app@example.com", To: []string{"ada@example.com"}, Subject: subject, Text: "Hello"}
+				if err := c.Send(context.Background(), msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			caught, err := c.Messages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(caught) != 2 || caught[0].Message.Subject != "First" || caught[1].Message.Subject != "Second" {
+				t.Fatalf("Expected the messages in the order they were caught, got %+v", caught)
+			}
+			if !strings.Contains(caught[0].Raw, "Subject: First") {
+				t.Errorf("Expected the raw message, got %q", caught[0].Raw)
+			}
+
+			m, err := c.Get(caught[1].ID)
+			if err != nil || m.Message.Subject != "Second" {
+				t.Errorf("Expected the second message, got %+v, %v", m, err)
+			}
+			if _, err := c.Get("../../etc/passwd"); err != ErrNotCaught {
+				t.Errorf("Expected ErrNotCaught, got %v", err)
+			}
+
+			if err := c.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			if caught, _ := c.Messages(); len(caught) != 0 {
+				t.Errorf("Expected no message after clearing, got %d", len(caught))
+			}
+		})
+	}
+}
+
+func TestCatcher_ServeHTTP(t *testing.T) {
+	c := NewCatcher("")
+	c.Send(context.Background(), &Message{From: "app@example.com", To: []string{"ada@example.com"}, Subject: "Receipt",
+		HTML: `<img src="cid:logo.png"><p>Paid</p>`, Text: "Paid",
+		Attachments: []Attachment{{Name: "logo.png", ContentType: "image/png", Data: []byte("\x89PNG"), Inline: true}}})
+	caught, _ := c.Messages()
+	id := caught[0].ID
+
+	for path, want := range map[string]string{
+		CatcherPath:                      "Receipt",
+		CatcherPath + "/" + id + "/html": "data:image/png;base64,",
+		CatcherPath + "/" + id + "/text": "Paid",
+		CatcherPath + "/" + id + "/raw":  "Content-Type: multipart/related",
+	} {
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("GET %s: expected %q, got %d %s", path, want, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CatcherPath+"/0/html", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown message to be a 404, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CatcherPath+"/clear", nil))
+	if caught, _ := c.Messages(); rec.Code != http.StatusSeeOther || len(caught) != 0 {
+		t.Errorf("Expected clearing to drop the mail and redirect, got %d and %d messages", rec.Code, len(caught))
+	}
+}
@@ -0,0 +1,70 @@
+package mail
+
+import (
+	"context"
+	"myapp/models"
+	"sync"
+	"time"
+)
+
+// Direct is an outbox that sends a message as soon as it is enqueued, through a transport,
+// and keeps nothing: there is nothing to retry and nothing survives a restart. It is the
+// outbox of an application without a database, and of tests (see the mailtest package).
+// Idempotency keys are remembered in memory.
+type Direct struct {
+	Transport Transport
+
+	mu   sync.Mutex
+	keys map[string]*models.OutboxMail
+	last int64
+}
+
+// NewDirect returns an outbox sending through a transport.
+func NewDirect(t Transport) *Direct {
+	return &Direct{Transport: t, keys: make(map[string]*models.OutboxMail)}
+}
+
+// Enqueue sends a message. A message enqueued again with the same idempotency key is not
+// sent again.
+func (d *Direct) Enqueue(ctx context.Context, msg Message, key string) (*models.OutboxMail, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, ok := d.keys[key]; ok && key != "" {
+		return existing, false, nil
+	}
+
+	if err := d.Transport.Send(ctx, &msg); err != nil {
+		return nil, false, err
+	}
+
+	d.last++
+	now := time.Now().UTC()
+	mail := &models.OutboxMail{ID: d.last, IdempotencyKey: key, Message: msg, Status: models.OutboxSent, Attempts: 1,
+		AvailableAt: now, SentAt: &now, CreatedAt: now, UpdatedAt: now}
+	if key != "" {
+		d.keys[key] = mail
+	}
+	return mail, true, nil
+}
+
+// Claim returns nothing: messages are sent when they are enqueued.
+func (d *Direct) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMail, error) {
+	return nil, nil
+}
+
+// Extend does nothing.
+func (d *Direct) Extend(ctx context.Context, id int64, attempt int, lease time.Duration) error {
+	return nil
+}
+
+// MarkSent does nothing.
+func (d *Direct) MarkSent(ctx context.Context, id int64, attempt int) error { return nil }
+
+// MarkFailed does nothing.
+func (d *Direct) MarkFailed(ctx context.Context, id int64, attempt int, sendErr error, retryAt time.Time, dead bool) error {
+	return nil
+}
+
+// Release does nothing.
+func (d *Direct) Release(ctx context.Context, id int64, attempt int) error { return nil }
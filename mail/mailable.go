@@ -0,0 +1,303 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"myapp/models"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/cidekar/adele-framework/mailer"
+	"github.com/vanng822/go-premailer/premailer"
+)
+
+// Message is a rendered message, ready for a transport. It is what the outbox stores.
+type Message = models.OutboxMessage
+
+// Attachment is a file attached to a message.
+type Attachment = models.OutboxAttachment
+
+// Mailable is a typed mail message: a struct of the data its templates show, whose Envelope
+// says who it is for and which templates render it. The templates are
+// resources/views/mail/<Template>.html.jet and <Template>.plain.jet, and see the mailable as
+// data. The HTML template can embed images of the public directory with embed, which
+// returns the cid: URL of the image attached inline.
+//
+// Example:
+//
+//	type Welcome struct {
+//		Name  string
+//		Email string
+//	}
+//
+//	func (m Welcome) Envelope() mail.Envelope {
+//		return mail.Envelope{To: []string{m.Email}, Subject: "Welcome", Template: "welcome"}
+//	}
+//
+// with resources/views/mail/welcome.html.jet:
+//
+//	{{ extends "./layout.jet" }}
+//	{{ block message() }}
+//	  <img src="{{ embed("images/logo.png") }}" alt="">
+//	  <p>Hello {{ data.Name }}</p>
+//	{{ end }}
+type Mailable interface {
+	Envelope() Envelope
+}
+
+// Envelope is how a mailable is sent.
+type Envelope struct {
+	// From and FromName default to MAILER_FROM_ADDRESS and MAILER_FROM_NAME.
+	From     string
+	FromName string
+	To       []string
+	Cc       []string
+	Bcc      []string
+	ReplyTo  string
+	Subject  string
+	// Template is the name of the templates in resources/views/mail.
+	Template string
+	// Locale is the locale the templates translate into (see views.Translate); the fallback
+	// locale when empty.
+	Locale string
+	// Files are the paths of files to attach, read when the mailable is rendered.
+	Files []string
+	// Attachments are attached as they are.
+	Attachments []Attachment
+}
+
+// Renderer renders mailables, and the messages sent to the framework's mailer, into messages.
+// The HTML of a message has the CSS of its <style> elements inlined, as mail clients ignore
+// most stylesheets.
+type Renderer struct {
+	// Views is the set the templates of mailables are loaded from, under mail/.
+	Views *jet.Set
+	// PublicDir is where embed finds images.
+	PublicDir string
+	// Mailer has the default sender, and the templates of the messages sent to it.
+	Mailer *mailer.Mail
+
+	mailerViews *jet.Set
+}
+
+// NewRenderer returns a renderer of the mailables whose templates are in a set.
+func NewRenderer(set *jet.Set, publicDir string, m *mailer.Mail) *Renderer {
+	r := &Renderer{Views: set, PublicDir: publicDir, Mailer: m}
+	if m != nil {
+		r.mailerViews = jet.NewSet(jet.NewOSFileSystemLoader(m.Templates))
+	}
+	return r
+}
+
+// Render renders a mailable.
+func (r *Renderer) Render(m Mailable) (*Message, error) {
+	env := m.Envelope()
+	if env.Template == "" {
+		return nil, fmt.Errorf("mail: %T has no template", m)
+	}
+
+	msg := r.message(env.From, env.FromName, env.Subject)
+	msg.To, msg.Cc, msg.Bcc, msg.ReplyTo = env.To, env.Cc, env.Bcc, env.ReplyTo
+	msg.Attachments = append(msg.Attachments, env.Attachments...)
+
+	embedded := make(map[string]string)
+	vars := make(jet.VarMap)
+	vars.Set("data", m)
+	if env.Locale != "" {
+		vars.Set("locale", env.Locale)
+	}
+
+	var err error
+	msg.HTML, err = r.render(r.Views, "mail/"+env.Template+".html.jet", vars, msg, embedded)
+	if err != nil {
+		return nil, err
+	}
+	msg.Text, err = r.render(r.Views, "mail/"+env.Template+".plain.jet", vars, msg, embedded)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := attachFiles(msg, env.Files); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// RenderMailer renders a message sent to the framework's mailer, from its templates in the
+// mailer's template directory (<Template>.html.jet and <Template>.plain.jet, seeing the data
+// of the message as data), as the mailer would.
+func (r *Renderer) RenderMailer(m mailer.Message) (*Message, error) {
+	if r.mailerViews == nil {
+		return nil, errors.New("mail: the renderer has no mailer")
+	}
+
+	msg := r.message(m.From, m.FromName, m.Subject)
+	msg.To = []string{m.To}
+
+	embedded := make(map[string]string)
+	vars := make(jet.VarMap)
+	vars.Set("data", m.Data)
+
+	var err error
+	msg.HTML, err = r.render(r.mailerViews, m.Template+".html.jet", vars, msg, embedded)
+	if err != nil {
+		return nil, err
+	}
+	msg.Text, err = r.render(r.mailerViews, m.Template+".plain.jet", vars, msg, embedded)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := attachFiles(msg, m.Attachments); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (r *Renderer) message(from, fromName, subject string) *Message {
+	msg := &Message{From: from, FromName: fromName, Subject: subject}
+	if r.Mailer != nil {
+		if msg.From == "" {
+			msg.From = r.Mailer.FromAddress
+		}
+		if msg.FromName == "" {
+			msg.FromName = r.Mailer.FromName
+		}
+	}
+	return msg
+}
+
+// Render a template of a message. Images embedded by the HTML template are attached to the
+// message inline.
+func (r *Renderer) render(set *jet.Set, name string, vars jet.VarMap, msg *Message, embedded map[string]string) (string, error) {
+	t, err := set.GetTemplate(name)
+	if err != nil {
+		return "", fmt.Errorf("mail: %w", err)
+	}
+
+	vars.SetFunc("embed", func(a jet.Arguments) reflect.Value {
+		a.RequireNumOfArguments("embed", 1, 1)
+		file, _ := a.Get(0).Interface().(string)
+		name, err := r.embed(msg, embedded, file)
+		if err != nil {
+			a.Panicf("embed: %s", err)
+		}
+		return reflect.ValueOf("cid:" + name)
+	})
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars, nil); err != nil {
+		return "", fmt.Errorf("mail: %w", err)
+	}
+
+	if strings.HasSuffix(name, ".plain.jet") {
+		// the set escapes for HTML
+		return html.UnescapeString(buf.String()), nil
+	}
+	return inlineCSS(buf.String())
+}
+
+// Attach an image of the public directory inline, once, and return its name. The images
+// embedded so far are in embedded, by path.
+func (r *Renderer) embed(msg *Message, embedded map[string]string, file string) (string, error) {
+	if file == "" || strings.Contains(file, "..") {
+		return "", fmt.Errorf("invalid path %q", file)
+	}
+	clean := path.Clean("/" + file)
+	if name, ok := embedded[clean]; ok {
+		return name, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.PublicDir, filepath.FromSlash(clean)))
+	if err != nil {
+		return "", err
+	}
+
+	name := path.Base(clean)
+	for i := 1; hasAttachment(msg, name); i++ {
+		name = fmt.Sprintf("%d-%s", i, path.Base(clean))
+	}
+	msg.Attachments = append(msg.Attachments, Attachment{Name: name, ContentType: mime.TypeByExtension(path.Ext(name)), Data: data, Inline: true})
+	embedded[clean] = name
+	return name, nil
+}
+
+func hasAttachment(msg *Message, name string) bool {
+	for _, a := range msg.Attachments {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Read the files to attach to a message.
+func attachFiles(msg *Message, files []string) error {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("mail: %w", err)
+		}
+		name := filepath.Base(file)
+		msg.Attachments = append(msg.Attachments, Attachment{Name: name, ContentType: mime.TypeByExtension(filepath.Ext(name)), Data: data})
+	}
+	return nil
+}
+
+func inlineCSS(s string) (string, error) {
+	prem, err := premailer.NewPremailerFromString(s, &premailer.Options{KeepBangImportant: true})
+	if err != nil {
+		return "", fmt.Errorf("mail: %w", err)
+	}
+	out, err := prem.Transform()
+	if err != nil {
+		return "", fmt.Errorf("mail: %w", err)
+	}
+	return out, nil
+}
+
+// DataURIs replaces the cid: URLs of the inline attachments of a message's HTML with data:
+// URLs, so the HTML shows its images outside of a mail client.
+func DataURIs(msg *Message) string {
+	out := msg.HTML
+	for _, a := range msg.Attachments {
+		if !a.Inline {
+			continue
+		}
+		uri := "data:" + a.ContentType + ";base64," + base64.StdEncoding.EncodeToString(a.Data)
+		out = strings.ReplaceAll(out, `"cid:`+a.Name+`"`, `"`+uri+`"`)
+	}
+	return out
+}
+
+// ErrNoRenderer is returned when sending a mailable through a worker without a renderer.
+var ErrNoRenderer = errors.New("mail: the worker has no renderer")
+
+// Send renders a mailable and adds it to the outbox. As with Outbox.Enqueue, a mailable
+// sent again with the same idempotency key is not sent twice, and mail sent with the
+// context of a models.Transaction is only sent when the transaction commits.
+func (w *Worker) Send(ctx context.Context, m Mailable, key string) error {
+	if w.Renderer == nil {
+		return ErrNoRenderer
+	}
+
+	msg, err := w.Renderer.Render(m)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := w.Outbox.Enqueue(ctx, *msg, key); err != nil {
+		return err
+	}
+	w.Wake()
+	return nil
+}
@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/cidekar/adele-framework/mailer"
+)
+
+type receipt struct {
+	Name   string
+	Email  string
+	Amount string
+	Files  []string
+}
+
+func (m receipt) Envelope() Envelope {
+	return Envelope{To: []string{m.Email}, Bcc: []string{"books@example.com"}, Subject: "Your receipt", Template: "receipt", Files: m.Files}
+}
+
+// A renderer of test templates and a public directory with an image.
+func testRenderer(t *testing.T) (*Renderer, string) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"views/mail/layout.jet": `<html><head><style>p { color: red; }</style></head><body>{{ yield message() }}</body></html>`,
+		"views/mail/receipt.html.jet": `{{ extends "./layout.jet" }}{{ block message() }}<img src="{{ embed("images/logo.png") }}">` +
+			`<img src="{{ embed("/images/logo.png") }}"><p>{{ data.Name }} paid {{ data.Amount }}</p>{{ end }}`,
+		"views/mail/receipt.plain.jet": `{{ data.Name }} paid {{ data.Amount }}`,
+		"public/images/logo.png":       "\x89PNG",
+		"receipt.pdf":                  "%PDF",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0o755)
+		os.WriteFile(file, []byte(content), 0o644)
+	}
+
+	set := jet.NewSet(jet.NewOSFileSystemLoader(filepath.Join(dir, "views")), jet.InDevelopmentMode())
+	return NewRenderer(set, filepath.Join(dir, "public"), &mailer.Mail{FromAddress: "shop@example.com", FromName: "Shop"}), dir
+}
+
+func TestRenderer_Render(t *testing.T) {
+	r, dir := testRenderer(t)
+
+	msg, err := r.Render(receipt{Name: "Ada & Bob", Email: "ada@example.com", Amount: "$5", Files: []string{filepath.Join(dir, "receipt.pdf")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.From != "shop@example.com" || msg.FromName != "Shop" || msg.Subject != "Your receipt" || msg.Bcc[0] != "books@example.com" {
+		t.Errorf("Unexpected envelope %+v", msg)
+	}
+	if !strings.Contains(msg.HTML, `<p style="color:red">Ada &amp; Bob paid $5</p>`) {
+		t.Errorf("Expected the CSS inlined, got %s", msg.HTML)
+	}
+	if msg.Text != "Ada & Bob paid $5" {
+		t.Errorf("Expected the text unescaped, got %q", msg.Text)
+	}
+
+	if len(msg.Attachments) != 2 {
+		t.Fatalf("Expected the image embedded once and the file attached, got %+v", msg.Attachments)
+	}
+	logo, pdf := msg.Attachments[0], msg.Attachments[1]
+	if !logo.Inline || logo.Name != "logo.png" || logo.ContentType != "image/png" || strings.Count(msg.HTML, `src="cid:logo.png"`) != 2 {
+		t.Errorf("Unexpected embedded image %+v in %s", logo, msg.HTML)
+	}
+	if pdf.Inline || pdf.Name != "receipt.pdf" || string(pdf.Data) != "%PDF" {
+		t.Errorf("Unexpected attachment %+v", pdf)
+	}
+
+	if html := DataURIs(msg); !strings.Contains(html, `src="data:image/png;base64,iVBORw=="`) {
+		t.Errorf("Expected the image as a data URI, got %s", html)
+	}
+}
+
+func TestRenderer_EmbedOutsidePublicDir(t *testing.T) {
+	r, dir := testRenderer(t)
+	os.WriteFile(filepath.Join(dir, "views/mail/escape.html.jet"), []byte(`<img src="{{ embed("../receipt.pdf") }}">`), 0o644)
+
+	_, err := r.render(r.Views, "mail/escape.html.jet", make(jet.VarMap), &Message{}, map[string]string{})
+	if err == nil {
+		t.Fatal("Expected embedding a file outside of the public directory to fail")
+	}
+}
+
+func TestSMTP_SendsMailables(t *testing.T) {
+	server := startSMTP(t, "")
+	r, _ := testRenderer(t)
+
+	msg, err := r.Render(receipt{Name: "Ada", Email: "ada@example.com", Amount: "$5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smtpTransport(server).Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	received := server.Received()
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(received))
+	}
+	for _, want := range []string{"From: \"Shop\" <shop@example.com>", "Subject: Your receipt", "multipart/related", "Content-Id: <", "text/plain"} {
+		if !strings.Contains(received[0], want) {
+			t.Errorf("Expected %q in the message:\n%s", want, received[0])
+		}
+	}
+}
+
+func TestPreviews(t *testing.T) {
+	r, _ := testRenderer(t)
+	previews := NewPreviews(r)
+	previews.Register("receipt", receipt{Name: "Ada", Email: "ada@example.com", Amount: "$5"})
+
+	for path, want := range map[string]string{
+		PreviewPath:                          `href="/_dev/mail/preview/receipt"`,
+		PreviewPath + "/receipt":             `<p style="color:red">Ada paid $5</p>`,
+		PreviewPath + "/receipt?format=text": "Ada paid $5",
+		PreviewPath + "/receipt/":            "data:image/png;base64,",
+	} {
+		rec := httptest.NewRecorder()
+		previews.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("GET %s: expected %q, got %d %s", path, want, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	previews.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PreviewPath+"/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown preview to be a 404, got %d", rec.Code)
+	}
+}
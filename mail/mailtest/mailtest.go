@@ -0,0 +1,219 @@
+// Package mailtest captures the mail sent during a test, so the test can check it without
+// an SMTP server: the worker a test sends through (e.g., Handlers.Mail) renders mail as
+// usual, and keeps it in a mail.Catcher instead of storing and sending it.
+//
+// Example:
+//
+//	func TestSignup(t *testing.T) {
+//		h := &handlers.Handlers{App: app, Mail: mailtest.New(t, renderer)}
+//		...
+//		msg := mailtest.AssertSent(t, mailtest.To("ada@example.com"), mailtest.Subject("Welcome"))
+//		if !strings.Contains(msg.Text, "Ada") {
+//			...
+//		}
+//	}
+//
+// Sent and the assertions read the mail of the worker captured last, so tests capturing
+// mail must not run in parallel.
+package mailtest
+
+import (
+	"io"
+	"myapp/mail"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	mu      sync.Mutex
+	current *mail.Catcher
+)
+
+// New returns a worker rendering mail with a renderer, whose mail is captured until the
+// end of the test.
+func New(t testing.TB, r *mail.Renderer) *mail.Worker {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	w := mail.NewWorker(nil, nil, log)
+	w.Renderer = r
+	Use(t, w)
+	return w
+}
+
+// Use captures the mail sent through a worker until the end of the test, and returns the
+// catcher keeping it.
+func Use(t testing.TB, w *mail.Worker) *mail.Catcher {
+	catcher := mail.NewCatcher("")
+
+	outbox, transport := w.Outbox, w.Transport
+	w.Outbox, w.Transport = mail.NewDirect(catcher), catcher
+
+	mu.Lock()
+	previous := current
+	current = catcher
+	mu.Unlock()
+
+	t.Cleanup(func() {
+		w.Outbox, w.Transport = outbox, transport
+
+		mu.Lock()
+		current = previous
+		mu.Unlock()
+	})
+	return catcher
+}
+
+// Sent returns the mail captured, oldest first.
+func Sent() []mail.Message {
+	mu.Lock()
+	catcher := current
+	mu.Unlock()
+
+	if catcher == nil {
+		return nil
+	}
+
+	// a catcher in memory does not fail
+	caught, _ := catcher.Messages()
+	sent := make([]mail.Message, len(caught))
+	for i, m := range caught {
+		sent[i] = m.Message
+	}
+	return sent
+}
+
+// Reset drops the mail captured so far.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if current != nil {
+		current.Clear()
+	}
+}
+
+// Match is a condition on a message.
+type Match struct {
+	desc string
+	fn   func(msg *mail.Message) bool
+}
+
+// To matches mail to an address, among its To, Cc and Bcc recipients.
+func To(address string) Match {
+	return Match{"to " + address, func(msg *mail.Message) bool {
+		for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+			for _, to := range list {
+				if strings.EqualFold(to, address) {
+					return true
+				}
+			}
+		}
+		return false
+	}}
+}
+
+// Subject matches mail with a subject.
+func Subject(subject string) Match {
+	return Match{"with the subject " + subject, func(msg *mail.Message) bool {
+		return msg.Subject == subject
+	}}
+}
+
+// Contains matches mail whose HTML or text contains a string.
+func Contains(s string) Match {
+	return Match{"containing " + s, func(msg *mail.Message) bool {
+		return strings.Contains(msg.HTML, s) || strings.Contains(msg.Text, s)
+	}}
+}
+
+// Attachment matches mail with an attachment of a name.
+func Attachment(name string) Match {
+	return Match{"with the attachment " + name, func(msg *mail.Message) bool {
+		for _, a := range msg.Attachments {
+			if a.Name == name {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// Find returns the mail captured that matches all the conditions.
+func Find(matches ...Match) []mail.Message {
+	var found []mail.Message
+	for _, msg := range Sent() {
+		if matchesAll(&msg, matches) {
+			found = append(found, msg)
+		}
+	}
+	return found
+}
+
+func matchesAll(msg *mail.Message, matches []Match) bool {
+	for _, m := range matches {
+		if !m.fn(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// AssertSent fails the test unless mail matching all the conditions was sent, and returns
+// the first such message.
+func AssertSent(t testing.TB, matches ...Match) mail.Message {
+	t.Helper()
+
+	found := Find(matches...)
+	if len(found) == 0 {
+		t.Fatalf("Expected mail %s; sent:\n%s", describe(matches), summary())
+		return mail.Message{}
+	}
+	return found[0]
+}
+
+// AssertNotSent fails the test when mail matching all the conditions was sent.
+func AssertNotSent(t testing.TB, matches ...Match) {
+	t.Helper()
+
+	if found := Find(matches...); len(found) > 0 {
+		t.Errorf("Expected no mail %s; sent:\n%s", describe(matches), summary())
+	}
+}
+
+// AssertCount fails the test unless n messages matching all the conditions were sent.
+func AssertCount(t testing.TB, n int, matches ...Match) {
+	t.Helper()
+
+	if found := Find(matches...); len(found) != n {
+		t.Errorf("Expected %d mail %s, got %d; sent:\n%s", n, describe(matches), len(found), summary())
+	}
+}
+
+func describe(matches []Match) string {
+	if len(matches) == 0 {
+		return "at all"
+	}
+	desc := make([]string, len(matches))
+	for i, m := range matches {
+		desc[i] = m.desc
+	}
+	return strings.Join(desc, ", ")
+}
+
+// The mail sent, one line a message.
+func summary() string {
+	sent := Sent()
+	if len(sent) == 0 {
+		return "  (none)"
+	}
+
+	var b strings.Builder
+	for _, msg := range sent {
+		b.WriteString("  to " + strings.Join(msg.To, ", ") + ": " + msg.Subject + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,83 @@
+package mailtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"myapp/mail"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/cidekar/adele-framework/mailer"
+)
+
+type welcome struct {
+	Name  string
+	Email string
+}
+
+func (m welcome) Envelope() mail.Envelope {
+	return mail.Envelope{To: []string{m.Email}, Subject: "Welcome", Template: "welcome"}
+}
+
+func testRenderer(t *testing.T) *mail.Renderer {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "mail"), 0o755)
+	os.WriteFile(filepath.Join(dir, "mail", "welcome.html.jet"), []byte("<p>Hello {{ data.Name }}</p>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "mail", "welcome.plain.jet"), []byte("Hello {{ data.Name }}"), 0o644)
+
+	set := jet.NewSet(jet.NewOSFileSystemLoader(dir), jet.InDevelopmentMode())
+	return mail.NewRenderer(set, dir, &mailer.Mail{FromAddress: "app@example.com"})
+}
+
+func TestCapture(t *testing.T) {
+	w := New(t, testRenderer(t))
+	ctx := context.Background()
+
+	if err := w.Send(ctx, welcome{Name: "Ada", Email: "ada@example.com"}, "welcome:ada"); err != nil {
+		t.Fatal(err)
+	}
+	// the same idempotency key is not sent twice
+	if err := w.Send(ctx, welcome{Name: "Ada", Email: "ada@example.com"}, "welcome:ada"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(ctx, welcome{Name: "Bob", Email: "bob@example.com"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := AssertSent(t, To("ADA@example.com"), Subject("Welcome"))
+	if msg.Text != "Hello Ada" || msg.From != "app@example.com" {
+		t.Errorf("Unexpected message %+v", msg)
+	}
+	AssertCount(t, 2)
+	AssertCount(t, 1, Contains("Hello Bob"))
+	AssertNotSent(t, To("carol@example.com"))
+	AssertNotSent(t, Attachment("invoice.pdf"))
+
+	Reset()
+	if len(Sent()) != 0 {
+		t.Errorf("Expected no mail after a reset, got %d", len(Sent()))
+	}
+}
+
+func TestCapture_Listen(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "note.html.jet"), []byte("<p>{{ data }}</p>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "note.plain.jet"), []byte("{{ data }}"), 0o644)
+
+	w := New(t, mail.NewRenderer(nil, "", &mailer.Mail{Templates: dir, FromAddress: "app@example.com"}))
+
+	jobs := make(chan mailer.Message, 1)
+	results := make(chan mailer.Result, 1)
+	jobs <- mailer.Message{To: "ada@example.com", Subject: "Note", Template: "note", Data: "Remember the milk"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Listen(ctx, jobs, results)
+
+	if result := <-results; !result.Success {
+		t.Fatalf("Expected the message to be sent, got %v", result.Error)
+	}
+	AssertSent(t, To("ada@example.com"), Contains("Remember the milk"))
+}
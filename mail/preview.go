@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PreviewPath is the path of the mail previews.
+const PreviewPath = "/_dev/mail/preview"
+
+// Previews renders mailables in the browser, for working on their templates in development.
+// Each preview is a mailable filled with sample data: PreviewPath lists them,
+// PreviewPath/<name> shows the HTML of one, and PreviewPath/<name>?format=text its text.
+// Templates are rendered on every request, so a change shows on reload.
+type Previews struct {
+	renderer *Renderer
+
+	mu        sync.RWMutex
+	mailables map[string]Mailable
+}
+
+// NewPreviews returns previews rendered by a renderer.
+func NewPreviews(r *Renderer) *Previews {
+	return &Previews{renderer: r, mailables: make(map[string]Mailable)}
+}
+
+// Register adds the preview of a mailable under a name.
+func (p *Previews) Register(name string, m Mailable) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mailables[name] = m
+}
+
+// Names returns the names of the previews, sorted.
+func (p *Previews) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := make([]string, 0, len(p.mailables))
+	for name := range p.mailables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var previewIndex = template.Must(template.New("index").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>Mail previews</title></head>
+<body style="font-family: sans-serif">
+<h1>Mail previews</h1>
+<ul>{{ range .Names }}<li><a href="{{ $.Path }}/{{ . }}">{{ . }}</a> (<a href="{{ $.Path }}/{{ . }}?format=text">text</a>)</li>{{ else }}<li>No mailable is registered.</li>{{ end }}</ul>
+</body></html>`))
+
+// ServeHTTP serves the previews.
+func (p *Previews) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, PreviewPath), "/")
+
+	// the mail is a document of its own, with inline styles and data: images
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data: https:; style-src 'unsafe-inline'")
+
+	if name == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		previewIndex.Execute(w, struct {
+			Path  string
+			Names []string
+		}{PreviewPath, p.Names()})
+		return
+	}
+
+	p.mu.RLock()
+	m, ok := p.mailables[name]
+	p.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	msg, err := p.renderer.Render(m)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(msg.Text))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(DataURIs(msg)))
+}
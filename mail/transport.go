@@ -0,0 +1,179 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"time"
+
+	apimail "github.com/ainsleyclark/go-mail"
+	"github.com/cidekar/adele-framework/mailer"
+	simplemail "github.com/xhit/go-simple-mail/v2"
+)
+
+// Transport sends rendered messages.
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewTransport returns the transport the framework's mailer is configured for: the mail API
+// of MAILER_API (mailgun, sparkpost or sendgrid) when it has a key and URL, and the SMTP
+// server of SMTP_HOST otherwise. Mail can be caught instead of sent, e.g., in development.
+//
+// Configuration via environment variables:
+//
+//	MAIL_TRANSPORT: "catcher" to keep mail in a Catcher instead of sending it
+//	MAIL_CATCHER_DIR: The directory the catcher keeps mail in; in memory when not set
+func NewTransport(m *mailer.Mail) Transport {
+	if os.Getenv("MAIL_TRANSPORT") == "catcher" {
+		return NewCatcher(os.Getenv("MAIL_CATCHER_DIR"))
+	}
+	if m.API != "" && m.API != "smtp" && m.APIKey != "" && m.APIUrl != "" {
+		return &API{Driver: m.API, URL: m.APIUrl, Key: m.APIKey, Domain: m.Domain}
+	}
+	return &SMTP{Host: m.Host, Port: m.Port, Username: m.Username, Password: m.Password, Encryption: m.Encryption}
+}
+
+// SMTP sends messages to an SMTP server.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Encryption is "tls" (STARTTLS), "ssl" or "none"; STARTTLS when empty.
+	Encryption string
+	// Timeout bounds connecting and sending; 10 seconds when zero.
+	Timeout time.Duration
+}
+
+// Send sends a message.
+func (t *SMTP) Send(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	server := simplemail.NewSMTPClient()
+	server.Host = t.Host
+	server.Port = t.Port
+	server.Username = t.Username
+	server.Password = t.Password
+	server.Encryption = encryption(t.Encryption)
+	server.ConnectTimeout = 10 * time.Second
+	server.SendTimeout = 10 * time.Second
+	if t.Timeout > 0 {
+		server.ConnectTimeout, server.SendTimeout = t.Timeout, t.Timeout
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < server.SendTimeout {
+		server.ConnectTimeout, server.SendTimeout = time.Until(deadline), time.Until(deadline)
+	}
+
+	email := buildEmail(msg)
+	if email.Error != nil {
+		return email.Error
+	}
+
+	client, err := server.Connect()
+	if err != nil {
+		return err
+	}
+	return email.Send(client)
+}
+
+// Build the MIME message of a message.
+func buildEmail(msg *Message) *simplemail.Email {
+	email := simplemail.NewMSG()
+	email.SetFrom(address(msg.From, msg.FromName)).AddTo(msg.To...).SetSubject(msg.Subject)
+	if len(msg.Cc) > 0 {
+		email.AddCc(msg.Cc...)
+	}
+	if len(msg.Bcc) > 0 {
+		email.AddBcc(msg.Bcc...)
+	}
+	if msg.ReplyTo != "" {
+		email.SetReplyTo(msg.ReplyTo)
+	}
+
+	switch {
+	case msg.HTML != "" && msg.Text != "":
+		email.SetBody(simplemail.TextHTML, msg.HTML)
+		email.AddAlternative(simplemail.TextPlain, msg.Text)
+	case msg.HTML != "":
+		email.SetBody(simplemail.TextHTML, msg.HTML)
+	default:
+		email.SetBody(simplemail.TextPlain, msg.Text)
+	}
+
+	for _, a := range msg.Attachments {
+		email.Attach(&simplemail.File{Name: a.Name, MimeType: a.ContentType, Data: a.Data, Inline: a.Inline})
+	}
+	return email
+}
+
+func encryption(e string) simplemail.Encryption {
+	switch e {
+	case "ssl":
+		return simplemail.EncryptionSSL
+	case "none":
+		return simplemail.EncryptionNone
+	default:
+		return simplemail.EncryptionSTARTTLS
+	}
+}
+
+func address(email, name string) string {
+	if name == "" {
+		return email
+	}
+	return name + " <" + email + ">"
+}
+
+// API sends messages through the API of a mail service: mailgun, sparkpost or sendgrid.
+// The APIs only know recipients, so Cc recipients are sent the message with the To
+// recipients, and each Bcc recipient is sent a copy of their own. Inline attachments are
+// attached as files, and Reply-To is dropped.
+type API struct {
+	Driver string
+	URL    string
+	Key    string
+	Domain string
+}
+
+// Send sends a message.
+func (t *API) Send(ctx context.Context, msg *Message) error {
+	client, err := apimail.NewClient(t.Driver, apimail.Config{
+		URL:         t.URL,
+		APIKey:      t.Key,
+		Domain:      t.Domain,
+		FromAddress: msg.From,
+		FromName:    msg.FromName,
+	})
+	if err != nil {
+		return err
+	}
+
+	var attachments apimail.Attachments
+	for _, a := range msg.Attachments {
+		attachments = append(attachments, apimail.Attachment{Filename: a.Name, Bytes: a.Data})
+	}
+
+	batches := [][]string{append(append([]string(nil), msg.To...), msg.Cc...)}
+	for _, bcc := range msg.Bcc {
+		batches = append(batches, []string{bcc})
+	}
+
+	for _, recipients := range batches {
+		if len(recipients) == 0 {
+			continue
+		}
+		_, err := client.Send(&apimail.Transmission{
+			Recipients:  recipients,
+			Subject:     msg.Subject,
+			HTML:        msg.HTML,
+			PlainText:   msg.Text,
+			Attachments: attachments,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
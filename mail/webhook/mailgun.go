@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"myapp/models"
+)
+
+// Mailgun reads the webhooks of Mailgun. A request holds one event, signed with the HTTP
+// webhook signing key: the signature is the HMAC-SHA256 of the timestamp followed by the
+// token.
+//
+// The signature does not cover the event, so the tokens of the requests whose events were
+// recorded within MaxAge are kept and a request reusing one is refused with ErrReplay;
+// otherwise a captured signature could be sent again with any event. A token is kept by
+// Commit, once the events of its request are recorded, so Mailgun retrying a request that
+// failed is not refused. Tokens are kept in memory by the process: with several instances
+// of the application, a request replayed to another instance is not caught.
+type Mailgun struct {
+	SigningKey string
+	// MaxAge is how old a signature may be; it defaults to the package's MaxAge.
+	MaxAge time.Duration
+	// Now is the current time, for tests.
+	Now func() time.Time
+
+	mu sync.Mutex
+	// the tokens committed, with when their signatures expire
+	seen map[string]time.Time
+}
+
+type mailgunRequest struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+	EventData json.RawMessage `json:"event-data"`
+}
+
+type mailgunEvent struct {
+	ID             string  `json:"id"`
+	Timestamp      float64 `json:"timestamp"`
+	Event          string  `json:"event"`
+	Severity       string  `json:"severity"`
+	Reason         string  `json:"reason"`
+	Recipient      string  `json:"recipient"`
+	DeliveryStatus struct {
+		Message     string `json:"message"`
+		Description string `json:"description"`
+	} `json:"delivery-status"`
+	Message struct {
+		Headers struct {
+			MessageID string `json:"message-id"`
+		} `json:"headers"`
+	} `json:"message"`
+}
+
+// Verify checks the signature of a request.
+func (m *Mailgun) Verify(header http.Header, body []byte) error {
+	var req mailgunRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return ErrSignature
+	}
+	sig := req.Signature
+
+	given, err := hex.DecodeString(sig.Signature)
+	if err != nil || sig.Token == "" || !fresh(sig.Timestamp, m.Now, m.MaxAge) {
+		return ErrSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(m.SigningKey))
+	mac.Write([]byte(sig.Timestamp + sig.Token))
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return ErrSignature
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.seen[sig.Token]; ok {
+		return ErrReplay
+	}
+	return nil
+}
+
+// Commit keeps the token of a verified request whose events were recorded, so a request
+// reusing it is refused with ErrReplay.
+func (m *Mailgun) Commit(header http.Header, body []byte) {
+	var req mailgunRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.Signature.Token == "" {
+		return
+	}
+	m.remember(req.Signature.Token, req.Signature.Timestamp)
+}
+
+// Keep a token until its signature expires. Tokens are forgotten then, as fresh refuses
+// them.
+func (m *Mailgun) remember(token, timestamp string) {
+	now := time.Now
+	if m.Now != nil {
+		now = m.Now
+	}
+	maxAge := m.MaxAge
+	if maxAge <= 0 {
+		maxAge = MaxAge
+	}
+	seconds, _ := strconv.ParseInt(timestamp, 10, 64)
+	expires := time.Unix(seconds, 0).Add(maxAge)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.seen == nil {
+		m.seen = make(map[string]time.Time)
+	}
+	at := now()
+	for t, expiry := range m.seen {
+		if at.After(expiry) {
+			delete(m.seen, t)
+		}
+	}
+
+	m.seen[token] = expires
+}
+
+// Parse returns the event of a request: a failure is a bounce when Mailgun will not retry
+// it, and a deferral otherwise.
+func (m *Mailgun) Parse(body []byte) ([]models.MailEvent, error) {
+	var req mailgunRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	var e mailgunEvent
+	if err := json.Unmarshal(req.EventData, &e); err != nil {
+		return nil, err
+	}
+
+	event := models.MailEvent{
+		Provider:   "mailgun",
+		EventID:    e.ID,
+		Recipient:  e.Recipient,
+		MessageID:  e.Message.Headers.MessageID,
+		Payload:    models.MailEventPayload(req.EventData),
+		OccurredAt: unixTime(e.Timestamp),
+	}
+
+	switch e.Event {
+	case "delivered":
+		event.Type = models.MailDelivered
+	case "failed":
+		event.Type = models.MailDeferred
+		if e.Severity == "permanent" {
+			event.Type = models.MailBounced
+			event.Permanent = true
+		}
+		event.Reason = e.DeliveryStatus.Description
+		if event.Reason == "" {
+			event.Reason = e.DeliveryStatus.Message
+		}
+		if event.Reason == "" {
+			event.Reason = e.Reason
+		}
+	case "complained":
+		event.Type = models.MailComplained
+	case "unsubscribed":
+		event.Type = models.MailUnsubscribed
+	default:
+		return nil, nil
+	}
+
+	return []models.MailEvent{event}, nil
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"myapp/models"
+)
+
+// The headers of a signed SendGrid request.
+const (
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// SendGrid reads the signed event webhook of SendGrid. A request holds a batch of events,
+// signed with ECDSA over the SHA-256 of the timestamp header followed by the body.
+type SendGrid struct {
+	PublicKey *ecdsa.PublicKey
+	// MaxAge is how old a signature may be; it defaults to the package's MaxAge.
+	MaxAge time.Duration
+	// Now is the current time, for tests.
+	Now func() time.Time
+}
+
+// NewSendGrid returns the provider verifying requests with a verification key, as the
+// SendGrid settings show it: a base64 DER public key.
+func NewSendGrid(publicKey string) (*SendGrid, error) {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, errors.New("webhook: the SendGrid verification key is not base64")
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.New("webhook: the SendGrid verification key is not a public key")
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("webhook: the SendGrid verification key is not an ECDSA key")
+	}
+	return &SendGrid{PublicKey: ecKey}, nil
+}
+
+type sendgridEvent struct {
+	Email     string `json:"email"`
+	Timestamp int64  `json:"timestamp"`
+	Event     string `json:"event"`
+	EventID   string `json:"sg_event_id"`
+	MessageID string `json:"sg_message_id"`
+	Reason    string `json:"reason"`
+	Response  string `json:"response"`
+	// Type tells a bounce ("bounce") from a block ("blocked"), which may succeed later.
+	Type string `json:"type"`
+}
+
+// Verify checks the signature of a request.
+func (s *SendGrid) Verify(header http.Header, body []byte) error {
+	timestamp := header.Get(SendGridTimestampHeader)
+	sig, err := base64.StdEncoding.DecodeString(header.Get(SendGridSignatureHeader))
+	if err != nil || len(sig) == 0 || !fresh(timestamp, s.Now, s.MaxAge) {
+		return ErrSignature
+	}
+
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	if !ecdsa.VerifyASN1(s.PublicKey, digest[:], sig) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// Parse returns the events of a request: bounces are permanent unless they are blocks, and
+// both group and global unsubscribes are unsubscribes.
+func (s *SendGrid) Parse(body []byte) ([]models.MailEvent, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+
+	events := make([]models.MailEvent, 0, len(raw))
+	for _, payload := range raw {
+		var e sendgridEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+
+		event := models.MailEvent{
+			Provider:   "sendgrid",
+			EventID:    e.EventID,
+			Recipient:  e.Email,
+			MessageID:  e.MessageID,
+			Payload:    models.MailEventPayload(payload),
+			OccurredAt: time.Unix(e.Timestamp, 0).UTC(),
+		}
+
+		switch e.Event {
+		case "delivered":
+			event.Type = models.MailDelivered
+		case "bounce":
+			event.Type = models.MailBounced
+			event.Permanent = e.Type != "blocked"
+			event.Reason = e.Reason
+		case "deferred":
+			event.Type = models.MailDeferred
+			event.Reason = e.Response
+		case "spamreport":
+			event.Type = models.MailComplained
+		case "unsubscribe", "group_unsubscribe":
+			event.Type = models.MailUnsubscribed
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
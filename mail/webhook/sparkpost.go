@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"myapp/models"
+)
+
+// SparkPost reads the webhooks of SparkPost. SparkPost does not sign its requests; the
+// webhook is set up with basic authentication and the credentials are checked instead.
+type SparkPost struct {
+	Username string
+	Password string
+}
+
+type sparkpostEvent struct {
+	Type        string `json:"type"`
+	EventID     string `json:"event_id"`
+	Recipient   string `json:"rcpt_to"`
+	Timestamp   string `json:"timestamp"`
+	MessageID   string `json:"message_id"`
+	Reason      string `json:"reason"`
+	RawReason   string `json:"raw_reason"`
+	BounceClass string `json:"bounce_class"`
+}
+
+// Verify checks the credentials of a request.
+func (s *SparkPost) Verify(header http.Header, body []byte) error {
+	r := http.Request{Header: header}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return ErrSignature
+	}
+
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(s.Username))
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.Password))
+	if userOK&passwordOK != 1 {
+		return ErrSignature
+	}
+	return nil
+}
+
+// The bounce classes of SparkPost that will not succeed on retry: invalid recipient, bad
+// domain and an undeliverable address reported by the recipient's server.
+var sparkpostHardBounces = map[string]bool{"10": true, "30": true, "90": true}
+
+// Parse returns the events of a request. SparkPost wraps each event in its kind of event,
+// e.g., {"msys": {"message_event": {...}}}; a test request has none.
+func (s *SparkPost) Parse(body []byte) ([]models.MailEvent, error) {
+	var batch []struct {
+		Msys map[string]json.RawMessage `json:"msys"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, err
+	}
+
+	events := make([]models.MailEvent, 0, len(batch))
+	for _, item := range batch {
+		for _, payload := range item.Msys {
+			var e sparkpostEvent
+			if err := json.Unmarshal(payload, &e); err != nil {
+				return nil, err
+			}
+
+			event := models.MailEvent{
+				Provider:  "sparkpost",
+				EventID:   e.EventID,
+				Recipient: e.Recipient,
+				MessageID: e.MessageID,
+				Payload:   models.MailEventPayload(payload),
+			}
+			if seconds, err := strconv.ParseInt(e.Timestamp, 10, 64); err == nil {
+				event.OccurredAt = time.Unix(seconds, 0).UTC()
+			}
+
+			switch e.Type {
+			case "delivery":
+				event.Type = models.MailDelivered
+			case "bounce", "out_of_band":
+				event.Type = models.MailBounced
+				event.Permanent = sparkpostHardBounces[e.BounceClass]
+				event.Reason = e.Reason
+			case "delay":
+				event.Type = models.MailDeferred
+				event.Reason = e.RawReason
+			case "spam_complaint":
+				event.Type = models.MailComplained
+			case "list_unsubscribe", "link_unsubscribe":
+				event.Type = models.MailUnsubscribed
+			default:
+				continue
+			}
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,98 @@
+// Package webhook reads the delivery events that mail services post to the application:
+// each Provider verifies that a request comes from its service and normalizes the events it
+// carries into models.MailEvent, so deliveries, bounces, complaints and unsubscribes are
+// recorded the same way whichever service sent the mail.
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"myapp/models"
+)
+
+// Provider reads the webhook requests of a mail service.
+type Provider interface {
+	// Verify returns ErrSignature unless the request was signed by the service, and
+	// ErrReplay for a signed request whose signature was committed before (see Committer).
+	Verify(header http.Header, body []byte) error
+	// Parse returns the events of a request's body. Events of types the application does
+	// not record, e.g., opens and clicks, are left out.
+	Parse(body []byte) ([]models.MailEvent, error)
+}
+
+// Committer is a Provider that remembers the signatures of the requests whose events were
+// recorded, to refuse them when they are sent again (ErrReplay). Commit is called only once
+// the events are recorded, so a request the service retries after a failure is not refused.
+type Committer interface {
+	Commit(header http.Header, body []byte)
+}
+
+// ErrSignature is returned for a request that was not signed by the service, or was signed
+// too long ago.
+var ErrSignature = errors.New("webhook: invalid signature")
+
+// ErrReplay is returned for a request carrying a signature whose events were recorded
+// already.
+var ErrReplay = errors.New("webhook: signature used already")
+
+// MaxAge is how old a signed request may be by default. Older requests are refused so a
+// request that was captured cannot be replayed later.
+const MaxAge = 15 * time.Minute
+
+// FromEnv returns the providers configured in the environment, by name. A provider whose
+// secret is not set is left out, so its webhook is not found.
+//
+// Configuration via environment variables:
+//
+//	MAILGUN_WEBHOOK_SIGNING_KEY: The HTTP webhook signing key of the Mailgun account
+//	SENDGRID_WEBHOOK_PUBLIC_KEY: The verification key of the SendGrid signed event webhook
+//	SPARKPOST_WEBHOOK_USERNAME: The basic auth username of the SparkPost webhook
+//	SPARKPOST_WEBHOOK_PASSWORD: The basic auth password of the SparkPost webhook
+func FromEnv() (map[string]Provider, error) {
+	providers := make(map[string]Provider)
+
+	if key := os.Getenv("MAILGUN_WEBHOOK_SIGNING_KEY"); key != "" {
+		providers["mailgun"] = &Mailgun{SigningKey: key}
+	}
+
+	if key := os.Getenv("SENDGRID_WEBHOOK_PUBLIC_KEY"); key != "" {
+		sendgrid, err := NewSendGrid(key)
+		if err != nil {
+			return nil, err
+		}
+		providers["sendgrid"] = sendgrid
+	}
+
+	if password := os.Getenv("SPARKPOST_WEBHOOK_PASSWORD"); password != "" {
+		providers["sparkpost"] = &SparkPost{Username: os.Getenv("SPARKPOST_WEBHOOK_USERNAME"), Password: password}
+	}
+
+	return providers, nil
+}
+
+// Check that a signature's Unix timestamp is within maxAge of now, either way.
+func fresh(timestamp string, now func() time.Time, maxAge time.Duration) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if now == nil {
+		now = time.Now
+	}
+	if maxAge <= 0 {
+		maxAge = MaxAge
+	}
+
+	age := now().Sub(time.Unix(seconds, 0))
+	return age <= maxAge && age >= -maxAge
+}
+
+// The time of a Unix timestamp with a fraction of a second, as Mailgun sends them.
+func unixTime(seconds float64) time.Time {
+	whole := int64(seconds)
+	return time.Unix(whole, int64((seconds-float64(whole))*float64(time.Second))).UTC()
+}
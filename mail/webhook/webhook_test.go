@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"myapp/models"
+)
+
+// The fixtures were signed at this time, with these keys.
+const (
+	fixtureTimestamp  = "1760886000"
+	mailgunSigningKey = "key-3ax6xnjp29jd6fds4gc373sgvjxteol0"
+	sendgridPublicKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEDcIcdIl7zDuJpcDT7wrz4PpMSV9CkxwXLzCu4qL6jB/iYjqfrjTUBpHfDeuQwiRfFibqQlqbVljOq0EzxKI+bg=="
+	sendgridSignature = "MEUCIQCL1q34A6FOiWLPFoLyYE3T2P4YbEnACiotMMTASmss5AIgYEjNkmg06BZhT764ESGlyulMJup9OidnN0mKseEEelE="
+)
+
+func fixtureNow() time.Time {
+	return time.Unix(1760886000, 0).Add(time.Minute)
+}
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestMailgunVerify(t *testing.T) {
+	body := fixture(t, "mailgun_bounce.json")
+	mailgun := &Mailgun{SigningKey: mailgunSigningKey, Now: fixtureNow}
+
+	if err := mailgun.Verify(nil, body); err != nil {
+		t.Fatalf("Verify = %v, want nil", err)
+	}
+
+	wrongKey := &Mailgun{SigningKey: "key-other", Now: fixtureNow}
+	if err := wrongKey.Verify(nil, body); err != ErrSignature {
+		t.Errorf("Verify with the wrong key = %v, want ErrSignature", err)
+	}
+
+	late := &Mailgun{SigningKey: mailgunSigningKey, Now: func() time.Time { return fixtureNow().Add(time.Hour) }}
+	if err := late.Verify(nil, body); err != ErrSignature {
+		t.Errorf("Verify of a replayed request = %v, want ErrSignature", err)
+	}
+
+	if err := mailgun.Verify(nil, []byte(`{"signature": {}}`)); err != ErrSignature {
+		t.Errorf("Verify of an unsigned request = %v, want ErrSignature", err)
+	}
+}
+
+func TestMailgunVerify_Replay(t *testing.T) {
+	mailgun := &Mailgun{SigningKey: mailgunSigningKey, Now: fixtureNow}
+
+	if err := mailgun.Verify(nil, fixture(t, "mailgun_bounce.json")); err != nil {
+		t.Fatalf("Verify = %v, want nil", err)
+	}
+
+	// until its events are recorded, the request may be retried
+	if err := mailgun.Verify(nil, fixture(t, "mailgun_bounce.json")); err != nil {
+		t.Fatalf("Verify of a retried request = %v, want nil", err)
+	}
+	mailgun.Commit(nil, fixture(t, "mailgun_bounce.json"))
+
+	// the signature of the bounce, captured and sent again with another event
+	if err := mailgun.Verify(nil, fixture(t, "mailgun_replayed.json")); err != ErrReplay {
+		t.Errorf("Verify of a reused token = %v, want ErrReplay", err)
+	}
+
+	// once the signature expires it is refused as too old
+	mailgun.Now = func() time.Time { return fixtureNow().Add(MaxAge) }
+	if err := mailgun.Verify(nil, fixture(t, "mailgun_replayed.json")); err != ErrSignature {
+		t.Errorf("Verify of an expired signature = %v, want ErrSignature", err)
+	}
+}
+
+func TestMailgunParse(t *testing.T) {
+	mailgun := &Mailgun{SigningKey: mailgunSigningKey}
+
+	events, err := mailgun.Parse(fixture(t, "mailgun_bounce.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	e := events[0]
+	if e.Type != models.MailBounced || !e.Permanent || e.Recipient != "Alice@Example.com" || e.EventID != "G9Bn5sl1TC6nu79C8C0bwg" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Reason != "No such user" || e.MessageID != "20261019150000.1.ABCDEF@mg.example.com" {
+		t.Errorf("reason %q, message ID %q", e.Reason, e.MessageID)
+	}
+	if e.OccurredAt.Unix() != 1760885998 {
+		t.Errorf("occurred at %v", e.OccurredAt)
+	}
+
+	events, err = mailgun.Parse(fixture(t, "mailgun_opened.json"))
+	if err != nil || len(events) != 0 {
+		t.Errorf("an open gave %v, %v; want no events", events, err)
+	}
+}
+
+func TestSendGridVerify(t *testing.T) {
+	body := fixture(t, "sendgrid_events.json")
+	sendgrid, err := NewSendGrid(sendgridPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendgrid.Now = fixtureNow
+
+	header := http.Header{}
+	header.Set(SendGridSignatureHeader, sendgridSignature)
+	header.Set(SendGridTimestampHeader, fixtureTimestamp)
+
+	if err := sendgrid.Verify(header, body); err != nil {
+		t.Fatalf("Verify = %v, want nil", err)
+	}
+
+	tampered := append([]byte(nil), body...)
+	tampered[len(tampered)-3] = ' '
+	if err := sendgrid.Verify(header, tampered); err != ErrSignature {
+		t.Errorf("Verify of a tampered body = %v, want ErrSignature", err)
+	}
+
+	header.Set(SendGridTimestampHeader, "1760886001")
+	if err := sendgrid.Verify(header, body); err != ErrSignature {
+		t.Errorf("Verify with another timestamp = %v, want ErrSignature", err)
+	}
+
+	if _, err := NewSendGrid("not a key"); err == nil {
+		t.Error("NewSendGrid accepted an invalid key")
+	}
+}
+
+func TestSendGridParse(t *testing.T) {
+	events, err := (&SendGrid{}).Parse(fixture(t, "sendgrid_events.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		typ       string
+		permanent bool
+		recipient string
+	}{
+		{models.MailDelivered, false, "bob@example.com"},
+		{models.MailBounced, true, "Alice@Example.com"},
+		{models.MailBounced, false, "carol@example.com"},
+		{models.MailComplained, false, "dave@example.com"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Type != w.typ || e.Permanent != w.permanent || e.Recipient != w.recipient || e.EventID == "" {
+			t.Errorf("event %d = %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestSparkPost(t *testing.T) {
+	body := fixture(t, "sparkpost_events.json")
+	sparkpost := &SparkPost{Username: "sparkpost", Password: "s3cret"}
+
+	r, _ := http.NewRequest(http.MethodPost, "/", nil)
+	r.SetBasicAuth("sparkpost", "s3cret")
+	if err := sparkpost.Verify(r.Header, body); err != nil {
+		t.Fatalf("Verify = %v, want nil", err)
+	}
+	r.SetBasicAuth("sparkpost", "guess")
+	if err := sparkpost.Verify(r.Header, body); err != ErrSignature {
+		t.Errorf("Verify with the wrong password = %v, want ErrSignature", err)
+	}
+	if err := sparkpost.Verify(http.Header{}, body); err != ErrSignature {
+		t.Errorf("Verify without credentials = %v, want ErrSignature", err)
+	}
+
+	events, err := sparkpost.Parse(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []string{models.MailDelivered, models.MailBounced, models.MailBounced, models.MailUnsubscribed}
+	if len(events) != len(types) {
+		t.Fatalf("got %d events, want %d", len(events), len(types))
+	}
+	for i, typ := range types {
+		if events[i].Type != typ {
+			t.Errorf("event %d is %q, want %q", i, events[i].Type, typ)
+		}
+	}
+	if !events[1].Permanent || events[2].Permanent {
+		t.Errorf("bounce classes 10 and 21 gave permanent %v and %v", events[1].Permanent, events[2].Permanent)
+	}
+
+	if events, err := sparkpost.Parse([]byte(`[{"msys": {}}]`)); err != nil || len(events) != 0 {
+		t.Errorf("a test request gave %v, %v; want no events", events, err)
+	}
+}
@@ -0,0 +1,335 @@
+// Package mail sends the mail of the application. Mail is a Mailable rendered from its
+// templates into a Message, which is kept in the outbox (see models.MailOutbox) until it is
+// sent, so mail is not lost when the application restarts or the mail server is down: a
+// Worker claims due messages, sends them through a Transport and retries failures with
+// exponential backoff until they are sent or become dead letters.
+package mail
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"myapp/models"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cidekar/adele-framework/mailer"
+	"github.com/sirupsen/logrus"
+)
+
+// Outbox is where the worker takes mail from. A claim is identified by the attempts of the
+// claimed message, and the methods taking an attempt return models.ErrClaimLost once
+// another worker claimed the message.
+type Outbox interface {
+	Enqueue(ctx context.Context, msg Message, key string) (*models.OutboxMail, bool, error)
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMail, error)
+	Extend(ctx context.Context, id int64, attempt int, lease time.Duration) error
+	MarkSent(ctx context.Context, id int64, attempt int) error
+	MarkFailed(ctx context.Context, id int64, attempt int, sendErr error, retryAt time.Time, dead bool) error
+	Release(ctx context.Context, id int64, attempt int) error
+}
+
+// Suppressions is the list of addresses mail is no longer sent to (see
+// models.MailSuppressions).
+type Suppressions interface {
+	Suppressed(ctx context.Context, addresses ...string) ([]string, error)
+}
+
+// ErrSuppressed is the failure of a message all of whose recipients are suppressed.
+var ErrSuppressed = errors.New("mail: every recipient is on the suppression list")
+
+// Worker sends the mail of the outbox.
+type Worker struct {
+	Outbox    Outbox
+	Transport Transport
+	Log       logrus.FieldLogger
+	// Suppressions, when set, is consulted before sending: suppressed recipients are left
+	// out, and a message left without recipients is a dead letter.
+	Suppressions Suppressions
+	// Renderer renders what Send and Listen add to the outbox.
+	Renderer *Renderer
+
+	// Interval is how often the outbox is polled for due mail.
+	Interval time.Duration
+	// Batch is the number of messages claimed at once.
+	Batch int
+	// Lease is how long a claim lasts; a message claimed by a worker that stopped is sent
+	// again once its lease expires. The claim is renewed before each message of a batch is
+	// sent, so it only needs to outlast sending one.
+	Lease time.Duration
+	// SendTimeout bounds sending one message; it is kept to at most half the lease.
+	SendTimeout time.Duration
+	// MaxAttempts is the number of attempts after which a message is a dead letter.
+	MaxAttempts int
+	// RetryBase is the delay before the first retry; each retry waits twice as long as the
+	// previous one, up to RetryMax.
+	RetryBase time.Duration
+	RetryMax  time.Duration
+	// DrainTimeout bounds how long Run keeps sending due mail once it is asked to stop.
+	DrainTimeout time.Duration
+
+	wake chan struct{}
+	once sync.Once
+}
+
+// NewWorker returns a worker configured from the environment.
+//
+// Configuration via environment variables:
+//
+//	MAIL_POLL_INTERVAL: How often the outbox is polled for due mail (default: "5s")
+//	MAIL_BATCH: The number of messages claimed at once (default: 20)
+//	MAIL_SEND_TIMEOUT: How long sending one message may take, up to a minute (default: "30s")
+//	MAIL_MAX_ATTEMPTS: Attempts after which a message is a dead letter (default: 8)
+//	MAIL_RETRY_BASE: The delay before the first retry, doubled on every retry (default: "30s")
+//	MAIL_RETRY_MAX: The longest delay between retries (default: "1h")
+//	MAIL_DRAIN_TIMEOUT: How long due mail is still sent on shutdown (default: "10s")
+func NewWorker(outbox Outbox, transport Transport, log logrus.FieldLogger) *Worker {
+	return &Worker{
+		Outbox:       outbox,
+		Transport:    transport,
+		Log:          log,
+		Interval:     envDuration("MAIL_POLL_INTERVAL", 5*time.Second),
+		Batch:        envInt("MAIL_BATCH", 20),
+		Lease:        2 * time.Minute,
+		SendTimeout:  envDuration("MAIL_SEND_TIMEOUT", 30*time.Second),
+		MaxAttempts:  envInt("MAIL_MAX_ATTEMPTS", 8),
+		RetryBase:    envDuration("MAIL_RETRY_BASE", 30*time.Second),
+		RetryMax:     envDuration("MAIL_RETRY_MAX", time.Hour),
+		DrainTimeout: envDuration("MAIL_DRAIN_TIMEOUT", 10*time.Second),
+	}
+}
+
+// Wake makes the worker look for due mail now instead of at its next poll.
+func (w *Worker) Wake() {
+	w.init()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) init() {
+	w.once.Do(func() {
+		w.wake = make(chan struct{}, 1)
+	})
+}
+
+// Run sends due mail until the context is done. Then it drains the outbox: due mail is
+// still sent for up to DrainTimeout, and whatever remains stays in the outbox for the next
+// start. Run returns once it has stopped, so shutdown can wait for it.
+func (w *Worker) Run(ctx context.Context) {
+	w.init()
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		// keep going while there is a backlog
+		for w.SendDue(ctx) == w.Batch && ctx.Err() == nil {
+		}
+
+		select {
+		case <-ctx.Done():
+			w.drain()
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// Send the mail due while shutting down, until the outbox has none or the time is up.
+func (w *Worker) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.DrainTimeout)
+	defer cancel()
+
+	for ctx.Err() == nil && w.SendDue(ctx) > 0 {
+	}
+}
+
+// SendDue claims a batch of due mail and sends it. It returns the number of messages
+// claimed. Messages claimed but not sent because the context ended are released.
+func (w *Worker) SendDue(ctx context.Context) int {
+	claimed, err := w.Outbox.Claim(context.Background(), w.Batch, w.Lease)
+	if err != nil {
+		w.Log.WithError(err).Error("mail: claiming the outbox failed")
+		return 0
+	}
+
+	for i, mail := range claimed {
+		if ctx.Err() != nil {
+			for _, unsent := range claimed[i:] {
+				if err := w.Outbox.Release(context.Background(), unsent.ID, unsent.Attempts); err != nil && !errors.Is(err, models.ErrClaimLost) {
+					w.Log.WithError(err).WithField("mail_id", unsent.ID).Error("mail: releasing a claim failed")
+				}
+			}
+			break
+		}
+		w.send(mail)
+	}
+
+	return len(claimed)
+}
+
+// Send a claimed message and record the outcome. The claim is renewed first, as the
+// message may have waited in its batch for most of the lease; a message another worker
+// claimed in the meantime is left to it.
+func (w *Worker) send(mail models.OutboxMail) {
+	log := w.Log.WithField("mail_id", mail.ID).WithField("attempt", mail.Attempts)
+
+	if err := w.Outbox.Extend(context.Background(), mail.ID, mail.Attempts, w.Lease); err != nil {
+		if errors.Is(err, models.ErrClaimLost) {
+			log.Warn("mail: not sending a message; its claim expired and another worker took it")
+		} else {
+			log.WithError(err).Error("mail: renewing a claim failed")
+		}
+		return
+	}
+
+	timeout := w.SendTimeout
+	if timeout <= 0 || timeout > w.Lease/2 {
+		timeout = w.Lease / 2
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	recorded := func(err error, what string) {
+		switch {
+		case errors.Is(err, models.ErrClaimLost):
+			log.Warn("mail: not recording a " + what + " message; its claim expired and another worker took it")
+		case err != nil:
+			log.WithError(err).Error("mail: recording a " + what + " message failed")
+		}
+	}
+
+	msg, sendErr := w.withoutSuppressed(ctx, mail.Message)
+	if sendErr == ErrSuppressed {
+		recorded(w.Outbox.MarkFailed(context.Background(), mail.ID, mail.Attempts, sendErr, time.Now(), true), "failed")
+		log.Info("mail: not sending a message; every recipient is suppressed")
+		return
+	}
+	if sendErr == nil {
+		sendErr = w.Transport.Send(ctx, &msg)
+	}
+	if sendErr == nil {
+		recorded(w.Outbox.MarkSent(context.Background(), mail.ID, mail.Attempts), "sent")
+		return
+	}
+
+	dead := mail.Attempts >= w.MaxAttempts
+	retryAt := time.Now().Add(w.Backoff(mail.Attempts))
+	recorded(w.Outbox.MarkFailed(context.Background(), mail.ID, mail.Attempts, sendErr, retryAt, dead), "failed")
+
+	if dead {
+		log.WithError(sendErr).Error("mail: giving up on a message; it is a dead letter")
+		return
+	}
+	log.WithError(sendErr).WithField("retry_at", retryAt).Warn("mail: sending failed; retrying later")
+}
+
+// Leave the suppressed recipients out of a message. The message kept in the outbox is not
+// changed, so an address taken off the list receives the retries.
+func (w *Worker) withoutSuppressed(ctx context.Context, msg Message) (Message, error) {
+	if w.Suppressions == nil {
+		return msg, nil
+	}
+
+	recipients := append(append(append([]string(nil), msg.To...), msg.Cc...), msg.Bcc...)
+	suppressed, err := w.Suppressions.Suppressed(ctx, recipients...)
+	if err != nil || len(suppressed) == 0 {
+		return msg, err
+	}
+
+	skip := make(map[string]bool, len(suppressed))
+	for _, address := range suppressed {
+		skip[address] = true
+	}
+	keep := func(addresses []string) []string {
+		var kept []string
+		for _, address := range addresses {
+			if !skip[address] {
+				kept = append(kept, address)
+			}
+		}
+		return kept
+	}
+	msg.To, msg.Cc, msg.Bcc = keep(msg.To), keep(msg.Cc), keep(msg.Bcc)
+
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return msg, ErrSuppressed
+	}
+	w.Log.WithField("suppressed", suppressed).Info("mail: leaving suppressed recipients out of a message")
+	return msg, nil
+}
+
+// Backoff returns the delay before retrying a message after its nth attempt: RetryBase
+// doubled for every attempt after the first, up to RetryMax, with up to a fifth taken off
+// at random so messages that failed together are not retried together.
+func (w *Worker) Backoff(attempt int) time.Duration {
+	delay := w.RetryBase
+	for i := 1; i < attempt && delay < w.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > w.RetryMax {
+		delay = w.RetryMax
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+func envInt(name string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// Listen moves the mail sent to the framework's mail channel (a.Mail.Jobs) into the outbox,
+// in place of mailer.Mail.ListenForMail, so code written against the channel gets durable
+// mail. Messages are rendered from the mailer's templates as they arrive (see
+// Renderer.RenderMailer). The result reported for a message is whether it was stored, not
+// whether it was sent. When the context is done, the messages still in the channel are
+// stored too.
+func (w *Worker) Listen(ctx context.Context, jobs <-chan mailer.Message, results chan<- mailer.Result) {
+	store := func(msg mailer.Message) {
+		rendered, err := w.Renderer.RenderMailer(msg)
+		if err == nil {
+			_, _, err = w.Outbox.Enqueue(context.Background(), *rendered, "")
+		}
+		if err != nil {
+			w.Log.WithError(err).Error("mail: storing a message in the outbox failed")
+		} else {
+			w.Wake()
+		}
+
+		// nobody may be reading the results
+		select {
+		case results <- mailer.Result{Success: err == nil, Error: err}:
+		default:
+		}
+	}
+
+	for {
+		select {
+		case msg := <-jobs:
+			store(msg)
+		case <-ctx.Done():
+			for {
+				select {
+				case msg := <-jobs:
+					store(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"myapp/models"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cidekar/adele-framework/mailer"
+	"github.com/sirupsen/logrus"
+)
+
+// A local SMTP stand-in: it accepts mail, or rejects recipients with reject when set.
+type smtpServer struct {
+	Addr   *net.TCPAddr
+	reject string
+
+	mu       sync.Mutex
+	received []string
+}
+
+func startSMTP(t *testing.T, reject string) *smtpServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &smtpServer{Addr: l.Addr().(*net.TCPAddr), reject: reject}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "RCPT") && s.reject != "":
+			reply(s.reject)
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.received = append(s.received, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpServer) Received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+// A transport to the stand-in.
+func smtpTransport(s *smtpServer) *SMTP {
+	return &SMTP{Host: "127.0.0.1", Port: s.Addr.Port, Encryption: "none"}
+}
+
+// The framework's mailer, with a test template.
+func testMailer(t *testing.T) *mailer.Mail {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "test.html.jet"), []byte("<p>Hello {{ data.name }}</p>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "test.plain.jet"), []byte("Hello {{ data.name }}"), 0o644)
+
+	return &mailer.Mail{Templates: dir, FromAddress: "app@example.com"}
+}
+
+// An outbox in memory, with the semantics of models.MailOutbox.
+type memOutbox struct {
+	mu   sync.Mutex
+	mail []*models.OutboxMail
+}
+
+func (o *memOutbox) Enqueue(ctx context.Context, msg Message, key string) (*models.OutboxMail, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, m := range o.mail {
+		if key != "" && m.IdempotencyKey == key {
+			return m, false, nil
+		}
+	}
+	m := &models.OutboxMail{ID: int64(len(o.mail) + 1), IdempotencyKey: key, Message: msg,
+		Status: models.OutboxPending, AvailableAt: time.Now()}
+	o.mail = append(o.mail, m)
+	return m, true, nil
+}
+
+func (o *memOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMail, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var claimed []models.OutboxMail
+	for _, m := range o.mail {
+		if len(claimed) < limit && m.Status == models.OutboxPending && !m.AvailableAt.After(time.Now()) {
+			m.Status = models.OutboxSending
+			m.Attempts++
+			claimed = append(claimed, *m)
+		}
+	}
+	return claimed, nil
+}
+
+func (o *memOutbox) set(id int64, attempt int, f func(m *models.OutboxMail)) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.mail[id-1]
+	if m.Status != models.OutboxSending || m.Attempts != attempt {
+		return models.ErrClaimLost
+	}
+	f(m)
+	return nil
+}
+
+func (o *memOutbox) Extend(ctx context.Context, id int64, attempt int, lease time.Duration) error {
+	return o.set(id, attempt, func(m *models.OutboxMail) {})
+}
+
+func (o *memOutbox) MarkSent(ctx context.Context, id int64, attempt int) error {
+	return o.set(id, attempt, func(m *models.OutboxMail) { m.Status = models.OutboxSent })
+}
+
+func (o *memOutbox) MarkFailed(ctx context.Context, id int64, attempt int, sendErr error, retryAt time.Time, dead bool) error {
+	return o.set(id, attempt, func(m *models.OutboxMail) {
+		m.Status, m.AvailableAt, m.LastError = models.OutboxPending, retryAt, sendErr.Error()
+		if dead {
+			m.Status = models.OutboxDead
+		}
+	})
+}
+
+func (o *memOutbox) Release(ctx context.Context, id int64, attempt int) error {
+	return o.set(id, attempt, func(m *models.OutboxMail) { m.Status, m.Attempts = models.OutboxPending, m.Attempts-1 })
+}
+
+func (o *memOutbox) get(id int64) models.OutboxMail {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return *o.mail[id-1]
+}
+
+func newTestWorker(outbox Outbox, transport Transport) *Worker {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	w := NewWorker(outbox, transport, log)
+	w.Interval = 10 * time.Millisecond
+	w.RetryBase = time.Millisecond
+	w.RetryMax = 5 * time.Millisecond
+	w.MaxAttempts = 3
+	return w
+}
+
+func TestWorker_SendsThroughSMTP(t *testing.T) {
+	server := startSMTP(t, "")
+	outbox := &memOutbox{}
+	w := newTestWorker(outbox, smtpTransport(server))
+
+	mail, _, _ := outbox.Enqueue(context.Background(), Message{From: "app@example.com", To: []string{"ada@example.com"},
+		Subject: "Welcome", HTML: "<p>Hello Ada</p>", Text: "Hello Ada"}, "welcome:ada")
+
+	if n := w.SendDue(context.Background()); n != 1 {
+		t.Fatalf("Expected 1 message claimed, got %d", n)
+	}
+	if got := outbox.get(mail.ID); got.Status != models.OutboxSent {
+		t.Fatalf("Expected the message to be sent, got %+v", got)
+	}
+
+	received := server.Received()
+	if len(received) != 1 || !strings.Contains(received[0], "Subject: Welcome") || !strings.Contains(received[0], "Hello Ada") {
+		t.Fatalf("Unexpected mail received: %q", received)
+	}
+}
+
+func TestWorker_RetriesUntilDead(t *testing.T) {
+	server := startSMTP(t, "451 try again later")
+	outbox := &memOutbox{}
+	w := newTestWorker(outbox, smtpTransport(server))
+
+	mail, _, _ := outbox.Enqueue(context.Background(), Message{From: "app@example.com", To: []string{"ada@example.com"}, Text: "Hello"}, "")
+
+	for i := 1; i <= w.MaxAttempts; i++ {
+		time.Sleep(w.RetryMax)
+		if n := w.SendDue(context.Background()); n != 1 {
+			t.Fatalf("Expected attempt %d to claim the message, got %d", i, n)
+		}
+	}
+
+	got := outbox.get(mail.ID)
+	if got.Status != models.OutboxDead || got.Attempts != w.MaxAttempts || !strings.Contains(got.LastError, "451") {
+		t.Fatalf("Expected a dead letter after %d attempts, got %+v", w.MaxAttempts, got)
+	}
+	if len(server.Received()) != 0 {
+		t.Errorf("Expected no mail received")
+	}
+}
+
+func TestWorker_StaleClaimIsNotSent(t *testing.T) {
+	catcher := NewCatcher("")
+	outbox := &memOutbox{}
+	w := newTestWorker(outbox, catcher)
+
+	mail, _, _ := outbox.Enqueue(context.Background(), Message{From: "app@example.com", To: []string{"ada@example.com"}, Text: "Hello"}, "")
+	stale, _ := outbox.Claim(context.Background(), w.Batch, w.Lease)
+
+	// the lease ran out while the batch was sending, and another worker took the message
+	outbox.set(mail.ID, stale[0].Attempts, func(m *models.OutboxMail) { m.Attempts++ })
+
+	w.send(stale[0])
+
+	if caught, _ := catcher.Messages(); len(caught) != 0 {
+		t.Errorf("Expected a message claimed by another worker not to be sent, got %d", len(caught))
+	}
+	if got := outbox.get(mail.ID); got.Status != models.OutboxSending || got.Attempts != 2 {
+		t.Errorf("Expected the other worker's claim to be left alone, got %+v", got)
+	}
+}
+
+// A suppression list in memory.
+type suppressionList map[string]bool
+
+func (l suppressionList) Suppressed(ctx context.Context, addresses ...string) ([]string, error) {
+	var suppressed []string
+	for _, address := range addresses {
+		if l[strings.ToLower(address)] {
+			suppressed = append(suppressed, address)
+		}
+	}
+	return suppressed, nil
+}
+
+func TestWorker_SkipsSuppressedRecipients(t *testing.T) {
+	catcher := NewCatcher("")
+	outbox := &memOutbox{}
+	w := newTestWorker(outbox, catcher)
+	w.Suppressions = suppressionList{"bounced@example.com": true, "complained@example.com": true}
+
+	some, _, _ := outbox.Enqueue(context.Background(), Message{From: "app@example.com",
+		To: []string{"ada@example.com", "Bounced@example.com"}, Bcc: []string{"complained@example.com"}, Text: "Hello"}, "")
+	all, _, _ := outbox.Enqueue(context.Background(), Message{From: "app@example.com",
+		To: []string{"bounced@example.com"}, Text: "Hello"}, "")
+
+	if n := w.SendDue(context.Background()); n != 2 {
+		t.Fatalf("Expected 2 messages claimed, got %d", n)
+	}
+
+	caught, _ := catcher.Messages()
+	if len(caught) != 1 || len(caught[0].Message.To) != 1 || caught[0].Message.To[0] != "ada@example.com" || len(caught[0].Message.Bcc) != 0 {
+		t.Fatalf("Expected one message to ada@example.com only, got %+v", caught)
+	}
+	if got := outbox.get(some.ID); got.Status != models.OutboxSent || len(got.Message.To) != 2 {
+		t.Errorf("Expected the stored message to be sent and unchanged, got %+v", got)
+	}
+	if got := outbox.get(all.ID); got.Status != models.OutboxDead || got.LastError != ErrSuppressed.Error() {
+		t.Errorf("Expected a message without recipients to be a dead letter, got %+v", got)
+	}
+}
+
+func TestWorker_ListenAndDrain(t *testing.T) {
+	server := startSMTP(t, "")
+	outbox := &memOutbox{}
+	w := newTestWorker(outbox, smtpTransport(server))
+	w.Renderer = NewRenderer(nil, "", testMailer(t))
+	w.Interval = time.Hour
+
+	jobs := make(chan mailer.Message, 5)
+	results := make(chan mailer.Result, 5)
+	for i := 0; i < 3; i++ {
+		jobs <- mailer.Message{To: "ada@example.com", Template: "test", Data: map[string]interface{}{"name": "Ada"}}
+	}
+
+	// stopped before it started: the queued mail is still stored and sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w.Listen(ctx, jobs, results)
+	w.Run(ctx)
+
+	if len(results) != 3 {
+		t.Errorf("Expected 3 results, got %d", len(results))
+	}
+	if len(server.Received()) != 3 {
+		t.Fatalf("Expected the stored mail to be sent on shutdown, got %d", len(server.Received()))
+	}
+}
+
+func TestWorker_Backoff(t *testing.T) {
+	w := &Worker{RetryBase: time.Second, RetryMax: 10 * time.Second}
+
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 10 * time.Second} {
+		got := w.Backoff(attempt)
+		if got > want || got < want-want/5 {
+			t.Errorf("Backoff(%d) = %s, expected %s less up to a fifth", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package mailables holds the mail the application sends: a struct per message, with the
+// data its templates in resources/views/mail show (see mail.Mailable). Handlers send them
+// with Handlers.Mail.Send, and in development they are previewed at /_dev/mail/preview.
+package mailables
+
+import "myapp/mail"
+
+// Welcome greets a new user.
+type Welcome struct {
+	Name    string
+	Email   string
+	Subject string
+	// Locale is the locale of the user, e.g., i18n.LocaleFromContext(r.Context()).
+	Locale string
+}
+
+// Envelope implements mail.Mailable.
+func (m Welcome) Envelope() mail.Envelope {
+	return mail.Envelope{
+		To:       []string{m.Email},
+		Subject:  m.Subject,
+		Template: "welcome",
+		Locale:   m.Locale,
+	}
+}
@@ -1,39 +1,67 @@
 package main
 
 import (
+	"context"
 	"log"
+	"myapp/flash"
 	"myapp/handlers"
+	"myapp/maintenance"
 	"myapp/middleware"
 	"myapp/models"
+	"myapp/rpcapi/admin"
+	"myapp/services"
+	"net/rpc"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cidekar/adele-framework"
 	"github.com/cidekar/adele-framework/httpserver"
-	"github.com/cidekar/adele-framework/provider"
-	"github.com/cidekar/adele-framework/rpcserver"
+	"github.com/robfig/cron/v3"
 )
 
 var wg sync.WaitGroup
 
 func main() {
 
+	if handled, err := runCommand(os.Args[1:], os.Stdout); handled {
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	a := bootstrapApplication()
 
-	go a.Mail.ListenForMail()
+	a.startMail()
 
 	go a.listenForShutdown()
 
-	err := rpcserver.Start(a.App)
+	err := maintenance.Register(maintenance.New(a.App.RootPath))
 	if err != nil {
-		log.Fatalf("failed to start rpc: %s", err)
+		log.Fatalf("failed to register maintenance rpc: %s", err)
+	}
+
+	err = a.RPC.Publish(rpc.DefaultServer)
+	if err != nil {
+		log.Fatalf("failed to register rpc methods: %s", err)
 	}
 
 	a.jobsSchedule()
+	a.App.Scheduler.Start()
 
-	err = httpserver.Start(a.App)
+	err = a.startRPC()
+	if err != nil {
+		log.Fatalf("failed to start rpc: %s", err)
+	}
+
+	// the server records the address of each connection, which the router's RealIP
+	// overwrites, for the TrustedProxy middleware to resolve the client from
+	server := httpserver.NewServer(a.App)
+	server.ConnContext = middleware.ConnContext
+	err = server.ListenAndServe()
 
 	a.App.Log.Error(err)
 
@@ -48,13 +76,28 @@ func (a *application) listenForShutdown() {
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	s := <-quit
+	select {
+	case s := <-quit:
+		a.App.Log.Info("Application received signal", s.String())
+	case <-a.shutdown:
+		a.App.Log.Info("Application asked to shut down over RPC")
+	}
 
-	a.App.Log.Info("Application received signal", s.String())
+	a.App.Scheduler.Stop()
 
-	err := rpcserver.Stop(a.App)
-	if err != nil {
-		log.Fatal("RPC server failed to stop:", err)
+	if a.rpcServer != nil {
+		if err := a.rpcServer.Close(); err != nil {
+			log.Fatal("RPC server failed to stop:", err)
+		}
+	}
+
+	if a.stopMail != nil {
+		a.stopMail()
+	}
+	wg.Wait()
+
+	if models.Sessions != nil {
+		models.Sessions.Close()
 	}
 
 	a.App.Log.Info("Good bye!")
@@ -65,11 +108,54 @@ func (a *application) listenForShutdown() {
 // Here is where you may add jobs to the scheduler. Any jobs added will be
 // called by the scheduler using the defined interval. You may use one of
 // several pre-defined schedules in place of a cron expression (i.e., @yearly,
-// @monthly, @weekly, @daily, @hourly and @every <duration>).
+// @monthly, @weekly, @daily, @hourly and @every <duration>). Jobs named in
+// jobs() are scheduled by name, so ./adeleApp ctl status can tell them apart:
+//
+//	a.schedule("@every 5m", "mail.send")
 func (a *application) jobsSchedule() {
 	// ...
 }
 
+// Schedule a job of jobs() by its name.
+func (a *application) schedule(spec, name string) {
+	job, ok := a.jobs()[name]
+	if !ok {
+		a.App.Log.Errorf("cannot schedule %q: no such job", name)
+		os.Exit(1)
+	}
+	id, err := a.App.Scheduler.AddFunc(spec, func() {
+		if err := job(context.Background()); err != nil {
+			a.App.Log.WithField("job", name).Error(err)
+		}
+	})
+	if err != nil {
+		a.App.Log.Errorf("cannot schedule %q: %s", name, err)
+		os.Exit(1)
+	}
+	a.scheduled[id] = admin.ScheduledJob{Name: name, Schedule: spec}
+}
+
+// Ask listenForShutdown to stop the application, as a signal does. It does not block, so
+// the RPC call asking is answered first.
+func (a *application) requestShutdown() {
+	select {
+	case a.shutdown <- struct{}{}:
+	default:
+	}
+}
+
+// Here is where the jobs of the application are named, so they can be run on demand over
+// RPC (Admin.RunJob) as well as on a schedule in jobsSchedule.
+func (a *application) jobs() map[string]admin.Job {
+	return map[string]admin.Job{
+		// Send the mail that is due now, rather than at the worker's next poll.
+		"mail.send": func(ctx context.Context) error {
+			a.Mailer.SendDue(ctx)
+			return nil
+		},
+	}
+}
+
 func bootstrapApplication() *application {
 	path, err := os.Getwd()
 	if err != nil {
@@ -84,12 +170,27 @@ func bootstrapApplication() *application {
 
 	a.AppName = "myapp"
 
+	myModels := models.New(a)
+	myServices := services.New()
+
+	lang, err := loadLang(a)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	myMiddleware := &middleware.Middleware{
-		App: a,
+		App:      a,
+		Lang:     lang,
+		Models:   myModels,
+		Services: myServices,
 	}
 
 	myHandlers := &handlers.Handlers{
-		App: a,
+		App:      a,
+		Flash:    flash.New(a.Session),
+		Lang:     lang,
+		Models:   myModels,
+		Services: myServices,
 	}
 
 	app := &application{
@@ -97,23 +198,29 @@ func bootstrapApplication() *application {
 		Handlers:   myHandlers,
 		Mail:       &a.Mail,
 		Middleware: myMiddleware,
+		Models:     myModels,
+		Services:   myServices,
+		started:    time.Now(),
+		shutdown:   make(chan struct{}, 1),
+		scheduled:  make(map[cron.EntryID]admin.ScheduledJob),
 	}
 
-	app.App.Routes = app.routes()
-
-	app.Models = models.New(a)
-
-	p := &provider.Provider{
-		EnabledProviders: make(map[string]bool),
-		ProviderConfigs:  make(map[string]map[string]interface{}),
+	myHandlers.Views = app.viewData()
+	myMiddleware.Views = myHandlers.Views
+	app.setupMail()
+	app.setupRPC()
+	if err := app.viewComponents(); err != nil {
+		a.Log.Error(err)
+		os.Exit(1)
 	}
-
-	a.Provider = p
-
-	if err := a.Provider.LoadProviders(app.App); err != nil {
+	if err := app.compileViews(); err != nil {
 		a.Log.Error(err)
 		os.Exit(1)
 	}
 
+	app.App.Routes = app.routes()
+
+	app.loadProviders()
+
 	return app
 }
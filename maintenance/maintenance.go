@@ -0,0 +1,192 @@
+package maintenance
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Take the application down for maintenance without stopping it. While it is down every
+// route answers 503 with a Retry-After header, except the paths and client IPs allowed by
+// the Maintenance middleware and clients holding a bypass cookie.
+//
+// The application is down while the flag file storage/down exists, so it can be taken down
+// by any process that can write the file:
+//
+//	./adeleApp down --secret s3cr3t --retry 60   write the flag file
+//	./adeleApp up                                remove it
+//	touch storage/down                           down without a secret or retry
+//	Maintenance.Down / Maintenance.Up            over the RPC server (see Register)
+//
+// Visiting /<secret> while down sets a bypass cookie, so the site can be checked before it
+// is brought back up.
+const (
+	// FlagFile is the path of the flag file relative to the root of the application.
+	FlagFile = "storage/down"
+
+	// BypassCookie is the name of the cookie set by visiting /<secret>.
+	BypassCookie = "adele_maintenance"
+
+	// BypassLifetime is how long a bypass cookie is valid.
+	BypassLifetime = 12 * time.Hour
+)
+
+// State describes a maintenance window. It is stored as JSON in the flag file; an empty
+// file is a window without a secret or retry.
+type State struct {
+	// Secret is the path (without the leading slash) that sets a bypass cookie. Without a
+	// secret the application can not be bypassed by cookie.
+	Secret string `json:"secret,omitempty"`
+
+	// Retry is the number of seconds clients are told to wait in Retry-After; zero omits
+	// the header.
+	Retry int `json:"retry,omitempty"`
+
+	// Since is when the application was taken down.
+	Since time.Time `json:"since"`
+}
+
+// Mode reads and writes the flag file of an application. The file is checked on every
+// call to State, but only read again when it changes.
+type Mode struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	state   *State
+}
+
+// New returns the maintenance mode of the application at root.
+func New(root string) *Mode {
+	return &Mode{path: filepath.Join(root, FlagFile)}
+}
+
+// Path returns the path of the flag file.
+func (m *Mode) Path() string {
+	return m.path
+}
+
+// Down takes the application down by writing the flag file. Since defaults to now.
+func (m *Mode) Down(state State) error {
+	if state.Since.IsZero() {
+		state.Since = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+
+	// write and rename so a request never reads a half-written file
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+	return nil
+}
+
+// Up brings the application back up by removing the flag file.
+func (m *Mode) Up() error {
+	if err := os.Remove(m.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+	return nil
+}
+
+// State returns the maintenance window and whether the application is down. A flag file
+// that can not be parsed still takes the application down, without a secret.
+func (m *Mode) State() (*State, bool) {
+	info, err := os.Stat(m.path)
+	if err != nil {
+		m.mu.Lock()
+		m.state = nil
+		m.mu.Unlock()
+		return nil, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state != nil && info.ModTime().Equal(m.modTime) && info.Size() == m.size {
+		return m.state, true
+	}
+
+	state := &State{Since: info.ModTime().UTC()}
+	if data, err := os.ReadFile(m.path); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, state); err != nil {
+			state = &State{Since: info.ModTime().UTC()}
+		}
+	}
+
+	m.state, m.modTime, m.size = state, info.ModTime(), info.Size()
+	return state, true
+}
+
+// IsSecret reports whether a request path is /<secret>.
+func (s *State) IsSecret(path string) bool {
+	if s.Secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(path, "/")), []byte(s.Secret)) == 1
+}
+
+// Cookie returns a bypass cookie valid for BypassLifetime. The cookie is signed with the
+// secret, so taking the application down with a new secret invalidates earlier cookies.
+func (s *State) Cookie(now time.Time, secure bool) *http.Cookie {
+	expires := now.Add(BypassLifetime)
+	return &http.Cookie{
+		Name:     BypassCookie,
+		Value:    s.sign(expires.Unix()),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Bypasses reports whether a request carries a valid bypass cookie.
+func (s *State) Bypasses(r *http.Request, now time.Time) bool {
+	if s.Secret == "" {
+		return false
+	}
+
+	cookie, err := r.Cookie(BypassCookie)
+	if err != nil {
+		return false
+	}
+
+	expiry, _, found := strings.Cut(cookie.Value, ".")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if !found || err != nil || now.Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(cookie.Value), []byte(s.sign(unix)))
+}
+
+// Sign an expiry time as "<expiry>.<signature>".
+func (s *State) sign(expiry int64) string {
+	mac := hmac.New(sha256.New, []byte(s.Secret))
+	fmt.Fprintf(mac, "%d", expiry)
+	return fmt.Sprintf("%d.%s", expiry, hex.EncodeToString(mac.Sum(nil)))
+}
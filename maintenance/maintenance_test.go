@@ -0,0 +1,77 @@
+package maintenance
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMode_DownUp(t *testing.T) {
+	mode := New(t.TempDir())
+
+	if _, down := mode.State(); down {
+		t.Fatal("Expected the application to be up without a flag file")
+	}
+
+	if err := mode.Down(State{Secret: "s3cr3t", Retry: 60}); err != nil {
+		t.Fatal(err)
+	}
+	state, down := mode.State()
+	if !down || state.Secret != "s3cr3t" || state.Retry != 60 || state.Since.IsZero() {
+		t.Fatalf("Unexpected state %+v (down %v)", state, down)
+	}
+
+	if err := mode.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, down := mode.State(); down {
+		t.Error("Expected the application to be up after Up")
+	}
+	if err := mode.Up(); err != nil {
+		t.Errorf("Expected Up to be idempotent, got %v", err)
+	}
+}
+
+func TestMode_EmptyFlagFile(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(root+"/storage", 0755)
+	if err := os.WriteFile(root+"/"+FlagFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, down := New(root).State()
+	if !down || state.Secret != "" || state.Retry != 0 {
+		t.Errorf("Expected a touched flag file to take the application down, got %+v (down %v)", state, down)
+	}
+}
+
+func TestState_Bypass(t *testing.T) {
+	now := time.Now()
+	state := &State{Secret: "s3cr3t"}
+
+	if !state.IsSecret("/s3cr3t") || state.IsSecret("/s3cr3") || (&State{}).IsSecret("/") {
+		t.Error("Unexpected secret path matching")
+	}
+
+	cookie := state.Cookie(now, false)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	if !state.Bypasses(req, now) {
+		t.Error("Expected the cookie to bypass maintenance mode")
+	}
+	if state.Bypasses(req, now.Add(BypassLifetime+time.Minute)) {
+		t.Error("Expected an expired cookie not to bypass maintenance mode")
+	}
+	if (&State{Secret: "rotated"}).Bypasses(req, now) {
+		t.Error("Expected a cookie of another secret not to bypass maintenance mode")
+	}
+
+	forged := httptest.NewRequest("GET", "/", nil)
+	cookie.Value = "9999999999.00"
+	forged.AddCookie(cookie)
+	if state.Bypasses(forged, now) {
+		t.Error("Expected a forged cookie not to bypass maintenance mode")
+	}
+}
@@ -0,0 +1,57 @@
+package maintenance
+
+import (
+	"net/rpc"
+	"time"
+)
+
+// DownArgs are the arguments of the Maintenance.Down call.
+type DownArgs struct {
+	Secret string
+	Retry  int
+}
+
+// Reply is the reply of the Maintenance.Down and Maintenance.Up calls.
+type Reply struct {
+	Status string
+	Since  time.Time
+}
+
+// Service toggles maintenance mode over RPC.
+type Service struct {
+	Mode *Mode
+}
+
+// Down takes the application down.
+func (s *Service) Down(args *DownArgs, reply *Reply) error {
+	if err := s.Mode.Down(State{Secret: args.Secret, Retry: args.Retry}); err != nil {
+		return err
+	}
+	state, _ := s.Mode.State()
+	reply.Status = "down"
+	if state != nil {
+		reply.Since = state.Since
+	}
+	return nil
+}
+
+// Up brings the application back up.
+func (s *Service) Up(args *DownArgs, reply *Reply) error {
+	if err := s.Mode.Up(); err != nil {
+		return err
+	}
+	reply.Status = "up"
+	return nil
+}
+
+// Register publishes the Maintenance service on the RPC server of the framework, which
+// serves the default net/rpc server. It must be called before rpcserver.Start.
+//
+// Example:
+//
+//	client, _ := rpc.Dial("tcp", "127.0.0.1:4040")
+//	var reply maintenance.Reply
+//	err := client.Call("Maintenance.Down", &maintenance.DownArgs{Secret: "s3cr3t", Retry: 60}, &reply)
+func Register(mode *Mode) error {
+	return rpc.RegisterName("Maintenance", &Service{Mode: mode})
+}
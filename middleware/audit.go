@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"myapp/models"
+	"net/http"
+	"os"
+	"strings"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// AuditContext records who a request is made by and where it comes from in the request
+// context, so changes made through the repository layer while serving it are attributed in
+// the audit log. The principal is the authenticated user of the session (userID); requests
+// without one are recorded as "anonymous". The client IP is the one TrustedProxy resolved
+// (see ClientIP), which headers the client sends cannot forge.
+func (a *Middleware) AuditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source := models.AuditSource{
+			Actor:     "anonymous",
+			IP:        ClientIP(r),
+			RequestID: chimw.GetReqID(r.Context()),
+		}
+
+		if a.App != nil && a.App.Session != nil && a.App.Session.Exists(r.Context(), "userID") {
+			source.Actor = fmt.Sprint(a.App.Session.Get(r.Context(), "userID"))
+		}
+
+		next.ServeHTTP(w, r.WithContext(models.WithAuditSource(r.Context(), source)))
+	})
+}
+
+// AuditAPI guards the audit log API with a bearer token. The audit log holds the history of
+// every change, so the API answers 404 until a token is configured.
+//
+// Configuration via environment variables:
+//
+//	AUDIT_API_TOKEN: Token clients send as "Authorization: Bearer <token>"
+func (a *Middleware) AuditAPI(next http.Handler) http.Handler {
+	token := os.Getenv("AUDIT_API_TOKEN")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="audit"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
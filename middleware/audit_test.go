@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"myapp/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAuditContext_ClientIP(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "127.0.0.1")
+	os.Setenv("TRUST_PROXY_HEADERS", "for")
+	defer func() {
+		os.Unsetenv("TRUSTED_PROXIES")
+		os.Unsetenv("TRUST_PROXY_HEADERS")
+	}()
+
+	m := &Middleware{}
+	var source models.AuditSource
+	handler := m.TrustedProxy(m.AuditContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source = models.AuditSourceFromContext(r.Context())
+	})))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:12345"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if source.IP != "203.0.113.7" || source.Actor != "anonymous" {
+		t.Errorf("Expected the client behind the proxy as anonymous, got %+v", source)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if source.IP != "198.51.100.1" {
+		t.Errorf("Expected a forged X-Forwarded-For to be ignored, got %s", source.IP)
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"myapp/i18n"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Locale picks the locale of every request from the locales with a catalog (see the i18n
+// package), in order of preference:
+//   - a URL prefix naming the locale, /fr/about, which is removed before routing
+//   - the lang query parameter, ?lang=fr
+//   - the locale cookie
+//   - the Accept-Language header
+//   - the fallback locale (APP_LOCALE)
+//
+// A locale chosen by the URL prefix or the lang parameter is remembered in the cookie.
+// Handlers read the locale with i18n.LocaleFromContext, and views get it as locale.
+//
+// Configuration via environment variables:
+//
+//	LOCALE_COOKIE: The name of the cookie remembering the locale (default: "locale")
+//	LOCALE_URL_PREFIX: Set to "false" to not pick the locale from a URL prefix
+func (a *Middleware) Locale(next http.Handler) http.Handler {
+	if a.Lang == nil {
+		return next
+	}
+
+	supported := a.Lang.Locales()
+	cookieName := os.Getenv("LOCALE_COOKIE")
+	if cookieName == "" {
+		cookieName = "locale"
+	}
+	prefixes := os.Getenv("LOCALE_URL_PREFIX") != "false"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale, chosen := "", false
+
+		if prefixes {
+			if l, rest, ok := localePrefix(r.URL.Path, supported); ok {
+				locale, chosen = l, true
+				r = r.Clone(r.Context())
+				r.URL.Path, r.URL.RawPath = rest, ""
+			}
+		}
+
+		if locale == "" {
+			if l, ok := i18n.Supported(r.URL.Query().Get("lang"), supported); ok {
+				locale, chosen = l, true
+			}
+		}
+
+		if locale == "" {
+			if c, err := r.Cookie(cookieName); err == nil {
+				locale, _ = i18n.Supported(c.Value, supported)
+			}
+		}
+
+		if locale == "" {
+			locale = i18n.Match(r.Header.Get("Accept-Language"), supported)
+		}
+
+		if chosen {
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookieName,
+				Value:    locale,
+				Path:     "/",
+				Expires:  time.Now().AddDate(1, 0, 0),
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		w.Header().Set("Content-Language", locale)
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Add("Vary", "Cookie")
+
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+	})
+}
+
+// Split a path into the locale it starts with and the rest of it: /fr/about is fr and
+// /about, and /fr is fr and /.
+func localePrefix(path string, supported []string) (string, string, bool) {
+	segment, rest, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	for _, s := range supported {
+		if segment != "" && strings.EqualFold(segment, s) {
+			return s, "/" + rest, true
+		}
+	}
+	return "", "", false
+}
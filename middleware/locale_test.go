@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"myapp/i18n"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	bundle := i18n.NewBundle("en")
+	bundle.Add("fr", map[string]interface{}{"hello": "Bonjour"})
+
+	var locale, path string
+	m := &Middleware{Lang: bundle}
+	chain := m.Locale(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale, path = i18n.LocaleFromContext(r.Context()), r.URL.Path
+	}))
+
+	tests := []struct {
+		name, target, header, cookie string
+		locale, path                 string
+		remembered                   bool
+	}{
+		{"default", "/about", "", "", "en", "/about", false},
+		{"accept-language", "/about", "de-DE, fr-CA;q=0.8", "", "fr", "/about", false},
+		{"cookie", "/about", "en", "fr", "fr", "/about", false},
+		{"query", "/about?lang=fr", "en", "", "fr", "/about", true},
+		{"prefix", "/fr/about", "en", "", "fr", "/about", true},
+		{"prefix root", "/fr", "", "", "fr", "/", true},
+		{"not a locale", "/francais", "", "", "en", "/francais", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set("Accept-Language", tt.header)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "locale", Value: tt.cookie})
+		}
+		w := httptest.NewRecorder()
+		chain.ServeHTTP(w, req)
+
+		if locale != tt.locale || path != tt.path {
+			t.Errorf("%s: got locale %q and path %q, want %q and %q", tt.name, locale, path, tt.locale, tt.path)
+		}
+		if remembered := len(w.Result().Cookies()) > 0; remembered != tt.remembered {
+			t.Errorf("%s: remembered = %v, want %v", tt.name, remembered, tt.remembered)
+		}
+		if got := w.Header().Get("Content-Language"); got != tt.locale {
+			t.Errorf("%s: Content-Language = %q", tt.name, got)
+		}
+	}
+}
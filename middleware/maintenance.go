@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"encoding/json"
+	"myapp/i18n"
+	"myapp/maintenance"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CloudyKit/jet/v6"
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// Maintenance answers every request with 503 while the application is down for maintenance
+// (see the maintenance package), with a Retry-After header when the window has a retry. API
+// clients get a problem document; browsers get the maintenance view, with the data of the
+// template data providers (a.Views) like any other page. The application is also down
+// while the framework's maintenance flag is set over its RPC server.
+//
+// Requests still served while down:
+//   - paths listed in MAINTENANCE_URL, e.g., health checks
+//   - clients whose IP, as resolved by TrustedProxy, is listed in MAINTENANCE_ALLOW_IPS
+//   - clients holding a bypass cookie, set by visiting /<secret>
+//
+// Configuration via environment variables:
+//
+//	MAINTENANCE_URL: Comma-separated paths served while down (default: "/health,/api/health")
+//	MAINTENANCE_ALLOW_IPS: Comma-separated list of IPs/CIDRs served while down
+//	                      Examples: "203.0.113.7,10.0.0.0/8"
+func (a *Middleware) Maintenance(next http.Handler) http.Handler {
+	root := "."
+	if a.App != nil && a.App.RootPath != "" {
+		root = a.App.RootPath
+	}
+	mode := maintenance.New(root)
+
+	paths := maintenancePaths()
+	allowed := parseTrustedProxies(os.Getenv("MAINTENANCE_ALLOW_IPS"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, down := mode.State()
+		if !down && a.App != nil && a.App.MaintenanceMode {
+			state, down = &maintenance.State{}, true
+		}
+		if !down {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if state.IsSecret(r.URL.Path) {
+			http.SetCookie(w, state.Cookie(time.Now(), r.TLS != nil))
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		if servedWhileDown(r.URL.Path, paths) || isTrustedProxy(ClientIP(r), allowed) || state.Bypasses(r, time.Now()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		a.unavailable(w, r, state)
+	})
+}
+
+// Answer a request with 503 during a maintenance window.
+func (a *Middleware) unavailable(w http.ResponseWriter, r *http.Request, state *maintenance.State) {
+	const detail = "The application is down for maintenance."
+
+	w.Header().Set("Cache-Control", "no-store")
+	if state.Retry > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(state.Retry))
+	}
+
+	accept := r.Header.Get("Accept")
+	api := r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") ||
+		(strings.Contains(accept, "json") && !strings.Contains(accept, "text/html"))
+
+	if api || a.App == nil || a.App.Helpers == nil {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(struct {
+			Type      string `json:"type"`
+			Title     string `json:"title"`
+			Status    int    `json:"status"`
+			Detail    string `json:"detail"`
+			RequestID string `json:"request_id,omitempty"`
+		}{"about:blank", http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable, detail, chimw.GetReqID(r.Context())})
+		return
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("status", http.StatusServiceUnavailable)
+	vars.Set("title", http.StatusText(http.StatusServiceUnavailable))
+	vars.Set("detail", detail)
+	vars.Set("requestID", chimw.GetReqID(r.Context()))
+	vars.Set("retry", state.Retry)
+	if locale := i18n.LocaleFromContext(r.Context()); locale != "" {
+		vars.Set("locale", locale)
+	}
+	if a.Views != nil {
+		a.Views.Apply(r, vars)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := a.App.Helpers.Render(w, r, "maintenance", vars, nil); err != nil {
+		a.App.Log.Error("error rendering:", err)
+	}
+}
+
+// Report whether a path is one of the paths served while down, or below one.
+func servedWhileDown(path string, paths []string) bool {
+	for _, p := range paths {
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// The paths served while down, from MAINTENANCE_URL.
+func maintenancePaths() []string {
+	if paths := splitList(os.Getenv("MAINTENANCE_URL")); len(paths) > 0 {
+		return paths
+	}
+	return []string{"/health", "/api/health"}
+}
+
+// Split a comma-separated list, dropping blanks.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
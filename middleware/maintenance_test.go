@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"errors"
+	"myapp/flash"
+	"myapp/maintenance"
+	"myapp/views"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/alexedwards/scs/v2"
+	"github.com/cidekar/adele-framework"
+	"github.com/cidekar/adele-framework/helpers"
+	"github.com/cidekar/adele-framework/render"
+)
+
+func TestMaintenance(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "127.0.0.1")
+	os.Setenv("TRUST_PROXY_HEADERS", "for")
+	os.Setenv("MAINTENANCE_ALLOW_IPS", "203.0.113.0/24")
+	defer func() {
+		os.Unsetenv("TRUSTED_PROXIES")
+		os.Unsetenv("TRUST_PROXY_HEADERS")
+		os.Unsetenv("MAINTENANCE_ALLOW_IPS")
+	}()
+
+	root := t.TempDir()
+	m := &Middleware{App: &adele.Adele{RootPath: root}}
+	chain := m.TrustedProxy(m.Maintenance(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		chain.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(httptest.NewRequest("GET", "/api/users", nil)); w.Code != http.StatusOK {
+		t.Fatalf("Expected requests to be served while up, got %d", w.Code)
+	}
+
+	if err := maintenance.New(root).Down(maintenance.State{Secret: "s3cr3t", Retry: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(httptest.NewRequest("GET", "/api/users", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected 503 with Retry-After while down, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if w := serve(httptest.NewRequest("GET", "/health", nil)); w.Code != http.StatusOK {
+		t.Errorf("Expected the health check to be served while down, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 127.0.0.1")
+	if w := serve(req); w.Code != http.StatusOK {
+		t.Errorf("Expected an allowlisted IP behind a trusted proxy to be served, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if w := serve(req); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a spoofed X-Forwarded-For to be ignored, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.1")
+	if w := serve(req); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a spoofed leftmost X-Forwarded-For to be ignored, got %d", w.Code)
+	}
+
+	w = serve(httptest.NewRequest("GET", "/s3cr3t", nil))
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusFound || len(cookies) != 1 {
+		t.Fatalf("Expected the secret to redirect with a bypass cookie, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	if w := serve(req); w.Code != http.StatusOK {
+		t.Errorf("Expected the bypass cookie to be served, got %d", w.Code)
+	}
+}
+
+func TestMaintenance_PageData(t *testing.T) {
+	root := t.TempDir()
+	if err := maintenance.New(root).Down(maintenance.State{}); err != nil {
+		t.Fatal(err)
+	}
+
+	session := scs.New()
+	store := flash.New(session)
+	registry := views.NewRegistry()
+	registry.Register("appName", views.AppName("myapp"))
+	registry.Register("flashes", views.Flashes(store))
+
+	loader := jet.NewInMemLoader()
+	loader.Set("/maintenance.jet", `{{ appName }}|{{ range _, m := flashes }}{{ m.Text }}{{ end }}|{{ old.Get("email") }}|{{ errors.First("form") }}`)
+
+	m := &Middleware{
+		App: &adele.Adele{
+			RootPath: root,
+			Helpers: &helpers.Helpers{Redner: &render.Render{
+				Renderer: "jet",
+				JetViews: jet.NewSet(loader),
+				Session:  session,
+			}},
+		},
+		Views: registry,
+	}
+
+	chain := session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store.Success(r.Context(), "Saved.")
+		store.Input(r.Context(), url.Values{"email": {"a@example.com"}}, errors.New("Try again."))
+		m.Maintenance(http.NotFoundHandler()).ServeHTTP(w, r)
+	}))
+
+	w := httptest.NewRecorder()
+	chain.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != "myapp|Saved.|a@example.com|Try again." {
+		t.Errorf("Expected the maintenance page to get the provided data, got %q", body)
+	}
+}
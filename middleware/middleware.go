@@ -1,12 +1,21 @@
 package middleware
 
 import (
+	"myapp/i18n"
 	"myapp/models"
+	"myapp/services"
+	"myapp/views"
 
 	"github.com/cidekar/adele-framework"
 )
 
 type Middleware struct {
 	App    *adele.Adele
+	Lang   *i18n.Bundle
 	Models *models.Models
+	// Services are those the providers publish; see the services package.
+	Services *services.Container
+	// Views are the template data providers, run for the pages middleware renders itself,
+	// e.g., the maintenance page; see the views package.
+	Views *views.Registry
 }
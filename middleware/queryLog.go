@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"fmt"
+	"myapp/models"
+	"net/http"
+
+	"github.com/cidekar/adele-framework/logger"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// QueryLog collects the statements executed while serving a request and reports statement
+// shapes that repeat within the request, the typical signature of an N+1 query. For the
+// statements to be attributed to the request, sessions must carry the request context:
+//
+//	models.DB.WithContext(r.Context()).Collection("users").Find(...)
+//
+// Findings are attached to the request log entry, each as its count, statement and caller,
+// when the structured request logger is enabled (debug mode); otherwise each is written as
+// a warning of its own.
+func (a *Middleware) QueryLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, collector := models.WithQueryCollector(r.Context())
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+
+		count, duration := collector.Count()
+		if count == 0 {
+			return
+		}
+
+		fields := logrus.Fields{
+			"db_queries": count,
+			"db_time_ms": float64(duration.Nanoseconds()) / 1000000.0,
+		}
+
+		repeated := collector.Repeated()
+		if len(repeated) > 0 {
+			var findings []string
+			for _, shape := range repeated {
+				findings = append(findings, fmt.Sprintf("%dx %s (%s)", shape.Count, shape.Statement, shape.Caller))
+			}
+			fields["db_repeated"] = findings
+		}
+
+		// The request logger writes its entry after this middleware returns, so the fields
+		// are added to that entry rather than logged on their own.
+		if entry, ok := chimw.GetLogEntry(r).(*logger.StructuredLoggerEntry); ok {
+			entry.Logger = entry.Logger.WithFields(fields)
+			return
+		}
+
+		for _, shape := range repeated {
+			a.App.Log.WithFields(logrus.Fields{
+				"req_id": chimw.GetReqID(ctx),
+				"path":   r.URL.Path,
+				"sql":    shape.Statement,
+				"count":  shape.Count,
+				"caller": shape.Caller,
+			}).Warn("possible N+1 query")
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"myapp/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/cidekar/adele-framework"
+	"github.com/cidekar/adele-framework/logger"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	upper "github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/sqlite"
+)
+
+// Serve a request running the same query four times, once with the structured request
+// logger installed and once without.
+func TestQueryLog_Findings(t *testing.T) {
+	sess, err := sqlite.Open(sqlite.ConnectionURL{Database: filepath.Join(t.TempDir(), "queries.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	if _, err := sess.SQL().Exec(`CREATE TABLE widgets (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+
+	queryLog, _ := test.NewNullLogger()
+	models.EnableQueryLog(sess, queryLog, models.QueryLogConfig{RepeatThreshold: 3})
+	t.Cleanup(func() {
+		upper.LC().SetLogger(nil)
+		upper.LC().SetLevel(upper.LogLevelWarn)
+	})
+
+	log, hook := test.NewNullLogger()
+	m := &Middleware{App: &adele.Adele{Log: log}}
+	handler := m.QueryLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for id := 1; id <= 4; id++ {
+			sess.WithContext(r.Context()).Collection("widgets").Find(id).Count()
+		}
+	}))
+
+	// debug mode: the findings go on the request entry
+	requestLog, requestHook := test.NewNullLogger()
+	entry := &logger.StructuredLoggerEntry{Logger: logrus.NewEntry(requestLog)}
+	r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), chimw.WithLogEntry(r, entry))
+
+	if len(hook.AllEntries()) != 0 {
+		t.Errorf("Expected no warning of its own in debug mode, got %v", hook.AllEntries())
+	}
+	entry.Logger.Info("request")
+	fields := requestHook.LastEntry().Data
+	findings, _ := fields["db_repeated"].([]string)
+	if fields["db_queries"] == nil || len(findings) != 1 {
+		t.Errorf("Expected the findings on the request entry, got %v", fields)
+	}
+
+	// otherwise: a warning per finding
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/widgets", nil))
+
+	warnings := hook.AllEntries()
+	if len(warnings) != 1 || warnings[0].Level != logrus.WarnLevel || warnings[0].Data["count"] != 4 {
+		t.Errorf("Expected a warning for the repeated query, got %v", warnings)
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"myapp/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The cookie carrying the time of a client's last write, in Unix milliseconds.
+const lastWriteCookie = "last_write"
+
+// ReadYourWrites makes the request context track database writes. Once a handler writes
+// through the repository layer, its reads are sent to the primary for the sticky window
+// (DATABASE_STICKY_WINDOW) instead of a replica that may not have the write yet.
+//
+// The time of the write is also set in a cookie lasting for the sticky window, so the
+// client's next requests read from the primary too, e.g., the page a form redirects to
+// after it is posted. A client can only make its own reads go to the primary with the
+// cookie, and times in the future are ignored.
+func (a *Middleware) ReadYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if c, err := r.Cookie(lastWriteCookie); err == nil {
+			if ms, err := strconv.ParseInt(c.Value, 10, 64); err == nil && ms <= time.Now().UnixMilli() {
+				since = time.UnixMilli(ms)
+			}
+		}
+
+		ctx := models.WithReadYourWrites(r.Context(), since)
+		lw := &lastWriteWriter{ResponseWriter: w, ctx: ctx, since: since}
+		next.ServeHTTP(lw, r.WithContext(ctx))
+
+		// a handler that wrote nothing is answered once it returns
+		lw.setCookie()
+	})
+}
+
+// A response writer setting the last write cookie before the response is written, when
+// the request wrote to the database.
+type lastWriteWriter struct {
+	http.ResponseWriter
+	ctx   context.Context
+	since time.Time
+	done  bool
+}
+
+func (w *lastWriteWriter) WriteHeader(code int) {
+	w.setCookie()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *lastWriteWriter) Write(b []byte) (int, error) {
+	w.setCookie()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the response writer, for http.ResponseController.
+func (w *lastWriteWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *lastWriteWriter) setCookie() {
+	if w.done {
+		return
+	}
+	w.done = true
+
+	lastWrite := models.LastWrite(w.ctx)
+	if models.Sessions == nil || len(models.Sessions.Replicas()) == 0 || models.Sessions.StickyWindow <= 0 || !lastWrite.After(w.since) {
+		return
+	}
+
+	http.SetCookie(w.ResponseWriter, &http.Cookie{
+		Name:     lastWriteCookie,
+		Value:    strconv.FormatInt(lastWrite.UnixMilli(), 10),
+		Path:     "/",
+		MaxAge:   int(math.Max(1, math.Ceil(models.Sessions.StickyWindow.Seconds()))),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"myapp/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	upper "github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/sqlite"
+)
+
+type widget struct {
+	ID   int    `db:"id,omitempty"`
+	Name string `db:"name"`
+}
+
+// Set up a primary and a replica that are separate databases, so the session a read ran on
+// can be told apart by the rows it sees.
+func setupReplicas(t *testing.T) {
+	open := func(name string) upper.Session {
+		sess, err := sqlite.Open(sqlite.ConnectionURL{Database: filepath.Join(t.TempDir(), name+".db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sess.Close() })
+		if _, err := sess.SQL().Exec(`CREATE TABLE widgets (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)`); err != nil {
+			t.Fatal(err)
+		}
+		return sess
+	}
+
+	primary, replica := open("primary"), open("replica")
+	if _, err := replica.Collection("widgets").Insert(&widget{Name: "from-replica"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := models.Sessions
+	models.Sessions = models.NewSessionRouter(primary, "sqlite")
+	models.Sessions.AddReplica("replica", replica)
+	models.Sessions.CheckReplicas()
+	t.Cleanup(func() { models.Sessions = sessions })
+}
+
+func TestReadYourWrites_AcrossRequests(t *testing.T) {
+	setupReplicas(t)
+	widgets := models.NewGlobalRepository[widget]("widgets")
+
+	m := &Middleware{}
+	handler := m.ReadYourWrites(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if _, err := widgets.Insert(r.Context(), &widget{Name: "written"}); err != nil {
+				t.Fatal(err)
+			}
+			http.Redirect(w, r, "/widgets", http.StatusSeeOther)
+			return
+		}
+		items, err := widgets.All(r.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, items[0].Name)
+	}))
+
+	// the form is posted and redirects
+	post := httptest.NewRecorder()
+	handler.ServeHTTP(post, httptest.NewRequest("POST", "/widgets", nil))
+	cookies := post.Result().Cookies()
+	if post.Code != http.StatusSeeOther || len(cookies) != 1 || cookies[0].Name != lastWriteCookie {
+		t.Fatalf("Expected a redirect setting the last write cookie, got %d %v", post.Code, cookies)
+	}
+
+	// the page it redirects to reads the write from the primary
+	req := httptest.NewRequest("GET", "/widgets", nil)
+	req.AddCookie(cookies[0])
+	get := httptest.NewRecorder()
+	handler.ServeHTTP(get, req)
+	if get.Body.String() != "written" {
+		t.Errorf("Expected the next request to read from the primary, got %q", get.Body.String())
+	}
+	if len(get.Result().Cookies()) != 0 {
+		t.Errorf("Expected a request that did not write to leave the cookie, got %v", get.Result().Cookies())
+	}
+
+	// other clients, and the client once the window is over, read from the replica
+	other := httptest.NewRecorder()
+	handler.ServeHTTP(other, httptest.NewRequest("GET", "/widgets", nil))
+	if other.Body.String() != "from-replica" {
+		t.Errorf("Expected a client without the cookie to read from the replica, got %q", other.Body.String())
+	}
+
+	models.Sessions.StickyWindow = 0
+	late := httptest.NewRecorder()
+	handler.ServeHTTP(late, req.Clone(context.Background()))
+	if late.Body.String() != "from-replica" {
+		t.Errorf("Expected a read after the sticky window to use the replica, got %q", late.Body.String())
+	}
+}
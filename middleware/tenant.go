@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"errors"
+	"myapp/models"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	upper "github.com/upper/db/v4"
+)
+
+var reTenantSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// TenantResolver finds the tenant a request is for and puts it into the request context, so
+// the models repository layer scopes every statement made with the context to the tenant.
+// The host is read after TrustedProxy has applied X-Forwarded-Host, so it must run after
+// TrustedProxy in the chain.
+//
+// A request naming an unknown tenant is answered with 404. A request that names no tenant
+// at all (e.g., the apex domain) is passed on without one; tenant-scoped repositories refuse
+// to run for it.
+//
+// Configuration via environment variables:
+//
+//	TENANT_RESOLVER: How the tenant is named by a request: "subdomain", "header" or "path"
+//	                 (default: subdomain)
+//	TENANT_DOMAIN: The domain tenant subdomains live under, e.g. "example.com" resolves
+//	               "acme.example.com" to the tenant acme
+//	TENANT_HEADER: The header naming the tenant in header mode (default: X-Tenant)
+//	TENANT_PATH_RESERVED: Comma-separated first path segments that never name a tenant in
+//	                      path mode (default: public,api)
+//
+// In path mode the first path segment names the tenant ("/acme/orders") and is stripped
+// before routing, so routes are declared without it. Besides TENANT_PATH_RESERVED, the
+// development tools (/_dev) and the first segments of the paths served during maintenance
+// (MAINTENANCE_URL, e.g., /health) never name a tenant. The maintenance bypass (/<secret>)
+// is answered by Maintenance before the tenant is resolved.
+func (a *Middleware) TenantResolver(next http.Handler) http.Handler {
+	mode := strings.ToLower(os.Getenv("TENANT_RESOLVER"))
+	domain := strings.ToLower(strings.Trim(os.Getenv("TENANT_DOMAIN"), "."))
+	header := os.Getenv("TENANT_HEADER")
+	if header == "" {
+		header = "X-Tenant"
+	}
+	reserved := []string{"public", "api"}
+	if list := splitList(os.Getenv("TENANT_PATH_RESERVED")); len(list) > 0 {
+		reserved = list
+	}
+	reserved = append(reserved, "_dev")
+	for _, path := range maintenancePaths() {
+		if segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/"); segment != "" {
+			reserved = append(reserved, segment)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if models.TenantIsolation == "" || a.Models == nil || a.Models.Tenants == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var slug string
+		switch mode {
+		case "header":
+			slug = strings.ToLower(strings.TrimSpace(r.Header.Get(header)))
+		case "path":
+			slug, r = tenantFromPath(r, reserved)
+		default:
+			slug = tenantFromHost(r.Host, domain)
+		}
+
+		if slug == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !reTenantSlug.MatchString(slug) {
+			http.NotFound(w, r)
+			return
+		}
+
+		tenant, err := a.Models.Tenants.BySlug(r.Context(), slug)
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			a.App.Log.WithError(err).Error("error resolving tenant")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(models.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// tenantFromHost returns the subdomain of the host under the tenant domain. Without a
+// configured domain the first label of a host with at least three labels is used. The
+// www subdomain names no tenant, as the apex domain.
+func tenantFromHost(host, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+
+	if domain != "" {
+		sub, found := strings.CutSuffix(host, "."+domain)
+		if !found || strings.Contains(sub, ".") || sub == "www" {
+			return ""
+		}
+		return sub
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 3 || labels[0] == "www" {
+		return ""
+	}
+	return labels[0]
+}
+
+// tenantFromPath returns the first segment of the request path, and a copy of the request
+// with the segment stripped so routing sees the path without the tenant. The request is
+// left as it is.
+func tenantFromPath(r *http.Request, reserved []string) (string, *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	slug, rest, _ := strings.Cut(path, "/")
+	if slug == "" || contains(reserved, slug) {
+		return "", r
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.Path = "/" + rest
+	if r.URL.RawPath != "" {
+		r.URL.RawPath = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.RawPath, "/"+slug), "/")
+	}
+
+	return slug, r
+}
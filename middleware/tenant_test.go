@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"myapp/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/upper/db/v4/adapter/sqlite"
+)
+
+// Set up column isolation with a tenants table holding acme.
+func setupTenants(t *testing.T) *Middleware {
+	sess, err := sqlite.Open(sqlite.ConnectionURL{Database: filepath.Join(t.TempDir(), "tenants.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sess.Close() })
+
+	if _, err := sess.SQL().Exec(`CREATE TABLE tenants (id INTEGER PRIMARY KEY AUTOINCREMENT, slug TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL, schema_name TEXT NOT NULL DEFAULT '', created_at DATETIME, updated_at DATETIME)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sess.SQL().Exec(`INSERT INTO tenants (slug, name, created_at, updated_at) VALUES ('acme', 'Acme', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, isolation := models.Sessions, models.TenantIsolation
+	models.Sessions = models.NewSessionRouter(sess, "sqlite")
+	models.TenantIsolation = models.TenantIsolationColumn
+	t.Cleanup(func() { models.Sessions, models.TenantIsolation = sessions, isolation })
+
+	return &Middleware{Models: &models.Models{Tenants: models.NewTenantRepository()}}
+}
+
+// Serve a request through the resolver and report the status, the tenant slug seen by the
+// handler and the path it was routed with.
+func serveTenant(handler func(http.Handler) http.Handler, req *http.Request) (int, string, string) {
+	var slug, path string
+	h := handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenant := models.TenantFromContext(r.Context()); tenant != nil {
+			slug = tenant.Slug
+		}
+		path = r.URL.Path
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code, slug, path
+}
+
+func TestTenantResolver_SubdomainFromTrustedProxy(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "127.0.0.1")
+	os.Setenv("TENANT_DOMAIN", "example.com")
+	defer func() {
+		os.Unsetenv("TRUSTED_PROXIES")
+		os.Unsetenv("TENANT_DOMAIN")
+	}()
+
+	m := setupTenants(t)
+	chain := func(next http.Handler) http.Handler { return m.TrustedProxy(m.TenantResolver(next)) }
+
+	req := httptest.NewRequest("GET", "http://localhost/orders", nil)
+	req.RemoteAddr = "127.0.0.1:12345"
+	req.Header.Set("X-Forwarded-Host", "acme.example.com")
+
+	if code, slug, _ := serveTenant(chain, req); code != http.StatusOK || slug != "acme" {
+		t.Errorf("Expected tenant acme, got %d %q", code, slug)
+	}
+
+	req = httptest.NewRequest("GET", "http://initech.example.com/orders", nil)
+	if code, _, _ := serveTenant(chain, req); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown tenant, got %d", code)
+	}
+
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	if code, slug, _ := serveTenant(chain, req); code != http.StatusOK || slug != "" {
+		t.Errorf("Expected the apex domain to pass without a tenant, got %d %q", code, slug)
+	}
+
+	os.Unsetenv("TENANT_DOMAIN")
+	req = httptest.NewRequest("GET", "http://www.example.com/", nil)
+	if code, slug, _ := serveTenant(chain, req); code != http.StatusOK || slug != "" {
+		t.Errorf("Expected www to pass without a tenant and without a domain, got %d %q", code, slug)
+	}
+}
+
+func TestTenantResolver_HeaderAndPath(t *testing.T) {
+	m := setupTenants(t)
+
+	os.Setenv("TENANT_RESOLVER", "header")
+	req := httptest.NewRequest("GET", "http://localhost/orders", nil)
+	req.Header.Set("X-Tenant", "acme")
+	code, slug, _ := serveTenant(m.TenantResolver, req)
+	if code != http.StatusOK || slug != "acme" {
+		t.Errorf("Expected tenant acme from the header, got %d %q", code, slug)
+	}
+
+	os.Setenv("TENANT_RESOLVER", "path")
+	defer os.Unsetenv("TENANT_RESOLVER")
+
+	req = httptest.NewRequest("GET", "http://localhost/acme/orders/7", nil)
+	code, slug, path := serveTenant(m.TenantResolver, req)
+	if code != http.StatusOK || slug != "acme" || path != "/orders/7" {
+		t.Errorf("Expected tenant acme and path /orders/7, got %d %q %q", code, slug, path)
+	}
+	if req.URL.Path != "/acme/orders/7" {
+		t.Errorf("Expected the request to be left as it is, got %q", req.URL.Path)
+	}
+
+	code, slug, path = serveTenant(m.TenantResolver, httptest.NewRequest("GET", "http://localhost/public/app.css", nil))
+	if code != http.StatusOK || slug != "" || path != "/public/app.css" {
+		t.Errorf("Expected reserved segment to pass untouched, got %d %q %q", code, slug, path)
+	}
+
+	code, slug, path = serveTenant(m.TenantResolver, httptest.NewRequest("GET", "http://localhost/api/health", nil))
+	if code != http.StatusOK || slug != "" || path != "/api/health" {
+		t.Errorf("Expected the api segment to be reserved, got %d %q %q", code, slug, path)
+	}
+
+	os.Setenv("MAINTENANCE_URL", "/status,/health")
+	defer os.Unsetenv("MAINTENANCE_URL")
+	for _, p := range []string{"/_dev/livereload", "/_dev/mail/preview", "/health", "/status"} {
+		code, slug, path = serveTenant(m.TenantResolver, httptest.NewRequest("GET", "http://localhost"+p, nil))
+		if code != http.StatusOK || slug != "" || path != p {
+			t.Errorf("Expected %s to be reserved, got %d %q %q", p, code, slug, path)
+		}
+	}
+}
+
+func TestTenantFromHost(t *testing.T) {
+	tests := []struct {
+		host, domain, want string
+	}{
+		{"acme.example.com", "example.com", "acme"},
+		{"acme.example.com:8080", "example.com", "acme"},
+		{"a.b.example.com", "example.com", ""},
+		{"acme.other.com", "example.com", ""},
+		{"acme.example.com", "", "acme"},
+		{"example.com", "", ""},
+		{"www.example.com", "", ""},
+		{"www.example.com", "example.com", ""},
+		{"127.0.0.1:4000", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := tenantFromHost(tt.host, tt.domain); got != tt.want {
+			t.Errorf("tenantFromHost(%q, %q) = %q, want %q", tt.host, tt.domain, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
@@ -29,6 +30,17 @@ import (
 //	TRUST_PROXY_HEADERS: Comma-separated list of headers to trust
 //	                    Examples: "proto,host" or "proto,host,port,for"
 //
+// With "for" trusted, the client IP of a request whose connection is made by a trusted
+// proxy is read from X-Forwarded-For right to left, as each proxy appends the address it
+// got the request from: it is the first address that is not a trusted proxy, since those
+// left of it are whatever the client sent. Otherwise it is the address of the connection.
+// Either way it is available to later middleware through ClientIP, and is set as the
+// request's RemoteAddr.
+//
+// The address of the connection is the one ConnContext records, as the framework's
+// router rewrites RemoteAddr from X-Real-IP, True-Client-IP and X-Forwarded-For before
+// any middleware of the application runs; the server must set it as its ConnContext.
+//
 // Security considerations:
 //   - Never set TRUSTED_PROXIES to "*" or "0.0.0.0/0" in production
 //   - Only include your actual reverse proxy IPs
@@ -40,11 +52,11 @@ func (a *Middleware) TrustedProxy(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		// Extract client IP (could be from X-Real-IP or direct connection)
-		clientIP := getClientIP(r)
+		// The address of the connection, not one a header set
+		peer, port := peerAddr(r)
 
 		// Only process headers if request comes from a trusted proxy
-		if isTrustedProxy(clientIP, trustedProxies) {
+		if isTrustedProxy(peer, trustedProxies) {
 			// Process X-Forwarded-Proto if trusted
 			if contains(trustedHeaders, "proto") {
 				if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" {
@@ -63,11 +75,75 @@ func (a *Middleware) TrustedProxy(next http.Handler) http.Handler {
 
 		}
 
+		// Resolve the client IP from the connection, or from X-Forwarded-For when the
+		// connection is made by a trusted proxy and the header is trusted
+		resolved := peer
+		if contains(trustedHeaders, "for") && isTrustedProxy(peer, trustedProxies) {
+			resolved = forwardedFor(r.Header.Values("X-Forwarded-For"), peer, trustedProxies)
+		}
+		r.RemoteAddr = net.JoinHostPort(resolved, port)
+
 		// If not from trusted proxy, ignore all headers (secure default)
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, resolved)))
 	})
 }
 
+type clientIPKey struct{}
+
+type connAddrKey struct{}
+
+// ConnContext records the address of a connection in the context of its requests, for
+// TrustedProxy to tell the peer apart from the address a header claims. It is to be set
+// as the http.Server's ConnContext.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connAddrKey{}, c.RemoteAddr().String())
+}
+
+// The IP and port of the connection of a request, as ConnContext recorded it; a request
+// served without it, e.g., in a test, has its RemoteAddr taken as is.
+func peerAddr(r *http.Request) (ip, port string) {
+	addr, ok := r.Context().Value(connAddrKey{}).(string)
+	if !ok {
+		addr = r.RemoteAddr
+	}
+	if ip, port, err := net.SplitHostPort(addr); err == nil {
+		return ip, port
+	}
+	return addr, "0"
+}
+
+// The client of a request forwarded by trusted proxies: the rightmost address of the
+// X-Forwarded-For headers that is not a trusted proxy, or the leftmost when all are.
+func forwardedFor(headers []string, peer string, trustedProxies []*net.IPNet) string {
+	var hops []string
+	for _, h := range headers {
+		for _, hop := range strings.Split(h, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		client = hops[i]
+		if !isTrustedProxy(client, trustedProxies) {
+			break
+		}
+	}
+	return client
+}
+
+// ClientIP returns the IP of the client that made a request, as resolved by TrustedProxy.
+// Requests that did not pass through TrustedProxy get the address of the connection.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	ip, _ := peerAddr(r)
+	return ip
+}
+
 // parseTrustedProxies converts environment string to list of trusted networks
 func parseTrustedProxies(proxyList string) []*net.IPNet {
 	if proxyList == "" {
@@ -114,29 +190,6 @@ func parseTrustedHeaders(headerList string) []string {
 	return headers
 }
 
-// getClientIP extracts the real client IP, handling various proxy scenarios
-func getClientIP(r *http.Request) string {
-	// Try X-Real-IP first (most reliable from reverse proxy)
-	if ip := r.Header.Get("X-Real-IP"); ip != "" {
-		return ip
-	}
-
-	// Try X-Forwarded-For (could be comma-separated list)
-	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
-		// Take the first IP (original client)
-		if parts := strings.Split(ip, ","); len(parts) > 0 {
-			return strings.TrimSpace(parts[0])
-		}
-	}
-
-	// Fall back to direct connection IP
-	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		return ip
-	}
-
-	return r.RemoteAddr
-}
-
 // isTrustedProxy checks if the given IP is in the trusted proxy list
 func isTrustedProxy(ip string, trustedNetworks []*net.IPNet) bool {
 	if len(trustedNetworks) == 0 {
@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"context"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
 func TestTrustedProxy_TrustedIPWithHTTPS(t *testing.T) {
@@ -274,61 +278,6 @@ func TestParseTrustedHeaders(t *testing.T) {
 	}
 }
 
-func TestGetClientIP(t *testing.T) {
-	testCases := []struct {
-		name       string
-		remoteAddr string
-		headers    map[string]string
-		expected   string
-	}{
-		{
-			name:       "X-Real-IP priority",
-			remoteAddr: "192.168.1.1:12345",
-			headers: map[string]string{
-				"X-Real-IP":       "203.0.113.1",
-				"X-Forwarded-For": "203.0.113.2",
-			},
-			expected: "203.0.113.1",
-		},
-		{
-			name:       "X-Forwarded-For fallback",
-			remoteAddr: "192.168.1.1:12345",
-			headers: map[string]string{
-				"X-Forwarded-For": "203.0.113.1, 192.168.1.1",
-			},
-			expected: "203.0.113.1",
-		},
-		{
-			name:       "RemoteAddr fallback",
-			remoteAddr: "203.0.113.1:12345",
-			headers:    map[string]string{},
-			expected:   "203.0.113.1",
-		},
-		{
-			name:       "IPv6",
-			remoteAddr: "[::1]:12345",
-			headers:    map[string]string{},
-			expected:   "::1",
-		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/", nil)
-			req.RemoteAddr = tc.remoteAddr
-
-			for k, v := range tc.headers {
-				req.Header.Set(k, v)
-			}
-
-			result := getClientIP(req)
-			if result != tc.expected {
-				t.Errorf("Expected %s, got %s", tc.expected, result)
-			}
-		})
-	}
-}
-
 func TestIsTrustedProxy(t *testing.T) {
 	networks := parseTrustedProxies("127.0.0.1,192.168.1.0/24")
 
@@ -360,3 +309,84 @@ func TestIsTrustedProxy_EmptyList(t *testing.T) {
 		t.Error("Expected false when no proxies are trusted")
 	}
 }
+
+func TestTrustedProxy_SpoofedHeaders(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+	os.Setenv("TRUST_PROXY_HEADERS", "proto,for")
+	defer func() {
+		os.Unsetenv("TRUSTED_PROXIES")
+		os.Unsetenv("TRUST_PROXY_HEADERS")
+	}()
+
+	// served as the application is: RealIP, as the framework's router runs it, then
+	// TrustedProxy, on a server recording the address of the connection
+	m := &Middleware{}
+	server := httptest.NewUnstartedServer(chimiddleware.RealIP(m.TrustedProxy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, ClientIP(r)+" "+r.URL.Scheme)
+	}))))
+	server.Config.ConnContext = ConnContext
+	server.Start()
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("X-Real-IP", "10.0.0.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if got := string(body); got != "127.0.0.1 " {
+		t.Errorf("Expected the connection's address and no trusted headers, got %q", got)
+	}
+}
+
+func TestTrustedProxy_ForwardedForRightmostUntrusted(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "127.0.0.1,10.0.0.0/8")
+	os.Setenv("TRUST_PROXY_HEADERS", "for")
+	defer func() {
+		os.Unsetenv("TRUSTED_PROXIES")
+		os.Unsetenv("TRUST_PROXY_HEADERS")
+	}()
+
+	m := &Middleware{}
+	var clientIP, remoteAddr string
+	handler := m.TrustedProxy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP, remoteAddr = ClientIP(r), r.RemoteAddr
+	}))
+
+	testCases := []struct {
+		name      string
+		forwarded []string
+		expected  string
+	}{
+		{"spoofed leftmost", []string{"10.0.0.5, 203.0.113.7"}, "203.0.113.7"},
+		{"behind proxies", []string{"198.51.100.1, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{"across headers", []string{"10.0.0.5", "203.0.113.7"}, "203.0.113.7"},
+		{"all trusted", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"none", nil, "127.0.0.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			// RealIP has rewritten RemoteAddr; the connection is the local proxy's
+			req.RemoteAddr = "10.0.0.5"
+			req = req.WithContext(context.WithValue(req.Context(), connAddrKey{}, "127.0.0.1:12345"))
+			for _, v := range tc.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if clientIP != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, clientIP)
+			}
+			if remoteAddr != net.JoinHostPort(tc.expected, "12345") {
+				t.Errorf("Expected RemoteAddr to be set to the client, got %s", remoteAddr)
+			}
+		})
+	}
+}
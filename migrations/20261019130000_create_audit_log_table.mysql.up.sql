@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    tenant_id INTEGER NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    table_name VARCHAR(255) NOT NULL,
+    record_id VARCHAR(255) NOT NULL,
+    changes JSON NOT NULL DEFAULT (JSON_OBJECT()),
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX audit_log_entity_idx (table_name, record_id),
+    INDEX audit_log_actor_idx (actor),
+    INDEX audit_log_tenant_idx (tenant_id)
+);
+
+-- The audit log is append-only: rows can be inserted but never changed or removed. MySQL
+-- fires no trigger for TRUNCATE, so revoke DROP on the table from the application's user.
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS mail_outbox (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    idempotency_key VARCHAR(255) NOT NULL DEFAULT '',
+    message JSON NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT (''),
+    available_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until DATETIME NULL,
+    sent_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- A message is enqueued once per idempotency key; messages without a key are not
+    -- deduplicated, as the key is NULL for them.
+    idempotency_key_unique VARCHAR(255) GENERATED ALWAYS AS (NULLIF(idempotency_key, '')) STORED,
+    UNIQUE INDEX mail_outbox_idempotency_key_idx (idempotency_key_unique),
+    -- The worker claims due messages by status and time.
+    INDEX mail_outbox_due_idx (status, available_at)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS mail_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    idempotency_key VARCHAR(255) NOT NULL DEFAULT '',
+    message JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    available_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP NULL,
+    sent_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A message is enqueued once per idempotency key; messages without a key are not deduplicated.
+CREATE UNIQUE INDEX IF NOT EXISTS mail_outbox_idempotency_key_idx ON mail_outbox (idempotency_key) WHERE idempotency_key <> '';
+
+-- The worker claims due messages by status and time.
+CREATE INDEX IF NOT EXISTS mail_outbox_due_idx ON mail_outbox (status, available_at);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS mail_events (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    event_id VARCHAR(255) NOT NULL DEFAULT '',
+    type VARCHAR(32) NOT NULL,
+    permanent BOOLEAN NOT NULL DEFAULT FALSE,
+    recipient VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT (''),
+    message_id VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSON NOT NULL,
+    occurred_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Providers retry webhooks; an event is recorded once. Events without an ID are not
+    -- deduplicated, as the ID is NULL for them.
+    event_id_unique VARCHAR(255) GENERATED ALWAYS AS (NULLIF(event_id, '')) STORED,
+    UNIQUE INDEX mail_events_provider_event_id_idx (provider, event_id_unique),
+    INDEX mail_events_recipient_idx (recipient)
+);
+
+-- The addresses mail is no longer sent to, lowercased.
+CREATE TABLE IF NOT EXISTS mail_suppressions (
+    email VARCHAR(255) NOT NULL PRIMARY KEY,
+    reason VARCHAR(32) NOT NULL,
+    detail TEXT NOT NULL DEFAULT (''),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS mail_suppressions;
+DROP TABLE IF EXISTS mail_events;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS mail_events (
+    id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    event_id VARCHAR(255) NOT NULL DEFAULT '',
+    type VARCHAR(32) NOT NULL,
+    permanent BOOLEAN NOT NULL DEFAULT FALSE,
+    recipient VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    message_id VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Providers retry webhooks; an event is recorded once.
+CREATE UNIQUE INDEX IF NOT EXISTS mail_events_provider_event_id_idx ON mail_events (provider, event_id) WHERE event_id <> '';
+CREATE INDEX IF NOT EXISTS mail_events_recipient_idx ON mail_events (recipient);
+
+-- The addresses mail is no longer sent to, lowercased.
+CREATE TABLE IF NOT EXISTS mail_suppressions (
+    email VARCHAR(255) PRIMARY KEY,
+    reason VARCHAR(32) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
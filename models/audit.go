@@ -0,0 +1,301 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+	upper "github.com/upper/db/v4"
+)
+
+// Audit actions.
+const (
+	AuditInsert = "insert"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditTable is the append-only table audit entries are written to.
+const AuditTable = "audit_log"
+
+// Auditing turns on the audit log of the repository layer. It is set by models.New.
+//
+// Configuration via environment variables:
+//
+//	AUDIT_LOG: Record inserts, updates and deletes made through the repository layer
+//	           (default: true)
+var Auditing bool
+
+// AuditEntry is a single change recorded in the audit log: who changed which row of which
+// table, how, when and from where.
+type AuditEntry struct {
+	ID        int64        `db:"id,omitempty" json:"id"`
+	TenantID  *int         `db:"tenant_id" json:"tenant_id,omitempty"`
+	Actor     string       `db:"actor" json:"actor"`
+	Action    string       `db:"action" json:"action"`
+	Table     string       `db:"table_name" json:"table"`
+	RecordID  string       `db:"record_id" json:"record_id"`
+	Changes   AuditChanges `db:"changes" json:"changes"`
+	IP        string       `db:"ip" json:"ip"`
+	RequestID string       `db:"request_id" json:"request_id"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+}
+
+// AuditChange is the value of a column before and after a change. Old is nil for inserts
+// and New is nil for deletes.
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditChanges are the changed columns of a row, stored as a JSON object.
+type AuditChanges map[string]AuditChange
+
+// AuditSource is where a change came from: the principal that made it and the client IP and
+// request ID of the request it was made in.
+type AuditSource struct {
+	Actor     string
+	IP        string
+	RequestID string
+}
+
+// AuditFilter narrows a query of the audit log. Zero fields are not filtered on.
+type AuditFilter struct {
+	Table    string
+	RecordID string
+	Actor    string
+	Action   string
+	Since    time.Time
+	Until    time.Time
+}
+
+// AuditLog queries the audit log. Entries are only ever written by the repository layer;
+// the log offers no way to change or remove them.
+type AuditLog struct {
+	repo *Repository[AuditEntry]
+}
+
+type auditContextKey int
+
+const auditSourceKey auditContextKey = iota
+
+// the columns whose values are never written to the audit log.
+var auditRedact = map[string]bool{}
+
+// Create the audit log.
+func NewAuditLog() *AuditLog {
+	return &AuditLog{repo: NewGlobalRepository[AuditEntry](AuditTable)}
+}
+
+// Turn the audit log on unless the environment turns it off. The values of the redacted
+// columns are replaced in the recorded changes.
+func enableAuditFromEnv(redact []string) {
+	Auditing = true
+	if enabled, err := strconv.ParseBool(os.Getenv("AUDIT_LOG")); err == nil {
+		Auditing = enabled
+	}
+
+	auditRedact = make(map[string]bool)
+	for _, column := range redact {
+		auditRedact[strings.ToLower(column)] = true
+	}
+}
+
+// WithAuditSource returns a context carrying the source recorded with every change made with
+// it.
+func WithAuditSource(ctx context.Context, source AuditSource) context.Context {
+	return context.WithValue(ctx, auditSourceKey, source)
+}
+
+// AuditSourceFromContext returns the source of the context. Changes made without a source,
+// e.g., by jobs, are recorded with the actor "system".
+func AuditSourceFromContext(ctx context.Context) AuditSource {
+	source, ok := ctx.Value(auditSourceKey).(AuditSource)
+	if !ok {
+		source.Actor = "system"
+	}
+	if source.RequestID == "" {
+		source.RequestID = chimw.GetReqID(ctx)
+	}
+	return source
+}
+
+// Find returns the entries matching the filter, newest first. With a tenant in the context
+// only the tenant's entries are returned.
+func (l *AuditLog) Find(ctx context.Context, filter AuditFilter) (upper.Result, error) {
+	cond := upper.Cond{}
+	if filter.Table != "" {
+		cond["table_name"] = filter.Table
+	}
+	if filter.RecordID != "" {
+		cond["record_id"] = filter.RecordID
+	}
+	if filter.Actor != "" {
+		cond["actor"] = filter.Actor
+	}
+	if filter.Action != "" {
+		cond["action"] = filter.Action
+	}
+	if !filter.Since.IsZero() {
+		cond["created_at >="] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		cond["created_at <"] = filter.Until
+	}
+	if tenant := TenantFromContext(ctx); tenant != nil {
+		cond["tenant_id"] = tenant.ID
+	}
+
+	res, err := l.repo.Find(ctx, cond)
+	if err != nil {
+		return nil, err
+	}
+	return res.OrderBy("-id"), nil
+}
+
+// History returns every change of a single row, oldest first.
+func (l *AuditLog) History(ctx context.Context, table string, id interface{}) ([]AuditEntry, error) {
+	res, err := l.Find(ctx, AuditFilter{Table: table, RecordID: fmt.Sprint(id)})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []AuditEntry{}
+	if err := res.OrderBy("id").All(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Write an entry for a change to the audit log, using the session of the change so the
+// entry is committed or rolled back along with it.
+func recordAudit(ctx context.Context, sess upper.Session, scope *tenantScope, action, table string, id interface{}, before, after interface{}) error {
+	changes := auditDiff(before, after)
+	if action == AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	source := AuditSourceFromContext(ctx)
+	entry := &AuditEntry{
+		Actor:     source.Actor,
+		Action:    action,
+		Table:     table,
+		RecordID:  fmt.Sprint(id),
+		Changes:   changes,
+		IP:        source.IP,
+		RequestID: source.RequestID,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	tenant := TenantFromContext(ctx)
+	if scope != nil {
+		tenant = scope.tenant
+	}
+	if tenant != nil {
+		entry.TenantID = &tenant.ID
+	}
+
+	_, err := sess.Collection(AuditTable).Insert(entry)
+	return err
+}
+
+// Compare the columns of a row before and after a change. Columns marked omitempty and left
+// zero in the new row are not written by upper/db and so are not changes.
+func auditDiff(before, after interface{}) AuditChanges {
+	previous, _ := columnValues(before)
+	current, omitted := columnValues(after)
+
+	changes := AuditChanges{}
+	for column, value := range current {
+		if omitted[column] {
+			continue
+		}
+		if old, ok := previous[column]; ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		changes[column] = AuditChange{Old: previous[column], New: value}
+	}
+	if after == nil {
+		for column, value := range previous {
+			changes[column] = AuditChange{Old: value}
+		}
+	}
+
+	for column, change := range changes {
+		if auditRedact[column] {
+			if change.Old != nil {
+				change.Old = "[REDACTED]"
+			}
+			if change.New != nil {
+				change.New = "[REDACTED]"
+			}
+			changes[column] = change
+		}
+	}
+
+	return changes
+}
+
+// Map the db-tagged fields of a struct to their column names. The second map holds the
+// columns tagged omitempty whose value is zero.
+func columnValues(item interface{}) (map[string]interface{}, map[string]bool) {
+	values := map[string]interface{}{}
+	omitted := map[string]bool{}
+
+	v := reflect.Indirect(reflect.ValueOf(item))
+	if v.Kind() != reflect.Struct {
+		return values, omitted
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		name, options, _ := strings.Cut(v.Type().Field(i).Tag.Get("db"), ",")
+		if name == "" || name == "-" || !v.Type().Field(i).IsExported() {
+			continue
+		}
+
+		field := v.Field(i)
+		if strings.Contains(options, "omitempty") && field.IsZero() {
+			omitted[name] = true
+		}
+
+		value := field.Interface()
+		if valuer, ok := value.(driver.Valuer); ok {
+			value, _ = valuer.Value()
+		}
+		values[name] = value
+	}
+
+	return values, omitted
+}
+
+// Value stores the changes as JSON.
+func (c AuditChanges) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan reads the changes from JSON.
+func (c *AuditChanges) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	default:
+		return errors.New("models: unsupported audit changes type")
+	}
+}
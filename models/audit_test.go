@@ -0,0 +1,114 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type account struct {
+	ID       int    `db:"id,omitempty"`
+	Email    string `db:"email"`
+	Password string `db:"password"`
+}
+
+const (
+	accountsTable = `CREATE TABLE accounts (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT NOT NULL, password TEXT NOT NULL)`
+	auditLogTable = `CREATE TABLE audit_log (id INTEGER PRIMARY KEY AUTOINCREMENT, tenant_id INTEGER NULL, actor TEXT NOT NULL,
+		action TEXT NOT NULL, table_name TEXT NOT NULL, record_id TEXT NOT NULL, changes TEXT NOT NULL, ip TEXT NOT NULL,
+		request_id TEXT NOT NULL, created_at DATETIME NOT NULL)`
+)
+
+// Turn the audit log on with the password column redacted.
+func setupAudit(t *testing.T) (*Repository[account], *AuditLog, context.Context) {
+	t.Setenv("AUDIT_LOG", "")
+	enableAuditFromEnv([]string{"password"})
+	t.Cleanup(func() { Auditing = false })
+
+	useSessions(t, NewSessionRouter(openTestSession(t, "audit", accountsTable, auditLogTable), "sqlite"))
+
+	ctx := WithAuditSource(context.Background(), AuditSource{Actor: "7", IP: "203.0.113.9", RequestID: "req-1"})
+	return NewRepository[account]("accounts"), NewAuditLog(), ctx
+}
+
+func TestAudit_RecordsChanges(t *testing.T) {
+	accounts, audit, ctx := setupAudit(t)
+
+	id, err := accounts.Insert(ctx, &account{Email: "ada@example.com", Password: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Update(ctx, id, &account{Email: "ada@example.org", Password: "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := audit.History(context.Background(), "accounts", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	for i, action := range []string{AuditInsert, AuditUpdate, AuditDelete} {
+		entry := entries[i]
+		if entry.Action != action || entry.Actor != "7" || entry.IP != "203.0.113.9" || entry.RequestID != "req-1" {
+			t.Errorf("Unexpected %s entry %+v", action, entry)
+		}
+	}
+
+	if change := entries[0].Changes["password"]; change.Old != nil || change.New != "[REDACTED]" {
+		t.Errorf("Expected the password to be redacted on insert, got %+v", change)
+	}
+
+	update := entries[1].Changes
+	if len(update) != 1 || update["email"].Old != "ada@example.com" || update["email"].New != "ada@example.org" {
+		t.Errorf("Expected the update to record only the email change, got %+v", update)
+	}
+
+	if change := entries[2].Changes["email"]; change.Old != "ada@example.org" || change.New != nil {
+		t.Errorf("Expected the delete to record the removed values, got %+v", change)
+	}
+}
+
+func TestAudit_RolledBackWithChange(t *testing.T) {
+	accounts, audit, ctx := setupAudit(t)
+	failed := errors.New("failed")
+
+	err := Transaction(ctx, func(ctx context.Context) error {
+		if _, err := accounts.Insert(ctx, &account{Email: "ada@example.com"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Expected the transaction to fail, got %v", err)
+	}
+
+	res, err := audit.Find(context.Background(), AuditFilter{Table: "accounts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.Count(); n != 0 {
+		t.Errorf("Expected no entry for a rolled back change, got %d", n)
+	}
+}
+
+func TestAudit_SystemActor(t *testing.T) {
+	accounts, audit, _ := setupAudit(t)
+
+	if _, err := accounts.Insert(context.Background(), &account{Email: "job@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := audit.Find(context.Background(), AuditFilter{Actor: "system", Action: AuditInsert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.Count(); n != 1 {
+		t.Errorf("Expected the change to be attributed to the system, got %d entries", n)
+	}
+}
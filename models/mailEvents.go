@@ -0,0 +1,273 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	upper "github.com/upper/db/v4"
+)
+
+// The tables of delivery events and of the suppression list.
+const (
+	MailEventsTable       = "mail_events"
+	MailSuppressionsTable = "mail_suppressions"
+)
+
+// Mail event types, as reported by the webhooks of mail services.
+const (
+	MailDelivered    = "delivered"
+	MailBounced      = "bounced"
+	MailDeferred     = "deferred"
+	MailComplained   = "complained"
+	MailUnsubscribed = "unsubscribed"
+)
+
+// Suppression reasons.
+const (
+	SuppressBounce      = "bounce"
+	SuppressComplaint   = "complaint"
+	SuppressUnsubscribe = "unsubscribe"
+	SuppressManual      = "manual"
+)
+
+// MailEvent is what happened to a message sent to a recipient, as reported by a mail
+// service.
+type MailEvent struct {
+	ID       int64  `db:"id,omitempty" json:"id"`
+	Provider string `db:"provider" json:"provider"`
+	// EventID is the provider's ID of the event; an event is recorded once.
+	EventID string `db:"event_id" json:"event_id,omitempty"`
+	Type    string `db:"type" json:"type"`
+	// Permanent is set for bounces that will not succeed on retry (hard bounces).
+	Permanent  bool             `db:"permanent" json:"permanent"`
+	Recipient  string           `db:"recipient" json:"recipient"`
+	Reason     string           `db:"reason" json:"reason,omitempty"`
+	MessageID  string           `db:"message_id" json:"message_id,omitempty"`
+	Payload    MailEventPayload `db:"payload" json:"payload"`
+	OccurredAt time.Time        `db:"occurred_at" json:"occurred_at"`
+	CreatedAt  time.Time        `db:"created_at" json:"created_at"`
+}
+
+// Suppresses tells whether the event puts its recipient on the suppression list, and why:
+// hard bounces, complaints and unsubscribes do.
+func (e MailEvent) Suppresses() (string, bool) {
+	switch {
+	case e.Type == MailBounced && e.Permanent:
+		return SuppressBounce, true
+	case e.Type == MailComplained:
+		return SuppressComplaint, true
+	case e.Type == MailUnsubscribed:
+		return SuppressUnsubscribe, true
+	}
+	return "", false
+}
+
+// MailEventPayload is the event as the provider sent it, stored as JSON.
+type MailEventPayload json.RawMessage
+
+// Value writes the payload.
+func (p MailEventPayload) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return "{}", nil
+	}
+	return string(p), nil
+}
+
+// Scan reads the payload.
+func (p *MailEventPayload) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		*p = append((*p)[:0], src...)
+	case string:
+		*p = MailEventPayload(src)
+	default:
+		return errors.New("models: unsupported mail event payload type")
+	}
+	return nil
+}
+
+// MarshalJSON writes the payload as it is.
+func (p MailEventPayload) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("{}"), nil
+	}
+	return p, nil
+}
+
+// MailEvents is the log of delivery events.
+type MailEvents struct{}
+
+// NewMailEvents returns the log of delivery events.
+func NewMailEvents() *MailEvents {
+	return &MailEvents{}
+}
+
+// Record stores events, and puts the recipients of the events that call for it on the
+// suppression list (see MailEvent.Suppresses), in a transaction. Events already recorded
+// are skipped, as providers send an event again when they are not sure it was received. It
+// returns the number of events recorded.
+func (m *MailEvents) Record(ctx context.Context, events []MailEvent) (int, error) {
+	recorded := 0
+	err := Transaction(ctx, func(ctx context.Context) error {
+		recorded = 0
+		sess := Sessions.Writer(ctx)
+		suppressions := NewMailSuppressions()
+
+		for _, e := range events {
+			if e.EventID != "" {
+				exists, err := sess.Collection(MailEventsTable).Find(upper.Cond{"provider": e.Provider, "event_id": e.EventID}).Exists()
+				if err != nil {
+					return err
+				}
+				if exists {
+					continue
+				}
+			}
+
+			e.Recipient = normalizeEmail(e.Recipient)
+			e.OccurredAt = e.OccurredAt.UTC()
+			e.CreatedAt = time.Now().UTC()
+			if _, err := sess.Collection(MailEventsTable).Insert(&e); err != nil {
+				return err
+			}
+			recorded++
+
+			if reason, ok := e.Suppresses(); ok {
+				if err := suppressions.Add(ctx, e.Recipient, reason, e.Provider+": "+e.Reason); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return recorded, err
+}
+
+// ForRecipient returns the events of a recipient, newest first.
+func (m *MailEvents) ForRecipient(ctx context.Context, email string) ([]MailEvent, error) {
+	if Sessions == nil {
+		return nil, ErrNoDatabase
+	}
+
+	events := []MailEvent{}
+	err := Sessions.Reader(ctx).Collection(MailEventsTable).
+		Find(upper.Cond{"recipient": normalizeEmail(email)}).
+		OrderBy("-occurred_at", "-id").
+		All(&events)
+	return events, err
+}
+
+// MailSuppression is an address mail is no longer sent to.
+type MailSuppression struct {
+	Email     string    `db:"email" json:"email"`
+	Reason    string    `db:"reason" json:"reason"`
+	Detail    string    `db:"detail" json:"detail,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MailSuppressions is the suppression list: the addresses that bounced for good, complained
+// or unsubscribed. The mail worker leaves them out of the recipients of a message.
+type MailSuppressions struct{}
+
+// NewMailSuppressions returns the suppression list.
+func NewMailSuppressions() *MailSuppressions {
+	return &MailSuppressions{}
+}
+
+// Add puts an address on the list, or updates why it is there.
+func (s *MailSuppressions) Add(ctx context.Context, email, reason, detail string) error {
+	if Sessions == nil {
+		return ErrNoDatabase
+	}
+
+	email = normalizeEmail(email)
+	now := time.Now().UTC()
+	sess := Sessions.Writer(ctx)
+
+	res, err := sess.SQL().Update(MailSuppressionsTable).
+		Set("reason", reason, "detail", detail, "updated_at", now).
+		Where(upper.Cond{"email": email}).
+		Exec()
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	_, err = sess.Collection(MailSuppressionsTable).Insert(&MailSuppression{
+		Email: email, Reason: reason, Detail: detail, CreatedAt: now, UpdatedAt: now,
+	})
+	return err
+}
+
+// Remove takes an address off the list.
+func (s *MailSuppressions) Remove(ctx context.Context, email string) error {
+	if Sessions == nil {
+		return ErrNoDatabase
+	}
+
+	return Sessions.Writer(ctx).Collection(MailSuppressionsTable).
+		Find(upper.Cond{"email": normalizeEmail(email)}).
+		Delete()
+}
+
+// Get returns the suppression of an address or upper.ErrNoMoreRows.
+func (s *MailSuppressions) Get(ctx context.Context, email string) (*MailSuppression, error) {
+	if Sessions == nil {
+		return nil, ErrNoDatabase
+	}
+
+	var suppression MailSuppression
+	err := Sessions.Reader(ctx).Collection(MailSuppressionsTable).
+		Find(upper.Cond{"email": normalizeEmail(email)}).
+		One(&suppression)
+	if err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+// Suppressed returns the addresses on the list among some addresses.
+func (s *MailSuppressions) Suppressed(ctx context.Context, addresses ...string) ([]string, error) {
+	if Sessions == nil {
+		return nil, ErrNoDatabase
+	}
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	lower := make([]interface{}, len(addresses))
+	byLower := make(map[string]string, len(addresses))
+	for i, a := range addresses {
+		l := normalizeEmail(a)
+		lower[i] = l
+		byLower[l] = a
+	}
+
+	var rows []MailSuppression
+	err := Sessions.Writer(ctx).Collection(MailSuppressionsTable).Find(upper.Cond{"email IN": lower}).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	suppressed := make([]string, 0, len(rows))
+	for _, row := range rows {
+		suppressed = append(suppressed, byLower[row.Email])
+	}
+	return suppressed, nil
+}
+
+// The address of an email address, e.g., "Ada <Ada@Example.com>", lowercased.
+func normalizeEmail(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		address = parsed.Address
+	}
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const mailEventsTables = `CREATE TABLE mail_events (id INTEGER PRIMARY KEY AUTOINCREMENT, provider TEXT NOT NULL,
+	event_id TEXT NOT NULL DEFAULT '', type TEXT NOT NULL, permanent BOOLEAN NOT NULL DEFAULT FALSE,
+	recipient TEXT NOT NULL, reason TEXT NOT NULL DEFAULT '', message_id TEXT NOT NULL DEFAULT '',
+	payload TEXT NOT NULL DEFAULT '{}', occurred_at DATETIME NOT NULL, created_at DATETIME NOT NULL);
+CREATE TABLE mail_suppressions (email TEXT PRIMARY KEY, reason TEXT NOT NULL, detail TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL)`
+
+func TestMailEvents_RecordSuppresses(t *testing.T) {
+	useSessions(t, NewSessionRouter(openTestSession(t, "mail_events", mailEventsTables), "sqlite"))
+	ctx := context.Background()
+	events, suppressions := NewMailEvents(), NewMailSuppressions()
+	at := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+
+	batch := []MailEvent{
+		{Provider: "mailgun", EventID: "e1", Type: MailDelivered, Recipient: "ada@example.com", OccurredAt: at},
+		{Provider: "mailgun", EventID: "e2", Type: MailBounced, Permanent: true, Recipient: "Alice <Alice@Example.com>",
+			Reason: "No such user", Payload: MailEventPayload(`{"event": "failed"}`), OccurredAt: at},
+		{Provider: "mailgun", EventID: "e3", Type: MailBounced, Recipient: "carol@example.com", OccurredAt: at},
+		{Provider: "mailgun", EventID: "e4", Type: MailComplained, Recipient: "dave@example.com", OccurredAt: at},
+	}
+	recorded, err := events.Record(ctx, batch)
+	if err != nil || recorded != 4 {
+		t.Fatalf("Expected 4 events recorded, got %d, %v", recorded, err)
+	}
+
+	// providers send events again; they are recorded once
+	recorded, err = events.Record(ctx, batch[1:2])
+	if err != nil || recorded != 0 {
+		t.Fatalf("Expected a resent event to be skipped, got %d, %v", recorded, err)
+	}
+
+	alice, err := events.ForRecipient(ctx, "alice@example.com")
+	if err != nil || len(alice) != 1 || string(alice[0].Payload) != `{"event": "failed"}` {
+		t.Fatalf("Unexpected events of alice: %+v, %v", alice, err)
+	}
+
+	suppressed, err := suppressions.Suppressed(ctx, "ada@example.com", "ALICE@example.com", "carol@example.com", "dave@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suppressed) != 2 || !contains(suppressed, "ALICE@example.com") || !contains(suppressed, "dave@example.com") {
+		t.Fatalf("Expected the hard bounce and the complaint to be suppressed, got %v", suppressed)
+	}
+
+	s, err := suppressions.Get(ctx, "alice@example.com")
+	if err != nil || s.Reason != SuppressBounce || s.Detail != "mailgun: No such user" {
+		t.Fatalf("Unexpected suppression %+v, %v", s, err)
+	}
+
+	if err := suppressions.Remove(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if suppressed, _ := suppressions.Suppressed(ctx, "alice@example.com"); len(suppressed) != 0 {
+		t.Errorf("Expected alice to be removed, got %v", suppressed)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
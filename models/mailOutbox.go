@@ -0,0 +1,342 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	upper "github.com/upper/db/v4"
+)
+
+// OutboxTable is the table mail waits in until it is sent.
+const OutboxTable = "mail_outbox"
+
+// The savepoint an enqueue inside a transaction inserts under.
+const outboxSavepoint = "mail_outbox_enqueue"
+
+// Outbox statuses. A message is pending until a worker claims it, sending while claimed,
+// and then sent, pending again with a later available_at when sending failed, or dead when
+// it failed too many times.
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+// ErrClaimLost is returned when the outcome of a claim is recorded after its lease expired
+// and another worker claimed the message; the outcome is not recorded.
+var ErrClaimLost = errors.New("models: the claim on the outbox message expired and was taken over")
+
+// OutboxMail is a message in the outbox.
+type OutboxMail struct {
+	ID             int64         `db:"id,omitempty" json:"id"`
+	IdempotencyKey string        `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	Message        OutboxMessage `db:"message" json:"message"`
+	Status         string        `db:"status" json:"status"`
+	Attempts       int           `db:"attempts" json:"attempts"`
+	LastError      string        `db:"last_error" json:"last_error,omitempty"`
+	AvailableAt    time.Time     `db:"available_at" json:"available_at"`
+	LockedUntil    *time.Time    `db:"locked_until" json:"locked_until,omitempty"`
+	SentAt         *time.Time    `db:"sent_at" json:"sent_at,omitempty"`
+	CreatedAt      time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time     `db:"updated_at" json:"updated_at"`
+}
+
+// OutboxMessage is a rendered message, stored as JSON: its bodies are rendered and its
+// attachments read when it is enqueued, so sending it later does not depend on templates or
+// files that may have changed in the meantime.
+type OutboxMessage struct {
+	From        string             `json:"from"`
+	FromName    string             `json:"from_name,omitempty"`
+	To          []string           `json:"to"`
+	Cc          []string           `json:"cc,omitempty"`
+	Bcc         []string           `json:"bcc,omitempty"`
+	ReplyTo     string             `json:"reply_to,omitempty"`
+	Subject     string             `json:"subject"`
+	HTML        string             `json:"html,omitempty"`
+	Text        string             `json:"text,omitempty"`
+	Attachments []OutboxAttachment `json:"attachments,omitempty"`
+}
+
+// OutboxAttachment is a file attached to a message. An inline attachment is an image the
+// HTML body refers to as cid:<Name>.
+type OutboxAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Data        []byte `json:"data"`
+	Inline      bool   `json:"inline,omitempty"`
+}
+
+// Value writes the message as JSON.
+func (m OutboxMessage) Value() (driver.Value, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan reads the message from JSON.
+func (m *OutboxMessage) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, m)
+	case string:
+		return json.Unmarshal([]byte(src), m)
+	default:
+		return errors.New("models: unsupported outbox message type")
+	}
+}
+
+// MailOutbox is a transactional outbox for mail. Enqueue writes a message to the outbox
+// table through the session router, so mail enqueued with the context of a
+// models.Transaction is only sent when the transaction commits, and mail survives restarts.
+// A worker (see the mail package) claims due messages, sends them and records the outcome.
+//
+// Example:
+//
+//	err := models.Transaction(ctx, func(ctx context.Context) error {
+//		if _, err := users.Insert(ctx, user); err != nil {
+//			return err
+//		}
+//		_, _, err := m.Outbox.Enqueue(ctx, welcome, "welcome:"+user.Email)
+//		return err
+//	})
+type MailOutbox struct{}
+
+// NewMailOutbox returns the outbox.
+func NewMailOutbox() *MailOutbox {
+	return &MailOutbox{}
+}
+
+// Enqueue adds a message to the outbox. A message enqueued again with the same idempotency
+// key is not added twice: the message already in the outbox is returned, and the reported
+// bool is false. An empty key is never deduplicated. Enqueuing inside Transaction leaves the
+// transaction usable when a concurrent enqueue with the same key wins.
+func (o *MailOutbox) Enqueue(ctx context.Context, msg OutboxMessage, key string) (*OutboxMail, bool, error) {
+	if Sessions == nil {
+		return nil, false, ErrNoDatabase
+	}
+
+	if key != "" {
+		if existing, err := o.byKey(ctx, key); err == nil {
+			return existing, false, nil
+		} else if !errors.Is(err, upper.ErrNoMoreRows) {
+			return nil, false, err
+		}
+	}
+
+	now := time.Now().UTC()
+	mail := &OutboxMail{
+		IdempotencyKey: key,
+		Message:        msg,
+		Status:         OutboxPending,
+		AvailableAt:    now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := o.insert(ctx, mail); err != nil {
+		// a concurrent enqueue with the same key won the unique index
+		if key != "" {
+			if existing, lookupErr := o.byKey(ctx, key); lookupErr == nil {
+				return existing, false, nil
+			}
+		}
+		return nil, false, err
+	}
+	return mail, true, nil
+}
+
+// Insert a message. Inside a transaction the insert runs under a savepoint which a failed
+// insert is rolled back to: Postgres refuses every statement of a transaction after one
+// failed, the lookup of the message that won the unique index of the key included.
+func (o *MailOutbox) insert(ctx context.Context, mail *OutboxMail) error {
+	sess := Sessions.Writer(ctx)
+	inTx := txSession(ctx) != nil
+	if inTx {
+		if _, err := sess.SQL().Exec("SAVEPOINT " + outboxSavepoint); err != nil {
+			return err
+		}
+	}
+
+	res, err := sess.Collection(OutboxTable).Insert(mail)
+	if inTx {
+		end := "RELEASE SAVEPOINT "
+		if err != nil {
+			end = "ROLLBACK TO SAVEPOINT "
+		}
+		if _, endErr := sess.SQL().Exec(end + outboxSavepoint); endErr != nil && err == nil {
+			err = endErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	switch id := res.ID().(type) {
+	case int64:
+		mail.ID = id
+	case int:
+		mail.ID = int64(id)
+	}
+	return nil
+}
+
+func (o *MailOutbox) byKey(ctx context.Context, key string) (*OutboxMail, error) {
+	var mail OutboxMail
+	err := Sessions.Writer(ctx).Collection(OutboxTable).Find(upper.Cond{"idempotency_key": key}).One(&mail)
+	if err != nil {
+		return nil, err
+	}
+	return &mail, nil
+}
+
+// Get returns a message of the outbox or upper.ErrNoMoreRows.
+func (o *MailOutbox) Get(ctx context.Context, id int64) (*OutboxMail, error) {
+	if Sessions == nil {
+		return nil, ErrNoDatabase
+	}
+
+	var mail OutboxMail
+	if err := Sessions.Writer(ctx).Collection(OutboxTable).Find(upper.Cond{"id": id}).One(&mail); err != nil {
+		return nil, err
+	}
+	return &mail, nil
+}
+
+// Claim takes up to limit due messages for sending: pending messages that are available,
+// and messages whose claim expired because the worker holding them stopped. A claim lasts
+// for the lease and counts as an attempt. Messages claimed by another worker in the
+// meantime are skipped, so any number of workers can share the outbox.
+//
+// The attempts of a claimed message identify the claim: the outcome of sending it is
+// recorded with them (see MarkSent), so a worker whose lease expired cannot overwrite the
+// claim of the worker that took the message over.
+func (o *MailOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxMail, error) {
+	if Sessions == nil {
+		return nil, ErrNoDatabase
+	}
+
+	sess := Sessions.Writer(ctx)
+	now := time.Now().UTC()
+
+	var due []OutboxMail
+	err := sess.Collection(OutboxTable).Find(upper.Or(
+		upper.Cond{"status": OutboxPending, "available_at <=": now},
+		upper.Cond{"status": OutboxSending, "locked_until <": now},
+	)).OrderBy("available_at", "id").Limit(limit).All(&due)
+	if err != nil {
+		return nil, err
+	}
+
+	until := now.Add(lease)
+	claimed := due[:0]
+	for _, mail := range due {
+		// the attempts column doubles as a version: only one worker moves it on
+		res, err := sess.SQL().Update(OutboxTable).
+			Set("status", OutboxSending, "locked_until", until, "attempts", mail.Attempts+1, "updated_at", now).
+			Where(upper.Cond{"id": mail.ID, "status": mail.Status, "attempts": mail.Attempts}).
+			Exec()
+		if err != nil {
+			return claimed, err
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			continue
+		}
+
+		mail.Status, mail.LockedUntil, mail.Attempts, mail.UpdatedAt = OutboxSending, &until, mail.Attempts+1, now
+		claimed = append(claimed, mail)
+	}
+
+	return claimed, nil
+}
+
+// Extend renews the claim on a message for the lease, e.g., before sending a message that
+// waited in a batch. It returns ErrClaimLost when the message was claimed by another
+// worker, which sends it instead.
+func (o *MailOutbox) Extend(ctx context.Context, id int64, attempt int, lease time.Duration) error {
+	now := time.Now().UTC()
+	return o.update(ctx, id, attempt, "locked_until", now.Add(lease), "updated_at", now)
+}
+
+// MarkSent records that a claimed message was sent; attempt is that of the claim.
+func (o *MailOutbox) MarkSent(ctx context.Context, id int64, attempt int) error {
+	now := time.Now().UTC()
+	return o.update(ctx, id, attempt, "status", OutboxSent, "sent_at", now, "locked_until", nil, "last_error", "", "updated_at", now)
+}
+
+// MarkFailed records that sending a claimed message failed. The message is retried at
+// retryAt, or moved to the dead letters when dead is set.
+func (o *MailOutbox) MarkFailed(ctx context.Context, id int64, attempt int, sendErr error, retryAt time.Time, dead bool) error {
+	status := OutboxPending
+	if dead {
+		status = OutboxDead
+	}
+	return o.update(ctx, id, attempt, "status", status, "available_at", retryAt.UTC(), "locked_until", nil,
+		"last_error", sendErr.Error(), "updated_at", time.Now().UTC())
+}
+
+// Release returns a claimed message that was not sent to the outbox, without counting the
+// claim as an attempt, e.g., when the application shuts down before sending it.
+func (o *MailOutbox) Release(ctx context.Context, id int64, attempt int) error {
+	return o.update(ctx, id, attempt, "status", OutboxPending, "locked_until", nil, "attempts", attempt-1, "updated_at", time.Now().UTC())
+}
+
+// Requeue sends a dead letter again, with its attempts reset.
+func (o *MailOutbox) Requeue(ctx context.Context, id int64) error {
+	if Sessions == nil {
+		return ErrNoDatabase
+	}
+
+	now := time.Now().UTC()
+	_, err := Sessions.Writer(ctx).SQL().Update(OutboxTable).
+		Set("status", OutboxPending, "attempts", 0, "available_at", now, "updated_at", now).
+		Where(upper.Cond{"id": id, "status": OutboxDead}).
+		Exec()
+	return err
+}
+
+// Dead returns the dead letters, newest first.
+func (o *MailOutbox) Dead(ctx context.Context) ([]OutboxMail, error) {
+	if Sessions == nil {
+		return nil, ErrNoDatabase
+	}
+
+	mail := []OutboxMail{}
+	err := Sessions.Reader(ctx).Collection(OutboxTable).Find(upper.Cond{"status": OutboxDead}).OrderBy("-id").All(&mail)
+	return mail, err
+}
+
+// Update the columns of a message while the claim of the attempt holds.
+func (o *MailOutbox) update(ctx context.Context, id int64, attempt int, columns ...interface{}) error {
+	if Sessions == nil {
+		return ErrNoDatabase
+	}
+
+	claim := upper.Cond{"id": id, "status": OutboxSending, "attempts": attempt}
+	res, err := Sessions.Writer(ctx).SQL().Update(OutboxTable).
+		Set(columns...).
+		Where(claim).
+		Exec()
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	// MySQL counts the rows changed rather than matched, so a row left as it was is
+	// looked for before the claim is taken as lost
+	n, err := Sessions.Writer(ctx).Collection(OutboxTable).Find(claim).Count()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4/adapter/sqlite"
+)
+
+const outboxTable = `CREATE TABLE mail_outbox (id INTEGER PRIMARY KEY AUTOINCREMENT, idempotency_key TEXT NOT NULL DEFAULT '',
+	message TEXT NOT NULL, status TEXT NOT NULL DEFAULT 'pending', attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '', available_at DATETIME NOT NULL, locked_until DATETIME NULL, sent_at DATETIME NULL,
+	created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL)`
+
+func setupOutbox(t *testing.T) *MailOutbox {
+	useSessions(t, NewSessionRouter(openTestSession(t, "outbox", outboxTable), "sqlite"))
+	return NewMailOutbox()
+}
+
+func TestMailOutbox_EnqueueIsIdempotent(t *testing.T) {
+	outbox := setupOutbox(t)
+	ctx := context.Background()
+	msg := OutboxMessage{To: []string{"ada@example.com"}, Subject: "Welcome", HTML: "<p>Hello Ada</p>",
+		Attachments: []OutboxAttachment{{Name: "logo.png", Data: []byte{0x89, 'P', 'N', 'G'}, Inline: true}}}
+
+	first, added, err := outbox.Enqueue(ctx, msg, "welcome:ada")
+	if err != nil || !added {
+		t.Fatalf("Expected the message to be added, got %v, %v", added, err)
+	}
+	again, added, err := outbox.Enqueue(ctx, msg, "welcome:ada")
+	if err != nil || added || again.ID != first.ID {
+		t.Fatalf("Expected the message of the first enqueue, got %+v, %v, %v", again, added, err)
+	}
+
+	stored, err := outbox.Get(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Message.HTML != msg.HTML || string(stored.Message.Attachments[0].Data) != string(msg.Attachments[0].Data) {
+		t.Errorf("Unexpected stored message %+v", stored.Message)
+	}
+}
+
+func TestMailOutbox_EnqueueTwiceInTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	plain, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	sess, err := sqlite.New(sql.OpenDB(&abortingConnector{driver: plain.Driver(), dsn: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	for _, stmt := range []string{outboxTable,
+		`CREATE UNIQUE INDEX mail_outbox_idempotency_key_idx ON mail_outbox (idempotency_key) WHERE idempotency_key <> ''`} {
+		if _, err := sess.SQL().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	useSessions(t, NewSessionRouter(sess, "sqlite"))
+	outbox := NewMailOutbox()
+	msg := OutboxMessage{To: []string{"ada@example.com"}, Subject: "Welcome", HTML: "<p>Hello Ada</p>"}
+
+	err = Transaction(context.Background(), func(ctx context.Context) error {
+		first, added, err := outbox.Enqueue(ctx, msg, "welcome:ada")
+		if err != nil || !added {
+			t.Fatalf("Expected the message to be added, got %v, %v", added, err)
+		}
+		again, added, err := outbox.Enqueue(ctx, msg, "welcome:ada")
+		if err != nil || added || again.ID != first.ID {
+			t.Fatalf("Expected the message of the first enqueue, got %+v, %v, %v", again, added, err)
+		}
+
+		// an enqueue which checked the key before the first one was inserted
+		now := time.Now().UTC()
+		racing := &OutboxMail{IdempotencyKey: "welcome:ada", Message: msg, Status: OutboxPending,
+			AvailableAt: now, CreatedAt: now, UpdatedAt: now}
+		if err := outbox.insert(ctx, racing); err == nil {
+			t.Fatal("Expected the unique index of the key to refuse the insert")
+		}
+		if existing, err := outbox.byKey(ctx, "welcome:ada"); err != nil || existing.ID != first.ID {
+			t.Fatalf("Expected the transaction to stay usable after the refused insert, got %+v, %v", existing, err)
+		}
+
+		_, added, err = outbox.Enqueue(ctx, msg, "welcome:grace")
+		if err != nil || !added {
+			t.Fatalf("Expected another message to be added, got %v, %v", added, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the transaction to commit, got %v", err)
+	}
+
+	n, err := sess.Collection(OutboxTable).Find().Count()
+	if err != nil || n != 2 {
+		t.Errorf("Expected 2 messages in the outbox, got %d (%v)", n, err)
+	}
+}
+
+func TestMailOutbox_ClaimAndOutcomes(t *testing.T) {
+	outbox := setupOutbox(t)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		mail, _, err := outbox.Enqueue(ctx, OutboxMessage{To: []string{to}}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, mail.ID)
+	}
+
+	claimed, err := outbox.Claim(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 3 || claimed[0].Attempts != 1 || claimed[0].Status != OutboxSending {
+		t.Fatalf("Expected 3 claimed messages on their first attempt, got %+v", claimed)
+	}
+	if again, _ := outbox.Claim(ctx, 10, time.Minute); len(again) != 0 {
+		t.Fatalf("Expected claimed messages not to be claimed again, got %d", len(again))
+	}
+
+	if err := outbox.MarkSent(ctx, ids[0], 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.MarkFailed(ctx, ids[1], 1, errors.New("451 try again"), time.Now().Add(time.Hour), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.MarkFailed(ctx, ids[2], 1, errors.New("550 no such user"), time.Now(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, _ := outbox.Get(ctx, ids[0])
+	retried, _ := outbox.Get(ctx, ids[1])
+	if sent.Status != OutboxSent || sent.SentAt == nil {
+		t.Errorf("Expected the first message to be sent, got %+v", sent)
+	}
+	if retried.Status != OutboxPending || retried.LastError != "451 try again" {
+		t.Errorf("Expected the second message to be pending, got %+v", retried)
+	}
+	if due, _ := outbox.Claim(ctx, 10, time.Minute); len(due) != 0 {
+		t.Errorf("Expected no message due before its retry, got %d", len(due))
+	}
+
+	dead, err := outbox.Dead(ctx)
+	if err != nil || len(dead) != 1 || dead[0].ID != ids[2] {
+		t.Fatalf("Expected the third message to be a dead letter, got %+v, %v", dead, err)
+	}
+	if err := outbox.Requeue(ctx, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	claimed, _ = outbox.Claim(ctx, 10, time.Minute)
+	if len(claimed) != 1 || claimed[0].ID != ids[2] || claimed[0].Attempts != 1 {
+		t.Fatalf("Expected the requeued message to be claimed afresh, got %+v", claimed)
+	}
+}
+
+func TestMailOutbox_ReleaseAndExpiredClaims(t *testing.T) {
+	outbox := setupOutbox(t)
+	ctx := context.Background()
+
+	mail, _, err := outbox.Enqueue(ctx, OutboxMessage{To: []string{"ada@example.com"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := outbox.Claim(ctx, 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Release(ctx, mail.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	released, _ := outbox.Get(ctx, mail.ID)
+	if released.Status != OutboxPending || released.Attempts != 0 {
+		t.Fatalf("Expected a released message to be pending without an attempt, got %+v", released)
+	}
+
+	// a worker that stopped holding a claim
+	if _, err := outbox.Claim(ctx, 1, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := outbox.Claim(ctx, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].Attempts != 2 {
+		t.Fatalf("Expected the expired claim to be claimed again, got %+v", claimed)
+	}
+}
+
+func TestMailOutbox_StaleWorker(t *testing.T) {
+	outbox := setupOutbox(t)
+	ctx := context.Background()
+
+	mail, _, err := outbox.Enqueue(ctx, OutboxMessage{To: []string{"ada@example.com"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a worker whose batch outlived its lease, and the worker that took the message over
+	stale, err := outbox.Claim(ctx, 1, -time.Second)
+	if err != nil || len(stale) != 1 {
+		t.Fatalf("Expected a claim, got %+v, %v", stale, err)
+	}
+	current, err := outbox.Claim(ctx, 1, time.Minute)
+	if err != nil || len(current) != 1 {
+		t.Fatalf("Expected the expired claim to be taken over, got %+v, %v", current, err)
+	}
+
+	if err := outbox.Extend(ctx, mail.ID, stale[0].Attempts, time.Minute); !errors.Is(err, ErrClaimLost) {
+		t.Errorf("Expected the stale claim not to be renewed, got %v", err)
+	}
+	if err := outbox.Extend(ctx, mail.ID, current[0].Attempts, time.Minute); err != nil {
+		t.Errorf("Expected the current claim to be renewed, got %v", err)
+	}
+	if err := outbox.MarkFailed(ctx, mail.ID, stale[0].Attempts, errors.New("timeout"), time.Now(), false); !errors.Is(err, ErrClaimLost) {
+		t.Errorf("Expected the stale worker's outcome to be refused, got %v", err)
+	}
+	if err := outbox.MarkSent(ctx, mail.ID, current[0].Attempts); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Release(ctx, mail.ID, stale[0].Attempts); !errors.Is(err, ErrClaimLost) {
+		t.Errorf("Expected the stale worker's release to be refused, got %v", err)
+	}
+
+	sent, _ := outbox.Get(ctx, mail.ID)
+	if sent.Status != OutboxSent || sent.Attempts != 2 || sent.LastError != "" {
+		t.Errorf("Expected the message sent by the current worker, got %+v", sent)
+	}
+}
+
+// A connector whose connections refuse every statement of a transaction after one failed,
+// until the transaction is rolled back to a savepoint, as Postgres does.
+type abortingConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c *abortingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &abortingConn{Conn: conn}, nil
+}
+
+func (c *abortingConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+var errTxAborted = errors.New("current transaction is aborted, commands ignored until end of transaction block")
+
+type abortingConn struct {
+	driver.Conn
+	inTx    bool
+	aborted bool
+}
+
+func (c *abortingConn) run(query string, fn func() error) error {
+	statement := strings.ToUpper(strings.TrimSpace(query))
+	if c.aborted && !strings.HasPrefix(statement, "ROLLBACK") {
+		return errTxAborted
+	}
+	if err := fn(); err != nil {
+		c.aborted = c.inTx
+		return err
+	}
+	if strings.HasPrefix(statement, "ROLLBACK TO SAVEPOINT") {
+		c.aborted = false
+	}
+	return nil
+}
+
+func (c *abortingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
+	err = c.run(query, func() error {
+		res, err = c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+		return err
+	})
+	return res, err
+}
+
+func (c *abortingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	err = c.run(query, func() error {
+		rows, err = c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+		return err
+	})
+	return rows, err
+}
+
+func (c *abortingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.inTx, c.aborted = true, false
+	return &abortingTx{Tx: tx, conn: c}, nil
+}
+
+type abortingTx struct {
+	driver.Tx
+	conn *abortingConn
+}
+
+func (tx *abortingTx) Commit() error {
+	aborted := tx.conn.aborted
+	tx.conn.inTx, tx.conn.aborted = false, false
+	if aborted {
+		tx.Tx.Rollback()
+		return errTxAborted
+	}
+	return tx.Tx.Commit()
+}
+
+func (tx *abortingTx) Rollback() error {
+	tx.conn.inTx, tx.conn.aborted = false, false
+	return tx.Tx.Rollback()
+}
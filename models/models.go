@@ -1,23 +1,77 @@
 package models
 
 import (
+	"os"
+
 	"github.com/cidekar/adele-framework"
+	"github.com/cidekar/adele-framework/database"
 	upper "github.com/upper/db/v4"
 )
 
 var DB upper.Session
 
+// The tenant-scoped tables of the application (see TenantIsolation): each has a tenant_id
+// column and is read and written through a repository created with NewRepository. They are
+// registered with the tenant guard by models.New, so raw statements on them are refused
+// from the start rather than once their repositories are created.
+var tenantScopedTables = []string{}
+
 // The Models struct is the single place to register and access all database models throughout
 // your application. The Models struct acts as a container that holds all your application's
 // data models— automatic database session setup.
-type Models struct{}
+type Models struct {
+	Audit        *AuditLog
+	MailEvents   *MailEvents
+	Outbox       *MailOutbox
+	Suppressions *MailSuppressions
+	Tenants      *TenantRepository
+}
 
 // A constructor that initializes and returns a Models struct for use throughout the appication.
 func New(a *adele.Adele) *Models {
 
-	// Sets Up Database Session
-	DB = a.DB.NewSession()
+	// Scopes the repository layer to the tenant of the request
+	TenantIsolation = tenantIsolationFromEnv()
+
+	// Sets Up Database Session, refusing statements on tenant-scoped tables that bypass the
+	// repository layer, e.g., raw queries on DB, before they run
+	registerTenantTables(tenantScopedTables...)
+	var err error
+	DB, err = guardDatabase(a.DB, &database.DataSourceName{
+		Host:         adele.Helpers.Getenv("DATABASE_HOST", "localhost"),
+		Port:         adele.Helpers.Getenv("DATABASE_PORT", "5432"),
+		User:         adele.Helpers.Getenv("DATABASE_USER"),
+		Password:     adele.Helpers.Getenv("DATABASE_PASSWORD"),
+		DatabaseName: adele.Helpers.Getenv("DATABASE_NAME"),
+		SslMode:      adele.Helpers.Getenv("DATABASE_SSL_MODE"),
+	})
+	if err != nil {
+		a.Log.Error(err)
+		os.Exit(1)
+	}
+
+	// Logs statements executed by the session, warns on slow queries and collects
+	// statement shapes for N+1 detection.
+	queryLogConfig := NewQueryLogConfig(a.Debug)
+	if DB != nil {
+		EnableQueryLog(DB, a.Log, queryLogConfig)
+	}
+
+	// Routes reads to the read replicas and writes to the primary
+	if DB != nil {
+		Sessions = newSessionRouterFromEnv(a, DB)
+	}
+
+	// Records changes made through the repository layer in the audit log, leaving out the
+	// values of the columns the query log redacts
+	enableAuditFromEnv(queryLogConfig.RedactColumns)
 
 	// Returns any initialized Models
-	return &Models{}
+	return &Models{
+		Audit:        NewAuditLog(),
+		MailEvents:   NewMailEvents(),
+		Outbox:       NewMailOutbox(),
+		Suppressions: NewMailSuppressions(),
+		Tenants:      NewTenantRepository(),
+	}
 }
@@ -0,0 +1,495 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/sirupsen/logrus"
+	upper "github.com/upper/db/v4"
+)
+
+// QueryLogConfig holds the settings used by the query logger. The values are read from
+// the environment when the models are initialized.
+//
+// Configuration via environment variables:
+//
+//	DATABASE_LOG_QUERIES: Log every statement at debug level (default: false)
+//	DATABASE_SLOW_QUERY_MS: Threshold in milliseconds for slow query warnings (default: 200)
+//	DATABASE_LOG_REDACT: Comma-separated list of columns whose bind args are redacted
+//	                     (default: "password,token,secret,remember_token")
+//	DATABASE_EXPLAIN_SLOW: Run EXPLAIN on slow queries, only honored in debug mode (default: false)
+//	DATABASE_REPEAT_THRESHOLD: Number of times the same statement shape may run in a single
+//	                           request before it is reported as an N+1 pattern (default: 5)
+type QueryLogConfig struct {
+	LogQueries      bool
+	SlowThreshold   time.Duration
+	RedactColumns   []string
+	ExplainSlow     bool
+	RepeatThreshold int
+}
+
+// QueryLogger receives the status of every statement executed by upper/db and writes it to
+// the application log. Statements are logged with their bind arguments (redacted where the
+// column is sensitive), duration, rows affected and the location in the application that
+// issued the query.
+type QueryLogger struct {
+	Config  QueryLogConfig
+	Log     *logrus.Logger
+	Session upper.Session
+	redact  map[string]bool
+}
+
+// QueryShape is a normalized statement and the number of times it was executed during
+// a single request.
+type QueryShape struct {
+	Statement string
+	Count     int
+	Caller    string
+	Duration  time.Duration
+}
+
+// QueryCollector records the statements executed while serving a request so repeated
+// statement shapes (the N+1 pattern) can be detected once the request completes.
+type QueryCollector struct {
+	mu        sync.Mutex
+	threshold int
+	count     int
+	duration  time.Duration
+	shapes    map[string]*QueryShape
+	order     []string
+}
+
+type queryLogContextKey int
+
+const (
+	queryCollectorKey queryLogContextKey = iota
+	querySkipLogKey
+)
+
+var (
+	activeQueryLogger *QueryLogger
+
+	// the package path is used to skip frames within the models layer when looking for
+	// the caller of a query.
+	modelsPackage = reflect.TypeOf(QueryLogger{}).PkgPath() + "."
+
+	reWhitespace   = regexp.MustCompile(`\s+`)
+	rePlaceholder  = regexp.MustCompile(`\$\d+|\?`)
+	reStringValue  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	reNumericValue = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	reInList       = regexp.MustCompile(`(?i)\bIN\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	reValuesList   = regexp.MustCompile(`(?i)\bVALUES\s*\(.*\)`)
+)
+
+// Create a query log configuration using the environment variables. The EXPLAIN option is
+// only honored when the application runs in debug mode.
+func NewQueryLogConfig(debug bool) QueryLogConfig {
+	config := QueryLogConfig{
+		SlowThreshold:   200 * time.Millisecond,
+		RedactColumns:   []string{"password", "token", "secret", "remember_token"},
+		RepeatThreshold: 5,
+	}
+
+	config.LogQueries, _ = strconv.ParseBool(os.Getenv("DATABASE_LOG_QUERIES"))
+
+	if ms, err := strconv.Atoi(os.Getenv("DATABASE_SLOW_QUERY_MS")); err == nil && ms > 0 {
+		config.SlowThreshold = time.Duration(ms) * time.Millisecond
+	}
+
+	if columns := os.Getenv("DATABASE_LOG_REDACT"); columns != "" {
+		config.RedactColumns = nil
+		for _, column := range strings.Split(columns, ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				config.RedactColumns = append(config.RedactColumns, column)
+			}
+		}
+	}
+
+	if explain, _ := strconv.ParseBool(os.Getenv("DATABASE_EXPLAIN_SLOW")); explain && debug {
+		config.ExplainSlow = true
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("DATABASE_REPEAT_THRESHOLD")); err == nil && n > 1 {
+		config.RepeatThreshold = n
+	}
+
+	return config
+}
+
+// Install the query logger as the upper/db logging collector. Every statement executed
+// through any session is handed to the logger, which decides what is written to the
+// application log.
+func EnableQueryLog(sess upper.Session, log *logrus.Logger, config QueryLogConfig) *QueryLogger {
+	l := &QueryLogger{
+		Config:  config,
+		Log:     log,
+		Session: sess,
+		redact:  make(map[string]bool),
+	}
+
+	for _, column := range config.RedactColumns {
+		l.redact[strings.ToLower(column)] = true
+	}
+
+	activeQueryLogger = l
+
+	upper.LC().SetLogger(l)
+	upper.LC().SetLevel(upper.LogLevelDebug)
+
+	return l
+}
+
+// Print is called by upper/db with the status of an executed statement.
+func (l *QueryLogger) Print(v ...interface{}) {
+	for _, value := range v {
+		if status, ok := value.(*upper.QueryStatus); ok {
+			l.record(status)
+			continue
+		}
+		l.Log.Debug(value)
+	}
+}
+
+// Printf is called by upper/db for messages that are not statements.
+func (l *QueryLogger) Printf(format string, v ...interface{}) {
+	l.Log.Debugf(format, v...)
+}
+
+// Fatal satisfies the upper/db logger interface.
+func (l *QueryLogger) Fatal(v ...interface{}) {
+	l.Log.Fatal(v...)
+}
+
+// Fatalf satisfies the upper/db logger interface.
+func (l *QueryLogger) Fatalf(format string, v ...interface{}) {
+	l.Log.Fatalf(format, v...)
+}
+
+// Panic satisfies the upper/db logger interface.
+func (l *QueryLogger) Panic(v ...interface{}) {
+	l.Log.Panic(v...)
+}
+
+// Panicf satisfies the upper/db logger interface.
+func (l *QueryLogger) Panicf(format string, v ...interface{}) {
+	l.Log.Panicf(format, v...)
+}
+
+// Record a single statement: write it to the log, warn when it is slow and add it to the
+// collector of the request that issued it.
+func (l *QueryLogger) record(status *upper.QueryStatus) {
+	ctx := status.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if skip, _ := ctx.Value(querySkipLogKey).(bool); skip {
+		return
+	}
+
+	query := status.Query()
+	duration := status.End.Sub(status.Start)
+	caller := queryCaller()
+
+	fields := logrus.Fields{
+		"sql":         query,
+		"args":        redactArgs(query, status.Args, l.redact),
+		"duration_ms": float64(duration.Nanoseconds()) / 1000000.0,
+		"caller":      caller,
+	}
+
+	if status.RowsAffected != nil {
+		fields["rows"] = *status.RowsAffected
+	}
+
+	if status.TxID > 0 {
+		fields["tx_id"] = status.TxID
+	}
+
+	if reqID := requestID(ctx); reqID != "" {
+		fields["req_id"] = reqID
+	}
+
+	if collector := QueryCollectorFromContext(ctx); collector != nil {
+		collector.add(query, caller, duration)
+	}
+
+	entry := l.Log.WithFields(fields)
+
+	// upper/db flags statements over its own fixed threshold as an error; that decision
+	// belongs to the configured threshold instead.
+	err := status.Err
+	if errors.Is(err, upper.ErrWarnSlowQuery) {
+		err = nil
+	}
+
+	switch {
+	case err != nil:
+		entry.WithError(err).Warn("query failed")
+	case duration >= l.Config.SlowThreshold:
+		entry.Warn("slow query")
+		if l.Config.ExplainSlow && status.TxID == 0 {
+			go l.explain(query, status.Args)
+		}
+	case l.Config.LogQueries:
+		entry.Debug("query")
+	}
+}
+
+// Run EXPLAIN for a slow statement and log the plan. The statement is re-run outside of
+// the request so the plan never competes with the original query for a connection.
+func (l *QueryLogger) explain(query string, args []interface{}) {
+	if l.Session == nil || !strings.HasPrefix(strings.ToUpper(query), "SELECT") {
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), querySkipLogKey, true)
+	rows, err := l.Session.WithContext(ctx).SQL().Query("EXPLAIN "+query, args...)
+	if err != nil {
+		l.Log.WithError(err).Debug("explain failed")
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	var plan []string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return
+		}
+
+		var line []string
+		for _, value := range values {
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			line = append(line, fmt.Sprint(value))
+		}
+		plan = append(plan, strings.Join(line, " | "))
+	}
+
+	l.Log.WithFields(logrus.Fields{
+		"sql":  query,
+		"plan": strings.Join(plan, "\n"),
+	}).Warn("slow query plan")
+}
+
+// Create a collector for the statements executed with the returned context. Sessions must
+// be used with the context (e.g., models.DB.WithContext(r.Context())) for their statements
+// to be attributed to the request.
+func WithQueryCollector(ctx context.Context) (context.Context, *QueryCollector) {
+	threshold := 5
+	if activeQueryLogger != nil {
+		threshold = activeQueryLogger.Config.RepeatThreshold
+	}
+
+	collector := &QueryCollector{
+		threshold: threshold,
+		shapes:    make(map[string]*QueryShape),
+	}
+
+	return context.WithValue(ctx, queryCollectorKey, collector), collector
+}
+
+// Get the query collector attached to the context or nil if there is none.
+func QueryCollectorFromContext(ctx context.Context) *QueryCollector {
+	collector, _ := ctx.Value(queryCollectorKey).(*QueryCollector)
+	return collector
+}
+
+func (c *QueryCollector) add(query, caller string, duration time.Duration) {
+	shape := normalizeQuery(query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count++
+	c.duration += duration
+
+	s, ok := c.shapes[shape]
+	if !ok {
+		s = &QueryShape{Statement: shape, Caller: caller}
+		c.shapes[shape] = s
+		c.order = append(c.order, shape)
+	}
+	s.Count++
+	s.Duration += duration
+}
+
+// Count returns the number of statements executed and the total time spent in the database.
+func (c *QueryCollector) Count() (int, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count, c.duration
+}
+
+// Repeated returns the statement shapes that ran at least as many times as the configured
+// threshold, in the order they were first seen.
+func (c *QueryCollector) Repeated() []QueryShape {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var repeated []QueryShape
+	for _, shape := range c.order {
+		if s := c.shapes[shape]; s.Count >= c.threshold {
+			repeated = append(repeated, *s)
+		}
+	}
+	return repeated
+}
+
+// Reduce a statement to its shape by replacing literals and placeholders so that the same
+// query executed with different values is counted together.
+func normalizeQuery(query string) string {
+	shape := reWhitespace.ReplaceAllString(strings.TrimSpace(query), " ")
+	shape = reStringValue.ReplaceAllString(shape, "?")
+	shape = rePlaceholder.ReplaceAllString(shape, "?")
+	shape = reNumericValue.ReplaceAllString(shape, "?")
+	shape = reInList.ReplaceAllString(shape, "IN (?)")
+	shape = reValuesList.ReplaceAllString(shape, "VALUES (?)")
+	return shape
+}
+
+// Replace the bind arguments of sensitive columns with a placeholder. Columns are matched
+// by the identifier compared against a placeholder (e.g., "password" = $1) or by position
+// in the column list of an INSERT statement.
+func redactArgs(query string, args []interface{}, columns map[string]bool) []interface{} {
+	if len(args) == 0 || len(columns) == 0 {
+		return args
+	}
+
+	redacted := make([]interface{}, len(args))
+	copy(redacted, args)
+
+	insertColumns, valuesAt := insertColumnList(query)
+
+	sequential := 0
+	for _, loc := range rePlaceholder.FindAllStringIndex(query, -1) {
+		index := sequential
+		if token := query[loc[0]:loc[1]]; token != "?" {
+			n, err := strconv.Atoi(token[1:])
+			if err != nil {
+				continue
+			}
+			index = n - 1
+		}
+		sequential++
+
+		if index < 0 || index >= len(redacted) {
+			continue
+		}
+
+		var column string
+		if valuesAt >= 0 && loc[0] > valuesAt && len(insertColumns) > 0 {
+			column = insertColumns[index%len(insertColumns)]
+		} else {
+			column = columnBefore(query[:loc[0]])
+		}
+
+		if columns[strings.ToLower(column)] {
+			redacted[index] = "[REDACTED]"
+		}
+	}
+
+	return redacted
+}
+
+// Get the column list and the offset of the VALUES keyword of an INSERT statement. The
+// offset is -1 for any other statement.
+func insertColumnList(query string) ([]string, int) {
+	upperQuery := strings.ToUpper(query)
+	if !strings.HasPrefix(strings.TrimSpace(upperQuery), "INSERT") {
+		return nil, -1
+	}
+
+	valuesAt := strings.Index(upperQuery, "VALUES")
+	if valuesAt < 0 {
+		return nil, -1
+	}
+
+	open := strings.Index(query[:valuesAt], "(")
+	end := strings.LastIndex(query[:valuesAt], ")")
+	if open < 0 || end < open {
+		return nil, valuesAt
+	}
+
+	var columns []string
+	for _, column := range strings.Split(query[open+1:end], ",") {
+		columns = append(columns, unquoteIdentifier(column))
+	}
+
+	return columns, valuesAt
+}
+
+// Find the identifier compared against the placeholder that follows the given prefix,
+// skipping comparison operators and keywords such as LIKE and IN.
+func columnBefore(prefix string) string {
+	fields := strings.Fields(strings.NewReplacer("(", " ", ",", " ").Replace(prefix))
+	for i := len(fields) - 1; i >= 0; i-- {
+		switch strings.ToUpper(fields[i]) {
+		case "=", "<>", "!=", "<", ">", "<=", ">=", "LIKE", "ILIKE", "IN", "NOT", "IS":
+			continue
+		}
+
+		field := strings.TrimRight(fields[i], "=<>!")
+		if field == "" {
+			continue
+		}
+		return unquoteIdentifier(field)
+	}
+	return ""
+}
+
+// Strip quotes and any table qualifier from an identifier.
+func unquoteIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if i := strings.LastIndex(identifier, "."); i >= 0 {
+		identifier = identifier[i+1:]
+	}
+	return strings.Trim(identifier, "\"`[] ")
+}
+
+// Find the first frame outside of upper/db, database/sql, the runtime and the models layer;
+// this is the application code that issued the query.
+func queryCaller() string {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.Function, "upper/db") &&
+			!strings.HasPrefix(frame.Function, "database/sql") &&
+			!strings.HasPrefix(frame.Function, "runtime.") &&
+			!strings.HasPrefix(frame.Function, modelsPackage) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// Get the request identifier placed in the context by the framework's RequestID middleware.
+func requestID(ctx context.Context) string {
+	return chimw.GetReqID(ctx)
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRedactArgs(t *testing.T) {
+	columns := map[string]bool{"password": true, "token": true}
+
+	testCases := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		expected []interface{}
+	}{
+		{
+			name:     "where clause with numbered placeholders",
+			query:    `SELECT * FROM "users" WHERE ("email" = $1 AND "password" = $2)`,
+			args:     []interface{}{"a@example.com", "hunter2"},
+			expected: []interface{}{"a@example.com", "[REDACTED]"},
+		},
+		{
+			name:     "where clause with question mark placeholders",
+			query:    "SELECT * FROM `tokens` WHERE `tokens`.`token` = ? AND `user_id` = ?",
+			args:     []interface{}{"abc", 7},
+			expected: []interface{}{"[REDACTED]", 7},
+		},
+		{
+			name:     "insert column list",
+			query:    `INSERT INTO "users" ("email", "password", "name") VALUES ($1, $2, $3)`,
+			args:     []interface{}{"a@example.com", "hunter2", "Ada"},
+			expected: []interface{}{"a@example.com", "[REDACTED]", "Ada"},
+		},
+		{
+			name:     "update set clause",
+			query:    `UPDATE "users" SET "password" = $1, "name" = $2 WHERE "id" = $3`,
+			args:     []interface{}{"hunter2", "Ada", 1},
+			expected: []interface{}{"[REDACTED]", "Ada", 1},
+		},
+		{
+			name:     "no sensitive columns",
+			query:    `SELECT * FROM "users" WHERE "id" = $1`,
+			args:     []interface{}{1},
+			expected: []interface{}{1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := redactArgs(tc.query, tc.args, columns)
+			if len(result) != len(tc.expected) {
+				t.Fatalf("Expected %d args, got %d", len(tc.expected), len(result))
+			}
+			for i := range result {
+				if result[i] != tc.expected[i] {
+					t.Errorf("Expected arg %d to be %v, got %v", i, tc.expected[i], result[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	a := normalizeQuery(`SELECT * FROM "posts" WHERE "user_id" = $1 AND "id" IN ($2, $3)`)
+	b := normalizeQuery("SELECT *  FROM \"posts\"\n WHERE \"user_id\" = 42 AND \"id\" IN (?)")
+
+	if a != b {
+		t.Errorf("Expected statements to share a shape:\n%s\n%s", a, b)
+	}
+}
+
+func TestQueryCollectorRepeated(t *testing.T) {
+	ctx, collector := WithQueryCollector(context.Background())
+
+	if QueryCollectorFromContext(ctx) != collector {
+		t.Fatal("Expected collector to be attached to the context")
+	}
+
+	collector.threshold = 3
+	for i := 0; i < 3; i++ {
+		collector.add(`SELECT * FROM "comments" WHERE "post_id" = $1`, "handlers.go:10", time.Millisecond)
+	}
+	collector.add(`SELECT * FROM "posts"`, "handlers.go:5", time.Millisecond)
+
+	count, duration := collector.Count()
+	if count != 4 || duration != 4*time.Millisecond {
+		t.Errorf("Expected 4 queries in 4ms, got %d in %s", count, duration)
+	}
+
+	repeated := collector.Repeated()
+	if len(repeated) != 1 {
+		t.Fatalf("Expected 1 repeated shape, got %d", len(repeated))
+	}
+
+	if repeated[0].Count != 3 || repeated[0].Caller != "handlers.go:10" {
+		t.Errorf("Unexpected repeated shape: %+v", repeated[0])
+	}
+}
@@ -0,0 +1,394 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cidekar/adele-framework"
+	"github.com/cidekar/adele-framework/database"
+	"github.com/sirupsen/logrus"
+	upper "github.com/upper/db/v4"
+)
+
+// Sessions routes statements between the primary database and its read replicas. It is
+// created by models.New and used by the repository layer; when no replicas are configured
+// every statement is sent to the primary.
+var Sessions *SessionRouter
+
+// ErrNoDatabase is returned by the repository layer when the application has no database
+// configured.
+var ErrNoDatabase = errors.New("models: no database session is configured")
+
+// SessionRouter picks the session a statement runs on. Reads are spread across healthy
+// replicas round-robin, while writes and anything inside a transaction go to the primary.
+// After a write, reads issued with the same request context stick to the primary for the
+// sticky window so a request always sees its own writes; the ReadYourWrites middleware
+// carries the time of the write over to the client's next requests, e.g., the GET that
+// follows a POST and its redirect.
+//
+// Configuration via environment variables:
+//
+//	DATABASE_REPLICAS: Comma-separated list of replica hosts (host or host:port) that share
+//	                   the primary's credentials and database name
+//	                   Examples: "10.0.0.11,10.0.0.12:5433"
+//	DATABASE_REPLICA_MAX_LAG: Seconds a replica may lag behind before it stops receiving
+//	                          reads (default: 10)
+//	DATABASE_REPLICA_CHECK_INTERVAL: Seconds between replica health checks (default: 5)
+//	DATABASE_STICKY_WINDOW: Seconds reads stay on the primary after a write of the same
+//	                        client (default: 5)
+type SessionRouter struct {
+	Primary      upper.Session
+	DataType     string
+	MaxLag       time.Duration
+	StickyWindow time.Duration
+	Log          *logrus.Logger
+
+	replicas []*replica
+	next     uint64
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// replica is a read-only session along with the result of its last health check.
+type replica struct {
+	Name    string
+	Session upper.Session
+	healthy atomic.Bool
+	lag     atomic.Int64
+}
+
+// ReplicaStatus reports the health of a replica as of its last check.
+type ReplicaStatus struct {
+	Name    string
+	Healthy bool
+	Lag     time.Duration
+}
+
+// stickiness records the time of the last write made with a request context.
+type stickiness struct {
+	mu        sync.Mutex
+	lastWrite time.Time
+}
+
+type sessionContextKey int
+
+const (
+	txSessionKey sessionContextKey = iota
+	stickinessKey
+)
+
+// Create a session router for the primary session. Replicas are added with AddReplica.
+func NewSessionRouter(primary upper.Session, dataType string) *SessionRouter {
+	return &SessionRouter{
+		Primary:      primary,
+		DataType:     strings.ToLower(dataType),
+		MaxLag:       10 * time.Second,
+		StickyWindow: 5 * time.Second,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Create the session router for the application, opening a session for each replica listed
+// in the environment. A replica that can not be reached is logged and left out rather than
+// failing the boot; reads fall back to the primary.
+func newSessionRouterFromEnv(a *adele.Adele, primary upper.Session) *SessionRouter {
+	router := NewSessionRouter(primary, os.Getenv("DATABASE_TYPE"))
+	router.Log = a.Log
+
+	if seconds, err := strconv.Atoi(os.Getenv("DATABASE_REPLICA_MAX_LAG")); err == nil && seconds >= 0 {
+		router.MaxLag = time.Duration(seconds) * time.Second
+	}
+
+	if seconds, err := strconv.Atoi(os.Getenv("DATABASE_STICKY_WINDOW")); err == nil && seconds >= 0 {
+		router.StickyWindow = time.Duration(seconds) * time.Second
+	}
+
+	for _, host := range strings.Split(os.Getenv("DATABASE_REPLICAS"), ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+
+		port := adele.Helpers.Getenv("DATABASE_PORT", "5432")
+		if h, p, found := strings.Cut(host, ":"); found {
+			host, port = h, p
+		}
+
+		source := &database.DataSourceName{
+			Host:         host,
+			Port:         port,
+			User:         adele.Helpers.Getenv("DATABASE_USER"),
+			Password:     adele.Helpers.Getenv("DATABASE_PASSWORD"),
+			DatabaseName: adele.Helpers.Getenv("DATABASE_NAME"),
+			SslMode:      adele.Helpers.Getenv("DATABASE_SSL_MODE"),
+		}
+		pool, err := database.OpenDB(os.Getenv("DATABASE_TYPE"), source)
+		if err != nil || pool == nil {
+			a.Log.WithError(err).Warnf("database replica %s:%s is unavailable", host, port)
+			continue
+		}
+
+		db := &database.Database{DataType: os.Getenv("DATABASE_TYPE"), Pool: pool}
+		sess, err := guardDatabase(db, source)
+		if err != nil {
+			a.Log.WithError(err).Warnf("database replica %s:%s is unavailable", host, port)
+			db.Pool.Close()
+			continue
+		}
+		if sess != nil {
+			router.AddReplica(host+":"+port, sess)
+		}
+	}
+
+	if len(router.replicas) > 0 {
+		interval := 5 * time.Second
+		if seconds, err := strconv.Atoi(os.Getenv("DATABASE_REPLICA_CHECK_INTERVAL")); err == nil && seconds > 0 {
+			interval = time.Duration(seconds) * time.Second
+		}
+		router.CheckReplicas()
+		go router.monitor(interval)
+	}
+
+	return router
+}
+
+// AddReplica adds a read replica. The replica receives reads once a health check marks it
+// healthy.
+func (s *SessionRouter) AddReplica(name string, sess upper.Session) {
+	s.replicas = append(s.replicas, &replica{Name: name, Session: sess})
+}
+
+// Reader returns the session to use for a read made with the context: the transaction when
+// the context carries one, the primary during the sticky window after a write, otherwise
+// the next healthy replica.
+func (s *SessionRouter) Reader(ctx context.Context) upper.Session {
+	if tx := txSession(ctx); tx != nil {
+		return tx
+	}
+
+	if s.isSticky(ctx) {
+		return s.Primary.WithContext(ctx)
+	}
+
+	if r := s.nextReplica(); r != nil {
+		return r.Session.WithContext(ctx)
+	}
+
+	return s.Primary.WithContext(ctx)
+}
+
+// Writer returns the session to use for a write made with the context and starts the
+// sticky window of the request.
+func (s *SessionRouter) Writer(ctx context.Context) upper.Session {
+	if st, ok := ctx.Value(stickinessKey).(*stickiness); ok {
+		st.mu.Lock()
+		st.lastWrite = time.Now()
+		st.mu.Unlock()
+	}
+
+	if tx := txSession(ctx); tx != nil {
+		return tx
+	}
+
+	return s.Primary.WithContext(ctx)
+}
+
+// Transaction runs fn in a transaction on the primary. The context passed to fn carries the
+// transaction so every read and write made through the repository layer with it joins
+// the transaction.
+func (s *SessionRouter) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txSession(ctx) != nil {
+		return fn(ctx)
+	}
+
+	return s.Primary.TxContext(ctx, func(tx upper.Session) error {
+		return fn(context.WithValue(ctx, txSessionKey, tx))
+	}, nil)
+}
+
+// Replicas reports the state of each replica as of its last health check.
+func (s *SessionRouter) Replicas() []ReplicaStatus {
+	status := make([]ReplicaStatus, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		status = append(status, ReplicaStatus{
+			Name:    r.Name,
+			Healthy: r.healthy.Load(),
+			Lag:     time.Duration(r.lag.Load()),
+		})
+	}
+	return status
+}
+
+// CheckReplicas pings every replica and measures its replication lag. A replica is healthy
+// when it answers and lags no more than MaxLag.
+func (s *SessionRouter) CheckReplicas() {
+	for _, r := range s.replicas {
+		lag, err := s.replicationLag(r.Session)
+		healthy := err == nil && lag <= s.MaxLag
+
+		if was := r.healthy.Swap(healthy); was != healthy && s.Log != nil {
+			entry := s.Log.WithFields(logrus.Fields{"replica": r.Name, "lag": lag.String()})
+			if healthy {
+				entry.Info("database replica is healthy")
+			} else {
+				entry.WithError(err).Warn("database replica removed from reads")
+			}
+		}
+
+		r.lag.Store(int64(lag))
+	}
+}
+
+// Close stops the replica health checks and closes the replica sessions.
+func (s *SessionRouter) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		for _, r := range s.replicas {
+			r.Session.Close()
+		}
+	})
+}
+
+func (s *SessionRouter) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.CheckReplicas()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *SessionRouter) nextReplica() *replica {
+	n := len(s.replicas)
+	for i := 0; i < n; i++ {
+		r := s.replicas[atomic.AddUint64(&s.next, 1)%uint64(n)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *SessionRouter) isSticky(ctx context.Context) bool {
+	st, ok := ctx.Value(stickinessKey).(*stickiness)
+	if !ok {
+		return false
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return !st.lastWrite.IsZero() && time.Since(st.lastWrite) < s.StickyWindow
+}
+
+// Measure how far a replica is behind the primary. Drivers without a lag query are only
+// pinged.
+func (s *SessionRouter) replicationLag(sess upper.Session) (time.Duration, error) {
+	if err := sess.Ping(); err != nil {
+		return 0, err
+	}
+
+	ctx := context.WithValue(context.Background(), querySkipLogKey, true)
+	sqlDB := sess.WithContext(ctx).SQL()
+
+	switch s.DataType {
+	case "postgres", "postgresql":
+		var seconds float64
+		row, err := sqlDB.QueryRow(`SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`)
+		if err != nil {
+			return 0, err
+		}
+		if err := row.Scan(&seconds); err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+
+	case "mysql", "mariadb":
+		rows, err := sqlDB.Query("SHOW REPLICA STATUS")
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		return mysqlReplicaLag(rows)
+	}
+
+	return 0, nil
+}
+
+// Read Seconds_Behind_Source (Seconds_Behind_Master on older servers) from the result of
+// SHOW REPLICA STATUS. A NULL value means replication is not running.
+func mysqlReplicaLag(rows *sql.Rows) (time.Duration, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	if !rows.Next() {
+		return 0, errors.New("server is not a replica")
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := strconv.Atoi(string(values[i]))
+		if err != nil {
+			return 0, fmt.Errorf("unexpected replication lag %q", values[i])
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return 0, errors.New("replication lag is not reported")
+}
+
+// WithReadYourWrites returns a context that tracks writes so reads made with it after a
+// write are sent to the primary for the sticky window. lastWrite is the time of a write made
+// before, e.g., by the previous request of the same client, or zero.
+func WithReadYourWrites(ctx context.Context, lastWrite time.Time) context.Context {
+	return context.WithValue(ctx, stickinessKey, &stickiness{lastWrite: lastWrite})
+}
+
+// LastWrite returns the time of the last write made with a context of WithReadYourWrites,
+// or of the write it was created with; it is zero when there is none.
+func LastWrite(ctx context.Context) time.Time {
+	st, ok := ctx.Value(stickinessKey).(*stickiness)
+	if !ok {
+		return time.Time{}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.lastWrite
+}
+
+func txSession(ctx context.Context) upper.Session {
+	tx, _ := ctx.Value(txSessionKey).(upper.Session)
+	return tx
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type widget struct {
+	ID   int    `db:"id,omitempty"`
+	Name string `db:"name"`
+}
+
+const widgetsTable = `CREATE TABLE widgets (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)`
+
+// Build a router with a primary and a replica that are separate databases, so the session
+// a statement ran on can be told apart by the rows it sees.
+func setupReplicaRouter(t *testing.T) (*SessionRouter, *Repository[widget]) {
+	primary := openTestSession(t, "primary", widgetsTable)
+	replica := openTestSession(t, "replica", widgetsTable)
+
+	if _, err := replica.Collection("widgets").Insert(&widget{Name: "from-replica"}); err != nil {
+		t.Fatal(err)
+	}
+
+	router := NewSessionRouter(primary, "sqlite")
+	router.AddReplica("replica", replica)
+	router.CheckReplicas()
+	useSessions(t, router)
+
+	return router, NewRepository[widget]("widgets")
+}
+
+func TestSessionRouter_ReadsGoToReplica(t *testing.T) {
+	_, widgets := setupReplicaRouter(t)
+
+	items, err := widgets.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0].Name != "from-replica" {
+		t.Errorf("Expected read to be served by the replica, got %+v", items)
+	}
+}
+
+func TestSessionRouter_WritesGoToPrimary(t *testing.T) {
+	router, widgets := setupReplicaRouter(t)
+
+	if _, err := widgets.Insert(context.Background(), &widget{Name: "written"}); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := router.Primary.Collection("widgets").Find().Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("Expected write on the primary, found %d rows", count)
+	}
+}
+
+func TestSessionRouter_ReadYourWrites(t *testing.T) {
+	router, widgets := setupReplicaRouter(t)
+	ctx := WithReadYourWrites(context.Background(), time.Time{})
+
+	items, _ := widgets.All(ctx)
+	if len(items) != 1 || items[0].Name != "from-replica" {
+		t.Fatalf("Expected read before a write to use the replica, got %+v", items)
+	}
+
+	if _, err := widgets.Insert(ctx, &widget{Name: "written"}); err != nil {
+		t.Fatal(err)
+	}
+
+	items, _ = widgets.All(ctx)
+	if len(items) != 1 || items[0].Name != "written" {
+		t.Errorf("Expected read after a write to use the primary, got %+v", items)
+	}
+
+	router.StickyWindow = 0
+	items, _ = widgets.All(ctx)
+	if len(items) != 1 || items[0].Name != "from-replica" {
+		t.Errorf("Expected read after the sticky window to use the replica, got %+v", items)
+	}
+
+	// requests that did not write are unaffected
+	items, _ = widgets.All(context.Background())
+	if len(items) != 1 || items[0].Name != "from-replica" {
+		t.Errorf("Expected other contexts to read from the replica, got %+v", items)
+	}
+}
+
+func TestSessionRouter_TransactionUsesPrimary(t *testing.T) {
+	_, widgets := setupReplicaRouter(t)
+
+	err := Transaction(context.Background(), func(ctx context.Context) error {
+		if _, err := widgets.Insert(ctx, &widget{Name: "in-tx"}); err != nil {
+			return err
+		}
+
+		items, err := widgets.All(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(items) != 1 || items[0].Name != "in-tx" {
+			t.Errorf("Expected read in the transaction to see its write, got %+v", items)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionRouter_UnhealthyReplicaFallsBackToPrimary(t *testing.T) {
+	router, widgets := setupReplicaRouter(t)
+
+	router.replicas[0].Session.Close()
+	router.CheckReplicas()
+
+	if status := router.Replicas(); status[0].Healthy {
+		t.Fatal("Expected closed replica to be unhealthy")
+	}
+
+	items, err := widgets.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 0 {
+		t.Errorf("Expected read from the empty primary, got %+v", items)
+	}
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	upper "github.com/upper/db/v4"
+)
+
+// Repository provides the common persistence operations for a single table. Models embed
+// or hold a repository instead of talking to models.DB directly so that statements are
+// routed through the session router: reads go to a replica, writes and transactions go to
+// the primary. The type parameter is the struct a row is scanned into; its primary key
+// must be the "id" column.
+//
+// Repositories are tenant-scoped: when tenancy is on (see TenantIsolation) every statement
+// is limited to the tenant carried by the context and a statement made without one fails
+// with ErrTenantRequired. Tables shared by all tenants use NewGlobalRepository. Inserts,
+// updates and deletes are recorded in the audit log when it is on (see Auditing).
+//
+// Example:
+//
+//	type User struct {
+//		ID    int    `db:"id,omitempty"`
+//		Email string `db:"email"`
+//	}
+//
+//	users := models.NewRepository[User]("users")
+//	user, err := users.Get(r.Context(), 1)
+type Repository[T any] struct {
+	Table string
+
+	// Global marks a table shared by all tenants; its statements are never scoped.
+	Global bool
+}
+
+// Create a tenant-scoped repository for the table. List the table in tenantScopedTables
+// (models.go) too, so the tenant guard knows it before its repository is created.
+func NewRepository[T any](table string) *Repository[T] {
+	registerTenantTables(table)
+	return &Repository[T]{Table: table}
+}
+
+// Create a repository for a table shared by all tenants.
+func NewGlobalRepository[T any](table string) *Repository[T] {
+	return &Repository[T]{Table: table, Global: true}
+}
+
+// Find returns a result set for the conditions on a read session. The result can be
+// refined (OrderBy, Limit, Paginate, ...) before it is fetched.
+func (r *Repository[T]) Find(ctx context.Context, cond ...interface{}) (upper.Result, error) {
+	scope, err := r.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return scope.restrict(Sessions.Reader(ctx).Collection(scope.table(r.Table)).Find(cond...)), nil
+}
+
+// All returns every row matching the conditions.
+func (r *Repository[T]) All(ctx context.Context, cond ...interface{}) ([]T, error) {
+	res, err := r.Find(ctx, cond...)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	if err := res.All(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// First returns the first row matching the conditions or upper.ErrNoMoreRows.
+func (r *Repository[T]) First(ctx context.Context, cond ...interface{}) (*T, error) {
+	res, err := r.Find(ctx, cond...)
+	if err != nil {
+		return nil, err
+	}
+
+	var item T
+	if err := res.One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Get returns the row with the primary key or upper.ErrNoMoreRows.
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	return r.First(ctx, upper.Cond{"id": id})
+}
+
+// Count returns the number of rows matching the conditions.
+func (r *Repository[T]) Count(ctx context.Context, cond ...interface{}) (uint64, error) {
+	res, err := r.Find(ctx, cond...)
+	if err != nil {
+		return 0, err
+	}
+	return res.Count()
+}
+
+// Insert writes a new row on the primary and returns its primary key. The row is placed in
+// the tenant of the context.
+func (r *Repository[T]) Insert(ctx context.Context, item *T) (interface{}, error) {
+	scope, err := r.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := scope.stamp(item); err != nil {
+		return nil, err
+	}
+
+	var id interface{}
+	err = r.write(ctx, func(ctx context.Context, sess upper.Session) error {
+		res, err := sess.Collection(scope.table(r.Table)).Insert(item)
+		if err != nil {
+			return err
+		}
+		id = res.ID()
+		return r.audit(ctx, sess, scope, AuditInsert, id, nil, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// Update replaces the row with the primary key on the primary. A row of another tenant is
+// left untouched, and the row can not be moved to another tenant.
+func (r *Repository[T]) Update(ctx context.Context, id interface{}, item *T) error {
+	scope, err := r.scope(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := scope.stamp(item); err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(ctx context.Context, sess upper.Session) error {
+		res := scope.restrict(sess.Collection(scope.table(r.Table)).Find(upper.Cond{"id": id}))
+
+		var before *T
+		if r.audited() {
+			before = new(T)
+			if err := res.One(before); errors.Is(err, upper.ErrNoMoreRows) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if err := res.Update(item); err != nil {
+			return err
+		}
+		return r.audit(ctx, sess, scope, AuditUpdate, id, before, item)
+	})
+}
+
+// Delete removes the row with the primary key on the primary. A row of another tenant is
+// left untouched.
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	scope, err := r.scope(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(ctx context.Context, sess upper.Session) error {
+		res := scope.restrict(sess.Collection(scope.table(r.Table)).Find(upper.Cond{"id": id}))
+
+		var before *T
+		if r.audited() {
+			before = new(T)
+			if err := res.One(before); errors.Is(err, upper.ErrNoMoreRows) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if err := res.Delete(); err != nil {
+			return err
+		}
+		return r.audit(ctx, sess, scope, AuditDelete, id, before, nil)
+	})
+}
+
+// Run a write on the primary. When the table is audited the write and its audit entry run
+// in one transaction, so a change is never committed without its entry.
+func (r *Repository[T]) write(ctx context.Context, fn func(ctx context.Context, sess upper.Session) error) error {
+	if !r.audited() {
+		return fn(ctx, Sessions.Writer(ctx))
+	}
+
+	return Sessions.Transaction(ctx, func(ctx context.Context) error {
+		return fn(ctx, Sessions.Writer(ctx))
+	})
+}
+
+// Report whether changes to the table are recorded in the audit log.
+func (r *Repository[T]) audited() bool {
+	return Auditing && r.Table != AuditTable
+}
+
+// Record a change in the audit log when the table is audited.
+func (r *Repository[T]) audit(ctx context.Context, sess upper.Session, scope *tenantScope, action string, id interface{}, before, after *T) error {
+	if !r.audited() {
+		return nil
+	}
+
+	// a nil *T must reach the diff as an untyped nil
+	var previous, current interface{}
+	if before != nil {
+		previous = before
+	}
+	if after != nil {
+		current = after
+	}
+
+	return recordAudit(ctx, sess, scope, action, r.Table, id, previous, current)
+}
+
+// Resolve the tenant scope of a statement on the table.
+func (r *Repository[T]) scope(ctx context.Context) (*tenantScope, error) {
+	if Sessions == nil {
+		return nil, ErrNoDatabase
+	}
+
+	if r.Global {
+		return nil, nil
+	}
+
+	return scopeFor(ctx)
+}
+
+// Transaction runs fn in a transaction on the primary. Repository calls made with the
+// context passed to fn are part of the transaction.
+func Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if Sessions == nil {
+		return ErrNoDatabase
+	}
+	return Sessions.Transaction(ctx, fn)
+}
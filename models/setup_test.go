@@ -0,0 +1,37 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+
+	upper "github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/sqlite"
+)
+
+// Open a session on a throwaway SQLite database and create the given tables.
+func openTestSession(t *testing.T, name string, schema ...string) upper.Session {
+	t.Helper()
+
+	sess, err := sqlite.Open(sqlite.ConnectionURL{Database: filepath.Join(t.TempDir(), name+".db")})
+	if err != nil {
+		t.Fatalf("failed to open test database: %s", err)
+	}
+	t.Cleanup(func() { sess.Close() })
+
+	for _, stmt := range schema {
+		if _, err := sess.SQL().Exec(stmt); err != nil {
+			t.Fatalf("failed to create schema: %s", err)
+		}
+	}
+
+	return sess
+}
+
+// Replace the session router for the duration of a test.
+func useSessions(t *testing.T, router *SessionRouter) {
+	t.Helper()
+
+	previous := Sessions
+	Sessions = router
+	t.Cleanup(func() { Sessions = previous })
+}
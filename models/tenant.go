@@ -0,0 +1,228 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	upper "github.com/upper/db/v4"
+)
+
+// Tenant isolation modes. With column isolation every tenant-scoped table has a tenant_id
+// column and the repository layer adds it to every statement. With schema isolation each
+// tenant has its own Postgres schema holding the tenant-scoped tables and the repository
+// layer qualifies the table name with the tenant's schema. With column isolation a
+// statement on a tenant-scoped table that is not scoped by a tenant_id = ? condition, e.g.,
+// a raw query on DB, is refused before it runs unless its context comes from
+// WithoutTenantScope.
+//
+// Configuration via environment variables:
+//
+//	TENANT_ISOLATION: "column", "schema" or empty to turn tenancy off (default: off)
+const (
+	TenantIsolationColumn = "column"
+	TenantIsolationSchema = "schema"
+)
+
+var (
+	// ErrTenantRequired is returned when a tenant-scoped repository is used with a context
+	// that carries no tenant.
+	ErrTenantRequired = errors.New("models: tenant-scoped query without a tenant in the context")
+
+	// ErrTenantMismatch is returned when a write would place a row in another tenant.
+	ErrTenantMismatch = errors.New("models: row belongs to another tenant")
+
+	// ErrTenantColumn is returned when a tenant-scoped model has no tenant_id field.
+	ErrTenantColumn = errors.New(`models: tenant-scoped model has no field tagged db:"tenant_id"`)
+)
+
+// TenantIsolation is the active isolation mode; it is set from the environment by
+// models.New.
+var TenantIsolation string
+
+// Tenant is a customer served by the deployment.
+type Tenant struct {
+	ID        int       `db:"id,omitempty" json:"id"`
+	Slug      string    `db:"slug" json:"slug"`
+	Name      string    `db:"name" json:"name"`
+	Schema    string    `db:"schema_name" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// TenantRepository looks up tenants. The tenants table itself is never tenant-scoped.
+type TenantRepository struct {
+	*Repository[Tenant]
+	cache sync.Map
+}
+
+type cachedTenant struct {
+	tenant  *Tenant
+	expires time.Time
+}
+
+type tenantContextKey int
+
+const (
+	tenantKey tenantContextKey = iota
+	tenantUnscopedKey
+)
+
+var (
+	reSchemaName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+	// the tenant-scoped tables, used to refuse raw statements that skip the repository layer
+	// (see checkTenantScope and registerTenantTables).
+	tenantTables sync.Map
+)
+
+// Create the tenant repository.
+func NewTenantRepository() *TenantRepository {
+	return &TenantRepository{Repository: NewGlobalRepository[Tenant]("tenants")}
+}
+
+// BySlug returns the tenant with the slug. Lookups are cached for a minute since tenants
+// are resolved on every request.
+func (t *TenantRepository) BySlug(ctx context.Context, slug string) (*Tenant, error) {
+	if cached, ok := t.cache.Load(slug); ok && time.Now().Before(cached.(cachedTenant).expires) {
+		return cached.(cachedTenant).tenant, nil
+	}
+
+	tenant, err := t.First(ctx, upper.Cond{"slug": slug})
+	if err != nil {
+		return nil, err
+	}
+
+	t.cache.Store(slug, cachedTenant{tenant: tenant, expires: time.Now().Add(time.Minute)})
+	return tenant, nil
+}
+
+// WithTenant returns a context carrying the tenant; tenant-scoped repositories used with
+// the context only see and write the tenant's rows.
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// TenantFromContext returns the tenant of the context or nil.
+func TenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantKey).(*Tenant)
+	return tenant
+}
+
+// WithoutTenantScope returns a context that lets tenant-scoped repositories run across all
+// tenants. It is meant for jobs and admin tooling that deliberately work on every tenant and
+// must never be used with a request context.
+func WithoutTenantScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantUnscopedKey, true)
+}
+
+// Read the isolation mode from the environment.
+func tenantIsolationFromEnv() string {
+	switch mode := strings.ToLower(os.Getenv("TENANT_ISOLATION")); mode {
+	case TenantIsolationColumn, TenantIsolationSchema:
+		return mode
+	default:
+		return ""
+	}
+}
+
+// The scope a tenant-scoped statement runs in. A nil scope means the statement runs
+// unscoped, either because tenancy is off or the context explicitly opted out.
+type tenantScope struct {
+	tenant *Tenant
+}
+
+// Resolve the scope for a statement on a tenant-scoped table. This is the guard of the
+// repository layer: a statement without a tenant is refused instead of reading or writing
+// across tenants.
+func scopeFor(ctx context.Context) (*tenantScope, error) {
+	if TenantIsolation == "" {
+		return nil, nil
+	}
+
+	if unscoped, _ := ctx.Value(tenantUnscopedKey).(bool); unscoped {
+		return nil, nil
+	}
+
+	tenant := TenantFromContext(ctx)
+	if tenant == nil {
+		return nil, ErrTenantRequired
+	}
+
+	if TenantIsolation == TenantIsolationSchema && !reSchemaName.MatchString(tenant.Schema) {
+		return nil, fmt.Errorf("models: tenant %q has an invalid schema name %q", tenant.Slug, tenant.Schema)
+	}
+
+	return &tenantScope{tenant: tenant}, nil
+}
+
+// The table name to use for the scope; tenant tables live in the tenant's schema when
+// schema isolation is used.
+func (s *tenantScope) table(table string) string {
+	if s != nil && TenantIsolation == TenantIsolationSchema {
+		return s.tenant.Schema + "." + table
+	}
+	return table
+}
+
+// Restrict a result to the rows of the tenant.
+func (s *tenantScope) restrict(res upper.Result) upper.Result {
+	if s != nil && TenantIsolation == TenantIsolationColumn {
+		return res.And(upper.Cond{"tenant_id": s.tenant.ID})
+	}
+	return res
+}
+
+// Stamp the tenant on a row about to be written. A row that already names a different
+// tenant is refused.
+func (s *tenantScope) stamp(item interface{}) error {
+	if s == nil || TenantIsolation != TenantIsolationColumn {
+		return nil
+	}
+
+	field, ok := tenantField(item)
+	if !ok {
+		return ErrTenantColumn
+	}
+
+	switch {
+	case field.CanInt():
+		if id := field.Int(); id != 0 && id != int64(s.tenant.ID) {
+			return ErrTenantMismatch
+		}
+		field.SetInt(int64(s.tenant.ID))
+	case field.CanUint():
+		if id := field.Uint(); id != 0 && id != uint64(s.tenant.ID) {
+			return ErrTenantMismatch
+		}
+		field.SetUint(uint64(s.tenant.ID))
+	default:
+		return ErrTenantColumn
+	}
+
+	return nil
+}
+
+// Find the settable field of a struct pointer mapped to the tenant_id column.
+func tenantField(item interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(item)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	v = v.Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("db"), ",")
+		if name == "tenant_id" && v.Field(i).CanSet() {
+			return v.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
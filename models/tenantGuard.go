@@ -0,0 +1,504 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cidekar/adele-framework/database"
+	"github.com/cidekar/adele-framework/database/mysqldriver"
+	"github.com/cidekar/adele-framework/database/postgresdriver"
+	upper "github.com/upper/db/v4"
+)
+
+// ErrTenantUnscoped is returned, before it runs, for a statement on a tenant-scoped table
+// that does not filter on tenant_id, e.g., a raw query on models.DB that skipped the
+// repository layer.
+var ErrTenantUnscoped = errors.New("models: statement on a tenant-scoped table without a tenant_id scope")
+
+var (
+	// the tables a statement reads or writes: those following FROM, JOIN, INTO and UPDATE,
+	// including the lists of FROM a, b
+	reStatementTables = regexp.MustCompile(`(?i)\b(from|join|into|update)\s+((?:[\w"` + "`" + `.]+(?:\s+(?:as\s+)?\w+)?\s*,\s*)*[\w"` + "`" + `.]+(?:\s+(?:as\s+)?\w+)?)`)
+	reStringLiteral   = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+	// the tenant_id column in a list of columns, and a tenant_id = <placeholder> condition
+	// with the table or alias qualifying the column
+	reTenantColumn    = regexp.MustCompile("(?i)(?:^|[\\s,\"`])tenant_id(?:$|[\\s,\"`])")
+	reTenantCondition = regexp.MustCompile(`(?i)(?:([\w"` + "`" + `]+)\.)?["` + "`" + `]?\btenant_id\b["` + "`" + `]?\s*=\s*(?:\?|\$\d+|:\w+)`)
+
+	// the words ending a WHERE clause, or a statement within a statement
+	clauseEnds    = []string{"order", "group", "having", "limit", "offset", "returning", "window", "for", "union", "intersect", "except"}
+	statementEnds = []string{"union", "intersect", "except"}
+
+	// words that follow a table name in a FROM list, and so are not its alias
+	tableListWords = map[string]bool{"where": true, "join": true, "inner": true, "left": true, "right": true,
+		"full": true, "cross": true, "natural": true, "on": true, "using": true, "set": true, "values": true,
+		"order": true, "group": true, "having": true, "limit": true, "offset": true, "returning": true,
+		"union": true, "intersect": true, "except": true, "window": true, "for": true, "default": true,
+		"select": true}
+)
+
+// Refuse a statement that reads or writes a tenant-scoped table without a tenant scope under
+// column isolation, unless its context opted out with WithoutTenantScope. The repository
+// layer scopes all its statements, so this only refuses those that bypass it (see
+// unscopedTenantTable).
+func checkTenantScope(ctx context.Context, query string) error {
+	if TenantIsolation != TenantIsolationColumn {
+		return nil
+	}
+
+	if unscoped, _ := ctx.Value(tenantUnscopedKey).(bool); unscoped {
+		return nil
+	}
+
+	if table := unscopedTenantTable(query); table != "" {
+		return fmt.Errorf("%w: %s", ErrTenantUnscoped, table)
+	}
+
+	return nil
+}
+
+// The first tenant-scoped table a statement reads or writes without a tenant scope, or "".
+// A read, update or delete is scoped by a tenant_id = <placeholder> condition on the table
+// that holds for every row: in the WHERE clause of the table's own statement and joined to
+// the rest of it with AND only, e.g., WHERE ("id" = ? AND "tenant_id" = ?). An insert is
+// scoped by setting tenant_id. Mentioning the column anywhere else, e.g., selecting it or
+// ordering by it, does not scope a statement.
+func unscopedTenantTable(query string) string {
+	query = reStringLiteral.ReplaceAllString(query, "''")
+
+	for _, match := range reStatementTables.FindAllStringSubmatchIndex(query, -1) {
+		keyword := strings.ToLower(query[match[2]:match[3]])
+		for _, item := range strings.Split(query[match[4]:match[5]], ",") {
+			fields := strings.Fields(strings.NewReplacer(`"`, "", "`", "").Replace(item))
+			name := fields[0]
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+			if _, ok := tenantTables.Load(name); !ok {
+				continue
+			}
+
+			alias := fields[len(fields)-1]
+			if len(fields) == 1 || tableListWords[strings.ToLower(alias)] {
+				alias = ""
+			}
+
+			// the statement of the table starts at its list, which holds no parentheses
+			rest := query[match[4]:]
+			if keyword == "into" {
+				if !insertsTenant(rest) {
+					return name
+				}
+			} else if !whereScoped(rest, name, alias) {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// Report whether an insert, from the table it inserts into on, sets tenant_id.
+func insertsTenant(rest string) bool {
+	open := strings.IndexByte(rest, '(')
+	if open < 0 || len(strings.Fields(rest[:open])) != 1 {
+		return false
+	}
+	columns := rest[open+1 : groupEnd(rest, open+1)]
+	return reTenantColumn.MatchString(columns)
+}
+
+// Report whether the statement following a table, from the table on, has a WHERE clause
+// scoping the table (see unscopedTenantTable).
+func whereScoped(rest, name, alias string) bool {
+	rest = rest[:statementEnd(rest)]
+
+	where := topLevelWord(rest, "where")
+	if where < 0 {
+		return false
+	}
+	clause := rest[where+len("where"):]
+	if end := topLevelWord(clause, clauseEnds...); end >= 0 {
+		clause = clause[:end]
+	}
+
+	for _, m := range reTenantCondition.FindAllStringSubmatchIndex(clause, -1) {
+		if m[2] >= 0 {
+			qualifier := strings.Trim(clause[m[2]:m[3]], "\"`")
+			if !strings.EqualFold(qualifier, name) && !strings.EqualFold(qualifier, alias) {
+				continue
+			}
+		}
+		if conjunct(clause, m[0], m[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Report whether the condition at clause[start:end] holds for every row the clause does: at
+// each level of parentheses around it, it is joined to the others with AND, and it is not
+// in a subquery.
+func conjunct(clause string, start, end int) bool {
+	for {
+		from, to := groupStart(clause, start), groupEnd(clause, end)
+		group := clause[from:to]
+
+		if topLevelWord(group, "or") >= 0 {
+			return false
+		}
+		if before := strings.TrimSpace(clause[from:start]); before != "" && !endsWithWord(before, "and") {
+			return false
+		}
+		if after := strings.TrimSpace(clause[end:to]); after != "" && topLevelWord(after, "and") != 0 {
+			return false
+		}
+
+		if from == 0 {
+			return true
+		}
+		if topLevelWord(group, "select") >= 0 {
+			return false
+		}
+		start, end = from-1, to+1
+	}
+}
+
+// The end of the statement s starts, at a parenthesis closing the statement around it or a
+// set operation.
+func statementEnd(s string) int {
+	end := groupEnd(s, 0)
+	if i := topLevelWord(s[:end], statementEnds...); i >= 0 {
+		return i
+	}
+	return end
+}
+
+// The start of the parentheses around s[pos], or 0.
+func groupStart(s string, pos int) int {
+	depth := 0
+	for i := pos - 1; i >= 0; i-- {
+		switch s[i] {
+		case ')':
+			depth++
+		case '(':
+			if depth == 0 {
+				return i + 1
+			}
+			depth--
+		}
+	}
+	return 0
+}
+
+// The end of the parentheses around s[pos], or len(s).
+func groupEnd(s string, pos int) int {
+	depth := 0
+	for i := pos; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(s)
+}
+
+// The index of the first of the words in s outside parentheses, or -1.
+func topLevelWord(s string, words ...string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+			continue
+		case ')':
+			depth--
+			continue
+		}
+		if depth != 0 || (i > 0 && isWordByte(s[i-1])) {
+			continue
+		}
+		for _, word := range words {
+			if end := i + len(word); end <= len(s) && strings.EqualFold(s[i:end], word) && (end == len(s) || !isWordByte(s[end])) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Report whether s ends with the word.
+func endsWithWord(s, word string) bool {
+	start := len(s) - len(word)
+	return start >= 0 && strings.EqualFold(s[start:], word) && (start == 0 || !isWordByte(s[start-1]))
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b == '$' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// Register tables as tenant-scoped, so the guard refuses statements on them that bypass the
+// tenant scope.
+func registerTenantTables(tables ...string) {
+	for _, table := range tables {
+		tenantTables.Store(table, true)
+	}
+}
+
+// Guard a database the framework opened under column isolation: its pool is replaced by one
+// with the same limits whose connections refuse statements that bypass the tenant scope
+// (see checkTenantScope) before they run, and the framework's pool is closed, so the process
+// keeps a single pool on the database. It returns a session on the database's pool, or nil
+// when no database is configured. source is where the framework opened the database.
+func guardDatabase(db *database.Database, source *database.DataSourceName) (upper.Session, error) {
+	if db == nil || db.Pool == nil {
+		return nil, nil
+	}
+
+	if TenantIsolation != TenantIsolationColumn {
+		return db.NewSession(), nil
+	}
+
+	guarded, err := openTenantGuard(db.DataType, db.Pool, source)
+	if err != nil {
+		return nil, err
+	}
+	db.Pool.Close()
+	db.Pool = guarded
+
+	if sess := db.NewSession(); sess != nil {
+		return sess, nil
+	}
+	return nil, fmt.Errorf("models: cannot open a session on the %s database", db.DataType)
+}
+
+// Open a pool on the database of source whose connections refuse statements that bypass
+// the tenant scope (see checkTenantScope) before they run. pool is the pool the framework
+// opened on the database; its driver and limits are reused.
+func openTenantGuard(dataType string, pool *sql.DB, source *database.DataSourceName) (*sql.DB, error) {
+	var dsn string
+	switch strings.ToLower(strings.TrimSpace(dataType)) {
+	case "postgres", "postgresql":
+		dsn = postgresdriver.BuildDSN(source.Host, source.Port, source.User, source.Password, source.DatabaseName, source.SslMode)
+	case "mysql", "mariadb":
+		dsn = mysqldriver.BuildDSN(source.Host, source.Port, source.User, source.Password, source.DatabaseName)
+	default:
+		return nil, fmt.Errorf("models: unsupported database type %q", dataType)
+	}
+
+	guarded, err := guardTenantTables(pool.Driver(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	copyPoolLimits(pool, guarded)
+
+	return guarded, nil
+}
+
+// Set the limits of a pool to those of another. database/sql only reports the limit of open
+// connections, so the others are read from the fields of the pool; those that can not be
+// read are left at their defaults.
+func copyPoolLimits(from, to *sql.DB) {
+	to.SetMaxOpenConns(from.Stats().MaxOpenConnections)
+
+	fields := reflect.ValueOf(from).Elem()
+	if f := fields.FieldByName("maxIdleCount"); f.IsValid() && f.CanInt() && f.Int() != 0 {
+		// negative when set to keep no idle connections; zero is the default
+		to.SetMaxIdleConns(int(max(f.Int(), 0)))
+	}
+	if f := fields.FieldByName("maxLifetime"); f.IsValid() && f.CanInt() {
+		to.SetConnMaxLifetime(time.Duration(f.Int()))
+	}
+	if f := fields.FieldByName("maxIdleTime"); f.IsValid() && f.CanInt() {
+		to.SetConnMaxIdleTime(time.Duration(f.Int()))
+	}
+}
+
+// Open a pool with the driver whose connections refuse statements that bypass the tenant
+// scope.
+func guardTenantTables(d driver.Driver, dsn string) (*sql.DB, error) {
+	connector := &tenantGuardConnector{driver: d, dsn: dsn}
+	if dc, ok := d.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		connector.connector = c
+	}
+	return sql.OpenDB(connector), nil
+}
+
+type tenantGuardConnector struct {
+	driver    driver.Driver
+	connector driver.Connector
+	dsn       string
+}
+
+func (c *tenantGuardConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var conn driver.Conn
+	var err error
+	if c.connector != nil {
+		conn, err = c.connector.Connect(ctx)
+	} else {
+		conn, err = c.driver.Open(c.dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tenantGuardConn{Conn: conn}, nil
+}
+
+func (c *tenantGuardConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// A connection checking statements before passing them on; the optional interfaces of the
+// driver's connection are passed through.
+type tenantGuardConn struct {
+	driver.Conn
+}
+
+func (c *tenantGuardConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := checkTenantScope(ctx, query); err != nil {
+		return nil, err
+	}
+
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tenantGuardStmt{Stmt: stmt, conn: c.Conn, query: query}, nil
+}
+
+func (c *tenantGuardConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := checkTenantScope(ctx, query); err != nil {
+		return nil, err
+	}
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *tenantGuardConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := checkTenantScope(ctx, query); err != nil {
+		return nil, err
+	}
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *tenantGuardConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *tenantGuardConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *tenantGuardConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tenantGuardConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tenantGuardConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// A prepared statement, checked again each time it runs as it may be run with another
+// context than the one it was prepared with, e.g., from a statement cache.
+type tenantGuardStmt struct {
+	driver.Stmt
+	conn  driver.Conn
+	query string
+}
+
+func (s *tenantGuardStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := checkTenantScope(ctx, s.query); err != nil {
+		return nil, err
+	}
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *tenantGuardStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := checkTenantScope(ctx, s.query); err != nil {
+		return nil, err
+	}
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+func (s *tenantGuardStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	if n, ok := s.conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// The values of arguments for a driver that takes no names.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("models: the driver does not support named arguments")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,307 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	upper "github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/sqlite"
+)
+
+type note struct {
+	ID       int    `db:"id,omitempty"`
+	TenantID int    `db:"tenant_id"`
+	Body     string `db:"body"`
+}
+
+const notesTable = `CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, tenant_id INTEGER NOT NULL, body TEXT NOT NULL)`
+
+var (
+	acme   = &Tenant{ID: 1, Slug: "acme", Schema: "acme"}
+	globex = &Tenant{ID: 2, Slug: "globex", Schema: "globex"}
+)
+
+// Switch tenant isolation on for the duration of a test.
+func useTenantIsolation(t *testing.T, mode string) {
+	t.Helper()
+
+	previous := TenantIsolation
+	TenantIsolation = mode
+	t.Cleanup(func() { TenantIsolation = previous })
+}
+
+// Set up column isolation with a note for each tenant; returns the ids of acme's and
+// globex's notes.
+func setupColumnTenancy(t *testing.T) (*Repository[note], int, int) {
+	useTenantIsolation(t, TenantIsolationColumn)
+	useSessions(t, NewSessionRouter(openTestSession(t, "tenants", notesTable), "sqlite"))
+
+	notes := NewRepository[note]("notes")
+
+	acmeID, err := notes.Insert(WithTenant(context.Background(), acme), &note{Body: "acme secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	globexID, err := notes.Insert(WithTenant(context.Background(), globex), &note{Body: "globex secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return notes, int(acmeID.(int64)), int(globexID.(int64))
+}
+
+func TestTenant_ColumnIsolationReads(t *testing.T) {
+	notes, acmeID, globexID := setupColumnTenancy(t)
+	ctx := WithTenant(context.Background(), acme)
+
+	items, err := notes.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Body != "acme secret" {
+		t.Errorf("Expected only acme's note, got %+v", items)
+	}
+
+	if _, err := notes.Get(ctx, globexID); !errors.Is(err, upper.ErrNoMoreRows) {
+		t.Errorf("Expected another tenant's row to be invisible, got %v", err)
+	}
+
+	// conditions supplied by the caller can not widen the scope
+	items, err = notes.All(ctx, upper.Or(upper.Cond{"id": acmeID}, upper.Cond{"id": globexID}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != acmeID {
+		t.Errorf("Expected an OR condition to stay within the tenant, got %+v", items)
+	}
+
+	if n, _ := notes.Count(ctx, upper.Cond{"tenant_id": globex.ID}); n != 0 {
+		t.Errorf("Expected a condition on another tenant_id to match nothing, got %d rows", n)
+	}
+}
+
+func TestTenant_ColumnIsolationWrites(t *testing.T) {
+	notes, _, globexID := setupColumnTenancy(t)
+	ctx := WithTenant(context.Background(), acme)
+
+	if err := notes.Update(ctx, globexID, &note{Body: "overwritten"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.Delete(ctx, globexID); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := notes.Get(WithTenant(context.Background(), globex), globexID)
+	if err != nil {
+		t.Fatalf("Expected globex's note to survive, got %v", err)
+	}
+	if item.Body != "globex secret" || item.TenantID != globex.ID {
+		t.Errorf("Expected globex's note to be untouched, got %+v", item)
+	}
+
+	if _, err := notes.Insert(ctx, &note{TenantID: globex.ID, Body: "planted"}); !errors.Is(err, ErrTenantMismatch) {
+		t.Errorf("Expected insert into another tenant to fail, got %v", err)
+	}
+}
+
+func TestTenant_ScopeRequired(t *testing.T) {
+	notes, _, _ := setupColumnTenancy(t)
+
+	if _, err := notes.All(context.Background()); !errors.Is(err, ErrTenantRequired) {
+		t.Errorf("Expected a read without a tenant to fail, got %v", err)
+	}
+	if _, err := notes.Insert(context.Background(), &note{Body: "orphan"}); !errors.Is(err, ErrTenantRequired) {
+		t.Errorf("Expected a write without a tenant to fail, got %v", err)
+	}
+
+	n, err := notes.Count(WithoutTenantScope(context.Background()))
+	if err != nil || n != 2 {
+		t.Errorf("Expected an explicitly unscoped read to see every tenant, got %d rows (%v)", n, err)
+	}
+}
+
+func TestTenant_GuardRefusesUnscopedStatements(t *testing.T) {
+	useTenantIsolation(t, TenantIsolationColumn)
+
+	path := filepath.Join(t.TempDir(), "guarded.db")
+	plain, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	pool, err := guardTenantTables(plain.Driver(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := sqlite.New(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	if _, err := sess.SQL().Exec(notesTable); err != nil {
+		t.Fatal(err)
+	}
+	useSessions(t, NewSessionRouter(sess, "sqlite"))
+
+	notes := NewRepository[note]("notes")
+	for _, tenant := range []*Tenant{acme, globex} {
+		if _, err := notes.Insert(WithTenant(context.Background(), tenant), &note{Body: tenant.Slug + " secret"}); err != nil {
+			t.Fatalf("Expected the repository layer to pass the guard, got %v", err)
+		}
+	}
+	if items, err := notes.All(WithTenant(context.Background(), acme)); err != nil || len(items) != 1 {
+		t.Fatalf("Expected the repository layer to read acme's note, got %d (%v)", len(items), err)
+	}
+
+	// raw statements, with or without a tenant in the context, never reach the database
+	for _, ctx := range []context.Context{context.Background(), WithTenant(context.Background(), acme)} {
+		if _, err := sess.WithContext(ctx).SQL().Query(`SELECT * FROM notes`); !errors.Is(err, ErrTenantUnscoped) {
+			t.Errorf("Expected a raw read without a tenant scope to fail, got %v", err)
+		}
+		if _, err := sess.WithContext(ctx).Collection("notes").Find().Count(); !errors.Is(err, ErrTenantUnscoped) {
+			t.Errorf("Expected a collection read without a tenant scope to fail, got %v", err)
+		}
+		if _, err := sess.WithContext(ctx).SQL().Exec(`DELETE FROM "notes"`); !errors.Is(err, ErrTenantUnscoped) {
+			t.Errorf("Expected a raw write without a tenant scope to fail, got %v", err)
+		}
+		if _, err := sess.WithContext(ctx).SQL().Query(`SELECT id, tenant_id FROM notes WHERE tenant_id IS NOT NULL`); !errors.Is(err, ErrTenantUnscoped) {
+			t.Errorf("Expected a raw read mentioning tenant_id without scoping by it to fail, got %v", err)
+		}
+	}
+
+	if n, err := notes.Count(WithoutTenantScope(context.Background())); err != nil || n != 2 {
+		t.Errorf("Expected the refused write not to run, got %d rows (%v)", n, err)
+	}
+	if _, err := sess.SQL().Query(`SELECT * FROM notes WHERE tenant_id = ?`, acme.ID); err != nil {
+		t.Errorf("Expected a raw read scoped by hand to pass, got %v", err)
+	}
+	if _, err := sess.WithContext(WithoutTenantScope(context.Background())).SQL().Query(`SELECT * FROM notes`); err != nil {
+		t.Errorf("Expected an explicitly unscoped raw read to pass, got %v", err)
+	}
+}
+
+func TestTenant_UnscopedTables(t *testing.T) {
+	NewRepository[note]("notes")
+
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		// as the repository layer writes them
+		{"SELECT\n  *\n  FROM \"notes\"\n  WHERE (\"id\" = ? AND \"tenant_id\" = ?)\n  LIMIT 1", ""},
+		{`SELECT * FROM "notes" WHERE (("body" = ? OR "id" = ?) AND "tenant_id" = ?)`, ""},
+		{`SELECT count(1) AS _t FROM "notes" WHERE ("tenant_id" = $1)`, ""},
+		{`INSERT INTO "notes" ("body", "tenant_id") VALUES (?, ?) RETURNING "id"`, ""},
+		{`UPDATE "notes" SET "body" = ?, "tenant_id" = ? WHERE ("id" = ? AND "tenant_id" = ?)`, ""},
+		{`DELETE FROM "notes" WHERE ("id" = ? AND "tenant_id" = ?)`, ""},
+
+		// scoped by hand
+		{`SELECT * FROM users u JOIN notes n ON n.user_id = u.id WHERE n.tenant_id = :tenant ORDER BY n.id`, ""},
+		{"SELECT * FROM `notes` WHERE `tenant_id` = ? AND body LIKE ?", ""},
+		{`SELECT * FROM users WHERE bio = 'copied from notes'`, ""},
+
+		// not scoped
+		{`SELECT * FROM "notes" WHERE ("id" = ?)`, "notes"},
+		{"SELECT * FROM `notes`", "notes"},
+		{`SELECT * FROM users AS u, public.notes`, "notes"},
+		{`INSERT INTO notes (body) VALUES (?)`, "notes"},
+		{`update notes set body = ?`, "notes"},
+		{`SELECT * FROM users WHERE id IN (SELECT user_id FROM notes)`, "notes"},
+
+		// mentioning tenant_id without scoping by it
+		{`SELECT id, tenant_id FROM notes`, "notes"},
+		{`SELECT * FROM notes WHERE tenant_id IS NOT NULL`, "notes"},
+		{`SELECT * FROM notes ORDER BY tenant_id`, "notes"},
+		{`SELECT * FROM notes WHERE body = 'tenant_id = ?'`, "notes"},
+		{`SELECT * FROM notes WHERE tenant_id = ? OR 1 = 1`, "notes"},
+		{`SELECT * FROM notes WHERE 1 = 1 OR tenant_id = ? AND id = ?`, "notes"},
+		{`SELECT * FROM notes WHERE NOT (tenant_id = ?)`, "notes"},
+		{`SELECT * FROM notes WHERE tenant_id = ? IS NOT NULL`, "notes"},
+		{`SELECT * FROM notes WHERE id IN (SELECT id FROM notes WHERE tenant_id = ?)`, "notes"},
+		{`SELECT * FROM notes n WHERE other.tenant_id = ?`, "notes"},
+		{`UPDATE notes SET tenant_id = ? WHERE id = ?`, "notes"},
+		{`SELECT * FROM notes WHERE tenant_id = ? UNION SELECT * FROM notes`, "notes"},
+		{`INSERT INTO notes SELECT * FROM archived WHERE tenant_id = ?`, "notes"},
+	}
+
+	for _, tc := range testCases {
+		if got := unscopedTenantTable(tc.query); got != tc.expected {
+			t.Errorf("Expected %q for %s, got %q", tc.expected, tc.query, got)
+		}
+	}
+}
+
+func TestTenant_SchemaIsolation(t *testing.T) {
+	useTenantIsolation(t, TenantIsolationSchema)
+
+	// SQLite's attached databases stand in for Postgres schemas
+	sess := openTestSession(t, "public")
+	sess.Driver().(*sql.DB).SetMaxOpenConns(1)
+	for _, tenant := range []*Tenant{acme, globex} {
+		path := filepath.Join(t.TempDir(), tenant.Schema+".db")
+		if _, err := sess.SQL().Exec("ATTACH DATABASE '" + path + "' AS " + tenant.Schema); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sess.SQL().Exec(`CREATE TABLE ` + tenant.Schema + `.notes (id INTEGER PRIMARY KEY AUTOINCREMENT, tenant_id INTEGER NOT NULL DEFAULT 0, body TEXT NOT NULL)`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	useSessions(t, NewSessionRouter(sess, "sqlite"))
+
+	notes := NewRepository[note]("notes")
+	if _, err := notes.Insert(WithTenant(context.Background(), globex), &note{Body: "globex secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := notes.All(WithTenant(context.Background(), acme))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected acme's schema to hold none of globex's rows, got %+v", items)
+	}
+
+	bad := &Tenant{Slug: "evil", Schema: `acme"; DROP TABLE notes; --`}
+	if _, err := notes.All(WithTenant(context.Background(), bad)); err == nil {
+		t.Error("Expected an invalid schema name to be refused")
+	}
+}
+
+func TestTenant_GuardKeepsPoolLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.db")
+	pool, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	pool.SetMaxOpenConns(7)
+	pool.SetMaxIdleConns(3)
+
+	guarded, err := guardTenantTables(pool.Driver(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer guarded.Close()
+	copyPoolLimits(pool, guarded)
+
+	if n := guarded.Stats().MaxOpenConnections; n != 7 {
+		t.Errorf("Expected the guarded pool to open at most 7 connections, got %d", n)
+	}
+
+	ctx := context.Background()
+	conns := make([]*sql.Conn, 5)
+	for i := range conns {
+		if conns[i], err = guarded.Conn(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if idle := guarded.Stats().Idle; idle != 3 {
+		t.Errorf("Expected the guarded pool to keep 3 idle connections, got %d", idle)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"myapp/mail"
+	"myapp/mail/webhook"
+	"myapp/mailables"
+	"myapp/models"
+	"os"
+	"path/filepath"
+)
+
+// Here is where mail is set up. Mail is rendered when it is sent (see mail.Mailable) and
+// kept in the outbox table until a worker has sent it, with retries (see
+// models.MailOutbox). Without a database, mail is sent right away and lost when sending
+// fails. Handlers send mailables through Handlers.Mail. In development, the mailables
+// registered in mailPreviews are previewed at /_dev/mail/preview, and the mail caught with
+// MAIL_TRANSPORT=catcher is shown at /_dev/mail. The delivery events of the configured mail
+// services are received at /api/webhooks/mail/{provider} (see the webhook package), and
+// the addresses they suppress are left out of the mail sent.
+func (a *application) setupMail() {
+	renderer := mail.NewRenderer(a.App.JetViews, filepath.Join(a.App.RootPath, "public"), a.Mail)
+	transport := mail.NewTransport(a.Mail)
+
+	webhooks, err := webhook.FromEnv()
+	if err != nil {
+		a.App.Log.Error(err)
+		os.Exit(1)
+	}
+	a.Handlers.MailWebhooks = webhooks
+
+	var outbox mail.Outbox = a.Models.Outbox
+	if models.Sessions == nil {
+		a.App.Log.Warn("mail: no database; mail is sent without retries")
+		outbox = mail.NewDirect(transport)
+	}
+
+	a.Mailer = mail.NewWorker(outbox, transport, a.App.Log)
+	a.Mailer.Renderer = renderer
+	if models.Sessions != nil {
+		a.Mailer.Suppressions = a.Models.Suppressions
+	}
+	a.Handlers.Mail = a.Mailer
+
+	if a.App.Debug {
+		a.MailPreviews = mail.NewPreviews(renderer)
+		a.mailPreviews(a.MailPreviews)
+
+		if catcher, ok := transport.(*mail.Catcher); ok {
+			a.MailCatcher = catcher
+		}
+	}
+}
+
+// Here is where the mailables are registered for preview, each filled with sample data.
+func (a *application) mailPreviews(previews *mail.Previews) {
+	lang := a.Handlers.Lang
+
+	previews.Register("welcome", mailables.Welcome{
+		Name:    "Ada Lovelace",
+		Email:   "ada@example.com",
+		Subject: lang.T(lang.Fallback(), "mail.welcome.subject"),
+	})
+}
+
+// Here is where mail sending starts. The worker sends the outbox, and mail sent to the
+// framework's mail channel is moved into the outbox. On shutdown the worker sends the mail
+// that is due for a while, and the rest is sent on the next start.
+func (a *application) startMail() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopMail = cancel
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		a.Mailer.Listen(ctx, a.Mail.Jobs, a.Mail.Results)
+	}()
+	go func() {
+		defer wg.Done()
+		a.Mailer.Run(ctx)
+	}()
+}
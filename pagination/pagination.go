@@ -0,0 +1,318 @@
+package pagination
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	upper "github.com/upper/db/v4"
+)
+
+// Paginate a list in one of two modes. Offset pagination (?page=2&per_page=20) is simple
+// and lets a user jump to any page, but gets slower the deeper the page and can skip or
+// repeat rows while the table changes. Cursor pagination (?cursor=...) walks the rows by
+// key and is stable and fast at any depth, but can only move to the next or previous page.
+//
+// Example:
+//
+//	params, err := pagination.Parse(r, pagination.DefaultOptions)
+//	if err != nil {
+//		... respond with 400
+//	}
+//	page, err := pagination.FromRepository(r.Context(), users, params)
+//	pagination.WriteJSON(w, r, page)
+const (
+	ModeOffset = "offset"
+	ModeCursor = "cursor"
+)
+
+// Options holds the limits applied when parsing the query parameters of a request.
+type Options struct {
+	// DefaultPerPage is used when the request does not ask for a page size.
+	DefaultPerPage int
+
+	// MaxPerPage caps the page size a request can ask for.
+	MaxPerPage int
+
+	// Key is the unique, ordered column walked by cursor pagination.
+	Key string
+
+	// Cursor makes cursor pagination the default mode when the request has neither a
+	// page nor a cursor parameter.
+	Cursor bool
+}
+
+// DefaultOptions are the options used by most lists.
+var DefaultOptions = Options{
+	DefaultPerPage: 20,
+	MaxPerPage:     100,
+	Key:            "id",
+}
+
+// Params are the pagination parameters of a request.
+type Params struct {
+	Mode    string
+	Page    int
+	PerPage int
+	Key     string
+	Cursor  *Cursor
+}
+
+// Cursor is the decoded form of the opaque cursor parameter: the key of the row to start
+// after (or before, when moving backwards).
+type Cursor struct {
+	Key      interface{} `json:"k"`
+	Backward bool        `json:"b,omitempty"`
+}
+
+// Meta describes the page returned to the client.
+type Meta struct {
+	Mode       string  `json:"mode"`
+	Page       int     `json:"page,omitempty"`
+	PerPage    int     `json:"per_page"`
+	Total      *uint64 `json:"total,omitempty"`
+	TotalPages int     `json:"total_pages,omitempty"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
+	HasNext    bool    `json:"has_next"`
+	HasPrev    bool    `json:"has_prev"`
+}
+
+// Page is a page of items and the metadata needed to move to the next or previous page.
+type Page[T any] struct {
+	Items []T
+	Meta  Meta
+}
+
+// ParamError describes a pagination parameter the request got wrong.
+type ParamError struct {
+	Param   string
+	Message string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid %s parameter: %s", e.Param, e.Message)
+}
+
+// Parse reads the page, per_page and cursor query parameters. A page size above the
+// maximum is capped; a malformed or out-of-range value is an error the handler should
+// report to the client as a bad request.
+func Parse(r *http.Request, opts Options) (Params, error) {
+	query := r.URL.Query()
+
+	if opts.DefaultPerPage <= 0 {
+		opts.DefaultPerPage = DefaultOptions.DefaultPerPage
+	}
+	if opts.MaxPerPage <= 0 {
+		opts.MaxPerPage = DefaultOptions.MaxPerPage
+	}
+	if opts.Key == "" {
+		opts.Key = DefaultOptions.Key
+	}
+
+	params := Params{
+		Mode:    ModeOffset,
+		Page:    1,
+		PerPage: opts.DefaultPerPage,
+		Key:     opts.Key,
+	}
+
+	if value := query.Get("per_page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return params, &ParamError{Param: "per_page", Message: "must be a positive integer"}
+		}
+		params.PerPage = min(n, opts.MaxPerPage)
+	}
+
+	if value := query.Get("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return params, &ParamError{Param: "page", Message: "must be a positive integer"}
+		}
+		params.Page = n
+		return params, nil
+	}
+
+	if value, ok := query["cursor"]; ok || opts.Cursor {
+		params.Mode = ModeCursor
+		params.Page = 0
+
+		if ok && value[0] != "" {
+			cursor, err := DecodeCursor(value[0])
+			if err != nil {
+				return params, &ParamError{Param: "cursor", Message: "is not a valid cursor"}
+			}
+			params.Cursor = cursor
+		}
+	}
+
+	return params, nil
+}
+
+// Encode the cursor into the opaque string handed to clients.
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes an opaque cursor string. Integer keys are decoded as int64 so they
+// compare correctly against integer columns.
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	var cursor Cursor
+	if err := decoder.Decode(&cursor); err != nil {
+		return nil, err
+	}
+
+	switch key := cursor.Key.(type) {
+	case json.Number:
+		if n, err := key.Int64(); err == nil {
+			cursor.Key = n
+		} else if f, err := key.Float64(); err == nil {
+			cursor.Key = f
+		}
+	case string:
+	default:
+		return nil, errors.New("cursor key must be a number or a string")
+	}
+
+	return &cursor, nil
+}
+
+// Paginate fetches a page of the result according to the parameters. In offset mode the
+// total number of rows is counted as well; cursor mode fetches one extra row to know if
+// there is a next page and never counts.
+func Paginate[T any](res upper.Result, params Params) (*Page[T], error) {
+	if params.Mode == ModeCursor {
+		return paginateCursor[T](res, params)
+	}
+	return paginateOffset[T](res, params)
+}
+
+func paginateOffset[T any](res upper.Result, params Params) (*Page[T], error) {
+	total, err := res.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []T{}
+	if err := res.Paginate(uint(params.PerPage)).Page(uint(params.Page)).All(&items); err != nil {
+		return nil, err
+	}
+
+	totalPages := int((total + uint64(params.PerPage) - 1) / uint64(params.PerPage))
+
+	return &Page[T]{
+		Items: items,
+		Meta: Meta{
+			Mode:       ModeOffset,
+			Page:       params.Page,
+			PerPage:    params.PerPage,
+			Total:      &total,
+			TotalPages: totalPages,
+			HasNext:    params.Page < totalPages,
+			HasPrev:    params.Page > 1,
+		},
+	}, nil
+}
+
+func paginateCursor[T any](res upper.Result, params Params) (*Page[T], error) {
+	key := params.Key
+	backward := params.Cursor != nil && params.Cursor.Backward
+
+	if params.Cursor != nil {
+		op := " >"
+		if backward {
+			op = " <"
+		}
+		res = res.And(upper.Cond{key + op: params.Cursor.Key})
+	}
+
+	if backward {
+		res = res.OrderBy("-" + key)
+	} else {
+		res = res.OrderBy(key)
+	}
+
+	items := []T{}
+	if err := res.Limit(params.PerPage + 1).All(&items); err != nil {
+		return nil, err
+	}
+
+	more := len(items) > params.PerPage
+	if more {
+		items = items[:params.PerPage]
+	}
+
+	// rows fetched backwards are returned in key order
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	meta := Meta{
+		Mode:    ModeCursor,
+		PerPage: params.PerPage,
+		HasNext: more || backward,
+		HasPrev: params.Cursor != nil && (!backward || more),
+	}
+
+	if len(items) > 0 {
+		first, err := keyValue(items[0], key)
+		if err != nil {
+			return nil, err
+		}
+		last, err := keyValue(items[len(items)-1], key)
+		if err != nil {
+			return nil, err
+		}
+
+		if meta.HasNext {
+			meta.NextCursor = (&Cursor{Key: last}).Encode()
+		}
+		if meta.HasPrev {
+			meta.PrevCursor = (&Cursor{Key: first, Backward: true}).Encode()
+		}
+	}
+
+	return &Page[T]{Items: items, Meta: meta}, nil
+}
+
+// Read the value of the field mapped to the column from an item.
+func keyValue(item interface{}, column string) (interface{}, error) {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	if v.Kind() == reflect.Map {
+		if value := v.MapIndex(reflect.ValueOf(column)); value.IsValid() {
+			return value.Interface(), nil
+		}
+		return nil, fmt.Errorf("pagination: item has no %q key", column)
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("pagination: can not read %q from %s", column, v.Kind())
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("db"), ",")
+		if name == column {
+			return v.Field(i).Interface(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("pagination: item has no field for column %q", column)
+}
@@ -0,0 +1,213 @@
+package pagination
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudyKit/jet/v6"
+	upper "github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/sqlite"
+)
+
+type item struct {
+	ID   int    `db:"id,omitempty" json:"id"`
+	Name string `db:"name" json:"name"`
+}
+
+// Open a throwaway SQLite database with the given number of items.
+func setupItems(t *testing.T, n int) upper.Session {
+	t.Helper()
+
+	sess, err := sqlite.Open(sqlite.ConnectionURL{Database: filepath.Join(t.TempDir(), "items.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sess.Close() })
+
+	if _, err := sess.SQL().Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= n; i++ {
+		if _, err := sess.Collection("items").Insert(&item{Name: fmt.Sprintf("item-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return sess
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		mode    string
+		page    int
+		perPage int
+		wantErr string
+	}{
+		{"defaults", "", ModeOffset, 1, 20, ""},
+		{"page and per page", "?page=3&per_page=10", ModeOffset, 3, 10, ""},
+		{"per page capped", "?per_page=1000", ModeOffset, 1, 100, ""},
+		{"empty cursor", "?cursor=", ModeCursor, 0, 20, ""},
+		{"invalid page", "?page=0", "", 0, 0, "page"},
+		{"invalid per page", "?per_page=abc", "", 0, 0, "per_page"},
+		{"invalid cursor", "?cursor=bm90LWpzb24", "", 0, 0, "cursor"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/items"+tc.query, nil)
+			params, err := Parse(r, DefaultOptions)
+
+			if tc.wantErr != "" {
+				paramErr, ok := err.(*ParamError)
+				if !ok || paramErr.Param != tc.wantErr {
+					t.Fatalf("Expected error for %s, got %v", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if params.Mode != tc.mode || params.Page != tc.page || params.PerPage != tc.perPage {
+				t.Errorf("Unexpected params: %+v", params)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	encoded := (&Cursor{Key: 42, Backward: true}).Encode()
+
+	cursor, err := DecodeCursor(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cursor.Key != int64(42) || !cursor.Backward {
+		t.Errorf("Unexpected cursor: %+v", cursor)
+	}
+}
+
+func TestPaginate_Offset(t *testing.T) {
+	sess := setupItems(t, 25)
+
+	page, err := Paginate[item](sess.Collection("items").Find().OrderBy("id"), Params{Mode: ModeOffset, Page: 2, PerPage: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Items) != 10 || page.Items[0].ID != 11 {
+		t.Errorf("Expected items 11-20, got %+v", page.Items)
+	}
+
+	if *page.Meta.Total != 25 || page.Meta.TotalPages != 3 || !page.Meta.HasNext || !page.Meta.HasPrev {
+		t.Errorf("Unexpected meta: %+v", page.Meta)
+	}
+}
+
+func TestPaginate_Cursor(t *testing.T) {
+	sess := setupItems(t, 25)
+	params := Params{Mode: ModeCursor, PerPage: 10, Key: "id"}
+
+	var seen []int
+	for {
+		page, err := Paginate[item](sess.Collection("items").Find(), params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range page.Items {
+			seen = append(seen, i.ID)
+		}
+		if !page.Meta.HasNext {
+			break
+		}
+		params.Cursor, _ = DecodeCursor(page.Meta.NextCursor)
+	}
+
+	if len(seen) != 25 || seen[0] != 1 || seen[24] != 25 {
+		t.Fatalf("Expected to walk all 25 items in order, got %v", seen)
+	}
+
+	// walk back from the last page
+	params.Cursor = &Cursor{Key: int64(21), Backward: true}
+	page, err := Paginate[item](sess.Collection("items").Find(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Items) != 10 || page.Items[0].ID != 11 || page.Items[9].ID != 20 {
+		t.Errorf("Expected items 11-20 in order, got %+v", page.Items)
+	}
+	if !page.Meta.HasPrev || !page.Meta.HasNext {
+		t.Errorf("Expected both directions to be available: %+v", page.Meta)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	sess := setupItems(t, 5)
+
+	page, err := Paginate[item](sess.Collection("items").Find().OrderBy("id"), Params{Mode: ModeOffset, Page: 1, PerPage: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/api/items?page=1&per_page=2&sort=name", nil)
+	w := httptest.NewRecorder()
+	if err := WriteJSON(w, r, page); err != nil {
+		t.Fatal(err)
+	}
+
+	link := w.Header().Get("Link")
+	if !strings.Contains(link, `</api/items?page=2&per_page=2&sort=name>; rel="next"`) {
+		t.Errorf("Expected next link keeping query parameters, got %s", link)
+	}
+	if !strings.Contains(link, `rel="last"`) || strings.Contains(link, `rel="prev"`) {
+		t.Errorf("Unexpected links: %s", link)
+	}
+
+	var body struct {
+		Data []item `json:"data"`
+		Meta Meta   `json:"meta"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Data) != 2 || body.Meta.TotalPages != 3 {
+		t.Errorf("Unexpected body: %+v", body)
+	}
+}
+
+func TestPagerPartial(t *testing.T) {
+	views := jet.NewSet(jet.NewOSFileSystemLoader("../resources/views"))
+
+	tmpl, err := views.Parse("/pager_test.jet", `{{ import "./partials/pager.jet" }}{{ yield pager(p=pager) }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := &Page[item]{Meta: Meta{Mode: ModeOffset, Page: 5, PerPage: 10, TotalPages: 10, HasNext: true, HasPrev: true}}
+	r := httptest.NewRequest("GET", "/items?page=5", nil)
+
+	vars := make(jet.VarMap)
+	vars.Set("pager", page.Pager(r))
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	html := out.String()
+	for _, expected := range []string{`href="/items?page=4" rel="prev"`, `aria-current="page"><span>5</span>`, `href="/items?page=10"`, "&hellip;"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected pager to contain %s", expected)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package pagination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"myapp/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Links are the URLs of the neighbouring pages. A link is empty when there is no such page.
+type Links struct {
+	First string `json:"first,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// PageLink is a single numbered link rendered by the pager partial.
+type PageLink struct {
+	Number  int
+	URL     string
+	Current bool
+	Gap     bool
+}
+
+// Pager is the pagination object handed to Jet templates and rendered by the pager
+// partial in resources/views/partials/pager.jet.
+//
+// Example:
+//
+//	vars := make(jet.VarMap)
+//	vars.Set("users", page.Items)
+//	vars.Set("pager", page.Pager(r))
+//
+//	{{ import "../partials/pager.jet" }}
+//	{{ yield pager(p=pager) }}
+type Pager struct {
+	Meta  Meta
+	Links Links
+	Pages []PageLink
+}
+
+// The envelope written for JSON responses.
+type envelope[T any] struct {
+	Data  []T   `json:"data"`
+	Meta  Meta  `json:"meta"`
+	Links Links `json:"links"`
+}
+
+// FromRepository runs the paginated query through the models layer, so the read is routed
+// like any other repository read.
+func FromRepository[T any](ctx context.Context, repo *models.Repository[T], params Params, cond ...interface{}) (*Page[T], error) {
+	res, err := repo.Find(ctx, cond...)
+	if err != nil {
+		return nil, err
+	}
+	return Paginate[T](res, params)
+}
+
+// Links builds the links to the neighbouring pages from the request URL, keeping any other
+// query parameters (filters, sorting) the request has.
+func (p *Page[T]) Links(r *http.Request) Links {
+	var links Links
+
+	switch p.Meta.Mode {
+	case ModeCursor:
+		links.First = pageURL(r, "cursor", "")
+		if p.Meta.HasPrev {
+			links.Prev = pageURL(r, "cursor", p.Meta.PrevCursor)
+		}
+		if p.Meta.HasNext {
+			links.Next = pageURL(r, "cursor", p.Meta.NextCursor)
+		}
+
+	default:
+		links.First = pageURL(r, "page", "1")
+		if p.Meta.HasPrev {
+			links.Prev = pageURL(r, "page", strconv.Itoa(p.Meta.Page-1))
+		}
+		if p.Meta.HasNext {
+			links.Next = pageURL(r, "page", strconv.Itoa(p.Meta.Page+1))
+		}
+		if p.Meta.TotalPages > 0 {
+			links.Last = pageURL(r, "page", strconv.Itoa(p.Meta.TotalPages))
+		}
+	}
+
+	return links
+}
+
+// Pager returns the pagination object for Jet templates. Numbered links are included in
+// offset mode: the first and last page and a window around the current page.
+func (p *Page[T]) Pager(r *http.Request) *Pager {
+	pager := &Pager{Meta: p.Meta, Links: p.Links(r)}
+
+	if p.Meta.Mode != ModeOffset {
+		return pager
+	}
+
+	const window = 2
+	last := 0
+	for n := 1; n <= p.Meta.TotalPages; n++ {
+		if n != 1 && n != p.Meta.TotalPages && (n < p.Meta.Page-window || n > p.Meta.Page+window) {
+			continue
+		}
+		if last != 0 && n > last+1 {
+			pager.Pages = append(pager.Pages, PageLink{Gap: true})
+		}
+		pager.Pages = append(pager.Pages, PageLink{
+			Number:  n,
+			URL:     pageURL(r, "page", strconv.Itoa(n)),
+			Current: n == p.Meta.Page,
+		})
+		last = n
+	}
+
+	return pager
+}
+
+// WriteJSON writes the page as a JSON envelope of data, meta and links, along with a Link
+// header so clients can follow the pages without reading the body.
+func WriteJSON[T any](w http.ResponseWriter, r *http.Request, p *Page[T]) error {
+	links := p.Links(r)
+
+	if header := LinkHeader(links); header != "" {
+		w.Header().Set("Link", header)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return json.NewEncoder(w).Encode(envelope[T]{
+		Data:  p.Items,
+		Meta:  p.Meta,
+		Links: links,
+	})
+}
+
+// LinkHeader formats the links as an RFC 8288 Link header value.
+func LinkHeader(links Links) string {
+	var parts []string
+	for _, link := range []struct{ rel, url string }{
+		{"first", links.First},
+		{"prev", links.Prev},
+		{"next", links.Next},
+		{"last", links.Last},
+	} {
+		if link.url != "" {
+			parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, link.url, link.rel))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Build the URL of a page by replacing one pagination parameter of the request URL.
+func pageURL(r *http.Request, param, value string) string {
+	query := r.URL.Query()
+	query.Del("page")
+	query.Del("cursor")
+
+	// an empty cursor is kept so the link stays in cursor mode
+	if value != "" || param == "cursor" {
+		query.Set(param, value)
+	}
+
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"myapp/boot"
+	"myapp/services"
+	"os"
+	"path/filepath"
+
+	"github.com/cidekar/adele-framework/provider"
+)
+
+// Here is where the service providers of the framework are loaded (see the boot package).
+// Providers are registered by importing their package and configured in
+// config/providers.yml, which enables or disables them and sets their configuration, so
+// changing it needs no rebuild. They are loaded in dependency order, their configuration
+// checked first, and a report of how each fared is printed at boot. Loading fails, and the
+// application exits, when a dependency is missing or disabled, the dependencies have a
+// cycle, or a configuration value has the wrong type; keys of the file that no provider
+// takes are logged as warnings.
+func (a *application) loadProviders() {
+	p := &provider.Provider{
+		EnabledProviders: make(map[string]bool),
+		ProviderConfigs:  make(map[string]map[string]interface{}),
+	}
+	a.App.Provider = p
+
+	providers := provider.GetRegisteredProviders()
+	loader := &boot.Loader{Provider: p, Specs: providerSpecs(), Services: a.Services}
+	unknown, err := loader.LoadConfig(filepath.Join(a.App.RootPath, "config", "providers.yml"), providers)
+	if err != nil {
+		a.App.Log.Error(err)
+		os.Exit(1)
+	}
+	for _, key := range unknown {
+		a.App.Log.Warnf("config/providers.yml: no provider takes %q", key)
+	}
+
+	report, err := loader.Load(a.App, providers)
+	a.Providers = report
+	report.WriteTo(os.Stdout)
+	if err != nil {
+		a.App.Log.Errorf("failed to load providers:\n%s", err)
+		os.Exit(1)
+	}
+
+	requireServices(a.Services)
+	if err := a.Services.Check(); err != nil {
+		a.App.Log.Error(err)
+		os.Exit(1)
+	}
+}
+
+// Here is where the services the application cannot run without are declared, so it
+// stops at boot rather than failing a request when the provider publishing one is missing
+// or disabled. Services are published by the providers once booted (see the services
+// package) and resolved by type, e.g., by a handler:
+//
+//	services.Require[*queue.Queue](c, "dispatching mail jobs")
+//
+//	q := services.MustResolve[*queue.Queue](h.Services)
+func requireServices(c *services.Container) {
+	// ...
+}
+
+// Here is where providers that do not declare their dependencies and configuration
+// themselves (see boot.DependentProvider and boot.SchemaProvider) are declared, by name.
+// The types are those the providers' Configure methods assert; a value of another type
+// is not converted but zero-defaulted by them, so it is refused at boot instead.
+func providerSpecs() map[string]boot.Spec {
+	return map[string]boot.Spec{
+		// github.com/cidekar/adele-queue
+		"queue": {Config: boot.Schema{
+			"backend":               {Type: boot.String},
+			"worker_count":          {Type: boot.Int},
+			"max_attempts":          {Type: boot.Int},
+			"high_water_mark":       {Type: boot.Int},
+			"queue_channels":        {Type: boot.Strings},
+			"queue_channel_default": {Type: boot.String},
+			"redis_prefix":          {Type: boot.String},
+			"redis_scan_interval":   {Type: boot.Int},
+			"lock_timeout":          {Type: boot.Int},
+			"reaper_interval":       {Type: boot.Int},
+		}},
+		// github.com/cidekar/adele-oauth2
+		"oauth": {Config: boot.Schema{
+			"guarded_route_groups": {Type: boot.Strings},
+			"unguarded_routes":     {Type: boot.Strings},
+			"scopes":               {Type: boot.StringMap},
+		}},
+	}
+}
@@ -13,12 +13,23 @@ func (a *application) ApiRoutes() http.Handler {
 
 	// API Middleware: here is where you can add your Middleware for the API routes. These middleware are
 	// called on each API route request.
+
+	// 404 and 405 Routes: API requests that match no route are answered with a problem
+	// document like every other API error.
+	r.NotFound(a.Handlers.NotFound)
+	r.MethodNotAllowed(a.Handlers.MethodNotAllowed)
+
 	r.Route("/api", func(mux chi.Router) {
 
 		// API Routes: here is where you can add your API routes for the application. These
 		// routes are loaded by the router.
 		// TODO:
 		//r.Get("/health", a.Handlers.HealthStatus)
+
+		r.With(a.Middleware.AuditAPI).Get("/audit", a.Handlers.AuditIndex)
+
+		// Mail webhooks: the delivery events of mail services, verified by their signatures.
+		r.Post("/webhooks/mail/{provider}", a.Handlers.MailWebhook)
 	})
 
 	return r
@@ -16,12 +16,14 @@ func (a *application) WebRoutes() http.Handler {
 
 	r.Use(a.Middleware.NoSurf)
 
-	// 404 Route: Here is a catch-all web route for routing paths in the application
-	// that could not be found.
+	// 404 and 405 Routes: Here is a catch-all web route for routing paths in the application
+	// that could not be found, or that do not support the method of the request. The views
+	// are in resources/views/errors.
 
 	r.NotFound(a.Handlers.NotFound)
+	r.MethodNotAllowed(a.Handlers.MethodNotAllowed)
 
-	r.Route("/", func(mux chi.Router) {
+	r.Group(func(mux chi.Router) {
 
 		// Web Routes: here is where you can add your web routes for the application. These
 		// routes are loaded by the router.
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"myapp/mail"
+	"myapp/views"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -9,6 +11,22 @@ import (
 )
 
 func (a *application) routes() *mux.Mux {
+	// Application middleware: here is where middleware that applies to every route
+	// is added. Middleware must be added before any routes are mounted.
+	a.App.Routes.Use(a.Middleware.QueryLog)
+	a.App.Routes.Use(a.Middleware.ReadYourWrites)
+	a.App.Routes.Use(a.Middleware.TrustedProxy)
+	a.App.Routes.Use(a.Middleware.Locale)
+	a.App.Routes.Use(a.Middleware.Maintenance)
+	a.App.Routes.Use(a.Middleware.ContentSecurityPolicy)
+	a.App.Routes.Use(a.Middleware.TenantResolver)
+	a.App.Routes.Use(a.Middleware.AuditContext)
+
+	// Requests the mounted routers do not reach (e.g., a POST to /public) are answered by
+	// the same error handlers as the web and API routes.
+	a.App.Routes.NotFound(a.Handlers.NotFound)
+	a.App.Routes.MethodNotAllowed(a.Handlers.MethodNotAllowed)
+
 	fileServer := http.FileServer(http.Dir("./public"))
 
 	// Wrapper function to clean the path and check for traveral attempts
@@ -28,6 +46,24 @@ func (a *application) routes() *mux.Mux {
 		fileServer.ServeHTTP(w, r)
 	})
 	a.App.Routes.Method("Get", "/public/*", http.StripPrefix("/public", secureFileServer))
+
+	// In development, pages reload themselves when the views change (see compileViews).
+	if a.LiveReload != nil {
+		a.App.Routes.Get(views.LiveReloadPath, a.LiveReload.ServeHTTP)
+	}
+
+	// In development, mail is previewed, and the mail caught is shown, in the browser (see
+	// setupMail).
+	if a.MailPreviews != nil {
+		a.App.Routes.Get(mail.PreviewPath, a.MailPreviews.ServeHTTP)
+		a.App.Routes.Get(mail.PreviewPath+"/*", a.MailPreviews.ServeHTTP)
+	}
+	if a.MailCatcher != nil {
+		a.App.Routes.Get(mail.CatcherPath, a.MailCatcher.ServeHTTP)
+		a.App.Routes.Get(mail.CatcherPath+"/*", a.MailCatcher.ServeHTTP)
+		a.App.Routes.Post(mail.CatcherPath+"/clear", a.MailCatcher.ServeHTTP)
+	}
+
 	a.App.Routes.Mount("/", a.WebRoutes())
 	a.App.Routes.Mount("/api", a.ApiRoutes())
 	return a.App.Routes
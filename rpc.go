@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"myapp/boot"
+	"myapp/maintenance"
+	"myapp/rpcapi"
+	"myapp/rpcapi/admin"
+	"net/http"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cidekar/adele-framework/rpcserver"
+	"github.com/go-chi/chi/v5"
+	"github.com/joho/godotenv"
+)
+
+// Here is where the RPC methods of the application are registered. They are served by the
+// framework's RPC server (RPC_SERVER_ADDR:RPC_SERVER_PORT) as typed methods (see the rpcapi
+// package): a method takes a context, bounded by the caller's deadline, and a request
+// struct, and returns a response struct. The Admin methods drive maintenance mode, the
+// cache, jobs, configuration, the log level and shutdown, and report the status of the
+// application; ./adeleApp ctl calls them. ./adeleApp rpc list shows the methods registered, and
+// ./adeleApp rpc client generates the rpcclient package that other services import to call
+// them; generate it again after changing the methods.
+func (a *application) rpcServices(r *rpcapi.Registry) {
+	admin.Register(r, &admin.Service{
+		Maintenance: maintenance.New(a.App.RootPath),
+		Cache:       a.App.Cache,
+		Jobs:        a.jobs(),
+		Reload:      a.reloadConfig,
+		Started:     a.started,
+		Providers:   a.providerStatus,
+		Routes:      a.routeTable,
+		Scheduled:   a.scheduledJobs,
+		Log:         a.App.Log,
+		Shutdown:    a.requestShutdown,
+	})
+
+	// The methods of the application are registered with rpcapi.Handle, their request and
+	// response structs declared in a package of their own so the client can import them:
+	//
+	//	rpcapi.Handle(r, "Users.Get", func(ctx context.Context, req *userrpc.GetRequest) (*userrpc.GetResponse, error) {
+	//		...
+	//	}, rpcapi.Describe("Get returns a user by ID."))
+}
+
+func (a *application) setupRPC() {
+	a.RPC = rpcapi.NewRegistry(a.App.Log)
+	a.rpcServices(a.RPC)
+}
+
+// Here is where the RPC server starts, in place of the framework's: callers authenticate
+// with a client certificate or a shared token, config/rpc.yml says which methods each of
+// them may call, and every call is logged with "audit=rpc" (see rpcapi.Server). Without
+// authentication the server only listens on loopback. RPC_SERVER_DISABLE turns it off.
+func (a *application) startRPC() error {
+	if os.Getenv("RPC_SERVER_DISABLE") != "" {
+		return nil
+	}
+
+	// the framework's maintenance method, as its RPC server served it
+	if err := rpc.Register(&rpcserver.RPCServer{App: a.App}); err != nil {
+		return err
+	}
+
+	server, err := rpcapi.ServerFromEnv(a.App.RootPath, a.App.Log)
+	if err != nil {
+		return err
+	}
+	if err := server.Listen(); err != nil {
+		return err
+	}
+	a.rpcServer = server
+	return nil
+}
+
+// The service providers of the framework, as they fared at boot.
+func (a *application) providerStatus() []admin.Provider {
+	providers := []admin.Provider{}
+	if a.Providers == nil {
+		return providers
+	}
+	for _, s := range a.Providers.Providers {
+		p := admin.Provider{Name: s.Name, Enabled: s.State != boot.Disabled, State: string(s.State), Boot: s.Register + s.Boot}
+		if s.Err != nil {
+			p.Error = s.Err.Error()
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// The routes of the HTTP server, mounted routers included.
+func (a *application) routeTable() []admin.Route {
+	routes := []admin.Route{}
+	if a.App.Routes == nil {
+		return routes
+	}
+	chi.Walk(a.App.Routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		routes = append(routes, admin.Route{Method: method, Pattern: route})
+		return nil
+	})
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// The entries of the scheduler, by when they run next.
+func (a *application) scheduledJobs() []admin.ScheduledJob {
+	jobs := []admin.ScheduledJob{}
+	if a.App.Scheduler == nil {
+		return jobs
+	}
+	for _, entry := range a.App.Scheduler.Entries() {
+		job := a.scheduled[entry.ID]
+		job.Next, job.Prev = entry.Next, entry.Prev
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// Read the .env file again and set the variables that changed. It returns their names, not
+// their values, which may be secrets. Settings read when they are used see the new values;
+// those read at boot, e.g., by middleware, need a restart.
+func (a *application) reloadConfig(ctx context.Context) ([]string, error) {
+	env, err := godotenv.Read(filepath.Join(a.App.RootPath, ".env"))
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for name, value := range env {
+		if current, ok := os.LookupEnv(name); ok && current == value {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return nil, err
+		}
+		changed = append(changed, name)
+	}
+	sort.Strings(changed)
+
+	a.App.Log.WithField("changed", changed).Info("configuration reloaded")
+	return changed, nil
+}
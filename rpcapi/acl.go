@@ -0,0 +1,59 @@
+package rpcapi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PrincipalLocal is the principal of the callers of a server without authentication, which
+// only listens on a loopback address.
+const PrincipalLocal = "local"
+
+// ACL is which methods each principal may call. A principal is the name of a token or the
+// common name of a client certificate; a method is a name, e.g., "Admin.Reload", a service
+// followed by ".*", or "*" for every method. Methods of other net/rpc services are named as
+// net/rpc names them, e.g., "Maintenance.Down". A principal that is not listed may call
+// nothing, except App.Methods, which every caller may list.
+type ACL map[string][]string
+
+// DefaultACL lets the callers of a server without authentication call every method, as the
+// framework's RPC server did.
+var DefaultACL = ACL{PrincipalLocal: {"*"}}
+
+// Allows tells whether a principal may call a method.
+func (a ACL) Allows(principal, method string) bool {
+	if method == ServiceName+".Methods" {
+		return true
+	}
+	for _, pattern := range a[principal] {
+		switch {
+		case pattern == "*", pattern == method:
+			return true
+		case strings.HasSuffix(pattern, ".*") && strings.HasPrefix(method, strings.TrimSuffix(pattern, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// LoadACL reads the ACL of config/rpc.yml. A file that does not exist gives DefaultACL.
+func LoadACL(file string) (ACL, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return DefaultACL, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		ACL ACL `yaml:"ACL"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("rpcapi: %s: %w", file, err)
+	}
+	return config.ACL, nil
+}
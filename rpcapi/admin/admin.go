@@ -0,0 +1,187 @@
+// Package admin is the Admin RPC service of the application: maintenance mode, the cache,
+// jobs and configuration, for operators and deploy scripts to drive a running application
+// (see the rpcclient package for a client).
+package admin
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"myapp/maintenance"
+	"myapp/rpcapi"
+
+	"github.com/cidekar/adele-framework/cache"
+	"github.com/sirupsen/logrus"
+)
+
+// Job is work the application can run on demand.
+type Job func(ctx context.Context) error
+
+// Service holds what the admin methods act on. A method whose dependency is not set fails
+// as unavailable.
+type Service struct {
+	Maintenance *maintenance.Mode
+	Cache       cache.Cache
+	// Jobs are the jobs Admin.RunJob runs, by name.
+	Jobs map[string]Job
+	// Reload reads the configuration again and returns what changed.
+	Reload func(ctx context.Context) ([]string, error)
+
+	// Started is when the application started, for Admin.Status to report its uptime.
+	Started time.Time
+	// Providers, Routes and Scheduled list what Admin.Status reports; nil lists nothing.
+	Providers func() []Provider
+	Routes    func() []Route
+	Scheduled func() []ScheduledJob
+	// Log is the logger whose level Admin.SetLogLevel changes.
+	Log *logrus.Logger
+	// Shutdown starts a graceful shutdown of the application. It must not block, so the
+	// caller is answered before the application stops.
+	Shutdown func()
+}
+
+// MaintenanceRequest takes the application down, or brings it back up.
+type MaintenanceRequest struct {
+	Down bool `json:"down"`
+	// Secret is a path that sets a cookie to bypass maintenance mode.
+	Secret string `json:"secret,omitempty"`
+	// Retry is the number of seconds clients are told to wait in Retry-After.
+	Retry int `json:"retry,omitempty"`
+}
+
+// MaintenanceResponse is the state of the application after the call.
+type MaintenanceResponse struct {
+	Status string    `json:"status"`
+	Since  time.Time `json:"since,omitempty"`
+}
+
+// ClearCacheRequest empties the cache, or drops the entries whose keys match a prefix.
+type ClearCacheRequest struct {
+	Match string `json:"match,omitempty"`
+}
+
+// ClearCacheResponse is the reply of Admin.ClearCache.
+type ClearCacheResponse struct {
+	Match string `json:"match,omitempty"`
+}
+
+// RunJobRequest runs a job now.
+type RunJobRequest struct {
+	Name string `json:"name"`
+}
+
+// RunJobResponse is how long the job ran.
+type RunJobResponse struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// JobsRequest lists the jobs.
+type JobsRequest struct{}
+
+// JobsResponse are the names of the jobs, sorted.
+type JobsResponse struct {
+	Names []string `json:"names"`
+}
+
+// ReloadRequest reads the configuration again.
+type ReloadRequest struct{}
+
+// ReloadResponse lists what changed.
+type ReloadResponse struct {
+	Changed []string `json:"changed"`
+}
+
+// Register adds the Admin methods to a registry.
+func Register(r *rpcapi.Registry, s *Service) {
+	rpcapi.Handle(r, "Admin.Maintenance", s.setMaintenance,
+		rpcapi.Describe("Maintenance takes the application down for maintenance, or brings it back up."))
+	rpcapi.Handle(r, "Admin.ClearCache", s.clearCache,
+		rpcapi.Describe("ClearCache empties the cache, or drops the entries whose keys match a prefix."))
+	rpcapi.Handle(r, "Admin.RunJob", s.runJob,
+		rpcapi.Describe("RunJob runs a job now and waits for it to finish."))
+	rpcapi.Handle(r, "Admin.Jobs", s.jobs,
+		rpcapi.Describe("Jobs lists the jobs RunJob runs."))
+	rpcapi.Handle(r, "Admin.Reload", s.reload,
+		rpcapi.Describe("Reload reads the configuration again and lists the settings that changed."))
+	rpcapi.Handle(r, "Admin.Status", s.status,
+		rpcapi.Describe("Status reports the uptime, goroutines, providers, routes and scheduled jobs of the application."))
+	rpcapi.Handle(r, "Admin.SetLogLevel", s.setLogLevel,
+		rpcapi.Describe("SetLogLevel changes the level of the application's log until it restarts."))
+	rpcapi.Handle(r, "Admin.Shutdown", s.shutdown,
+		rpcapi.Describe("Shutdown stops the application gracefully, as SIGTERM does."))
+}
+
+func (s *Service) setMaintenance(ctx context.Context, req *MaintenanceRequest) (*MaintenanceResponse, error) {
+	if s.Maintenance == nil {
+		return nil, rpcapi.Errorf(rpcapi.CodeUnavailable, "maintenance mode is not set up")
+	}
+
+	if !req.Down {
+		if err := s.Maintenance.Up(); err != nil {
+			return nil, err
+		}
+		return &MaintenanceResponse{Status: "up"}, nil
+	}
+
+	if err := s.Maintenance.Down(maintenance.State{Secret: req.Secret, Retry: req.Retry}); err != nil {
+		return nil, err
+	}
+	resp := &MaintenanceResponse{Status: "down"}
+	if state, down := s.Maintenance.State(); down && state != nil {
+		resp.Since = state.Since
+	}
+	return resp, nil
+}
+
+func (s *Service) clearCache(ctx context.Context, req *ClearCacheRequest) (*ClearCacheResponse, error) {
+	if s.Cache == nil {
+		return nil, rpcapi.Errorf(rpcapi.CodeUnavailable, "no cache is configured")
+	}
+
+	var err error
+	if req.Match == "" {
+		err = s.Cache.Empty()
+	} else {
+		err = s.Cache.EmptyByMatch(req.Match)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ClearCacheResponse{Match: req.Match}, nil
+}
+
+func (s *Service) runJob(ctx context.Context, req *RunJobRequest) (*RunJobResponse, error) {
+	job, ok := s.Jobs[req.Name]
+	if !ok {
+		return nil, rpcapi.Errorf(rpcapi.CodeNotFound, "no job %q", req.Name)
+	}
+
+	start := time.Now()
+	if err := job(ctx); err != nil {
+		return nil, err
+	}
+	return &RunJobResponse{Name: req.Name, Duration: time.Since(start)}, nil
+}
+
+func (s *Service) jobs(ctx context.Context, req *JobsRequest) (*JobsResponse, error) {
+	names := make([]string, 0, len(s.Jobs))
+	for name := range s.Jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &JobsResponse{Names: names}, nil
+}
+
+func (s *Service) reload(ctx context.Context, req *ReloadRequest) (*ReloadResponse, error) {
+	if s.Reload == nil {
+		return nil, rpcapi.Errorf(rpcapi.CodeUnavailable, "reloading is not set up")
+	}
+
+	changed, err := s.Reload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ReloadResponse{Changed: changed}, nil
+}
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"myapp/maintenance"
+	"myapp/rpcapi"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAdmin(t *testing.T) {
+	ran := 0
+	mode := maintenance.New(t.TempDir())
+	r := rpcapi.NewRegistry(nil)
+	Register(r, &Service{
+		Maintenance: mode,
+		Jobs:        map[string]Job{"count": func(ctx context.Context) error { ran++; return nil }},
+	})
+	ctx := context.Background()
+
+	call := func(method string, req interface{}) ([]byte, error) {
+		body, _ := json.Marshal(req)
+		return r.Call(ctx, method, body)
+	}
+
+	if _, err := call("Admin.Maintenance", MaintenanceRequest{Down: true, Retry: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if _, down := mode.State(); !down {
+		t.Fatal("Expected the application to be down")
+	}
+	if _, err := call("Admin.Maintenance", MaintenanceRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, down := mode.State(); down {
+		t.Fatal("Expected the application to be up")
+	}
+
+	if _, err := call("Admin.RunJob", RunJobRequest{Name: "count"}); err != nil || ran != 1 {
+		t.Fatalf("Expected the job to run once, got %d runs, %v", ran, err)
+	}
+
+	var rpcErr *rpcapi.Error
+	if _, err := call("Admin.RunJob", RunJobRequest{Name: "nope"}); !errors.As(err, &rpcErr) || rpcErr.Code != rpcapi.CodeNotFound {
+		t.Errorf("Expected an unknown job to be not found, got %v", err)
+	}
+	if _, err := call("Admin.ClearCache", ClearCacheRequest{}); !errors.As(err, &rpcErr) || rpcErr.Code != rpcapi.CodeUnavailable {
+		t.Errorf("Expected clearing without a cache to be unavailable, got %v", err)
+	}
+}
+
+func TestAdmin_Status(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	shutdowns := 0
+	r := rpcapi.NewRegistry(nil)
+	Register(r, &Service{
+		Started:   time.Now().Add(-time.Minute),
+		Routes:    func() []Route { return []Route{{Method: "GET", Pattern: "/"}} },
+		Scheduled: func() []ScheduledJob { return []ScheduledJob{{Name: "mail.send", Schedule: "@every 5m"}} },
+		Log:       log,
+		Shutdown:  func() { shutdowns++ },
+	})
+	ctx := context.Background()
+
+	body, err := r.Call(ctx, "Admin.Status", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status StatusResponse
+	json.Unmarshal(body, &status)
+	if status.Uptime < time.Minute || status.Goroutines == 0 || status.LogLevel != "info" || status.Maintenance != "up" {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if len(status.Routes) != 1 || len(status.Scheduled) != 1 || status.Scheduled[0].Name != "mail.send" {
+		t.Errorf("Expected the routes and scheduled jobs, got %+v", status)
+	}
+
+	if _, err := r.Call(ctx, "Admin.SetLogLevel", []byte(`{"level":"debug"}`)); err != nil || log.GetLevel() != logrus.DebugLevel {
+		t.Errorf("Expected the log level to be debug, got %s, %v", log.GetLevel(), err)
+	}
+	var rpcErr *rpcapi.Error
+	if _, err := r.Call(ctx, "Admin.SetLogLevel", []byte(`{"level":"loud"}`)); !errors.As(err, &rpcErr) || rpcErr.Code != rpcapi.CodeInvalid {
+		t.Errorf("Expected an unknown level to be invalid, got %v", err)
+	}
+
+	if _, err := r.Call(ctx, "Admin.Shutdown", []byte("{}")); err != nil || shutdowns != 1 {
+		t.Errorf("Expected a shutdown to be requested, got %d, %v", shutdowns, err)
+	}
+}
@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"myapp/rpcapi"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Provider is a service provider of the framework, as it fared at boot.
+type Provider struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// State is how far it got, e.g., "booted"; see the boot package.
+	State string `json:"state,omitempty"`
+	// Boot is how long it took to register and boot.
+	Boot  time.Duration `json:"boot,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// Route is a route of the HTTP server.
+type Route struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+}
+
+// ScheduledJob is an entry of the scheduler. Name and Schedule are empty for the entries
+// the framework schedules itself.
+type ScheduledJob struct {
+	Name     string    `json:"name,omitempty"`
+	Schedule string    `json:"schedule,omitempty"`
+	Next     time.Time `json:"next"`
+	Prev     time.Time `json:"prev,omitempty"`
+}
+
+// StatusRequest asks for the status of the application.
+type StatusRequest struct{}
+
+// StatusResponse is the status of the application.
+type StatusResponse struct {
+	StartedAt   time.Time      `json:"started_at"`
+	Uptime      time.Duration  `json:"uptime"`
+	Goroutines  int            `json:"goroutines"`
+	Maintenance string         `json:"maintenance"`
+	LogLevel    string         `json:"log_level,omitempty"`
+	Providers   []Provider     `json:"providers"`
+	Routes      []Route        `json:"routes"`
+	Scheduled   []ScheduledJob `json:"scheduled"`
+}
+
+// SetLogLevelRequest sets the level of the log, e.g., "debug".
+type SetLogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// SetLogLevelResponse is the level before and after the call.
+type SetLogLevelResponse struct {
+	Previous string `json:"previous"`
+	Level    string `json:"level"`
+}
+
+// ShutdownRequest stops the application.
+type ShutdownRequest struct{}
+
+// ShutdownResponse is the reply of Admin.Shutdown, sent before the application stops.
+type ShutdownResponse struct{}
+
+func (s *Service) status(ctx context.Context, req *StatusRequest) (*StatusResponse, error) {
+	resp := &StatusResponse{
+		StartedAt:   s.Started,
+		Goroutines:  runtime.NumGoroutine(),
+		Maintenance: "up",
+	}
+	if !s.Started.IsZero() {
+		resp.Uptime = time.Since(s.Started)
+	}
+	if s.Maintenance != nil {
+		if _, down := s.Maintenance.State(); down {
+			resp.Maintenance = "down"
+		}
+	}
+	if s.Log != nil {
+		resp.LogLevel = s.Log.GetLevel().String()
+	}
+	if s.Providers != nil {
+		resp.Providers = s.Providers()
+	}
+	if s.Routes != nil {
+		resp.Routes = s.Routes()
+	}
+	if s.Scheduled != nil {
+		resp.Scheduled = s.Scheduled()
+	}
+	return resp, nil
+}
+
+func (s *Service) setLogLevel(ctx context.Context, req *SetLogLevelRequest) (*SetLogLevelResponse, error) {
+	if s.Log == nil {
+		return nil, rpcapi.Errorf(rpcapi.CodeUnavailable, "no log is configured")
+	}
+
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		return nil, rpcapi.Errorf(rpcapi.CodeInvalid, "%s", err)
+	}
+	previous := s.Log.GetLevel()
+	s.Log.SetLevel(level)
+	s.Log.WithFields(logrus.Fields{"previous": previous.String(), "level": level.String()}).Warn("log level changed")
+	return &SetLogLevelResponse{Previous: previous.String(), Level: level.String()}, nil
+}
+
+func (s *Service) shutdown(ctx context.Context, req *ShutdownRequest) (*ShutdownResponse, error) {
+	if s.Shutdown == nil {
+		return nil, rpcapi.Errorf(rpcapi.CodeUnavailable, "shutdown is not set up")
+	}
+	s.Shutdown()
+	return &ShutdownResponse{}, nil
+}
@@ -0,0 +1,188 @@
+package rpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cidekar/adele-framework/rpcserver"
+)
+
+// Client calls the methods of a registry served by another process.
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Credentials authenticate a client to a Server.
+type Credentials struct {
+	// Token is a shared token of the server.
+	Token string
+	// TLS, when set, connects over TLS; its Certificates hold the client certificate of
+	// mutual TLS.
+	TLS *tls.Config
+}
+
+// CredentialsFromEnv returns the credentials configured in the environment.
+//
+// Configuration via environment variables:
+//
+//	RPC_CLIENT_TOKEN: A shared token of the server
+//	RPC_CLIENT_CERT: The client certificate, in PEM; with RPC_CLIENT_KEY, for mutual TLS
+//	RPC_CLIENT_KEY: The private key of the client certificate, in PEM
+//	RPC_SERVER_CA: The CA of the server's certificate, in PEM; set it, or the client
+//	               certificate, to connect over TLS
+func CredentialsFromEnv() (Credentials, error) {
+	creds := Credentials{Token: os.Getenv("RPC_CLIENT_TOKEN")}
+
+	cert, key, ca := os.Getenv("RPC_CLIENT_CERT"), os.Getenv("RPC_CLIENT_KEY"), os.Getenv("RPC_SERVER_CA")
+	if cert == "" && key == "" && ca == "" {
+		return creds, nil
+	}
+
+	creds.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	if cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return creds, fmt.Errorf("rpcapi: %w", err)
+		}
+		creds.TLS.Certificates = []tls.Certificate{pair}
+	}
+	if ca != "" {
+		pool, err := loadPool(ca)
+		if err != nil {
+			return creds, err
+		}
+		creds.TLS.RootCAs = pool
+	}
+	return creds, nil
+}
+
+// ErrUnauthenticated is returned when a server refuses the credentials of a client.
+var ErrUnauthenticated = errors.New("rpcapi: the server refused the credentials")
+
+// Dial connects to an RPC server with the credentials of the environment. An empty address
+// is the server of this application, as RPC_SERVER_ADDR and RPC_SERVER_PORT configure it.
+func Dial(addr string) (*Client, error) {
+	creds, err := CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return DialWith(addr, creds)
+}
+
+// DialWith connects to an RPC server with credentials.
+func DialWith(addr string, creds Credentials) (*Client, error) {
+	if addr == "" {
+		addr = getenv("RPC_SERVER_ADDR", rpcserver.ServerAddrDefault) + ":" + getenv("RPC_SERVER_PORT", rpcserver.ServerPortDefault)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if creds.TLS != nil {
+		config := creds.TLS.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, config)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if creds.Token != "" {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		_, err := io.WriteString(conn, "TOKEN "+creds.Token+"\n")
+		var reply string
+		if err == nil {
+			reply, err = readLine(conn, 16)
+		}
+		if err != nil || reply != "OK" {
+			conn.Close()
+			return nil, ErrUnauthenticated
+		}
+		conn.SetDeadline(time.Time{})
+	}
+
+	return &Client{rpc: rpc.NewClient(conn)}, nil
+}
+
+// NewClient returns a client calling over a connected net/rpc client.
+func NewClient(client *rpc.Client) *Client {
+	return &Client{rpc: client}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// Call calls a method, decoding its response into resp. The deadline of the context is
+// sent along, and the call returns once the context is done even if the server has not
+// answered. A failure of the method is an *Error.
+func (c *Client) Call(ctx context.Context, method string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	call := &Request{Method: method, Body: body}
+	if deadline, ok := ctx.Deadline(); ok {
+		if call.Timeout = time.Until(deadline); call.Timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+
+	var reply Response
+	if err := c.do(ctx, ServiceName+".Call", call, &reply); err != nil {
+		return err
+	}
+	if reply.Error != nil {
+		return reply.Error
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Body, resp)
+}
+
+// Methods lists the methods the server serves.
+func (c *Client) Methods(ctx context.Context) ([]MethodInfo, error) {
+	var methods []MethodInfo
+	err := c.do(ctx, ServiceName+".Methods", &MethodsArgs{}, &methods)
+	return methods, err
+}
+
+func (c *Client) do(ctx context.Context, method string, args, reply interface{}) error {
+	call := c.rpc.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		// a call the ACL denies fails before it reaches the method
+		var serverErr rpc.ServerError
+		if errors.As(call.Error, &serverErr) {
+			if message, ok := strings.CutPrefix(string(serverErr), CodePermissionDenied+": "); ok {
+				return &Error{Code: CodePermissionDenied, Message: message}
+			}
+		}
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func getenv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
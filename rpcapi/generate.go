@@ -0,0 +1,156 @@
+package rpcapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var clientTemplate = template.Must(template.New("client").Parse(`// Code generated by "./adeleApp rpc client"; DO NOT EDIT.
+
+// Package {{ .Package }} calls the RPC methods of the application. Other services import it
+// to call the application with the same request and response types it serves.
+package {{ .Package }}
+
+import (
+	"context"
+{{ range .Imports }}
+	{{ if ne .Alias .Base }}{{ .Alias }} {{ end }}"{{ .Path }}"{{ end }}
+)
+
+// Client calls the RPC methods of the application.
+type Client struct {
+	*rpcapi.Client
+{{ range .Services }}
+	{{ .Name }} *{{ .Name }}Client{{ end }}
+}
+
+// Dial connects to the RPC server of the application; see rpcapi.Dial.
+func Dial(addr string) (*Client, error) {
+	c, err := rpcapi.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return New(c), nil
+}
+
+// New returns a client calling through c.
+func New(c *rpcapi.Client) *Client {
+	return &Client{
+		Client: c,{{ range .Services }}
+		{{ .Name }}: &{{ .Name }}Client{c: c},{{ end }}
+	}
+}
+{{ range .Services }}{{ $service := . }}
+// {{ .Name }}Client calls the methods of the {{ .Name }} service.
+type {{ .Name }}Client struct {
+	c *rpcapi.Client
+}
+{{ range .Methods }}
+{{ .Doc }}
+func (s *{{ $service.Name }}Client) {{ .Name }}(ctx context.Context, req *{{ .Request }}) (*{{ .Response }}, error) {
+	resp := new({{ .Response }})
+	if err := s.c.Call(ctx, "{{ $service.Name }}.{{ .Name }}", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+{{ end }}{{ end }}`))
+
+type genImport struct {
+	Alias, Base, Path string
+}
+
+type genMethod struct {
+	Name, Doc, Request, Response string
+}
+
+type genService struct {
+	Name    string
+	Methods []genMethod
+}
+
+// Generate returns the source of a client package for the methods of a registry: a Client
+// with a field per service, whose methods call the registry's methods with their request
+// and response types. The types must be named types of packages other programs can
+// import, not of package main.
+func Generate(r *Registry, pkg string) ([]byte, error) {
+	imports := map[string]string{}
+	aliases := map[string]bool{"context": true, "rpcapi": true, pkg: true}
+	imports[reflect.TypeOf(Registry{}).PkgPath()] = "rpcapi"
+	alias := func(pkgPath string) string {
+		if a, ok := imports[pkgPath]; ok {
+			return a
+		}
+		base := strings.NewReplacer("-", "", ".", "").Replace(path.Base(pkgPath))
+		a := base
+		for i := 2; aliases[a]; i++ {
+			a = fmt.Sprintf("%s%d", base, i)
+		}
+		imports[pkgPath], aliases[a] = a, true
+		return a
+	}
+
+	typeName := func(m *Method, t reflect.Type) (string, error) {
+		if t.Name() == "" || t.PkgPath() == "" || t.PkgPath() == "main" || !isExported(t.Name()) {
+			return "", fmt.Errorf("rpcapi: %s: %s is not an exported type of an importable package", m.Name, t)
+		}
+		return alias(t.PkgPath()) + "." + t.Name(), nil
+	}
+
+	var services []*genService
+	for _, m := range r.Methods() {
+		service, name, _ := strings.Cut(m.Name, ".")
+		if len(services) == 0 || services[len(services)-1].Name != service {
+			services = append(services, &genService{Name: service})
+		}
+
+		req, err := typeName(m, m.Request)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := typeName(m, m.Response)
+		if err != nil {
+			return nil, err
+		}
+
+		doc := m.Description
+		if !strings.HasPrefix(doc, name+" ") {
+			doc = strings.TrimSpace(fmt.Sprintf("%s calls %s. %s", name, m.Name, doc))
+		}
+		services[len(services)-1].Methods = append(services[len(services)-1].Methods, genMethod{
+			Name: name, Doc: "// " + strings.ReplaceAll(doc, "\n", "\n// "), Request: req, Response: resp,
+		})
+	}
+
+	var sorted []genImport
+	for p, a := range imports {
+		sorted = append(sorted, genImport{Alias: a, Base: path.Base(p), Path: p})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	var buf bytes.Buffer
+	err := clientTemplate.Execute(&buf, struct {
+		Package  string
+		Imports  []genImport
+		Services []*genService
+	}{pkg, sorted, services})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("rpcapi: formatting the client: %w", err)
+	}
+	return src, nil
+}
+
+func isExported(name string) bool {
+	return name != "" && strings.ToUpper(name[:1]) == name[:1]
+}
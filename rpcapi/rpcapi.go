@@ -0,0 +1,213 @@
+// Package rpcapi serves typed RPC methods on the framework's RPC server. A method is a
+// function of a context and a request struct returning a response struct; it is registered
+// under a name such as "Admin.ClearCache" with Handle. The methods of a Registry are
+// published on a net/rpc server as the App service, which decodes requests, applies the
+// caller's deadline to the context and encodes responses, so callers get typed errors and
+// cancellation that net/rpc alone does not carry. Other services call the methods through a
+// Client, or through the typed client generated by Generate.
+package rpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/rpc"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ServiceName is the name of the registry's service on the net/rpc server.
+const ServiceName = "App"
+
+// Method is a registered method.
+type Method struct {
+	// Name is the name the method is called by, e.g., "Admin.ClearCache".
+	Name        string
+	Description string
+	Request     reflect.Type
+	Response    reflect.Type
+	// Timeout bounds how long a call runs, whatever the caller's deadline; zero is the
+	// registry's Timeout.
+	Timeout time.Duration
+
+	call func(ctx context.Context, body []byte) (interface{}, error)
+}
+
+// Option configures a method.
+type Option func(*Method)
+
+// Describe sets the description of a method, shown in listings and in the generated client.
+func Describe(description string) Option {
+	return func(m *Method) { m.Description = description }
+}
+
+// Timeout sets how long a call of a method may run.
+func Timeout(d time.Duration) Option {
+	return func(m *Method) { m.Timeout = d }
+}
+
+// Registry holds the methods served over RPC.
+type Registry struct {
+	// Timeout bounds how long a call runs when neither the caller nor the method sets a
+	// shorter deadline.
+	Timeout time.Duration
+	Log     logrus.FieldLogger
+
+	mu      sync.RWMutex
+	methods map[string]*Method
+}
+
+// NewRegistry returns a registry without methods.
+//
+// Configuration via environment variables:
+//
+//	RPC_TIMEOUT: How long a call may run at most (default: "30s")
+func NewRegistry(log logrus.FieldLogger) *Registry {
+	timeout := 30 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("RPC_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	if log == nil {
+		log = logrus.StandardLogger()
+	}
+	return &Registry{Timeout: timeout, Log: log, methods: make(map[string]*Method)}
+}
+
+var methodName = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*\.[A-Z][A-Za-z0-9]*$`)
+
+// Handle registers a method. The name is a service and a method, both exported Go
+// identifiers, e.g., "Admin.ClearCache". Request and response are structs; they travel as
+// JSON, so they are declared with the fields and tags of any JSON document. Methods are
+// registered at boot, so a malformed or duplicate name panics.
+//
+// Example:
+//
+//	rpcapi.Handle(a.RPC, "Users.Get", func(ctx context.Context, req *userrpc.GetRequest) (*userrpc.GetResponse, error) {
+//		...
+//	}, rpcapi.Describe("Get returns a user by ID."))
+func Handle[Req, Resp any](r *Registry, name string, h func(ctx context.Context, req *Req) (*Resp, error), opts ...Option) {
+	if !methodName.MatchString(name) {
+		panic("rpcapi: invalid method name " + name)
+	}
+
+	m := &Method{
+		Name:     name,
+		Request:  reflect.TypeOf((*Req)(nil)).Elem(),
+		Response: reflect.TypeOf((*Resp)(nil)).Elem(),
+		call: func(ctx context.Context, body []byte) (interface{}, error) {
+			req := new(Req)
+			if len(body) > 0 {
+				if err := json.Unmarshal(body, req); err != nil {
+					return nil, Errorf(CodeInvalid, "invalid request: %v", err)
+				}
+			}
+			return h(ctx, req)
+		},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.methods[name]; ok {
+		panic("rpcapi: method " + name + " is registered twice")
+	}
+	r.methods[name] = m
+}
+
+// Methods returns the registered methods, sorted by name.
+func (r *Registry) Methods() []*Method {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	methods := make([]*Method, 0, len(r.methods))
+	for _, m := range r.methods {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods
+}
+
+// Call calls a method with the JSON of its request and returns the JSON of its response.
+// The call runs until the context is done or the method's timeout passes, whichever comes
+// first; a method still running then keeps running, with its context canceled, and its
+// response is dropped.
+func (r *Registry) Call(ctx context.Context, name string, body []byte) ([]byte, error) {
+	r.mu.RLock()
+	m, ok := r.methods[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, Errorf(CodeNotFound, "no method %s", name)
+	}
+
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = r.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		resp interface{}
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				r.Log.WithField("rpc_method", name).Errorf("rpcapi: panic: %v", p)
+				done <- result{err: Errorf(CodeInternal, "the method failed")}
+			}
+		}()
+		resp, err := m.call(ctx, body)
+		done <- result{resp, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return json.Marshal(res.resp)
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, Errorf(CodeDeadline, "%s did not finish before its deadline", name)
+		}
+		return nil, Errorf(CodeCanceled, "%s was canceled", name)
+	}
+}
+
+// Publish registers the registry's service on a net/rpc server; the framework's RPC server
+// serves rpc.DefaultServer.
+func (r *Registry) Publish(server *rpc.Server) error {
+	if err := server.RegisterName(ServiceName, &Service{Registry: r}); err != nil {
+		return fmt.Errorf("rpcapi: %w", err)
+	}
+	return nil
+}
+
+// MethodInfo describes a method to callers.
+type MethodInfo struct {
+	Name        string
+	Description string
+	// Request and Response are the Go types of the structs, e.g., "admin.ClearCacheRequest".
+	Request  string
+	Response string
+	Timeout  time.Duration
+}
+
+// Info describes the method to callers.
+func (m *Method) Info() MethodInfo {
+	return MethodInfo{Name: m.Name, Description: m.Description, Request: m.Request.String(),
+		Response: m.Response.String(), Timeout: m.Timeout}
+}
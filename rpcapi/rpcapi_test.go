@@ -0,0 +1,161 @@
+package rpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type EchoRequest struct {
+	Text  string
+	Sleep time.Duration
+}
+
+type EchoResponse struct {
+	Text     string
+	Deadline bool
+}
+
+func testRegistry() *Registry {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	r := NewRegistry(log)
+
+	Handle(r, "Echo.Say", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+		if req.Text == "" {
+			return nil, Errorf(CodeInvalid, "nothing to say")
+		}
+		select {
+		case <-time.After(req.Sleep):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		_, deadline := ctx.Deadline()
+		return &EchoResponse{Text: req.Text, Deadline: deadline}, nil
+	}, Describe("Say says it back."))
+
+	Handle(r, "Echo.Panic", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+		panic("boom")
+	})
+	return r
+}
+
+// A client of a registry served over a connection, as the framework's RPC server serves it.
+func dialTest(t *testing.T, r *Registry) *Client {
+	server := rpc.NewServer()
+	if err := r.Publish(server); err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	c := NewClient(rpc.NewClient(clientConn))
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_Call(t *testing.T) {
+	c := dialTest(t, testRegistry())
+	ctx := context.Background()
+
+	var resp EchoResponse
+	if err := c.Call(ctx, "Echo.Say", &EchoRequest{Text: "hello"}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "hello" || !resp.Deadline {
+		t.Errorf("Expected hello with the registry's deadline, got %+v", resp)
+	}
+
+	for _, tt := range []struct {
+		method string
+		req    EchoRequest
+		code   string
+	}{
+		{"Echo.Say", EchoRequest{}, CodeInvalid},
+		{"Echo.Nope", EchoRequest{Text: "hello"}, CodeNotFound},
+		{"Echo.Panic", EchoRequest{Text: "hello"}, CodeInternal},
+	} {
+		var rpcErr *Error
+		err := c.Call(ctx, tt.method, &tt.req, &resp)
+		if !errors.As(err, &rpcErr) || rpcErr.Code != tt.code {
+			t.Errorf("%s(%+v): expected a %s error, got %v", tt.method, tt.req, tt.code, err)
+		}
+	}
+}
+
+func TestClient_Deadline(t *testing.T) {
+	c := dialTest(t, testRegistry())
+
+	// the caller's deadline reaches the server
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var rpcErr *Error
+	err := c.Call(ctx, "Echo.Say", &EchoRequest{Text: "hello", Sleep: time.Second}, &EchoResponse{})
+	if !errors.Is(err, context.DeadlineExceeded) && !(errors.As(err, &rpcErr) && rpcErr.Code == CodeDeadline) {
+		t.Errorf("Expected the call to miss its deadline, got %v", err)
+	}
+
+	// and the registry bounds calls without one
+	r := testRegistry()
+	r.Timeout = 20 * time.Millisecond
+	err = dialTest(t, r).Call(context.Background(), "Echo.Say", &EchoRequest{Text: "hello", Sleep: time.Second}, &EchoResponse{})
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeDeadline {
+		t.Errorf("Expected a deadline_exceeded error, got %v", err)
+	}
+}
+
+func TestClient_Methods(t *testing.T) {
+	methods, err := dialTest(t, testRegistry()).Methods(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 2 || methods[1].Name != "Echo.Say" || methods[1].Request != "rpcapi.EchoRequest" || methods[1].Description != "Say says it back." {
+		t.Errorf("Unexpected methods %+v", methods)
+	}
+}
+
+func TestHandle_PanicsOnBadNames(t *testing.T) {
+	for _, name := range []string{"Say", "echo.Say", "Echo.Say"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected registering %q to panic", name)
+				}
+			}()
+			r := testRegistry()
+			Handle(r, name, func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) { return nil, nil })
+		}()
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate(testRegistry(), "echoclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package echoclient",
+		`"myapp/rpcapi"`,
+		"Echo *EchoClient",
+		"// Say says it back.\nfunc (s *EchoClient) Say(ctx context.Context, req *rpcapi.EchoRequest) (*rpcapi.EchoResponse, error) {",
+		`s.c.Call(ctx, "Echo.Say", req, resp)`,
+		"// Panic calls Echo.Panic.\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Expected the client to contain %q:\n%s", want, src)
+		}
+	}
+
+	r := NewRegistry(nil)
+	Handle(r, "Bad.Map", func(ctx context.Context, req *map[string]string) (*EchoResponse, error) { return nil, nil })
+	if _, err := Generate(r, "badclient"); err == nil {
+		t.Error("Expected an unnamed request type to be refused")
+	}
+}
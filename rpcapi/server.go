@@ -0,0 +1,466 @@
+package rpcapi
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cidekar/adele-framework/rpcserver"
+	"github.com/sirupsen/logrus"
+)
+
+// Server serves a net/rpc server to authenticated callers, in place of the framework's RPC
+// server, which serves anyone who can reach its port. Callers authenticate with a client
+// certificate (mutual TLS) or a shared token; the ACL says which methods each of them may
+// call, and every call is written to the audit log.
+//
+// The token handshake is a line the client sends before the net/rpc stream, "TOKEN
+// <token>\n", which the server answers with "OK\n" or closes the connection. A server
+// without authentication only listens on a loopback address.
+type Server struct {
+	// Addr is the address to listen on; see ServerFromEnv.
+	Addr string
+	// RPC is the net/rpc server served; nil is rpc.DefaultServer.
+	RPC *rpc.Server
+	// TLS, when set, serves TLS. When its ClientCAs are set, a client certificate signed by
+	// one of them authenticates the caller as the certificate's common name.
+	TLS *tls.Config
+	// Tokens are the shared tokens of callers, by principal.
+	Tokens map[string]string
+	ACL    ACL
+	// Audit receives an entry for every call.
+	Audit logrus.FieldLogger
+	// HandshakeTimeout bounds the TLS and token handshakes of a connection.
+	HandshakeTimeout time.Duration
+	// DrainTimeout bounds how long Close waits for the calls in flight to be answered;
+	// zero is 10 seconds.
+	DrainTimeout time.Duration
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	// pending counts the calls read and not yet answered.
+	pending int
+}
+
+// ServerFromEnv returns the server configured in the environment, with the ACL of
+// config/rpc.yml under root.
+//
+// Configuration via environment variables:
+//
+//	RPC_SERVER_ADDR: The address to listen on (default: "127.0.0.1")
+//	RPC_SERVER_PORT: The port to listen on (default: "4040")
+//	RPC_TLS_CERT: The certificate of the server, in PEM; with RPC_TLS_KEY, serves TLS
+//	RPC_TLS_KEY: The private key of the certificate, in PEM
+//	RPC_TLS_CLIENT_CA: The CA of client certificates, in PEM; callers presenting a
+//	                   certificate it signed are authenticated as its common name
+//	RPC_TOKENS: Shared tokens, as principal:token pairs separated by commas
+func ServerFromEnv(root string, audit logrus.FieldLogger) (*Server, error) {
+	s := &Server{
+		Addr:             getenv("RPC_SERVER_ADDR", rpcserver.ServerAddrDefault) + ":" + getenv("RPC_SERVER_PORT", rpcserver.ServerPortDefault),
+		Audit:            audit,
+		HandshakeTimeout: 10 * time.Second,
+	}
+
+	if cert, key := os.Getenv("RPC_TLS_CERT"), os.Getenv("RPC_TLS_KEY"); cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("rpcapi: %w", err)
+		}
+		s.TLS = &tls.Config{Certificates: []tls.Certificate{pair}, MinVersion: tls.VersionTLS12}
+	}
+
+	if file := os.Getenv("RPC_TLS_CLIENT_CA"); file != "" {
+		if s.TLS == nil {
+			return nil, errors.New("rpcapi: RPC_TLS_CLIENT_CA needs RPC_TLS_CERT and RPC_TLS_KEY")
+		}
+		pool, err := loadPool(file)
+		if err != nil {
+			return nil, err
+		}
+		s.TLS.ClientCAs = pool
+	}
+
+	if list := os.Getenv("RPC_TOKENS"); list != "" {
+		s.Tokens = make(map[string]string)
+		for _, pair := range strings.Split(list, ",") {
+			principal, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || principal == "" || token == "" {
+				return nil, errors.New("rpcapi: RPC_TOKENS must be principal:token pairs separated by commas")
+			}
+			s.Tokens[principal] = token
+		}
+	}
+
+	acl, err := LoadACL(filepath.Join(root, "config", "rpc.yml"))
+	if err != nil {
+		return nil, err
+	}
+	s.ACL = acl
+
+	return s, nil
+}
+
+func loadPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("rpcapi: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("rpcapi: %s holds no PEM certificate", file)
+	}
+	return pool, nil
+}
+
+// Report whether callers must authenticate.
+func (s *Server) authenticates() bool {
+	return len(s.Tokens) > 0 || (s.TLS != nil && s.TLS.ClientCAs != nil)
+}
+
+// Listen starts serving, and returns once the server is listening. A server without
+// authentication refuses to listen on an address other than loopback.
+func (s *Server) Listen() error {
+	if !s.authenticates() {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("rpcapi: %w", err)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("rpcapi: refusing to serve RPC on %s without authentication; set RPC_TOKENS or RPC_TLS_CLIENT_CA", s.Addr)
+		}
+	}
+
+	if s.TLS != nil && s.TLS.ClientCAs != nil {
+		s.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+		if len(s.Tokens) > 0 {
+			s.TLS.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	if s.Audit == nil {
+		s.Audit = logrus.StandardLogger()
+	}
+
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("rpcapi: %w", err)
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err != nil {
+				continue
+			}
+			go s.serve(conn)
+		}
+	}()
+	return nil
+}
+
+// ListenAddr returns the address the server listens on, e.g., the port it was given for
+// port 0.
+func (s *Server) ListenAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close stops listening, waits for the calls in flight to be answered, e.g., the
+// Admin.Shutdown call that stopped the application, then closes the connections of callers.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.listener == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	err := s.listener.Close()
+	s.mu.Unlock()
+
+	timeout := s.DrainTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline) && s.inFlight(0) > 0; {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.listener, s.conns = nil, nil
+	return err
+}
+
+// Add to the count of calls in flight, returning it.
+func (s *Server) inFlight(delta int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending += delta
+	return s.pending
+}
+
+func (s *Server) track(conn net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns == nil {
+		return false
+	}
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+	return true
+}
+
+// Serve a connection: authenticate the caller, then serve calls until it hangs up.
+func (s *Server) serve(conn net.Conn) {
+	if !s.track(conn, true) {
+		conn.Close()
+		return
+	}
+	defer s.track(conn, false)
+	defer conn.Close()
+
+	remote := conn.RemoteAddr().String()
+	principal, rw, err := s.authenticate(conn)
+	if err != nil {
+		s.Audit.WithFields(logrus.Fields{"audit": "rpc", "remote_addr": remote}).WithError(err).Warn("rpc: authentication failed")
+		return
+	}
+
+	server := s.RPC
+	if server == nil {
+		server = rpc.DefaultServer
+	}
+	server.ServeCodec(&auditCodec{
+		ServerCodec: newGobCodec(rw),
+		server:      s,
+		principal:   principal,
+		remote:      remote,
+		calls:       make(map[uint64]call),
+	})
+}
+
+// Authenticate the caller of a connection, returning its principal and the connection to
+// serve calls on.
+func (s *Server) authenticate(conn net.Conn) (string, io.ReadWriteCloser, error) {
+	timeout := s.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	var rw io.ReadWriteCloser = conn
+	if s.TLS != nil {
+		tlsConn := tls.Server(conn, s.TLS)
+		if err := tlsConn.Handshake(); err != nil {
+			return "", nil, err
+		}
+		rw = tlsConn
+
+		if certs := tlsConn.ConnectionState().VerifiedChains; len(certs) > 0 && s.TLS.ClientCAs != nil {
+			return certs[0][0].Subject.CommonName, rw, nil
+		}
+	}
+
+	if len(s.Tokens) == 0 {
+		if s.authenticates() {
+			return "", nil, errors.New("no client certificate")
+		}
+		return PrincipalLocal, rw, nil
+	}
+
+	// the token line is read a byte at a time, so nothing of the net/rpc stream after it
+	// is consumed
+	line, err := readLine(rw, 512)
+	if err != nil {
+		return "", nil, err
+	}
+	token, ok := strings.CutPrefix(line, "TOKEN ")
+	if !ok {
+		return "", nil, errors.New("no token")
+	}
+
+	principal := ""
+	for name, want := range s.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+			principal = name
+		}
+	}
+	if principal == "" {
+		return "", nil, errors.New("unknown token")
+	}
+	if _, err := io.WriteString(rw, "OK\n"); err != nil {
+		return "", nil, err
+	}
+	return principal, rw, nil
+}
+
+func readLine(r io.Reader, max int) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for len(line) < max {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+		line = append(line, b[0])
+	}
+	return "", errors.New("handshake line too long")
+}
+
+type call struct {
+	method string
+	start  time.Time
+}
+
+// auditCodec authorizes and logs the calls of a connection. The method of a call is known
+// once its body is read, as App.Call carries the method of the registry it calls.
+type auditCodec struct {
+	rpc.ServerCodec
+	server    *Server
+	principal string
+	remote    string
+
+	header rpc.Request
+	mu     sync.Mutex
+	calls  map[uint64]call
+}
+
+func (c *auditCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	c.header = *r
+	return err
+}
+
+func (c *auditCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil || body == nil {
+		return err
+	}
+
+	method := c.header.ServiceMethod
+	if req, ok := body.(*Request); ok && method == ServiceName+".Call" {
+		method = req.Method
+	}
+
+	c.mu.Lock()
+	c.calls[c.header.Seq] = call{method: method, start: time.Now()}
+	c.mu.Unlock()
+	c.server.inFlight(1)
+
+	if !c.server.ACL.Allows(c.principal, method) {
+		return Errorf(CodePermissionDenied, "%s may not call %s", c.principal, method)
+	}
+	return nil
+}
+
+func (c *auditCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mu.Lock()
+	call, ok := c.calls[r.Seq]
+	delete(c.calls, r.Seq)
+	c.mu.Unlock()
+	if ok {
+		defer c.server.inFlight(-1)
+	} else {
+		call.method = r.ServiceMethod
+	}
+
+	entry := c.server.Audit.WithFields(logrus.Fields{
+		"audit":       "rpc",
+		"principal":   c.principal,
+		"remote_addr": c.remote,
+		"rpc_method":  call.method,
+	})
+	if ok {
+		entry = entry.WithField("duration_ms", float64(time.Since(call.start).Microseconds())/1000)
+	}
+
+	switch resp, _ := body.(*Response); {
+	case strings.HasPrefix(r.Error, CodePermissionDenied+": "):
+		entry.WithField("outcome", CodePermissionDenied).Warn("rpc call denied")
+	case r.Error != "":
+		entry.WithField("outcome", "error").WithField("error", r.Error).Info("rpc call")
+	case resp != nil && resp.Error != nil:
+		entry.WithField("outcome", resp.Error.Code).WithField("error", resp.Error.Message).Info("rpc call")
+	default:
+		entry.WithField("outcome", "ok").Info("rpc call")
+	}
+
+	return c.ServerCodec.WriteResponse(r, body)
+}
+
+// gobCodec is the codec of net/rpc, which is not exported.
+type gobCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func newGobCodec(rwc io.ReadWriteCloser) *gobCodec {
+	buf := bufio.NewWriter(rwc)
+	return &gobCodec{rwc: rwc, dec: gob.NewDecoder(rwc), enc: gob.NewEncoder(buf), encBuf: buf}
+}
+
+func (c *gobCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
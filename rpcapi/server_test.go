@@ -0,0 +1,266 @@
+package rpcapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// A certificate authority generated for a test.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// Issue a certificate for a server on 127.0.0.1, or for a client named commonName.
+func (ca *testCA) issue(t *testing.T, commonName string, server bool) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// Start a server of the test registry, logging to a test hook.
+func startServer(t *testing.T, s *Server) *logtest.Hook {
+	t.Helper()
+	log, hook := logtest.NewNullLogger()
+	s.Addr, s.Audit, s.HandshakeTimeout = "127.0.0.1:0", log, time.Second
+	s.RPC = rpc.NewServer()
+	if err := testRegistry().Publish(s.RPC); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return hook
+}
+
+func callSay(c *Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.Call(ctx, "Echo.Say", &EchoRequest{Text: "hello"}, &EchoResponse{})
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	s := &Server{
+		TLS: &tls.Config{Certificates: []tls.Certificate{ca.issue(t, "127.0.0.1", true)}, ClientCAs: ca.pool},
+		ACL: ACL{"deploy": {"Echo.Say"}},
+	}
+	hook := startServer(t, s)
+	addr := s.ListenAddr().String()
+
+	c, err := DialWith(addr, Credentials{TLS: &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{ca.issue(t, "deploy", false)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := callSay(c); err != nil {
+		t.Fatalf("Expected deploy to call Echo.Say, got %v", err)
+	}
+
+	var rpcErr *Error
+	err = c.Call(context.Background(), "Echo.Panic", &EchoRequest{}, &EchoResponse{})
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodePermissionDenied {
+		t.Fatalf("Expected deploy to be denied Echo.Panic, got %v", err)
+	}
+
+	entries := hook.AllEntries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(entries))
+	}
+	if e := entries[0]; e.Data["principal"] != "deploy" || e.Data["rpc_method"] != "Echo.Say" || e.Data["outcome"] != "ok" {
+		t.Errorf("Unexpected audit entry %v", e.Data)
+	}
+	if e := entries[1]; e.Data["rpc_method"] != "Echo.Panic" || e.Data["outcome"] != CodePermissionDenied || e.Level != logrus.WarnLevel {
+		t.Errorf("Unexpected audit entry %v", e.Data)
+	}
+
+	// a certificate of another CA is refused
+	other := newTestCA(t)
+	c, err = DialWith(addr, Credentials{TLS: &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{other.issue(t, "deploy", false)}}})
+	if err == nil {
+		err = callSay(c)
+		c.Close()
+	}
+	if err == nil {
+		t.Error("Expected a certificate of another CA to be refused")
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	s := &Server{Tokens: map[string]string{"ops": "s3cret"}, ACL: ACL{"ops": {"Echo.*"}}}
+	hook := startServer(t, s)
+	addr := s.ListenAddr().String()
+
+	c, err := DialWith(addr, Credentials{Token: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := callSay(c); err != nil {
+		t.Fatalf("Expected ops to call Echo.Say, got %v", err)
+	}
+
+	if _, err := DialWith(addr, Credentials{Token: "guess"}); err != ErrUnauthenticated {
+		t.Errorf("Expected a wrong token to be refused, got %v", err)
+	}
+
+	// a caller without a token gets nothing served
+	c, err = DialWith(addr, Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := callSay(c); err == nil {
+		t.Error("Expected a caller without a token to be refused")
+	}
+
+	var refused int
+	for _, e := range hook.AllEntries() {
+		if e.Message == "rpc: authentication failed" {
+			refused++
+		}
+	}
+	if refused != 2 {
+		t.Errorf("Expected 2 refused connections to be logged, got %d", refused)
+	}
+}
+
+func TestServer_UnauthenticatedOnlyOnLoopback(t *testing.T) {
+	s := &Server{Addr: "0.0.0.0:0", ACL: DefaultACL, Audit: logrus.New()}
+	if err := s.Listen(); err == nil {
+		s.Close()
+		t.Fatal("Expected a server without authentication to refuse a public address")
+	}
+
+	s = &Server{ACL: DefaultACL}
+	startServer(t, s)
+	c, err := DialWith(s.ListenAddr().String(), Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := callSay(c); err != nil {
+		t.Errorf("Expected a local caller to call Echo.Say, got %v", err)
+	}
+}
+
+func TestACL(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rpc.yml")
+	os.WriteFile(file, []byte("ACL:\n  deploy:\n    - Admin.*\n    - Maintenance.Down\n"), 0o644)
+	acl, err := LoadACL(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		principal, method string
+		allowed           bool
+	}{
+		{"deploy", "Admin.Reload", true},
+		{"deploy", "Maintenance.Down", true},
+		{"deploy", "Maintenance.Up", false},
+		{"deploy", "Administration.Reload", false},
+		{"other", "Admin.Reload", false},
+		{"other", "App.Methods", true},
+	} {
+		if got := acl.Allows(tt.principal, tt.method); got != tt.allowed {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tt.principal, tt.method, got, tt.allowed)
+		}
+	}
+
+	if acl, _ := LoadACL(filepath.Join(t.TempDir(), "missing.yml")); !acl.Allows(PrincipalLocal, "Admin.Reload") {
+		t.Error("Expected local callers to be allowed everything without a config file")
+	}
+}
+
+func TestServer_CloseAnswersCallsInFlight(t *testing.T) {
+	s := &Server{ACL: DefaultACL}
+	startServer(t, s)
+	addr := s.ListenAddr().String()
+	c, err := DialWith(addr, Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Call(context.Background(), "Echo.Say", &EchoRequest{Text: "hello", Sleep: 200 * time.Millisecond}, &EchoResponse{})
+	}()
+	for s.inFlight(0) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Expected the call in flight to be answered, got %v", err)
+	}
+	if _, err := DialWith(addr, Credentials{}); err == nil {
+		t.Error("Expected the server to stop listening")
+	}
+}
@@ -0,0 +1,95 @@
+package rpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error codes, for callers to tell failures apart.
+const (
+	CodeNotFound         = "not_found"
+	CodeInvalid          = "invalid_argument"
+	CodePermissionDenied = "permission_denied"
+	CodeUnavailable      = "unavailable"
+	CodeDeadline         = "deadline_exceeded"
+	CodeCanceled         = "canceled"
+	CodeInternal         = "internal"
+)
+
+// Error is the failure of a call, as the caller receives it.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Errorf returns an error with a code, for methods to tell callers why they failed.
+func Errorf(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// The error sent to the caller: an *Error as it is, and anything else as an internal error.
+func toError(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return &Error{Code: CodeInternal, Message: err.Error()}
+}
+
+// Request is a call on the wire.
+type Request struct {
+	Method string
+	// Timeout is what was left of the caller's deadline when it called; zero means no
+	// deadline. A duration rather than a time, so the clocks of the hosts need not agree.
+	Timeout time.Duration
+	// Body is the JSON of the request struct.
+	Body []byte
+}
+
+// Response is the outcome of a call on the wire: the JSON of the response struct, or an
+// error.
+type Response struct {
+	Body  []byte
+	Error *Error
+}
+
+// MethodsArgs are the arguments of App.Methods.
+type MethodsArgs struct{}
+
+// Service is the net/rpc receiver of a registry; see Registry.Publish.
+type Service struct {
+	Registry *Registry
+}
+
+// Call calls a method. Failures of the method are in the response, so the net/rpc error is
+// only set when the call itself fails.
+func (s *Service) Call(req *Request, resp *Response) error {
+	ctx := context.Background()
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+
+	body, err := s.Registry.Call(ctx, req.Method, req.Body)
+	if err != nil {
+		resp.Error = toError(err)
+		return nil
+	}
+	resp.Body = body
+	return nil
+}
+
+// Methods lists the methods served.
+func (s *Service) Methods(args *MethodsArgs, reply *[]MethodInfo) error {
+	for _, m := range s.Registry.Methods() {
+		*reply = append(*reply, m.Info())
+	}
+	return nil
+}
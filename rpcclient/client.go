@@ -0,0 +1,113 @@
+// Code generated by "./adeleApp rpc client"; DO NOT EDIT.
+
+// Package rpcclient calls the RPC methods of the application. Other services import it
+// to call the application with the same request and response types it serves.
+package rpcclient
+
+import (
+	"context"
+
+	"myapp/rpcapi"
+	"myapp/rpcapi/admin"
+)
+
+// Client calls the RPC methods of the application.
+type Client struct {
+	*rpcapi.Client
+
+	Admin *AdminClient
+}
+
+// Dial connects to the RPC server of the application; see rpcapi.Dial.
+func Dial(addr string) (*Client, error) {
+	c, err := rpcapi.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return New(c), nil
+}
+
+// New returns a client calling through c.
+func New(c *rpcapi.Client) *Client {
+	return &Client{
+		Client: c,
+		Admin:  &AdminClient{c: c},
+	}
+}
+
+// AdminClient calls the methods of the Admin service.
+type AdminClient struct {
+	c *rpcapi.Client
+}
+
+// ClearCache empties the cache, or drops the entries whose keys match a prefix.
+func (s *AdminClient) ClearCache(ctx context.Context, req *admin.ClearCacheRequest) (*admin.ClearCacheResponse, error) {
+	resp := new(admin.ClearCacheResponse)
+	if err := s.c.Call(ctx, "Admin.ClearCache", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Jobs lists the jobs RunJob runs.
+func (s *AdminClient) Jobs(ctx context.Context, req *admin.JobsRequest) (*admin.JobsResponse, error) {
+	resp := new(admin.JobsResponse)
+	if err := s.c.Call(ctx, "Admin.Jobs", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Maintenance takes the application down for maintenance, or brings it back up.
+func (s *AdminClient) Maintenance(ctx context.Context, req *admin.MaintenanceRequest) (*admin.MaintenanceResponse, error) {
+	resp := new(admin.MaintenanceResponse)
+	if err := s.c.Call(ctx, "Admin.Maintenance", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Reload reads the configuration again and lists the settings that changed.
+func (s *AdminClient) Reload(ctx context.Context, req *admin.ReloadRequest) (*admin.ReloadResponse, error) {
+	resp := new(admin.ReloadResponse)
+	if err := s.c.Call(ctx, "Admin.Reload", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RunJob runs a job now and waits for it to finish.
+func (s *AdminClient) RunJob(ctx context.Context, req *admin.RunJobRequest) (*admin.RunJobResponse, error) {
+	resp := new(admin.RunJobResponse)
+	if err := s.c.Call(ctx, "Admin.RunJob", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SetLogLevel changes the level of the application's log until it restarts.
+func (s *AdminClient) SetLogLevel(ctx context.Context, req *admin.SetLogLevelRequest) (*admin.SetLogLevelResponse, error) {
+	resp := new(admin.SetLogLevelResponse)
+	if err := s.c.Call(ctx, "Admin.SetLogLevel", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Shutdown stops the application gracefully, as SIGTERM does.
+func (s *AdminClient) Shutdown(ctx context.Context, req *admin.ShutdownRequest) (*admin.ShutdownResponse, error) {
+	resp := new(admin.ShutdownResponse)
+	if err := s.c.Call(ctx, "Admin.Shutdown", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Status reports the uptime, goroutines, providers, routes and scheduled jobs of the application.
+func (s *AdminClient) Status(ctx context.Context, req *admin.StatusRequest) (*admin.StatusResponse, error) {
+	resp := new(admin.StatusResponse)
+	if err := s.c.Call(ctx, "Admin.Status", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,172 @@
+// Package services is a typed container of the services the application's providers
+// publish, e.g., the queue of adele-queue, so handlers and middleware resolve them by type
+// instead of looking the provider up by name and asserting its type:
+//
+//	q, err := services.Resolve[*queue.Queue](h.Services)
+//
+// Providers publish services once booted (see Container.PublishFrom); the application
+// declares the services it cannot run without with Require, and Check fails the boot
+// when one is missing. Tests replace a service with a fake with Override.
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when no service of a type is published.
+var ErrNotFound = errors.New("services: no service of the type is published")
+
+// Container holds services by type. The zero value is not usable; see New.
+type Container struct {
+	mu       sync.RWMutex
+	services map[reflect.Type]entry
+	required map[reflect.Type]string
+}
+
+type entry struct {
+	value interface{}
+	// by is the provider that published the service, when known, for errors to tell.
+	by string
+}
+
+// Source is what services are resolved from: a container, or something holding one.
+type Source interface {
+	ServiceContainer() *Container
+}
+
+// Publisher is a provider that publishes its services itself.
+type Publisher interface {
+	Publish(c *Container) error
+}
+
+// New returns an empty container.
+func New() *Container {
+	return &Container{services: make(map[reflect.Type]entry), required: make(map[reflect.Type]string)}
+}
+
+// ServiceContainer returns c, so a container is a Source.
+func (c *Container) ServiceContainer() *Container {
+	return c
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Provide publishes a service as type T, which may be an interface the service
+// implements. It fails when a service of the type is published already.
+func Provide[T any](c *Container, service T) error {
+	return c.provide(typeOf[T](), service, "")
+}
+
+func (c *Container) provide(t reflect.Type, service interface{}, by string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.services[t]; ok {
+		if e.by != "" {
+			return fmt.Errorf("services: %s is published already by %s", t, e.by)
+		}
+		return fmt.Errorf("services: %s is published already", t)
+	}
+	c.services[t] = entry{value: service, by: by}
+	return nil
+}
+
+// Resolve returns the service of type T.
+func Resolve[T any](s Source) (T, error) {
+	c := s.ServiceContainer()
+	c.mu.RLock()
+	e, ok := c.services[typeOf[T]()]
+	c.mu.RUnlock()
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%w: %s", ErrNotFound, typeOf[T]())
+	}
+	return e.value.(T), nil
+}
+
+// MustResolve returns the service of type T, and panics when there is none. It is for
+// services declared with Require, which Check made sure of at boot.
+func MustResolve[T any](s Source) T {
+	service, err := Resolve[T](s)
+	if err != nil {
+		panic(err)
+	}
+	return service
+}
+
+// Require declares that the application needs a service of type T, for Check to make
+// sure of; why tells what for.
+func Require[T any](c *Container, why string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.required[typeOf[T]()] = why
+}
+
+// Check reports the required services that are not published.
+func (c *Container) Check() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var missing []string
+	for t, why := range c.required {
+		if _, ok := c.services[t]; !ok {
+			missing = append(missing, fmt.Sprintf("%s (%s)", t, why))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("services: missing required services: %s", strings.Join(missing, ", "))
+}
+
+// Override replaces the service of type T, published or not, e.g., with a fake in a test,
+// and returns a function that puts back what was there:
+//
+//	t.Cleanup(services.Override[mail.Transport](h.Services, fake))
+func Override[T any](c *Container, service T) (restore func()) {
+	t := typeOf[T]()
+	c.mu.Lock()
+	previous, had := c.services[t]
+	c.services[t] = entry{value: service, by: "override"}
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if had {
+			c.services[t] = previous
+		} else {
+			delete(c.services, t)
+		}
+	}
+}
+
+// PublishFrom publishes the services of a booted provider named name: those it publishes
+// itself, when it is a Publisher, or else the result of its Service method, as the
+// first-party providers expose their service, e.g., (*queue.ServiceProvider).Service,
+// published as *queue.Queue. A provider with neither publishes nothing.
+func (c *Container) PublishFrom(name string, provider interface{}) error {
+	if p, ok := provider.(Publisher); ok {
+		if err := p.Publish(c); err != nil {
+			return fmt.Errorf("services: %s: %w", name, err)
+		}
+		return nil
+	}
+
+	method := reflect.ValueOf(provider).MethodByName("Service")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil
+	}
+	result := method.Call(nil)[0]
+	if (result.Kind() == reflect.Pointer || result.Kind() == reflect.Interface) && result.IsNil() {
+		return fmt.Errorf("services: %s has no service to publish", name)
+	}
+	return c.provide(result.Type(), result.Interface(), name)
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type Queue struct{ name string }
+
+type Dispatcher interface{ Dispatch(job string) error }
+
+func (q *Queue) Dispatch(job string) error { return nil }
+
+type fakeDispatcher struct{ jobs []string }
+
+func (f *fakeDispatcher) Dispatch(job string) error {
+	f.jobs = append(f.jobs, job)
+	return nil
+}
+
+// A provider exposing its service as the first-party providers do.
+type queueProvider struct{ q *Queue }
+
+func (p *queueProvider) Service() *Queue { return p.q }
+
+func TestContainer(t *testing.T) {
+	c := New()
+	if err := c.PublishFrom("queue", &queueProvider{q: &Queue{name: "default"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Provide[Dispatcher](c, &Queue{}); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := Resolve[*Queue](c)
+	if err != nil || q.name != "default" {
+		t.Fatalf("Expected the queue the provider published, got %v, %v", q, err)
+	}
+	if _, err := Resolve[*fakeDispatcher](c); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an unpublished type not to be found, got %v", err)
+	}
+
+	err = c.PublishFrom("other", &queueProvider{q: &Queue{}})
+	if err == nil || !strings.Contains(err.Error(), "published already by queue") {
+		t.Errorf("Expected a second queue to be refused, got %v", err)
+	}
+	if err := c.PublishFrom("queue", &queueProvider{}); err == nil {
+		t.Error("Expected a provider without its service to fail")
+	}
+}
+
+func TestContainer_Require(t *testing.T) {
+	c := New()
+	Require[*Queue](c, "dispatching jobs")
+	Require[Dispatcher](c, "sending mail")
+	Provide[Dispatcher](c, &Queue{})
+
+	err := c.Check()
+	if err == nil || !strings.Contains(err.Error(), "*services.Queue (dispatching jobs)") || strings.Contains(err.Error(), "Dispatcher") {
+		t.Errorf("Expected the queue to be reported missing, got %v", err)
+	}
+	Provide[*Queue](c, &Queue{})
+	if err := c.Check(); err != nil {
+		t.Errorf("Expected every required service to be published, got %v", err)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	c := New()
+	real := &Queue{}
+	Provide[Dispatcher](c, real)
+
+	fake := &fakeDispatcher{}
+	restore := Override[Dispatcher](c, fake)
+	MustResolve[Dispatcher](c).Dispatch("mail.send")
+	if len(fake.jobs) != 1 {
+		t.Errorf("Expected the fake to be resolved, got %v", fake.jobs)
+	}
+
+	restore()
+	if MustResolve[Dispatcher](c) != Dispatcher(real) {
+		t.Error("Expected the real service to be put back")
+	}
+
+	// an override of a service that is not published is removed again
+	Override[*Queue](c, &Queue{})()
+	if _, err := Resolve[*Queue](c); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected no queue after the override, got %v", err)
+	}
+}
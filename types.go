@@ -1,18 +1,50 @@
 package main
 
 import (
+	"myapp/boot"
 	"myapp/handlers"
+	"myapp/mail"
 	"myapp/middleware"
 	"myapp/models"
+	"myapp/rpcapi"
+	"myapp/rpcapi/admin"
+	"myapp/services"
+	"myapp/views"
+	"time"
 
 	"github.com/cidekar/adele-framework"
 	"github.com/cidekar/adele-framework/mailer"
+	"github.com/robfig/cron/v3"
 )
 
 type application struct {
-	App        *adele.Adele
-	Handlers   *handlers.Handlers
-	Mail       *mailer.Mail
-	Middleware *middleware.Middleware
-	Models     *models.Models
+	App          *adele.Adele
+	Handlers     *handlers.Handlers
+	LiveReload   *views.Watcher
+	Mail         *mailer.Mail
+	MailCatcher  *mail.Catcher
+	Mailer       *mail.Worker
+	MailPreviews *mail.Previews
+	Middleware   *middleware.Middleware
+	Models       *models.Models
+	Providers    *boot.Report
+	RPC          *rpcapi.Registry
+	Services     *services.Container
+
+	// stopMail stops the mail worker; see startMail.
+	stopMail func()
+	// rpcServer serves RPC; see startRPC.
+	rpcServer *rpcapi.Server
+	// started is when the application booted.
+	started time.Time
+	// shutdown asks listenForShutdown to stop the application; see requestShutdown.
+	shutdown chan struct{}
+	// scheduled names the entries of the scheduler added by schedule.
+	scheduled map[cron.EntryID]admin.ScheduledJob
+}
+
+// ServiceContainer returns the services of the application, so it is a services.Source:
+// services.Resolve[*queue.Queue](a).
+func (a *application) ServiceContainer() *services.Container {
+	return a.Services
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"myapp/views"
+)
+
+// Here is where the template data providers of the application are registered. Every
+// provider runs each time a handler renders a view with Handlers.Render, or middleware
+// renders a page of its own like the maintenance page, and sets the variables it is
+// responsible for, so handlers don't have to. A variable set by the
+// handler itself wins over a provided one. Providers run in the order they are registered.
+func (a *application) viewData() *views.Registry {
+	registry := views.NewRegistry()
+
+	registry.Register("appName", views.AppName(a.App.AppName))
+	registry.Register("user", views.CurrentUser(a.App.Session, nil))
+	registry.Register("flashes", views.Flashes(a.Handlers.Flash))
+	registry.Register("cspNonce", views.Nonce())
+	registry.Register("asset", views.Assets(filepath.Join(a.App.RootPath, "public")))
+	registry.Register("features", views.Features(os.Getenv("FEATURES")))
+	registry.Register("locale", views.Locale(a.Handlers.Lang.Locales()...))
+
+	return registry
+}
+
+// Here is where the view components of the application are registered. A component is a
+// template in resources/views/components and a struct of its props; the struct's values
+// are the defaults. Views render a component with component("name", map(...)).
+func (a *application) viewComponents() error {
+	components := views.NewComponents(a.App.JetViews)
+
+	return components.Register("alert", alertProps{Level: "info"})
+}
+
+type alertProps struct {
+	Level string `prop:"level"`
+	Title string `prop:"title"`
+	Text  string `prop:"text,required"`
+}
+
+// Here is where the views are compiled at boot: every template in the views directory is
+// parsed, so a syntax error or a missing extends target stops the application from
+// starting. In production the parsed templates are cached. In development the views are
+// watched instead: a change compiles them again and reloads the pages open in the browser
+// through the live reload endpoint the base layout connects to.
+func (a *application) compileViews() error {
+	dir := filepath.Join(a.App.RootPath, a.App.ViewsTemplateDir)
+
+	count, err := views.Compile(a.App.JetViews, dir)
+	if err != nil {
+		return err
+	}
+	a.App.Log.Info("Compiled ", count, " views")
+
+	if a.App.Debug {
+		a.LiveReload = views.NewWatcher(a.App.JetViews, dir, 500*time.Millisecond)
+		a.LiveReload.OnError = func(err error) {
+			a.App.Log.Error(err)
+		}
+		go a.LiveReload.Run(context.Background())
+
+		a.Handlers.Views.Register("liveReload", views.LiveReload(views.LiveReloadPath))
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package views
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+// CompileError lists the templates that failed to parse.
+type CompileError struct {
+	Errors []error
+}
+
+func (e *CompileError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("views: %d template(s) failed to compile:\n\t%s", len(e.Errors), strings.Join(messages, "\n\t"))
+}
+
+func (e *CompileError) Unwrap() []error {
+	return e.Errors
+}
+
+// Compile parses every template (*.jet) in the views directory, so a syntax error or an
+// extends, import or include of a template that does not exist fails at boot instead of on
+// the first request for the page. Outside of development mode the set caches the parsed
+// templates, so pages are never parsed while serving requests. It returns the number of
+// templates parsed, and a *CompileError listing every template that failed.
+func Compile(set *jet.Set, dir string) (int, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".jet") {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, "/"+filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("views: %w", err)
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, path := range paths {
+		if _, err := set.GetTemplate(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return len(paths), &CompileError{Errors: errs}
+	}
+	return len(paths), nil
+}
@@ -0,0 +1,117 @@
+package views
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+func writeViews(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	dir := t.TempDir()
+	writeViews(t, dir, map[string]string{
+		"layouts/base.jet": `<main>{{ yield pageContent() }}</main>`,
+		"home.jet":         `{{ extends "./layouts/base.jet" }}{{ block pageContent() }}home{{ end }}`,
+		"notes.txt":        `not a view`,
+	})
+
+	set := jet.NewSet(jet.NewOSFileSystemLoader(dir))
+	count, err := Compile(set, dir)
+	if err != nil || count != 2 {
+		t.Fatalf("Compile() = %d, %v, want 2, nil", count, err)
+	}
+
+	writeViews(t, dir, map[string]string{
+		"broken.jet":  `{{ if }}`,
+		"missing.jet": `{{ extends "./layouts/nope.jet" }}`,
+	})
+
+	set = jet.NewSet(jet.NewOSFileSystemLoader(dir))
+	_, err = Compile(set, dir)
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) || len(compileErr.Errors) != 2 {
+		t.Fatalf("Compile() error = %v, want a CompileError of both templates", err)
+	}
+	if !strings.Contains(err.Error(), "nope.jet") {
+		t.Errorf("error = %q, want the missing extends target", err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeViews(t, dir, map[string]string{"home.jet": `home`})
+
+	w := NewWatcher(jet.NewSet(jet.NewOSFileSystemLoader(dir), jet.InDevelopmentMode()), dir, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	srv := httptest.NewServer(w)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	events := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				events <- event
+			}
+		}
+	}()
+
+	// wait for the client to be registered before changing the views
+	for {
+		w.mu.Lock()
+		n := len(w.clients)
+		w.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, tc := range []struct{ content, event string }{
+		{`home, changed`, "reload"},
+		{`{{ if }}`, "error"},
+	} {
+		writeViews(t, dir, map[string]string{"home.jet": tc.content})
+		select {
+		case event := <-events:
+			if event != tc.event {
+				t.Errorf("event = %q, want %q", event, tc.event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %q event", tc.event)
+		}
+	}
+}
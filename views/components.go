@@ -0,0 +1,257 @@
+package views
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+// ComponentDir is the directory of the component templates, relative to the views.
+const ComponentDir = "components"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Components are reusable pieces of markup with typed props. A component is a template in
+// resources/views/components named after the component, and a Go struct describing its
+// props. Views render a component with the component function, passing the props as a map
+// (or as the struct itself); the map is checked against the struct, so a misspelled or
+// missing prop fails the render instead of rendering an empty value. The template gets the
+// struct as props.
+//
+// Props are matched by the prop tag of a field, then its name. A prop tagged required must
+// be given.
+//
+// Example:
+//
+//	type AlertProps struct {
+//		Level string `prop:"level"`
+//		Text  string `prop:"text,required"`
+//	}
+//
+//	components := views.NewComponents(a.App.JetViews)
+//	components.Register("alert", AlertProps{Level: "info"})
+//
+//	{* resources/views/components/alert.jet *}
+//	<div class="alert alert-{{ props.Level }}">{{ props.Text }}</div>
+//
+//	{* any view *}
+//	{{ component("alert", map("level", "error", "text", "Something went wrong.")) }}
+type Components struct {
+	set *jet.Set
+
+	mu    sync.RWMutex
+	props map[string]reflect.Value
+}
+
+// NewComponents adds the component function to a set of views.
+func NewComponents(set *jet.Set) *Components {
+	c := &Components{set: set, props: make(map[string]reflect.Value)}
+	set.AddGlobalFunc("component", c.render)
+	return c
+}
+
+// Register adds a component. The props are a struct value whose fields are the props of the
+// component and whose values are the defaults. The template of the component must exist.
+func (c *Components) Register(name string, props interface{}) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("views: invalid component name %q", name)
+	}
+
+	v := reflect.ValueOf(props)
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("views: props of component %q must be a struct, got %T", name, props)
+	}
+
+	if _, err := c.set.GetTemplate(componentPath(name)); err != nil {
+		return fmt.Errorf("views: component %q: %w", name, err)
+	}
+
+	c.mu.Lock()
+	c.props[name] = v
+	c.mu.Unlock()
+	return nil
+}
+
+// Render a component into a string, for use outside of views (e.g., in a response to an
+// HTMX request that swaps in a single component).
+func (c *Components) Render(name string, props interface{}) (string, error) {
+	p, err := c.build(name, reflect.ValueOf(props))
+	if err != nil {
+		return "", err
+	}
+
+	t, err := c.set.GetTemplate(componentPath(name))
+	if err != nil {
+		return "", err
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("props", p.Interface())
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// The component function of the views: component(name, props).
+func (c *Components) render(a jet.Arguments) reflect.Value {
+	a.RequireNumOfArguments("component", 1, 2)
+
+	name := a.Get(0)
+	if name.Kind() == reflect.Interface {
+		name = name.Elem()
+	}
+	if name.Kind() != reflect.String {
+		a.Panicf("component: the name must be a string, got %s", name.Type())
+	}
+
+	var props reflect.Value
+	if a.IsSet(1) {
+		props = a.Get(1)
+	}
+
+	p, err := c.build(name.String(), props)
+	if err != nil {
+		a.Panicf("%s", err)
+	}
+
+	t, err := c.set.GetTemplate(componentPath(name.String()))
+	if err != nil {
+		a.Panicf("component %q: %s", name.String(), err)
+	}
+
+	return reflect.ValueOf(jet.RendererFunc(func(rt *jet.Runtime) {
+		vars := make(jet.VarMap)
+		vars.Set("props", p.Interface())
+
+		var data interface{}
+		if ctx := rt.Context(); ctx.IsValid() {
+			data = ctx.Interface()
+		}
+		if err := t.Execute(rt.Writer, vars, data); err != nil {
+			panic(err)
+		}
+	}))
+}
+
+// Build the props of a component from the given value: a map of props, the props struct
+// itself, or nothing for the defaults.
+func (c *Components) build(name string, given reflect.Value) (reflect.Value, error) {
+	c.mu.RLock()
+	defaults, ok := c.props[name]
+	c.mu.RUnlock()
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("component %q is not registered", name)
+	}
+
+	for given.IsValid() && (given.Kind() == reflect.Interface || given.Kind() == reflect.Pointer) {
+		if given.IsNil() {
+			given = reflect.Value{}
+			break
+		}
+		given = given.Elem()
+	}
+
+	props := reflect.New(defaults.Type()).Elem()
+	props.Set(defaults)
+
+	if given.IsValid() && given.Type() == defaults.Type() {
+		props.Set(given)
+		return props, checkRequired(name, props, nil)
+	}
+
+	set := make(map[string]bool)
+	if given.IsValid() {
+		if given.Kind() != reflect.Map || given.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, fmt.Errorf("component %q: props must be a map or %s, got %s", name, defaults.Type(), given.Type())
+		}
+
+		iter := given.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			i, ok := propField(props.Type(), key)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("component %q has no prop %q", name, key)
+			}
+
+			value := iter.Value()
+			for value.Kind() == reflect.Interface && !value.IsNil() {
+				value = value.Elem()
+			}
+			if err := assignProp(props.Field(i), value); err != nil {
+				return reflect.Value{}, fmt.Errorf("component %q: prop %q %s", name, key, err)
+			}
+			set[propName(props.Type().Field(i))] = true
+		}
+	}
+
+	return props, checkRequired(name, props, set)
+}
+
+// Find the index of the field of a prop by its prop tag or name.
+func propField(t reflect.Type, key string) (int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.IsExported() && (propName(sf) == key || strings.EqualFold(sf.Name, key)) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Assign a value to a prop, converting between numeric types since Jet numbers are floats.
+func assignProp(field, value reflect.Value) error {
+	switch {
+	case !value.IsValid() || (value.Kind() == reflect.Interface && value.IsNil()):
+		field.Set(reflect.Zero(field.Type()))
+	case value.Type().AssignableTo(field.Type()):
+		field.Set(value)
+	case isNumber(value.Kind()) && isNumber(field.Kind()):
+		field.Set(value.Convert(field.Type()))
+	default:
+		return fmt.Errorf("must be %s, got %s", field.Type(), value.Type())
+	}
+	return nil
+}
+
+// Report an error when a required prop was not given. Props given as a struct are checked
+// for a zero value instead.
+func checkRequired(name string, props reflect.Value, set map[string]bool) error {
+	for i := 0; i < props.NumField(); i++ {
+		sf := props.Type().Field(i)
+		_, opts, _ := strings.Cut(sf.Tag.Get("prop"), ",")
+		if opts != "required" {
+			continue
+		}
+		given := !props.Field(i).IsZero()
+		if set != nil {
+			given = set[propName(sf)]
+		}
+		if !given {
+			return fmt.Errorf("component %q requires prop %q", name, propName(sf))
+		}
+	}
+	return nil
+}
+
+func propName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("prop"), ","); name != "" {
+		return name
+	}
+	return sf.Name
+}
+
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+func componentPath(name string) string {
+	return "/" + ComponentDir + "/" + name + ".jet"
+}
@@ -0,0 +1,142 @@
+package views
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/CloudyKit/jet/v6/loaders/httpfs"
+)
+
+type badgeProps struct {
+	Label string `prop:"label,required"`
+	Count int    `prop:"count"`
+	Tone  string `prop:"tone"`
+}
+
+func newTestSet(t *testing.T, files map[string]string) *jet.Set {
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	loader, err := httpfs.NewLoader(http.FS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jet.NewSet(loader, jet.InDevelopmentMode())
+}
+
+func render(t *testing.T, set *jet.Set, name string, vars jet.VarMap) (string, error) {
+	t.Helper()
+	tmpl, err := set.GetTemplate(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, vars, nil)
+	return strings.TrimSpace(out.String()), err
+}
+
+func TestComponents(t *testing.T) {
+	set := newTestSet(t, map[string]string{
+		"components/badge.jet": `<span class="badge badge-{{ props.Tone }}">{{ props.Label }} ({{ props.Count }})</span>`,
+		"page.jet":             `{{ component("badge", map("label", "<Inbox>", "count", 3)) }}`,
+		"typo.jet":             `{{ component("badge", map("label", "Inbox", "cuont", 3)) }}`,
+		"missing.jet":          `{{ component("badge", map("count", 3)) }}`,
+		"struct.jet":           `{{ component("badge", badge) }}`,
+	})
+
+	components := NewComponents(set)
+	if err := components.Register("badge", badgeProps{Tone: "neutral"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := components.Register("nope", badgeProps{}); err == nil {
+		t.Error("Expected a component without a template to be refused")
+	}
+
+	html, err := render(t, set, "page.jet", nil)
+	if err != nil || html != `<span class="badge badge-neutral">&lt;Inbox&gt; (3)</span>` {
+		t.Errorf("Unexpected component %q (%v)", html, err)
+	}
+
+	if _, err := render(t, set, "typo.jet", nil); err == nil || !strings.Contains(err.Error(), `no prop "cuont"`) {
+		t.Errorf("Expected an unknown prop to fail, got %v", err)
+	}
+	if _, err := render(t, set, "missing.jet", nil); err == nil || !strings.Contains(err.Error(), `requires prop "label"`) {
+		t.Errorf("Expected a missing required prop to fail, got %v", err)
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("badge", badgeProps{Label: "Sent", Tone: "muted"})
+	if html, err := render(t, set, "struct.jet", vars); err != nil || !strings.Contains(html, "badge-muted") {
+		t.Errorf("Expected props given as the struct, got %q (%v)", html, err)
+	}
+
+	if html, err := components.Render("badge", map[string]interface{}{"label": "Drafts"}); err != nil || !strings.Contains(html, "Drafts (0)") {
+		t.Errorf("Unexpected component rendered from Go %q (%v)", html, err)
+	}
+}
+
+func TestFragment(t *testing.T) {
+	set := newTestSet(t, map[string]string{
+		"layout.jet": `<html>{{ yield pageContent() }}</html>`,
+		"users.jet":  `{{ extends "./layout.jet" }}{{ block pageContent() }}<h1>Users</h1>{{ yield list() }}{{ end }}{{ block list() }}<ul>{{ range users }}<li>{{ . }}</li>{{ end }}</ul>{{ end }}`,
+	})
+
+	tests := []struct {
+		url      string
+		headers  map[string]string
+		block    string
+		targeted bool
+	}{
+		{"/users", nil, "", false},
+		{"/users?fragment=list", nil, "list", false},
+		{"/users", map[string]string{"HX-Request": "true"}, DefaultFragment, false},
+		{"/users", map[string]string{"HX-Request": "true", "HX-Target": "list"}, "list", true},
+		{"/users", map[string]string{"HX-Request": "true", "HX-Target": "#user-list"}, DefaultFragment, false},
+		{"/users", map[string]string{"HX-Request": "true", "HX-Boosted": "true"}, "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		if block, targeted := Fragment(req); block != tt.block || targeted != tt.targeted {
+			t.Errorf("%s %v: expected %q (%v), got %q (%v)", tt.url, tt.headers, tt.block, tt.targeted, block, targeted)
+		}
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("users", []string{"ada", "grace"})
+
+	tmpl, err := FragmentTemplate(set, "users", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars, nil); err != nil || out.String() != "<ul><li>ada</li><li>grace</li></ul>" {
+		t.Errorf("Unexpected fragment %q (%v)", out.String(), err)
+	}
+
+	tmpl, _ = FragmentTemplate(set, "users", "sidebar")
+	if err := tmpl.Execute(&out, vars, nil); !IsUnknownBlock(err) {
+		t.Errorf("Expected an unknown block error, got %v", err)
+	}
+	if _, err := FragmentTemplate(set, "users", "list() }}{{ x"); err == nil {
+		t.Error("Expected an invalid block name to be refused")
+	}
+}
+
+func TestHXTrigger(t *testing.T) {
+	w := httptest.NewRecorder()
+	HXTrigger(w, "todo-added", map[string]int{"id": 7})
+	HXTrigger(w, "close-modal", nil)
+
+	if got := w.Header().Get("HX-Trigger"); got != `{"close-modal":null,"todo-added":{"id":7}}` {
+		t.Errorf("Unexpected HX-Trigger %s", got)
+	}
+}
@@ -0,0 +1,314 @@
+package views
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"myapp/flash"
+	"myapp/i18n"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/alexedwards/scs/v2"
+)
+
+// Provider adds the data it is responsible for to the variables of a view rendered for a
+// request. Providers run on every render, so they should be cheap or cache what they can.
+type Provider func(r *http.Request, vars jet.VarMap)
+
+// Registry holds the template data providers of the application. Handlers.Render runs them
+// before every render, as does middleware rendering a page itself, so data every page needs
+// (the current user, the app name, flash messages, ...) is set in one place instead of in
+// every handler.
+//
+// Example:
+//
+//	registry := views.NewRegistry()
+//	registry.Register("appName", views.AppName(a.AppName))
+//	registry.Register("now", func(r *http.Request, vars jet.VarMap) {
+//		vars.Set("now", time.Now())
+//	})
+type Registry struct {
+	mu        sync.RWMutex
+	names     []string
+	providers map[string]Provider
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]Provider)}
+}
+
+// Register adds a provider under a name. Registering a name again replaces its provider but
+// keeps its place in the order providers run in.
+func (reg *Registry) Register(name string, p Provider) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, ok := reg.providers[name]; !ok {
+		reg.names = append(reg.names, name)
+	}
+	reg.providers[name] = p
+}
+
+// Names returns the names of the providers in the order they run in.
+func (reg *Registry) Names() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return append([]string(nil), reg.names...)
+}
+
+// Apply runs the providers in the order they were registered and merges what they set into
+// the variables. Variables already set by the caller win over provided ones, so a handler
+// can always override shared data for its view. A provider whose name the caller already
+// set is not run at all, so its side effects (e.g., popping the flash messages) are skipped
+// too.
+func (reg *Registry) Apply(r *http.Request, variables jet.VarMap) {
+	reg.mu.RLock()
+	providers := make([]Provider, 0, len(reg.names))
+	for _, name := range reg.names {
+		if _, ok := variables[name]; !ok {
+			providers = append(providers, reg.providers[name])
+		}
+	}
+	reg.mu.RUnlock()
+
+	provided := make(jet.VarMap)
+	for _, p := range providers {
+		p(r, provided)
+	}
+
+	for name, value := range provided {
+		if _, ok := variables[name]; !ok {
+			variables[name] = value
+		}
+	}
+}
+
+// AppName provides the name of the application as appName.
+func AppName(name string) Provider {
+	return func(r *http.Request, vars jet.VarMap) {
+		vars.Set("appName", name)
+	}
+}
+
+// CurrentUser provides the authenticated user of the session as user, and isAuthenticated.
+// The user is loaded by its ID (the session's userID) with load; with a nil load, or when
+// loading fails, user is the ID itself. Anonymous requests get a nil user.
+func CurrentUser(session *scs.SessionManager, load func(ctx context.Context, id interface{}) (interface{}, error)) Provider {
+	return func(r *http.Request, vars jet.VarMap) {
+		var user interface{}
+		if session != nil && session.Exists(r.Context(), "userID") {
+			user = session.Get(r.Context(), "userID")
+			if load != nil {
+				if loaded, err := load(r.Context(), user); err == nil {
+					user = loaded
+				}
+			}
+		}
+		vars.Set("user", user)
+		vars.Set("isAuthenticated", user != nil)
+	}
+}
+
+// FormErrors are the validation messages of a form by input name, handed to views as the
+// errors variable.
+//
+// Example:
+//
+//	<input name="email" value="{{ old.Get("email") }}">
+//	{{ if errors.Has("email") }}<p class="error">{{ errors.First("email") }}</p>{{ end }}
+type FormErrors map[string][]string
+
+// Has reports whether the input has an error.
+func (e FormErrors) Has(field string) bool {
+	return len(e[field]) > 0
+}
+
+// First returns the first message of the input.
+func (e FormErrors) First(field string) string {
+	if len(e[field]) == 0 {
+		return ""
+	}
+	return e[field][0]
+}
+
+// Flashes provides the flash messages of the session as flashes, and the errors and values
+// of a form that redirected back as errors (FormErrors) and old (url.Values). errors and old
+// are always set, so views can call errors.Has and old.Get without checking.
+//
+// Messages are popped from the session only for full pages. Partial renders (fragments and
+// error pages; see IsPartial) get none, so they stay for the next page the client loads.
+func Flashes(store *flash.Store) Provider {
+	return func(r *http.Request, vars jet.VarMap) {
+		messages := []flash.Message{}
+		old, fields := url.Values{}, FormErrors{}
+
+		if store != nil && !IsPartial(r) {
+			if popped := store.Pop(r.Context()); len(popped) > 0 {
+				messages = popped
+			}
+			kept, keptFields := store.PopInput(r.Context())
+			if kept != nil {
+				old = kept
+			}
+			if keptFields != nil {
+				fields = FormErrors(keptFields)
+			}
+		}
+
+		vars.Set("flashes", messages)
+		vars.Set("errors", fields)
+		vars.Set("old", old)
+	}
+}
+
+type partialKey struct{}
+
+// AsPartial returns a copy of the request marking its render as not being a full page, e.g.,
+// an error page rendered in place of the page that was asked for.
+func AsPartial(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), partialKey{}, true))
+}
+
+// IsPartial reports whether a request renders less than a full page: a fragment (see
+// Fragment) or a render marked with AsPartial.
+func IsPartial(r *http.Request) bool {
+	if partial, _ := r.Context().Value(partialKey{}).(bool); partial {
+		return true
+	}
+	block, _ := Fragment(r)
+	return block != ""
+}
+
+type nonceKey struct{}
+
+// WithNonce returns a copy of the context carrying the CSP nonce of a request.
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey{}, nonce)
+}
+
+// NonceFromContext returns the CSP nonce of a request, or "" when none was generated.
+func NonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+// Nonce provides the CSP nonce of the request (see middleware.ContentSecurityPolicy) as
+// cspNonce, for the nonce attribute of inline scripts and styles.
+func Nonce() Provider {
+	return func(r *http.Request, vars jet.VarMap) {
+		vars.Set("cspNonce", NonceFromContext(r.Context()))
+	}
+}
+
+// Assets provides asset, a function that returns the URL of a file in the public directory
+// with a version parameter derived from its content, so browsers fetch it again when it
+// changes: {{ asset("css/app.css") }} is /public/css/app.css?v=1a2b3c4d.
+func Assets(publicDir string) Provider {
+	versions := &assetVersions{dir: publicDir}
+	asset := func(name string) string {
+		return versions.url(name)
+	}
+	return func(r *http.Request, vars jet.VarMap) {
+		vars.Set("asset", asset)
+	}
+}
+
+type assetVersion struct {
+	modTime time.Time
+	version string
+}
+
+type assetVersions struct {
+	dir      string
+	versions sync.Map
+}
+
+// The URL of an asset. The version is the start of the SHA-256 of the file, computed again
+// only when the file changes.
+func (a *assetVersions) url(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	u := "/public/" + name
+
+	file := filepath.Join(a.dir, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if err != nil {
+		return u
+	}
+
+	if v, ok := a.versions.Load(name); ok && v.(assetVersion).modTime.Equal(info.ModTime()) {
+		return u + "?v=" + v.(assetVersion).version
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return u
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return u
+	}
+
+	version := hex.EncodeToString(hash.Sum(nil))[:8]
+	a.versions.Store(name, assetVersion{modTime: info.ModTime(), version: version})
+	return u + "?v=" + version
+}
+
+// Flags are feature flags, provided to views as features.
+//
+// Example:
+//
+//	{{ if features.Enabled("new-dashboard") }} ... {{ end }}
+type Flags map[string]bool
+
+// Enabled reports whether a feature is on.
+func (f Flags) Enabled(name string) bool {
+	return f[name]
+}
+
+// Features provides the feature flags as features. The flags are read from a comma-separated
+// list of enabled features, e.g., FEATURES="new-dashboard,beta-search".
+func Features(list string) Provider {
+	flags := Flags{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			flags[name] = true
+		}
+	}
+	return func(r *http.Request, vars jet.VarMap) {
+		vars.Set("features", flags)
+	}
+}
+
+// Locale provides the locale of the request as locale: the one chosen by the Locale
+// middleware, or else the preferred language of Accept-Language that is supported, or else
+// the first supported language.
+func Locale(supported ...string) Provider {
+	if len(supported) == 0 {
+		supported = []string{"en"}
+	}
+	return func(r *http.Request, vars jet.VarMap) {
+		locale := i18n.LocaleFromContext(r.Context())
+		if locale == "" {
+			locale = preferredLocale(r.Header.Get("Accept-Language"), supported)
+		}
+		vars.Set("locale", locale)
+	}
+}
+
+// Pick the preferred language of an Accept-Language header that is supported (see
+// i18n.Match).
+func preferredLocale(header string, supported []string) string {
+	return i18n.Match(header, supported)
+}
@@ -0,0 +1,124 @@
+package views
+
+import (
+	"myapp/flash"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/alexedwards/scs/v2"
+)
+
+func TestRegistry_Apply(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("appName", AppName("myapp"))
+	ran := false
+	registry.Register("title", func(r *http.Request, vars jet.VarMap) {
+		ran = true
+		vars.Set("title", "Provided")
+	})
+	registry.Register("features", Features("beta, search"))
+	registry.Register("appName", AppName("renamed"))
+
+	if names := registry.Names(); strings.Join(names, ",") != "appName,title,features" {
+		t.Errorf("Expected providers to keep their order, got %v", names)
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("title", "Set by the handler")
+	registry.Apply(httptest.NewRequest("GET", "/", nil), vars)
+
+	if vars["appName"].String() != "renamed" {
+		t.Errorf("Expected the replaced provider to run, got %v", vars["appName"])
+	}
+	if vars["title"].String() != "Set by the handler" || ran {
+		t.Errorf("Expected the variable of the handler to win without running its provider, got %v", vars["title"])
+	}
+	if flags := vars["features"].Interface().(Flags); !flags.Enabled("search") || flags.Enabled("alpha") {
+		t.Errorf("Unexpected feature flags %v", flags)
+	}
+}
+
+func TestFlashes_FullPagesOnly(t *testing.T) {
+	session := scs.New()
+	store := flash.New(session)
+	registry := NewRegistry()
+	registry.Register("flashes", Flashes(store))
+
+	flashes := func(r *http.Request) []flash.Message {
+		vars := make(jet.VarMap)
+		registry.Apply(r, vars)
+		return vars["flashes"].Interface().([]flash.Message)
+	}
+
+	session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store.Success(r.Context(), "Saved.")
+
+		if messages := flashes(AsPartial(r)); len(messages) != 0 {
+			t.Errorf("Expected an error page to get no messages, got %v", messages)
+		}
+
+		fragment := r.Clone(r.Context())
+		fragment.Header.Set("HX-Request", "true")
+		if messages := flashes(fragment); len(messages) != 0 {
+			t.Errorf("Expected a fragment to get no messages, got %v", messages)
+		}
+
+		if messages := flashes(r); len(messages) != 1 || messages[0].Text != "Saved." {
+			t.Errorf("Expected the full page to get the message, got %v", messages)
+		}
+		if messages := flashes(r); len(messages) != 0 {
+			t.Errorf("Expected the message to be shown once, got %v", messages)
+		}
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestAssets(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "css"), 0755)
+	os.WriteFile(filepath.Join(dir, "css", "app.css"), []byte("body{}"), 0644)
+
+	vars := make(jet.VarMap)
+	Assets(dir)(httptest.NewRequest("GET", "/", nil), vars)
+	asset := vars["asset"].Interface().(func(string) string)
+
+	u := asset("css/app.css")
+	if !strings.HasPrefix(u, "/public/css/app.css?v=") || len(u) != len("/public/css/app.css?v=")+8 {
+		t.Errorf("Expected a versioned URL, got %s", u)
+	}
+	if asset("/css/app.css") != u {
+		t.Error("Expected a leading slash to make no difference")
+	}
+	if got := asset("js/missing.js"); got != "/public/js/missing.js" {
+		t.Errorf("Expected an unversioned URL for a missing file, got %s", got)
+	}
+	if got := asset("../../etc/passwd"); got != "/public/etc/passwd" {
+		t.Errorf("Expected the path to stay in the public directory, got %s", got)
+	}
+}
+
+func TestPreferredLocale(t *testing.T) {
+	supported := []string{"en", "fr", "pt-BR"}
+
+	tests := []struct {
+		header string
+		locale string
+	}{
+		{"", "en"},
+		{"fr-CA,fr;q=0.9,en;q=0.8", "fr"},
+		{"pt-br", "pt-BR"},
+		{"de,fr;q=0.5", "fr"},
+		{"fr;q=0,en", "en"},
+		{"ja", "en"},
+	}
+
+	for _, tt := range tests {
+		if got := preferredLocale(tt.header, supported); got != tt.locale {
+			t.Errorf("%q: expected %s, got %s", tt.header, tt.locale, got)
+		}
+	}
+}
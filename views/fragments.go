@@ -0,0 +1,132 @@
+package views
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+// DefaultFragment is the block rendered for an HTMX request that does not name one: the
+// content of the page without the layout around it.
+const DefaultFragment = "pageContent"
+
+// Fragment returns the name of the block to render instead of the whole view, or "" to
+// render the whole view. A fragment is asked for by the fragment query parameter, e.g.,
+// /users?fragment=list, or by an HTMX request (HX-Request), which gets the block named
+// after its target element (HX-Target) when the view has one, and DefaultFragment
+// otherwise. Boosted requests (HX-Boosted) swap in the whole body and get the whole view.
+//
+// The second result reports whether the block was named by HX-Target, in which case the
+// renderer falls back to DefaultFragment when the view has no such block.
+func Fragment(r *http.Request) (string, bool) {
+	if block := r.URL.Query().Get("fragment"); block != "" {
+		return block, false
+	}
+
+	if !IsHTMX(r) || r.Header.Get("HX-Boosted") == "true" {
+		return "", false
+	}
+
+	if target := strings.TrimPrefix(r.Header.Get("HX-Target"), "#"); namePattern.MatchString(target) {
+		return target, true
+	}
+	return DefaultFragment, false
+}
+
+// IsHTMX reports whether a request is made by HTMX.
+func IsHTMX(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+var fragments sync.Map
+
+// FragmentTemplate returns a template that renders a single block of a view. The view's
+// own variables and blocks are available to the block. Templates are cached by the set
+// they belong to; ResetFragments clears them when the views change.
+func FragmentTemplate(set *jet.Set, view, block string) (*jet.Template, error) {
+	if !namePattern.MatchString(block) {
+		return nil, fmt.Errorf("views: invalid fragment %q", block)
+	}
+
+	type key struct {
+		set         *jet.Set
+		view, block string
+	}
+	k := key{set, view, block}
+	if t, ok := fragments.Load(k); ok {
+		return t.(*jet.Template), nil
+	}
+
+	path := "/" + strings.TrimPrefix(view, "/") + ".jet"
+	t, err := set.Parse(path+"#"+block, fmt.Sprintf("{{ import %q }}{{ yield %s() }}", path, block))
+	if err != nil {
+		return nil, err
+	}
+
+	fragments.Store(k, t)
+	return t, nil
+}
+
+// ResetFragments clears the cached fragment templates.
+func ResetFragments() {
+	fragments.Range(func(k, _ interface{}) bool {
+		fragments.Delete(k)
+		return true
+	})
+}
+
+// IsUnknownBlock reports whether rendering failed because the block does not exist.
+func IsUnknownBlock(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "unresolved block")
+}
+
+// HXRedirect tells HTMX to load another page, as a full page load.
+func HXRedirect(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Redirect", url)
+}
+
+// HXRefresh tells HTMX to reload the whole page.
+func HXRefresh(w http.ResponseWriter) {
+	w.Header().Set("HX-Refresh", "true")
+}
+
+// HXRetarget tells HTMX to swap the response into another element (a CSS selector).
+func HXRetarget(w http.ResponseWriter, selector string) {
+	w.Header().Set("HX-Retarget", selector)
+}
+
+// HXReswap tells HTMX to swap the response in another way, e.g., "outerHTML".
+func HXReswap(w http.ResponseWriter, swap string) {
+	w.Header().Set("HX-Reswap", swap)
+}
+
+// HXTrigger triggers a client-side event once the response is received. The detail is
+// passed to the event listeners and may be nil. Events triggered by one response are
+// collected, so the helper can be called more than once.
+//
+// Example:
+//
+//	views.HXTrigger(w, "todo-added", map[string]interface{}{"id": todo.ID})
+//	views.HXTrigger(w, "close-modal", nil)
+func HXTrigger(w http.ResponseWriter, event string, detail interface{}) {
+	events := map[string]interface{}{}
+	if current := w.Header().Get("HX-Trigger"); current != "" {
+		if err := json.Unmarshal([]byte(current), &events); err != nil {
+			// a plain event name set directly
+			for _, name := range strings.Split(current, ",") {
+				events[strings.TrimSpace(name)] = nil
+			}
+		}
+	}
+	events[event] = detail
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		return
+	}
+	w.Header().Set("HX-Trigger", string(data))
+}
@@ -0,0 +1,155 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+// LiveReloadPath is the path of the live reload endpoint.
+const LiveReloadPath = "/_dev/livereload"
+
+// Watcher reloads the views in development. It polls the views directory for changes, and
+// on a change compiles the templates again and tells every browser connected to its
+// Server-Sent Events endpoint to reload the page, or logs the errors when the templates no
+// longer compile. The base layout connects to the endpoint when the liveReload variable is
+// set (see LiveReload).
+type Watcher struct {
+	set      *jet.Set
+	dir      string
+	interval time.Duration
+
+	// OnError is called with the error of a compile that failed after a change.
+	OnError func(error)
+
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+}
+
+// NewWatcher returns a watcher of the views directory of a set, polling at the interval.
+func NewWatcher(set *jet.Set, dir string, interval time.Duration) *Watcher {
+	return &Watcher{set: set, dir: dir, interval: interval, clients: make(map[chan string]struct{})}
+}
+
+// Run polls the views directory until the context is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	last := w.snapshot()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := w.snapshot()
+		if current == last {
+			continue
+		}
+		last = current
+
+		ResetFragments()
+		if _, err := Compile(w.set, w.dir); err != nil {
+			if w.OnError != nil {
+				w.OnError(err)
+			}
+			w.broadcast("error", err.Error())
+			continue
+		}
+		w.broadcast("reload", "")
+	}
+}
+
+// A fingerprint of the views directory: the paths, sizes and modification times of its
+// files. Any change (including a removed file) changes it.
+func (w *Watcher) snapshot() string {
+	var b strings.Builder
+	filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return b.String()
+}
+
+// Send an event to every connected browser. Browsers that are not keeping up miss it.
+func (w *Watcher) broadcast(event, data string) {
+	message := "event: " + event + "\n"
+	for _, line := range strings.Split(data, "\n") {
+		message += "data: " + line + "\n"
+	}
+	message += "\n"
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for client := range w.clients {
+		select {
+		case client <- message:
+		default:
+		}
+	}
+}
+
+// ServeHTTP is the Server-Sent Events endpoint browsers connect to.
+func (w *Watcher) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(rw)
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+
+	// the server's write timeout would end the stream; the browser reconnects anyway
+	rc.SetWriteDeadline(time.Time{})
+
+	client := make(chan string, 4)
+	w.mu.Lock()
+	w.clients[client] = struct{}{}
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.clients, client)
+		w.mu.Unlock()
+	}()
+
+	fmt.Fprint(rw, "retry: 1000\n: connected\n\n")
+	rc.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message := <-client:
+			fmt.Fprint(rw, message)
+		case <-heartbeat.C:
+			fmt.Fprint(rw, ": heartbeat\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// LiveReload provides liveReload, the path of the live reload endpoint, which makes the base
+// layout connect to it. Register it in development only.
+func LiveReload(path string) Provider {
+	return func(r *http.Request, vars jet.VarMap) {
+		vars.Set("liveReload", path)
+	}
+}
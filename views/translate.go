@@ -0,0 +1,115 @@
+package views
+
+import (
+	"myapp/i18n"
+	"reflect"
+	"time"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+// Translate adds the translation functions to a set of views. They translate into the locale
+// of the view (the locale variable, see Locale), or the fallback locale of the bundle.
+//
+//	t(key, args...)            the message of a key (see i18n.Bundle.T)
+//	formatNumber(n, decimals?) a number with the separators of the locale
+//	formatDate(t, style?)      a time in the "date", "time", "datetime" or "long" style
+//
+// Example:
+//
+//	<h1>{{ t("home.title") }}</h1>
+//	<p>{{ t("inbox.count", "count", len(messages)) }}</p>
+//	<p>{{ t("orders.total", "total", formatNumber(order.Total, 2)) }}, {{ formatDate(order.PlacedAt, "long") }}</p>
+func Translate(set *jet.Set, bundle *i18n.Bundle) {
+	set.AddGlobalFunc("t", func(a jet.Arguments) reflect.Value {
+		if a.NumOfArguments() < 1 {
+			a.Panicf("t: the key is missing")
+		}
+
+		key, ok := stringArgument(a.Get(0))
+		if !ok {
+			a.Panicf("t: the key must be a string, got %s", a.Get(0).Type())
+		}
+
+		args := make([]interface{}, 0, a.NumOfArguments()-1)
+		for i := 1; i < a.NumOfArguments(); i++ {
+			args = append(args, argumentValue(a.Get(i)))
+		}
+
+		return reflect.ValueOf(bundle.T(viewLocale(a, bundle), key, args...))
+	})
+
+	set.AddGlobalFunc("formatNumber", func(a jet.Arguments) reflect.Value {
+		a.RequireNumOfArguments("formatNumber", 1, 2)
+
+		n, ok := numberArgument(a.Get(0))
+		if !ok {
+			a.Panicf("formatNumber: not a number: %s", a.Get(0).Type())
+		}
+		decimals := -1
+		if a.IsSet(1) {
+			d, ok := numberArgument(a.Get(1))
+			if !ok {
+				a.Panicf("formatNumber: the decimals must be a number")
+			}
+			decimals = int(d)
+		}
+
+		return reflect.ValueOf(bundle.Formats(viewLocale(a, bundle)).FormatNumber(n, decimals))
+	})
+
+	set.AddGlobalFunc("formatDate", func(a jet.Arguments) reflect.Value {
+		a.RequireNumOfArguments("formatDate", 1, 2)
+
+		t, ok := argumentValue(a.Get(0)).(time.Time)
+		if !ok {
+			a.Panicf("formatDate: not a time: %s", a.Get(0).Type())
+		}
+		style := ""
+		if a.IsSet(1) {
+			style, _ = stringArgument(a.Get(1))
+		}
+
+		return reflect.ValueOf(bundle.Formats(viewLocale(a, bundle)).FormatTime(t, style))
+	})
+}
+
+// The locale of the view being rendered.
+func viewLocale(a jet.Arguments, bundle *i18n.Bundle) string {
+	if locale, ok := stringArgument(a.Runtime().Resolve("locale")); ok && locale != "" {
+		return locale
+	}
+	return bundle.Fallback()
+}
+
+func argumentValue(v reflect.Value) interface{} {
+	for v.IsValid() && v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+func stringArgument(v reflect.Value) (string, bool) {
+	s, ok := argumentValue(v).(string)
+	return s, ok
+}
+
+func numberArgument(v reflect.Value) (float64, bool) {
+	for v.IsValid() && v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch {
+	case !v.IsValid():
+		return 0, false
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		return float64(v.Int()), true
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr:
+		return float64(v.Uint()), true
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,42 @@
+package views
+
+import (
+	"myapp/i18n"
+	"testing"
+	"time"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+func TestTranslate(t *testing.T) {
+	bundle := i18n.NewBundle("en")
+	bundle.Add("en", map[string]interface{}{
+		"items": map[string]interface{}{"one": "{count} item", "other": "{count} items"},
+	})
+	bundle.Add("fr", map[string]interface{}{
+		"_formats": map[string]interface{}{"date": "02/01/2006", "decimal": ","},
+		"items":    map[string]interface{}{"one": "{count} article", "other": "{count} articles"},
+	})
+
+	set := newTestSet(t, map[string]string{
+		"page.jet": `{{ t("items", "count", n) }}|{{ formatNumber(2.5, 2) }}|{{ formatDate(day) }}`,
+	})
+	Translate(set, bundle)
+
+	day := time.Date(2026, time.March, 7, 0, 0, 0, 0, time.UTC)
+	for locale, want := range map[string]string{
+		"":   "3 items|2.50|03/07/2026",
+		"fr": "3 articles|2,50|07/03/2026",
+	} {
+		vars := jet.VarMap{}
+		vars.Set("n", 3)
+		vars.Set("day", day)
+		if locale != "" {
+			vars.Set("locale", locale)
+		}
+		got, err := render(t, set, "page.jet", vars)
+		if err != nil || got != want {
+			t.Errorf("locale %q: got %q, %v, want %q", locale, got, err, want)
+		}
+	}
+}